@@ -15,14 +15,41 @@
 package config
 
 import (
+	"fmt"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
 	exp "github.com/coreos/ignition/v2/config/v3_4_experimental"
 	types_exp "github.com/coreos/ignition/v2/config/v3_4_experimental/types"
 
+	"github.com/coreos/go-semver/semver"
 	"github.com/coreos/vcontext/report"
 )
 
+// ErrUnsupportedVersion is returned by Parse when the config's version is newer
+// than the latest version this build of Ignition supports.
+type ErrUnsupportedVersion struct {
+	Requested semver.Version
+	Max       semver.Version
+}
+
+func (e ErrUnsupportedVersion) Error() string {
+	return fmt.Sprintf("%v: config version %s is newer than the maximum supported version %s", errors.ErrUnknownVersion, e.Requested, e.Max)
+}
+
+// Unwrap allows callers to keep matching errors.ErrUnknownVersion.
+func (e ErrUnsupportedVersion) Unwrap() error {
+	return errors.ErrUnknownVersion
+}
+
 // Parse parses a config of any supported version and returns the equivalent config at the latest
 // supported version.
 func Parse(raw []byte) (types_exp.Config, report.Report, error) {
-	return exp.ParseCompatibleVersion(raw)
+	cfg, rpt, err := exp.ParseCompatibleVersion(raw)
+	if err == errors.ErrUnknownVersion {
+		if version, _, verr := util.GetConfigVersion(raw); verr == nil && types_exp.MaxVersion.LessThan(version) {
+			return cfg, rpt, ErrUnsupportedVersion{Requested: version, Max: types_exp.MaxVersion}
+		}
+	}
+	return cfg, rpt, err
 }
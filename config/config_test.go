@@ -19,12 +19,15 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/coreos/ignition/v2/config/shared/errors"
 	"github.com/coreos/ignition/v2/config/util"
 	v3_0 "github.com/coreos/ignition/v2/config/v3_0/types"
 	v3_1 "github.com/coreos/ignition/v2/config/v3_1/types"
 	v3_2 "github.com/coreos/ignition/v2/config/v3_2/types"
 	v3_3 "github.com/coreos/ignition/v2/config/v3_3/types"
 	v3_4 "github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+
+	"github.com/coreos/go-semver/semver"
 )
 
 type typeSet map[reflect.Type]struct{}
@@ -283,3 +286,159 @@ func TestConfigStructure(t *testing.T) {
 		}
 	}
 }
+
+func TestParseUnsupportedVersion(t *testing.T) {
+	tests := []struct {
+		in  string
+		out error
+	}{
+		// too-new minor
+		{
+			in: `{"ignition": {"version": "3.5.0"}}`,
+			out: ErrUnsupportedVersion{
+				Requested: *semver.New("3.5.0"),
+				Max:       v3_4.MaxVersion,
+			},
+		},
+		// too-new major
+		{
+			in: `{"ignition": {"version": "4.0.0"}}`,
+			out: ErrUnsupportedVersion{
+				Requested: *semver.New("4.0.0"),
+				Max:       v3_4.MaxVersion,
+			},
+		},
+		// old and unsupported
+		{
+			in:  `{"ignition": {"version": "2.3.0"}}`,
+			out: errors.ErrUnknownVersion,
+		},
+		// supported
+		{
+			in:  `{"ignition": {"version": "3.3.0"}}`,
+			out: nil,
+		},
+	}
+
+	for i, test := range tests {
+		_, _, err := Parse([]byte(test.in))
+		if !reflect.DeepEqual(test.out, err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out, err)
+		}
+	}
+
+	err := ErrUnsupportedVersion{Requested: *semver.New("3.5.0"), Max: v3_4.MaxVersion}
+	expected := "unsupported config version: config version 3.5.0 is newer than the maximum supported version 3.4.0-experimental"
+	if err.Error() != expected {
+		t.Errorf("bad message: want %q, got %q", expected, err.Error())
+	}
+	if err.Unwrap() != errors.ErrUnknownVersion {
+		t.Errorf("bad unwrapped error: want %v, got %v", errors.ErrUnknownVersion, err.Unwrap())
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	in := `
+ignition:
+  version: 3.4.0-experimental
+storage:
+  files:
+    - path: /etc/hostname
+      mode: 420
+      overwrite: true
+      contents:
+        source: "data:,example"
+systemd:
+  units:
+    - name: example.service
+      enabled: false
+`
+	expected := v3_4.Config{
+		Ignition: v3_4.Ignition{Version: "3.4.0-experimental"},
+		Storage: v3_4.Storage{
+			Files: []v3_4.File{
+				{
+					Node: v3_4.Node{
+						Path:      "/etc/hostname",
+						Overwrite: util.BoolToPtr(true),
+					},
+					FileEmbedded1: v3_4.FileEmbedded1{
+						Mode: util.IntToPtr(420),
+						Contents: v3_4.Resource{
+							Source: util.StrToPtr("data:,example"),
+						},
+					},
+				},
+			},
+		},
+		Systemd: v3_4.Systemd{
+			Units: []v3_4.Unit{
+				{
+					Name:    "example.service",
+					Enabled: util.BoolToPtr(false),
+				},
+			},
+		},
+	}
+
+	raw, err := util.YAMLToJSON([]byte(in))
+	if err != nil {
+		t.Fatalf("converting YAML: %v", err)
+	}
+	cfg, rpt, err := Parse(raw)
+	if err != nil {
+		t.Fatalf("parsing converted YAML: %v: %v", err, rpt)
+	}
+	if !reflect.DeepEqual(expected, cfg) {
+		t.Errorf("bad config: want %+v, got %+v", expected, cfg)
+	}
+}
+
+func TestParseRelaxed(t *testing.T) {
+	in := `{
+  // the version must still be set
+  "ignition": {"version": "3.4.0-experimental"},
+  "storage": {
+    "files": [
+      {
+        "path": "/etc/hostname", /* no mode */
+        "contents": {"source": "data:,example"},
+      },
+    ],
+  },
+}`
+	expected := v3_4.Config{
+		Ignition: v3_4.Ignition{Version: "3.4.0-experimental"},
+		Storage: v3_4.Storage{
+			Files: []v3_4.File{
+				{
+					Node: v3_4.Node{
+						Path: "/etc/hostname",
+					},
+					FileEmbedded1: v3_4.FileEmbedded1{
+						Contents: v3_4.Resource{
+							Source: util.StrToPtr("data:,example"),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// strict parsing remains the default
+	if _, _, err := Parse([]byte(in)); err != errors.ErrInvalid {
+		t.Errorf("bad strict error: want %v, got %v", errors.ErrInvalid, err)
+	}
+
+	raw, err := util.RelaxedJSONToJSON([]byte(in))
+	if err != nil {
+		t.Fatalf("converting relaxed config: %v", err)
+	}
+	cfg, rpt, err := Parse(raw)
+	if err != nil {
+		t.Fatalf("parsing converted config: %v: %v", err, rpt)
+	}
+	if !reflect.DeepEqual(expected, cfg) {
+		t.Errorf("bad config: want %+v, got %+v", expected, cfg)
+	}
+}
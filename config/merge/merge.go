@@ -38,6 +38,7 @@ import (
 //      - merge entries with the same Key() that are in the same list
 //      - remove entries from the parent with the same Key() that are not in the same list
 //      - append entries that are unique to the child
+//   g) List merging of a list with ReplacedLists: the child's list replaces the parent's if it is non-empty (e.g. ignition.storage.files[i].platforms)
 
 const (
 	TAG_PARENT = "parent"
@@ -105,6 +106,9 @@ type structInfo struct {
 	// checking done across all fields that share that handle
 	mergedKeys map[string]string
 
+	// set of field names whose lists are replaced by the child's rather than merged
+	replacedLists map[string]struct{}
+
 	// map from each handle + key() to the corresponding item
 	keysToValues map[handleKey]reflect.Value
 
@@ -121,6 +125,12 @@ func (s structInfo) ignoreField(name string) bool {
 	return ignore
 }
 
+// returns if this field's list should be replaced by the child's rather than merged
+func (s structInfo) replaceField(name string) bool {
+	_, replace := s.replacedLists[name]
+	return replace
+}
+
 // getChildEntryByKey takes the name of a field (not handle) in the parent and a key and looks that entry
 // up in the child. It will look up across all slices that share the same handle. It returns the value,
 // name of the field in the child it was found in, and the list index within that field. The bool indicates
@@ -152,6 +162,11 @@ func newStructInfo(parent, child reflect.Value) structInfo {
 		mergedKeys = merger.MergedKeys()
 	}
 
+	replacedLists := map[string]struct{}{}
+	if replacer, ok := parent.Interface().(util.ReplacesLists); ok {
+		replacedLists = replacer.ReplacedLists()
+	}
+
 	keysToValues := map[handleKey]reflect.Value{}
 	keysToLists := map[handleKey]string{}
 	keysToListIndexes := map[handleKey]int{}
@@ -165,6 +180,9 @@ func newStructInfo(parent, child reflect.Value) structInfo {
 		if _, ok := ignoreDups[fieldName]; ok {
 			continue
 		}
+		if _, ok := replacedLists[fieldName]; ok {
+			continue
+		}
 
 		handle := fieldName
 		if tmp, ok := mergedKeys[handle]; ok {
@@ -186,6 +204,7 @@ func newStructInfo(parent, child reflect.Value) structInfo {
 	return structInfo{
 		ignoreDups:        ignoreDups,
 		mergedKeys:        mergedKeys,
+		replacedLists:     replacedLists,
 		keysToValues:      keysToValues,
 		keysToLists:       keysToLists,
 		keysToListIndexes: keysToListIndexes,
@@ -258,6 +277,12 @@ func mergeStruct(parent reflect.Value, parentPath path.ContextPath, child reflec
 				transcribeOne(parentFieldPath, resultFieldPath, transcript)
 				transcribeOne(childFieldPath, resultFieldPath, transcript)
 			}
+		case kind == reflect.Slice && info.replaceField(fieldMeta.Name) && childField.Len() > 0:
+			resultField.Set(childField)
+			transcribe(childFieldPath, resultFieldPath, resultField, fieldMeta, transcript)
+		case kind == reflect.Slice && info.replaceField(fieldMeta.Name):
+			resultField.Set(parentField)
+			transcribe(parentFieldPath, resultFieldPath, resultField, fieldMeta, transcript)
 		case kind == reflect.Slice && info.ignoreField(fieldMeta.Name):
 			if parentField.Len()+childField.Len() == 0 {
 				continue
@@ -1338,6 +1338,184 @@ func TestMerge(t *testing.T) {
 				{path.New(TAG_CHILD, "kernelArguments"), path.New(TAG_RESULT, "kernelArguments")},
 			}},
 		},
+		{
+			// merge units and their dropins by name, child wins
+			in1: types.Config{
+				Systemd: types.Systemd{
+					Units: []types.Unit{
+						{
+							Name:     "a.service",
+							Contents: util.StrToPtr("parent"),
+							Enabled:  util.BoolToPtr(true),
+							Dropins: []types.Dropin{
+								{
+									Name:     "10-foo.conf",
+									Contents: util.StrToPtr("parent"),
+								},
+								{
+									Name:     "20-bar.conf",
+									Contents: util.StrToPtr("parent"),
+								},
+							},
+						},
+						{
+							Name: "b.service",
+							Mask: util.BoolToPtr(true),
+						},
+					},
+				},
+			},
+			in2: types.Config{
+				Systemd: types.Systemd{
+					Units: []types.Unit{
+						{
+							Name:     "a.service",
+							Contents: util.StrToPtr("child"),
+							Dropins: []types.Dropin{
+								{
+									Name:     "10-foo.conf",
+									Contents: util.StrToPtr("child"),
+								},
+							},
+						},
+					},
+				},
+			},
+			out: types.Config{
+				Systemd: types.Systemd{
+					Units: []types.Unit{
+						{
+							Name:     "a.service",
+							Contents: util.StrToPtr("child"),
+							Enabled:  util.BoolToPtr(true),
+							Dropins: []types.Dropin{
+								{
+									Name:     "10-foo.conf",
+									Contents: util.StrToPtr("child"),
+								},
+								{
+									Name:     "20-bar.conf",
+									Contents: util.StrToPtr("parent"),
+								},
+							},
+						},
+						{
+							Name: "b.service",
+							Mask: util.BoolToPtr(true),
+						},
+					},
+				},
+			},
+			transcript: Transcript{[]Mapping{
+				{path.New(TAG_CHILD, "systemd", "units", 0, "contents"), path.New(TAG_RESULT, "systemd", "units", 0, "contents")},
+				{path.New(TAG_CHILD, "systemd", "units", 0, "dropins", 0, "contents"), path.New(TAG_RESULT, "systemd", "units", 0, "dropins", 0, "contents")},
+				{path.New(TAG_CHILD, "systemd", "units", 0, "dropins", 0, "name"), path.New(TAG_RESULT, "systemd", "units", 0, "dropins", 0, "name")},
+				{path.New(TAG_PARENT, "systemd", "units", 0, "dropins", 0), path.New(TAG_RESULT, "systemd", "units", 0, "dropins", 0)},
+				{path.New(TAG_CHILD, "systemd", "units", 0, "dropins", 0), path.New(TAG_RESULT, "systemd", "units", 0, "dropins", 0)},
+				{path.New(TAG_PARENT, "systemd", "units", 0, "dropins", 1, "contents"), path.New(TAG_RESULT, "systemd", "units", 0, "dropins", 1, "contents")},
+				{path.New(TAG_PARENT, "systemd", "units", 0, "dropins", 1, "name"), path.New(TAG_RESULT, "systemd", "units", 0, "dropins", 1, "name")},
+				{path.New(TAG_PARENT, "systemd", "units", 0, "dropins", 1), path.New(TAG_RESULT, "systemd", "units", 0, "dropins", 1)},
+				{path.New(TAG_PARENT, "systemd", "units", 0, "dropins"), path.New(TAG_RESULT, "systemd", "units", 0, "dropins")},
+				{path.New(TAG_CHILD, "systemd", "units", 0, "dropins"), path.New(TAG_RESULT, "systemd", "units", 0, "dropins")},
+				{path.New(TAG_PARENT, "systemd", "units", 0, "enabled"), path.New(TAG_RESULT, "systemd", "units", 0, "enabled")},
+				{path.New(TAG_CHILD, "systemd", "units", 0, "name"), path.New(TAG_RESULT, "systemd", "units", 0, "name")},
+				{path.New(TAG_PARENT, "systemd", "units", 0), path.New(TAG_RESULT, "systemd", "units", 0)},
+				{path.New(TAG_CHILD, "systemd", "units", 0), path.New(TAG_RESULT, "systemd", "units", 0)},
+				{path.New(TAG_PARENT, "systemd", "units", 1, "mask"), path.New(TAG_RESULT, "systemd", "units", 1, "mask")},
+				{path.New(TAG_PARENT, "systemd", "units", 1, "name"), path.New(TAG_RESULT, "systemd", "units", 1, "name")},
+				{path.New(TAG_PARENT, "systemd", "units", 1), path.New(TAG_RESULT, "systemd", "units", 1)},
+				{path.New(TAG_PARENT, "systemd", "units"), path.New(TAG_RESULT, "systemd", "units")},
+				{path.New(TAG_CHILD, "systemd", "units"), path.New(TAG_RESULT, "systemd", "units")},
+				{path.New(TAG_PARENT, "systemd"), path.New(TAG_RESULT, "systemd")},
+				{path.New(TAG_CHILD, "systemd"), path.New(TAG_RESULT, "systemd")},
+			}},
+		},
+		{
+			// a child's platforms replace the parent's, and
+			// omitting them keeps the parent's
+			in1: types.Config{
+				Storage: types.Storage{
+					Files: []types.File{
+						{
+							Node: types.Node{
+								Path:      "/foo",
+								Platforms: []string{"aws", "gcp"},
+							},
+						},
+					},
+				},
+				Systemd: types.Systemd{
+					Units: []types.Unit{
+						{
+							Name:      "a.service",
+							Platforms: []string{"aws"},
+						},
+					},
+				},
+			},
+			in2: types.Config{
+				Storage: types.Storage{
+					Files: []types.File{
+						{
+							Node: types.Node{
+								Path:      "/foo",
+								Platforms: []string{"azure"},
+							},
+						},
+					},
+				},
+				Systemd: types.Systemd{
+					Units: []types.Unit{
+						{
+							Name: "a.service",
+							Mask: util.BoolToPtr(true),
+						},
+					},
+				},
+			},
+			out: types.Config{
+				Storage: types.Storage{
+					Files: []types.File{
+						{
+							Node: types.Node{
+								Path:      "/foo",
+								Platforms: []string{"azure"},
+							},
+						},
+					},
+				},
+				Systemd: types.Systemd{
+					Units: []types.Unit{
+						{
+							Name:      "a.service",
+							Mask:      util.BoolToPtr(true),
+							Platforms: []string{"aws"},
+						},
+					},
+				},
+			},
+			transcript: Transcript{[]Mapping{
+				{path.New(TAG_CHILD, "storage", "files", 0, "path"), path.New(TAG_RESULT, "storage", "files", 0, "path")},
+				{path.New(TAG_CHILD, "storage", "files", 0, "platforms", 0), path.New(TAG_RESULT, "storage", "files", 0, "platforms", 0)},
+				{path.New(TAG_CHILD, "storage", "files", 0, "platforms"), path.New(TAG_RESULT, "storage", "files", 0, "platforms")},
+				{path.New(TAG_PARENT, "storage", "files", 0), path.New(TAG_RESULT, "storage", "files", 0)},
+				{path.New(TAG_CHILD, "storage", "files", 0), path.New(TAG_RESULT, "storage", "files", 0)},
+				{path.New(TAG_PARENT, "storage", "files"), path.New(TAG_RESULT, "storage", "files")},
+				{path.New(TAG_CHILD, "storage", "files"), path.New(TAG_RESULT, "storage", "files")},
+				{path.New(TAG_PARENT, "storage"), path.New(TAG_RESULT, "storage")},
+				{path.New(TAG_CHILD, "storage"), path.New(TAG_RESULT, "storage")},
+				{path.New(TAG_CHILD, "systemd", "units", 0, "mask"), path.New(TAG_RESULT, "systemd", "units", 0, "mask")},
+				{path.New(TAG_CHILD, "systemd", "units", 0, "name"), path.New(TAG_RESULT, "systemd", "units", 0, "name")},
+				{path.New(TAG_PARENT, "systemd", "units", 0, "platforms", 0), path.New(TAG_RESULT, "systemd", "units", 0, "platforms", 0)},
+				{path.New(TAG_PARENT, "systemd", "units", 0, "platforms"), path.New(TAG_RESULT, "systemd", "units", 0, "platforms")},
+				{path.New(TAG_PARENT, "systemd", "units", 0), path.New(TAG_RESULT, "systemd", "units", 0)},
+				{path.New(TAG_CHILD, "systemd", "units", 0), path.New(TAG_RESULT, "systemd", "units", 0)},
+				{path.New(TAG_PARENT, "systemd", "units"), path.New(TAG_RESULT, "systemd", "units")},
+				{path.New(TAG_CHILD, "systemd", "units"), path.New(TAG_RESULT, "systemd", "units")},
+				{path.New(TAG_PARENT, "systemd"), path.New(TAG_RESULT, "systemd")},
+				{path.New(TAG_CHILD, "systemd"), path.New(TAG_RESULT, "systemd")},
+			}},
+		},
 	}
 
 	for i, test := range tests {
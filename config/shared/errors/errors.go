@@ -26,12 +26,24 @@ var (
 	ErrEmpty     = errors.New("not a config (empty)")
 	ErrDuplicate = errors.New("duplicate entry defined")
 
+	// YAML conversion errors
+	ErrYAMLMultipleDocuments = errors.New("YAML configs must contain a single document")
+	ErrYAMLKey               = errors.New("YAML mapping keys must be strings")
+	ErrYAMLAlias             = errors.New("YAML aliases and merge keys must refer to a mapping")
+	ErrYAMLTag               = errors.New("unsupported YAML tag")
+	ErrYAMLAliasExpansion    = errors.New("YAML aliases expand to too many values")
+
+	// Relaxed JSON conversion errors
+	ErrUnterminatedComment = errors.New("unterminated comment")
+
 	// Ignition section errors
 	ErrInvalidVersion = errors.New("invalid config version (couldn't parse)")
 	ErrUnknownVersion = errors.New("unsupported config version")
 
 	ErrDeprecated         = errors.New("config format deprecated")
 	ErrCompressionInvalid = errors.New("invalid compression method")
+	ErrNegativeTimeout    = errors.New("timeouts cannot be negative")
+	ErrNegativeAttempts   = errors.New("maximum attempts cannot be negative")
 
 	// Storage section errors
 	ErrFileUsedSymlink           = errors.New("file path includes link in config")
@@ -43,6 +55,7 @@ var (
 	ErrPartitionNumbersCollide   = errors.New("partition numbers collide")
 	ErrPartitionsOverlap         = errors.New("partitions overlap")
 	ErrPartitionsMisaligned      = errors.New("partitions misaligned")
+	ErrPartitionAttributeRange   = errors.New("partition attribute must be a GPT attribute bit between 0 and 63")
 	ErrOverwriteAndNilSource     = errors.New("overwrite must be false if source is unspecified")
 	ErrVerificationAndNilSource  = errors.New("source must be specified if verification is specified")
 	ErrFilesystemInvalidFormat   = errors.New("invalid filesystem format")
@@ -53,6 +66,24 @@ var (
 	ErrXfsLabelTooLong           = errors.New("filesystem labels cannot be longer than 12 characters when using xfs")
 	ErrSwapLabelTooLong          = errors.New("filesystem labels cannot be longer than 15 characters when using swap")
 	ErrVfatLabelTooLong          = errors.New("filesystem labels cannot be longer than 11 characters when using vfat")
+	ErrFilesystemUUIDInvalid     = errors.New("filesystem uuid must be of the form \"01234567-89ab-cdef-edcb-a98765432101\"")
+	ErrVfatUUIDInvalid           = errors.New("filesystem uuid must be a volume ID of the form \"0123-4567\" when using vfat")
+	ErrVfatLabelInvalidChars     = errors.New("filesystem labels cannot contain any of \"*+,./:;<=>?[\\]| when using vfat")
+	ErrFilesystemOptionsUUID     = errors.New("options cannot set the filesystem uuid when uuid is specified")
+	ErrFilesystemOptionsLabel    = errors.New("options cannot set the filesystem label when label is specified")
+	ErrMountOptionsWithSwap      = errors.New("mountOptions cannot be specified for swap filesystems")
+	ErrMountOptionContainsComma  = errors.New("mount option contains a comma and will be passed to mount as multiple options")
+	ErrMountUnitNoFormat         = errors.New("format is required if withMountUnit is true")
+	ErrMountUnitNoPath           = errors.New("path is required if withMountUnit is true and format is not swap")
+	ErrResizeUnsupportedFormat   = errors.New("resize is only supported for ext4, xfs, and btrfs filesystems")
+	ErrFsckUnsupportedFormat     = errors.New("fsck is only supported for ext4 and vfat filesystems")
+	ErrFsckNoPath                = errors.New("path is required if fsck is true")
+	ErrSubvolumesNeedBtrfs       = errors.New("subvolumes can only be specified for btrfs filesystems")
+	ErrSubvolumePathInvalid      = errors.New("subvolume paths must be relative, normalized, and not contain \"..\"")
+	ErrLoopDeviceInvalid         = errors.New("loop device must be of the form /dev/loopN")
+	ErrLoopSizeInvalid           = errors.New("loop backing file size must be positive")
+	ErrLoopFileNotInitramfs      = errors.New("files backing loop devices must target the initramfs")
+	ErrLoopSizeWithDeclaredFile  = errors.New("sizeMiB cannot be used with a backing file from storage.files")
 	ErrLuksLabelTooLong          = errors.New("luks device labels cannot be longer than 47 characters")
 	ErrLuksNameContainsSlash     = errors.New("device names cannot contain slashes")
 	ErrInvalidLuksKeyFile        = errors.New("invalid key-file source")
@@ -61,18 +92,43 @@ var (
 	ErrClevisConfigRequired      = errors.New("missing required custom clevis config")
 	ErrClevisCustomWithOthers    = errors.New("cannot use custom clevis config with tpm2, tang, or threshold")
 	ErrTangThumbprintRequired    = errors.New("thumbprint is required")
+	ErrClevisNoPins              = errors.New("clevis threshold is set but no tpm2 or tang pins are configured")
+	ErrClevisThresholdTooHigh    = errors.New("clevis threshold is greater than the number of configured pins")
 	ErrFileIllegalMode           = errors.New("illegal file mode")
 	ErrBothIDAndNameSet          = errors.New("cannot set both id and name")
+	ErrSelinuxContextInvalid     = errors.New("SELinux context must be of the form user:role:type[:level]")
+	ErrFileFlagInvalid           = errors.New("file flag must be \"immutable\" or \"append-only\"")
+	ErrXattrNameRequired         = errors.New("extended attribute name is required")
+	ErrXattrNamespace            = errors.New("extended attribute name must start with security., system., trusted., or user.")
+	ErrXattrSelinuxConflict      = errors.New("cannot set the security.selinux extended attribute and selinuxContext together")
+	ErrXattrValueNotDataURL      = errors.New("extended attribute value must be a data URL")
+	ErrTemplateNotInline         = errors.New("templated file contents must be specified with a data URL")
+	ErrFileTargetInvalid         = errors.New("file target must be \"sysroot\" or \"initramfs\"")
+	ErrInitramfsFileOwnerName    = errors.New("owners of files targeting the initramfs must be specified by ID, since names are looked up in the sysroot")
+	ErrInitramfsFileOnFilesystem = errors.New("files targeting the initramfs cannot be on a filesystem mounted in the sysroot")
 	ErrLabelTooLong              = errors.New("partition labels may not exceed 36 characters")
 	ErrDoesntMatchGUIDRegex      = errors.New("doesn't match the form \"01234567-89AB-CDEF-EDCB-A98765432101\"")
 	ErrLabelContainsColon        = errors.New("partition label will be truncated to text before the colon")
 	ErrNoPath                    = errors.New("path not specified")
 	ErrPathRelative              = errors.New("path not absolute")
 	ErrDirtyPath                 = errors.New("path is not fully simplified")
+	ErrMustExistWithShouldExist  = errors.New("mustExist cannot be true if shouldExist is false")
+	ErrRemovedGroupConflict      = errors.New("removeFromGroups cannot contain the primary group or a group in groups")
+	ErrSkeletonNoCreateHome      = errors.New("skeletonDir and noCopySkeleton cannot be set if noCreateHome is true")
+	ErrSkeletonDirAndNoCopy      = errors.New("skeletonDir cannot be set if noCopySkeleton is true")
+	ErrPasswordHashPlaintext     = errors.New("password hash is not in crypt(3) format; plaintext passwords are not supported")
+	ErrPasswordHashWeak          = errors.New("password hash uses a weak or unsupported scheme")
+	ErrLockedForcePasswordChange = errors.New("forcePasswordChange cannot be true if locked is true")
+	ErrPasswordMaxDaysInvalid    = errors.New("passwordMaxDays must be -1 or greater")
+	ErrMachineIDInvalid          = errors.New("machine ID must be 32 lowercase hexadecimal characters")
+	ErrMachineIDClearAndValue    = errors.New("machine ID value cannot be set if clear is true")
+	ErrHostnameInvalid           = errors.New("hostname must be a valid RFC 1123 hostname of at most 64 characters")
+	ErrHostnameRequired          = errors.New("hostname name is required if updateHosts is true")
 	ErrRaidLevelRequired         = errors.New("raid level is required")
 	ErrSparesUnsupportedForLevel = errors.New("spares unsupported for linear and raid0 arrays")
 	ErrUnrecognizedRaidLevel     = errors.New("unrecognized raid level")
 	ErrRaidDevicesRequired       = errors.New("raid devices required")
+	ErrRaidOptionConflict        = errors.New("raid option conflicts with the level, devices, or spares fields")
 	ErrShouldNotExistWithOthers  = errors.New("shouldExist specified false with other options also specified")
 	ErrZeroesWithShouldNotExist  = errors.New("shouldExist is false for a partition and other partition(s) has start or size 0")
 	ErrNeedLabelOrNumber         = errors.New("a partition number >= 1 or a label must be specified")
@@ -85,11 +141,25 @@ var (
 	ErrInvalidSystemdDropinExt = errors.New("invalid systemd drop-in extension")
 	ErrNoSystemdExt            = errors.New("no systemd unit extension")
 	ErrInvalidInstantiatedUnit = errors.New("invalid systemd instantiated unit")
+	ErrInstallTargetNotTarget  = errors.New("wantedBy and requiredBy entries must be .target units")
+	ErrInstallTargetsIgnored   = errors.New("unit has an install section, so wantedBy and requiredBy are ignored")
+
+	// Platform errors
+	ErrUnknownPlatform = errors.New("unknown platform")
+
+	// Kernel argument errors
+	ErrKernelArgumentEmpty      = errors.New("kernel argument cannot be empty")
+	ErrKernelArgumentWhitespace = errors.New("kernel argument cannot contain whitespace; use a separate entry for each argument")
+
+	// Lint warnings
+	ErrFileModeZero     = errors.New("file mode is 0, so the file is only accessible by root; set a mode or omit it to use the default")
+	ErrFilesystemUnused = errors.New("filesystem is created but no files, directories, or links are written to it")
 
 	// Misc errors
 	ErrSourceRequired                  = errors.New("source is required")
 	ErrInvalidScheme                   = errors.New("invalid url scheme")
 	ErrInvalidUrl                      = errors.New("unable to parse url")
+	ErrInvalidFileURL                  = errors.New("file urls must have an absolute path and no remote host")
 	ErrInvalidHTTPHeader               = errors.New("unable to parse HTTP header")
 	ErrEmptyHTTPHeaderName             = errors.New("HTTP header name can't be empty")
 	ErrUnsupportedSchemeForHTTPHeaders = errors.New("cannot use HTTP headers with this source scheme")
@@ -97,6 +167,10 @@ var (
 	ErrHashWrongSize                   = errors.New("incorrect size for hash sum")
 	ErrHashUnrecognized                = errors.New("unrecognized hash function")
 	ErrEngineConfiguration             = errors.New("engine incorrectly configured")
+	ErrConfigReferenceDepth            = errors.New("too many nested config references; is there a replace or merge loop?")
+
+	// Command errors
+	ErrCommandNameRequired = errors.New("command name is required")
 
 	// AWS S3 specific errors
 	ErrInvalidS3ObjectVersionId = errors.New("invalid S3 object VersionId")
@@ -22,22 +22,57 @@ import (
 
 type versionStub struct {
 	Ignition struct {
-		Version string
+		Version *string
 	}
 }
 
-// GetConfigVersion parses the version from the given raw config
+// v1Version is reported for configs without an ignition.version field.
+// Spec 1 configs declared a top-level ignitionVersion instead.
+var v1Version = semver.Version{Major: 1}
+
+// GetConfigVersion parses the version from the given raw config. Configs
+// which don't declare ignition.version return ErrInvalidVersion.
 func GetConfigVersion(raw []byte) (semver.Version, report.Report, error) {
+	stub, rpt, err := parseVersionStub(raw)
+	if err != nil {
+		return semver.Version{}, rpt, err
+	}
+	if stub.Ignition.Version == nil {
+		return semver.Version{}, report.Report{}, errors.ErrInvalidVersion
+	}
+	return parseVersion(*stub.Ignition.Version)
+}
+
+// DetectConfigVersion parses the version from the given raw config without
+// validating the rest of it, so tooling can pick the parser to use. Unlike
+// GetConfigVersion, configs which don't declare ignition.version are
+// reported as version 1.0.0; a version which can't be parsed returns
+// ErrInvalidVersion.
+func DetectConfigVersion(raw []byte) (semver.Version, report.Report, error) {
+	stub, rpt, err := parseVersionStub(raw)
+	if err != nil {
+		return semver.Version{}, rpt, err
+	}
+	if stub.Ignition.Version == nil {
+		return v1Version, report.Report{}, nil
+	}
+	return parseVersion(*stub.Ignition.Version)
+}
+
+func parseVersionStub(raw []byte) (versionStub, report.Report, error) {
 	if len(raw) == 0 {
-		return semver.Version{}, report.Report{}, errors.ErrEmpty
+		return versionStub{}, report.Report{}, errors.ErrEmpty
 	}
 
 	stub := versionStub{}
 	if rpt, err := HandleParseErrors(raw, &stub); err != nil {
-		return semver.Version{}, rpt, err
+		return versionStub{}, rpt, err
 	}
+	return stub, report.Report{}, nil
+}
 
-	version, err := semver.NewVersion(stub.Ignition.Version)
+func parseVersion(v string) (semver.Version, report.Report, error) {
+	version, err := semver.NewVersion(v)
 	if err != nil {
 		return semver.Version{}, report.Report{}, errors.ErrInvalidVersion
 	}
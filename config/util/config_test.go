@@ -0,0 +1,129 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"errors"
+	"testing"
+
+	shared "github.com/coreos/ignition/v2/config/shared/errors"
+
+	"github.com/coreos/go-semver/semver"
+)
+
+func TestDetectConfigVersion(t *testing.T) {
+	tests := []struct {
+		in  string
+		out semver.Version
+		err error
+	}{
+		{
+			in:  `{"ignition": {"version": "3.2.0"}}`,
+			out: semver.Version{Major: 3, Minor: 2},
+		},
+		{
+			in:  `{"ignition": {"version": "3.4.0-experimental"}}`,
+			out: semver.Version{Major: 3, Minor: 4, PreRelease: "experimental"},
+		},
+		// the rest of the config isn't validated
+		{
+			in:  `{"ignition": {"version": "3.0.0"}, "storage": {"files": "bogus"}, "unknown": 1}`,
+			out: semver.Version{Major: 3},
+		},
+		// missing version is treated as spec 1
+		{
+			in:  `{"ignitionVersion": 1}`,
+			out: semver.Version{Major: 1},
+		},
+		{
+			in:  `{}`,
+			out: semver.Version{Major: 1},
+		},
+		{
+			in:  `{"ignition": {}}`,
+			out: semver.Version{Major: 1},
+		},
+		// malformed versions
+		{
+			in:  `{"ignition": {"version": ""}}`,
+			err: shared.ErrInvalidVersion,
+		},
+		{
+			in:  `{"ignition": {"version": "invalid.semver"}}`,
+			err: shared.ErrInvalidVersion,
+		},
+		{
+			in:  `{"ignition": {"version": "3.2"}}`,
+			err: shared.ErrInvalidVersion,
+		},
+		// not a config
+		{
+			in:  `{"ignition": {"version": "3.2.0"},}`,
+			err: shared.ErrInvalid,
+		},
+		{
+			in:  ``,
+			err: shared.ErrEmpty,
+		},
+	}
+
+	for i, test := range tests {
+		out, _, err := DetectConfigVersion([]byte(test.in))
+		if !errors.Is(err, test.err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+			continue
+		}
+		if out != test.out {
+			t.Errorf("#%d: bad version: want %v, got %v", i, test.out, out)
+		}
+	}
+}
+
+func TestGetConfigVersion(t *testing.T) {
+	tests := []struct {
+		in  string
+		out semver.Version
+		err error
+	}{
+		{
+			in:  `{"ignition": {"version": "3.2.0"}}`,
+			out: semver.Version{Major: 3, Minor: 2},
+		},
+		// missing version is invalid
+		{
+			in:  `{"ignitionVersion": 1}`,
+			err: shared.ErrInvalidVersion,
+		},
+		{
+			in:  `{"ignition": {}}`,
+			err: shared.ErrInvalidVersion,
+		},
+		{
+			in:  `{"ignition": {"version": "invalid.semver"}}`,
+			err: shared.ErrInvalidVersion,
+		},
+	}
+
+	for i, test := range tests {
+		out, _, err := GetConfigVersion([]byte(test.in))
+		if !errors.Is(err, test.err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+			continue
+		}
+		if out != test.out {
+			t.Errorf("#%d: bad version: want %v, got %v", i, test.out, out)
+		}
+	}
+}
@@ -26,6 +26,10 @@ type IgnoresDups interface {
 	IgnoreDuplicates() map[string]struct{}
 }
 
+type ReplacesLists interface {
+	ReplacedLists() map[string]struct{}
+}
+
 type Keyed interface {
 	Key() string
 }
@@ -0,0 +1,80 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"errors"
+	"testing"
+
+	shared "github.com/coreos/ignition/v2/config/shared/errors"
+)
+
+func TestRelaxedJSONToJSON(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+		err error
+	}{
+		// strict JSON is unchanged
+		{
+			in:  `{"a": [1, 2], "b": {"c": "d"}}`,
+			out: `{"a": [1, 2], "b": {"c": "d"}}`,
+		},
+		// line comments
+		{
+			in:  "{\n  // comment\n  \"a\": 1 // trailing\n}",
+			out: "{\n            \n  \"a\": 1            \n}",
+		},
+		// block comments keep their line breaks
+		{
+			in:  "{/* a\nb */\"a\": 1}",
+			out: "{    \n    \"a\": 1}",
+		},
+		// trailing commas, including before comments
+		{
+			in:  `{"a": [1, 2,], "b": {"c": "d",},}`,
+			out: `{"a": [1, 2 ], "b": {"c": "d" } }`,
+		},
+		{
+			in:  "[1, // one\n]",
+			out: "[1        \n]",
+		},
+		// comment markers and commas in strings are left alone
+		{
+			in:  `{"a": "http://example.com/*", "b": ",}", "c": "\"//"}`,
+			out: `{"a": "http://example.com/*", "b": ",}", "c": "\"//"}`,
+		},
+		// doubled commas aren't relaxed
+		{
+			in:  `[1,,]`,
+			out: `[1, ]`,
+		},
+		{
+			in:  "{\n/* a\n",
+			err: shared.ErrUnterminatedComment,
+		},
+	}
+
+	for i, test := range tests {
+		out, err := RelaxedJSONToJSON([]byte(test.in))
+		if !errors.Is(err, test.err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+			continue
+		}
+		if string(out) != test.out {
+			t.Errorf("#%d: bad output: want %q, got %q", i, test.out, out)
+		}
+	}
+}
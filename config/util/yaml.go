@@ -0,0 +1,199 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxYAMLAliasValues limits the number of values produced by expanding
+// aliases and merge keys, since nested aliases can expand exponentially.
+const maxYAMLAliasValues = 100000
+
+// YAMLToJSON converts a YAML document into the equivalent JSON so it can be
+// parsed like any other config. Scalars keep the type YAML resolves them to,
+// so quoted numbers stay strings and unquoted ones stay numbers. Aliases and
+// merge keys are only supported when they refer to a mapping.
+func YAMLToJSON(raw []byte) ([]byte, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	var doc yaml.Node
+	if err := dec.Decode(&doc); err != nil {
+		if err == io.EOF {
+			return nil, errors.ErrEmpty
+		}
+		return nil, err
+	}
+	var extra yaml.Node
+	if err := dec.Decode(&extra); err != io.EOF {
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("line %d: %w", extra.Line, errors.ErrYAMLMultipleDocuments)
+	}
+	var c yamlConverter
+	v, err := c.value(&doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// yamlConverter converts YAML nodes to values which marshal to JSON,
+// counting the values produced by alias expansion.
+type yamlConverter struct {
+	// inAlias is the depth of alias expansion of the current node
+	inAlias int
+	// aliasValues is the number of values produced by alias expansion
+	aliasValues int
+}
+
+// alias calls convert on the mapping alias n refers to, counting the values
+// it produces against maxYAMLAliasValues.
+func (c *yamlConverter) alias(n *yaml.Node, convert func(*yaml.Node) error) error {
+	if n.Alias.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: %w", n.Line, errors.ErrYAMLAlias)
+	}
+	c.inAlias++
+	defer func() { c.inAlias-- }()
+	return convert(n.Alias)
+}
+
+func (c *yamlConverter) value(n *yaml.Node) (interface{}, error) {
+	if c.inAlias > 0 {
+		c.aliasValues++
+		if c.aliasValues > maxYAMLAliasValues {
+			return nil, fmt.Errorf("line %d: %w", n.Line, errors.ErrYAMLAliasExpansion)
+		}
+	}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, errors.ErrEmpty
+		}
+		return c.value(n.Content[0])
+	case yaml.AliasNode:
+		var v interface{}
+		err := c.alias(n, func(a *yaml.Node) (err error) {
+			v, err = c.value(a)
+			return
+		})
+		return v, err
+	case yaml.SequenceNode:
+		seq := make([]interface{}, 0, len(n.Content))
+		for _, child := range n.Content {
+			v, err := c.value(child)
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, v)
+		}
+		return seq, nil
+	case yaml.MappingNode:
+		m := map[string]interface{}{}
+		if err := c.mapping(n, m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	case yaml.ScalarNode:
+		return yamlScalar(n)
+	}
+	return nil, fmt.Errorf("line %d: unknown YAML node kind %d", n.Line, n.Kind)
+}
+
+// mapping adds the keys of mapping n to m. Keys set explicitly in n take
+// precedence over keys pulled in with a merge key, regardless of order.
+func (c *yamlConverter) mapping(n *yaml.Node, m map[string]interface{}) error {
+	var merges []*yaml.Node
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		if k.Kind == yaml.ScalarNode && k.ShortTag() == "!!merge" {
+			if v.Kind == yaml.SequenceNode {
+				merges = append(merges, v.Content...)
+			} else {
+				merges = append(merges, v)
+			}
+			continue
+		}
+		if k.Kind != yaml.ScalarNode || k.ShortTag() != "!!str" {
+			return fmt.Errorf("line %d: %w", k.Line, errors.ErrYAMLKey)
+		}
+		val, err := c.value(v)
+		if err != nil {
+			return err
+		}
+		m[k.Value] = val
+	}
+	for _, merge := range merges {
+		merged := map[string]interface{}{}
+		var err error
+		switch merge.Kind {
+		case yaml.AliasNode:
+			err = c.alias(merge, func(a *yaml.Node) error {
+				return c.mapping(a, merged)
+			})
+		case yaml.MappingNode:
+			err = c.mapping(merge, merged)
+		default:
+			err = fmt.Errorf("line %d: %w", merge.Line, errors.ErrYAMLAlias)
+		}
+		if err != nil {
+			return err
+		}
+		for k, v := range merged {
+			if _, ok := m[k]; !ok {
+				m[k] = v
+			}
+		}
+	}
+	return nil
+}
+
+func yamlScalar(n *yaml.Node) (interface{}, error) {
+	switch n.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool":
+		var b bool
+		err := n.Decode(&b)
+		return b, err
+	case "!!int":
+		// keep integers exact rather than rounding them through float64
+		var i int64
+		if err := n.Decode(&i); err == nil {
+			return json.Number(strconv.FormatInt(i, 10)), nil
+		}
+		var u uint64
+		if err := n.Decode(&u); err != nil {
+			return nil, err
+		}
+		return json.Number(strconv.FormatUint(u, 10)), nil
+	case "!!float":
+		var f float64
+		err := n.Decode(&f)
+		return f, err
+	case "!!str", "!!timestamp":
+		// ignition has no timestamp fields; keep the text as written
+		return n.Value, nil
+	}
+	return nil, fmt.Errorf("line %d: %w %q", n.Line, errors.ErrYAMLTag, n.Tag)
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	shared "github.com/coreos/ignition/v2/config/shared/errors"
+)
+
+func TestYAMLToJSON(t *testing.T) {
+	// each level refers to the previous one ten times, so the last
+	// expands to 10^9 values
+	var laughs strings.Builder
+	laughs.WriteString("l0: &l0 {a: lol}\n")
+	for i := 1; i <= 9; i++ {
+		fmt.Fprintf(&laughs, "l%d: &l%d {", i, i)
+		for j := 0; j < 10; j++ {
+			fmt.Fprintf(&laughs, "k%d: *l%d, ", j, i-1)
+		}
+		laughs.WriteString("}\n")
+	}
+	mergeLaughs := strings.Replace(laughs.String(), "k0: *l", "<<: *l", -1)
+
+	tests := []struct {
+		in  string
+		out string
+		err error
+	}{
+		// typing follows YAML resolution
+		{
+			in:  "a: 420\nb: \"420\"\nc: 1.5\nd: true\ne: \"true\"\nf: null\ng: 0o644\n",
+			out: `{"a":420,"b":"420","c":1.5,"d":true,"e":"true","f":null,"g":420}`,
+		},
+		// large integers aren't rounded through float64
+		{
+			in:  "a: 9007199254740993\n",
+			out: `{"a":9007199254740993}`,
+		},
+		{
+			in:  "a: 18446744073709551615\nb: -9223372036854775808\n",
+			out: `{"a":18446744073709551615,"b":-9223372036854775808}`,
+		},
+		// nested sequences and mappings
+		{
+			in:  "ignition:\n  version: 3.4.0-experimental\nstorage:\n  files:\n    - path: /a\n    - path: /b\n",
+			out: `{"ignition":{"version":"3.4.0-experimental"},"storage":{"files":[{"path":"/a"},{"path":"/b"}]}}`,
+		},
+		// aliases and merge keys of mappings
+		{
+			in:  "base: &base\n  mode: 420\n  overwrite: true\nfile:\n  <<: *base\n  mode: 384\n",
+			out: `{"base":{"mode":420,"overwrite":true},"file":{"mode":384,"overwrite":true}}`,
+		},
+		{
+			in:  "a: &a {x: 1}\nb: *a\n",
+			out: `{"a":{"x":1},"b":{"x":1}}`,
+		},
+		// JSON is valid YAML
+		{
+			in:  `{"ignition": {"version": "3.4.0-experimental"}}`,
+			out: `{"ignition":{"version":"3.4.0-experimental"}}`,
+		},
+		// aliases of scalars and sequences
+		{
+			in:  "a: &a 1\nb: *a\n",
+			err: shared.ErrYAMLAlias,
+		},
+		{
+			in:  "a: &a [1]\nb: *a\n",
+			err: shared.ErrYAMLAlias,
+		},
+		{
+			in:  "a: &a 1\nb:\n  <<: *a\n",
+			err: shared.ErrYAMLAlias,
+		},
+		// alias expansion is limited
+		{
+			in:  laughs.String(),
+			err: shared.ErrYAMLAliasExpansion,
+		},
+		{
+			in:  mergeLaughs,
+			err: shared.ErrYAMLAliasExpansion,
+		},
+		// non-string keys
+		{
+			in:  "1: a\n",
+			err: shared.ErrYAMLKey,
+		},
+		{
+			in:  "[a]: b\n",
+			err: shared.ErrYAMLKey,
+		},
+		// unsupported tags
+		{
+			in:  "a: !!binary aGVsbG8=\n",
+			err: shared.ErrYAMLTag,
+		},
+		{
+			in:  "a: !custom b\n",
+			err: shared.ErrYAMLTag,
+		},
+		// multiple documents
+		{
+			in:  "a: 1\n---\nb: 2\n",
+			err: shared.ErrYAMLMultipleDocuments,
+		},
+		{
+			in:  "",
+			err: shared.ErrEmpty,
+		},
+	}
+
+	for i, test := range tests {
+		out, err := YAMLToJSON([]byte(test.in))
+		if !errors.Is(err, test.err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+			continue
+		}
+		if string(out) != test.out {
+			t.Errorf("#%d: bad output: want %s, got %s", i, test.out, out)
+		}
+	}
+}
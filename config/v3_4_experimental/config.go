@@ -58,6 +58,14 @@ func Parse(rawConfig []byte) (types.Config, report.Report, error) {
 	return config, rpt, nil
 }
 
+// Validate validates the whole config and returns a report of every error
+// and warning found, each with the path to the offending field. Unlike Parse,
+// it doesn't stop at the first error. Use validate.Diagnostics to convert the
+// report into JSON pointers.
+func Validate(cfg types.Config) report.Report {
+	return validate.ValidateWithContext(cfg, nil)
+}
+
 // ParseCompatibleVersion parses the raw config of version 3.4.0-experimental or
 // lesser into a 3.4-exp types.Config struct and generates a report of any errors,
 // warnings, info, and deprecations it encountered
@@ -18,7 +18,9 @@ import (
 	"testing"
 
 	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
 	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/config/validate"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -208,3 +210,48 @@ func TestParse(t *testing.T) {
 		assert.Equal(t, test.out.config, config, "#%d: bad config, report: %+v", i, report)
 	}
 }
+
+func TestValidate(t *testing.T) {
+	cfg := types.Config{
+		Ignition: types.Ignition{Version: "3.4.0-experimental"},
+		Storage: types.Storage{
+			Files: []types.File{
+				{Node: types.Node{Path: "/etc/ok"}},
+				{Node: types.Node{Path: "relative"}},
+			},
+		},
+		Systemd: types.Systemd{
+			Units: []types.Unit{
+				{Name: "foo.bar"},
+				{
+					Name:     "example.service",
+					Contents: util.StrToPtr("[Service]\nExecStart=/bin/true\n"),
+					Enabled:  util.BoolToPtr(true),
+				},
+			},
+		},
+	}
+	want := []validate.Diagnostic{
+		{
+			Pointer:  "/storage/files/1/path",
+			Severity: "error",
+			Message:  errors.ErrPathRelative.Error(),
+		},
+		{
+			Pointer:  "/systemd/units/0/name",
+			Severity: "error",
+			Message:  errors.ErrInvalidSystemdExt.Error(),
+		},
+		{
+			Pointer:  "/systemd/units/1/contents",
+			Severity: "warning",
+			Message:  errors.NewNoInstallSectionError("example.service").Error(),
+		},
+	}
+
+	r := Validate(cfg)
+	if !r.IsFatal() {
+		t.Errorf("expected fatal report, got %v", r)
+	}
+	assert.Equal(t, want, validate.Diagnostics(r))
+}
@@ -0,0 +1,150 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3_4_experimental
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+
+	"github.com/coreos/go-systemd/v22/unit"
+	"github.com/coreos/vcontext/path"
+)
+
+// Warning is a lint finding: something which is valid, and so doesn't fail
+// provisioning, but is probably a mistake.
+type Warning struct {
+	Context path.ContextPath
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("warning at %s: %s", w.Context, w.Message)
+}
+
+// Lint checks cfg for likely mistakes. It assumes cfg is valid.
+func Lint(cfg types.Config) []Warning {
+	var ws []Warning
+	c := path.New("json")
+	ws = append(ws, lintFileModes(c, cfg)...)
+	ws = append(ws, lintUnusedFilesystems(c, cfg)...)
+	ws = append(ws, lintUnitInstallSections(c, cfg)...)
+	return ws
+}
+
+// lintFileModes warns about files explicitly created with mode 0, which are
+// only accessible by root.
+func lintFileModes(c path.ContextPath, cfg types.Config) (ws []Warning) {
+	for i, f := range cfg.Storage.Files {
+		if f.Mode != nil && *f.Mode == 0 {
+			ws = append(ws, Warning{
+				Context: c.Append("storage", "files", i, "mode"),
+				Message: errors.ErrFileModeZero.Error(),
+			})
+		}
+	}
+	return
+}
+
+// lintUnusedFilesystems warns about filesystems which are created but which
+// no file, directory, or link is written to. Each node is written to the
+// filesystem mounted deepest above it; filesystems without a path aren't
+// mounted, so nothing is written to them.
+func lintUnusedFilesystems(c path.ContextPath, cfg types.Config) (ws []Warning) {
+	var nodes []string
+	for _, f := range cfg.Storage.Files {
+		if !f.TargetsInitramfs() {
+			nodes = append(nodes, f.Path)
+		}
+	}
+	for _, d := range cfg.Storage.Directories {
+		nodes = append(nodes, d.Path)
+	}
+	for _, l := range cfg.Storage.Links {
+		nodes = append(nodes, l.Path)
+	}
+
+	used := map[int]bool{}
+	for _, node := range nodes {
+		best, bestLen := -1, -1
+		for i, fs := range cfg.Storage.Filesystems {
+			if util.NilOrEmpty(fs.Path) {
+				continue
+			}
+			mnt := strings.TrimSuffix(*fs.Path, "/")
+			if (node == mnt || strings.HasPrefix(node, mnt+"/")) && len(mnt) > bestLen {
+				best, bestLen = i, len(mnt)
+			}
+		}
+		if best >= 0 {
+			used[best] = true
+		}
+	}
+
+	for i, fs := range cfg.Storage.Filesystems {
+		if util.NilOrEmpty(fs.Format) || *fs.Format == "none" || *fs.Format == "swap" {
+			continue
+		}
+		if !used[i] {
+			ws = append(ws, Warning{
+				Context: c.Append("storage", "filesystems", i),
+				Message: errors.ErrFilesystemUnused.Error(),
+			})
+		}
+	}
+	return
+}
+
+// lintUnitInstallSections warns about enabled units which can't be enabled
+// because neither their contents nor their dropins have an [Install]
+// section and no wantedBy or requiredBy targets are given.
+func lintUnitInstallSections(c path.ContextPath, cfg types.Config) (ws []Warning) {
+	for i, u := range cfg.Systemd.Units {
+		if !util.IsTrue(u.Enabled) || util.NilOrEmpty(u.Contents) || len(u.WantedBy) > 0 || len(u.RequiredBy) > 0 {
+			continue
+		}
+		if hasInstallSection(u.Contents) {
+			continue
+		}
+		found := false
+		for _, d := range u.Dropins {
+			if hasInstallSection(d.Contents) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ws = append(ws, Warning{
+				Context: c.Append("systemd", "units", i, "contents"),
+				Message: errors.NewNoInstallSectionError(u.Name).Error(),
+			})
+		}
+	}
+	return
+}
+
+func hasInstallSection(contents *string) bool {
+	if util.NilOrEmpty(contents) {
+		return false
+	}
+	opts, err := unit.Deserialize(strings.NewReader(*contents))
+	if err != nil {
+		return false
+	}
+	return types.HasInstallSection(opts)
+}
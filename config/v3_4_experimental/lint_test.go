@@ -0,0 +1,174 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3_4_experimental
+
+import (
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		in  types.Config
+		out []Warning
+	}{
+		{
+			in:  types.Config{},
+			out: nil,
+		},
+		// file with mode 0
+		{
+			in: types.Config{
+				Storage: types.Storage{
+					Files: []types.File{
+						{
+							Node:          types.Node{Path: "/etc/default"},
+							FileEmbedded1: types.FileEmbedded1{},
+						},
+						{
+							Node:          types.Node{Path: "/etc/zero"},
+							FileEmbedded1: types.FileEmbedded1{Mode: util.IntToPtr(0)},
+						},
+					},
+				},
+			},
+			out: []Warning{
+				{
+					Context: path.New("json", "storage", "files", 1, "mode"),
+					Message: errors.ErrFileModeZero.Error(),
+				},
+			},
+		},
+		// filesystem without a path
+		{
+			in: types.Config{
+				Storage: types.Storage{
+					Filesystems: []types.Filesystem{
+						{Device: "/dev/vda1", Format: util.StrToPtr("ext4"), Path: util.StrToPtr("/var")},
+						{Device: "/dev/vda2", Format: util.StrToPtr("swap")},
+						{Device: "/dev/vda3", Format: util.StrToPtr("xfs")},
+					},
+					Files: []types.File{
+						{Node: types.Node{Path: "/var/lib/foo"}},
+					},
+				},
+			},
+			out: []Warning{
+				{
+					Context: path.New("json", "storage", "filesystems", 2),
+					Message: errors.ErrFilesystemUnused.Error(),
+				},
+			},
+		},
+		// mounted filesystems nothing is written to
+		{
+			in: types.Config{
+				Storage: types.Storage{
+					Filesystems: []types.Filesystem{
+						{Device: "/dev/vda1", Format: util.StrToPtr("xfs"), Path: util.StrToPtr("/srv")},
+						{Device: "/dev/vda2", Format: util.StrToPtr("xfs"), Path: util.StrToPtr("/var")},
+						{Device: "/dev/vda3", Format: util.StrToPtr("xfs"), Path: util.StrToPtr("/var/lib")},
+						{Device: "/dev/vda4", Format: util.StrToPtr("xfs"), Path: util.StrToPtr("/home")},
+						{Device: "/dev/vda5", Format: util.StrToPtr("xfs"), Path: util.StrToPtr("/opt")},
+						{Device: "/dev/vda6", Format: util.StrToPtr("xfs"), Path: util.StrToPtr("/data")},
+					},
+					Files: []types.File{
+						// on /var/lib, not /var
+						{Node: types.Node{Path: "/var/lib/foo"}},
+						// not on /srv
+						{Node: types.Node{Path: "/srvfoo"}},
+						// written to the initramfs
+						{
+							Node:          types.Node{Path: "/data/foo"},
+							FileEmbedded1: types.FileEmbedded1{Target: util.StrToPtr("initramfs")},
+						},
+					},
+					Directories: []types.Directory{
+						{Node: types.Node{Path: "/home/core"}},
+					},
+					Links: []types.Link{
+						{Node: types.Node{Path: "/opt/bin"}},
+					},
+				},
+			},
+			out: []Warning{
+				{
+					Context: path.New("json", "storage", "filesystems", 0),
+					Message: errors.ErrFilesystemUnused.Error(),
+				},
+				{
+					Context: path.New("json", "storage", "filesystems", 1),
+					Message: errors.ErrFilesystemUnused.Error(),
+				},
+				{
+					Context: path.New("json", "storage", "filesystems", 5),
+					Message: errors.ErrFilesystemUnused.Error(),
+				},
+			},
+		},
+		// enabled unit without an install section
+		{
+			in: types.Config{
+				Systemd: types.Systemd{
+					Units: []types.Unit{
+						{
+							Name:     "install.service",
+							Contents: util.StrToPtr("[Service]\nExecStart=/bin/true\n[Install]\nWantedBy=multi-user.target\n"),
+							Enabled:  util.BoolToPtr(true),
+						},
+						{
+							Name:     "wanted.service",
+							Contents: util.StrToPtr("[Service]\nExecStart=/bin/true\n"),
+							Enabled:  util.BoolToPtr(true),
+							WantedBy: []string{"multi-user.target"},
+						},
+						{
+							Name:     "dropin.service",
+							Contents: util.StrToPtr("[Service]\nExecStart=/bin/true\n"),
+							Enabled:  util.BoolToPtr(true),
+							Dropins: []types.Dropin{
+								{
+									Name:     "install.conf",
+									Contents: util.StrToPtr("[Install]\nWantedBy=multi-user.target\n"),
+								},
+							},
+						},
+						{
+							Name:     "missing.service",
+							Contents: util.StrToPtr("[Service]\nExecStart=/bin/true\n"),
+							Enabled:  util.BoolToPtr(true),
+						},
+					},
+				},
+			},
+			out: []Warning{
+				{
+					Context: path.New("json", "systemd", "units", 3, "contents"),
+					Message: errors.NewNoInstallSectionError("missing.service").Error(),
+				},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		assert.Equal(t, test.out, Lint(test.in), "#%d: bad warnings", i)
+	}
+}
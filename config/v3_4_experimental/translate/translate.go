@@ -22,14 +22,137 @@ import (
 
 func translateIgnition(old old_types.Ignition) (ret types.Ignition) {
 	// use a new translator so we don't recurse infinitely
-	translate.NewTranslator().Translate(&old, &ret)
+	tr := translate.NewTranslator()
+	tr.AddCustomTranslator(translateVerification)
+	tr.Translate(&old.Config, &ret.Config)
+	tr.Translate(&old.Proxy, &ret.Proxy)
+	tr.Translate(&old.Security, &ret.Security)
+	tr.Translate(&old.Timeouts.HTTPResponseHeaders, &ret.Timeouts.HTTPResponseHeaders)
+	tr.Translate(&old.Timeouts.HTTPTotal, &ret.Timeouts.HTTPTotal)
 	ret.Version = types.MaxVersion.String()
 	return
 }
 
+func translateDirectoryEmbedded1(old old_types.DirectoryEmbedded1) (ret types.DirectoryEmbedded1) {
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Mode, &ret.Mode)
+	return
+}
+
+func translateVerification(old old_types.Verification) (ret types.Verification) {
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Hash, &ret.Hash)
+	return
+}
+
+func translateFileEmbedded1(old old_types.FileEmbedded1) (ret types.FileEmbedded1) {
+	tr := translate.NewTranslator()
+	tr.AddCustomTranslator(translateVerification)
+	tr.Translate(&old.Append, &ret.Append)
+	tr.Translate(&old.Contents, &ret.Contents)
+	tr.Translate(&old.Mode, &ret.Mode)
+	return
+}
+
+func translateFilesystem(old old_types.Filesystem) (ret types.Filesystem) {
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Device, &ret.Device)
+	tr.Translate(&old.Format, &ret.Format)
+	tr.Translate(&old.Label, &ret.Label)
+	tr.Translate(&old.MountOptions, &ret.MountOptions)
+	tr.Translate(&old.Options, &ret.Options)
+	tr.Translate(&old.Path, &ret.Path)
+	tr.Translate(&old.UUID, &ret.UUID)
+	tr.Translate(&old.WipeFilesystem, &ret.WipeFilesystem)
+	return
+}
+
+func translateNode(old old_types.Node) (ret types.Node) {
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Group, &ret.Group)
+	tr.Translate(&old.Overwrite, &ret.Overwrite)
+	tr.Translate(&old.Path, &ret.Path)
+	tr.Translate(&old.User, &ret.User)
+	return
+}
+
+func translatePartition(old old_types.Partition) (ret types.Partition) {
+	tr := translate.NewTranslator()
+	tr.Translate(&old.GUID, &ret.GUID)
+	tr.Translate(&old.Label, &ret.Label)
+	tr.Translate(&old.Number, &ret.Number)
+	tr.Translate(&old.Resize, &ret.Resize)
+	tr.Translate(&old.ShouldExist, &ret.ShouldExist)
+	tr.Translate(&old.SizeMiB, &ret.SizeMiB)
+	tr.Translate(&old.StartMiB, &ret.StartMiB)
+	tr.Translate(&old.TypeGUID, &ret.TypeGUID)
+	tr.Translate(&old.WipePartitionEntry, &ret.WipePartitionEntry)
+	return
+}
+
+func translateUnit(old old_types.Unit) (ret types.Unit) {
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Contents, &ret.Contents)
+	tr.Translate(&old.Dropins, &ret.Dropins)
+	tr.Translate(&old.Enabled, &ret.Enabled)
+	tr.Translate(&old.Mask, &ret.Mask)
+	tr.Translate(&old.Name, &ret.Name)
+	return
+}
+
+func translatePasswdUser(old old_types.PasswdUser) (ret types.PasswdUser) {
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Gecos, &ret.Gecos)
+	tr.Translate(&old.Groups, &ret.Groups)
+	tr.Translate(&old.HomeDir, &ret.HomeDir)
+	tr.Translate(&old.Name, &ret.Name)
+	tr.Translate(&old.NoCreateHome, &ret.NoCreateHome)
+	tr.Translate(&old.NoLogInit, &ret.NoLogInit)
+	tr.Translate(&old.NoUserGroup, &ret.NoUserGroup)
+	tr.Translate(&old.PasswordHash, &ret.PasswordHash)
+	tr.Translate(&old.PrimaryGroup, &ret.PrimaryGroup)
+	tr.Translate(&old.SSHAuthorizedKeys, &ret.SSHAuthorizedKeys)
+	tr.Translate(&old.Shell, &ret.Shell)
+	tr.Translate(&old.ShouldExist, &ret.ShouldExist)
+	tr.Translate(&old.System, &ret.System)
+	tr.Translate(&old.UID, &ret.UID)
+	return
+}
+
+func translatePasswdGroup(old old_types.PasswdGroup) (ret types.PasswdGroup) {
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Gid, &ret.Gid)
+	tr.Translate(&old.Name, &ret.Name)
+	tr.Translate(&old.PasswordHash, &ret.PasswordHash)
+	tr.Translate(&old.ShouldExist, &ret.ShouldExist)
+	tr.Translate(&old.System, &ret.System)
+	return
+}
+
 func Translate(old old_types.Config) (ret types.Config) {
 	tr := translate.NewTranslator()
 	tr.AddCustomTranslator(translateIgnition)
-	tr.Translate(&old, &ret)
+	tr.AddCustomTranslator(translateDirectoryEmbedded1)
+	tr.AddCustomTranslator(translateFileEmbedded1)
+	tr.AddCustomTranslator(translateFilesystem)
+	tr.AddCustomTranslator(translateNode)
+	tr.AddCustomTranslator(translatePartition)
+	tr.AddCustomTranslator(translateUnit)
+	tr.AddCustomTranslator(translatePasswdUser)
+	tr.AddCustomTranslator(translatePasswdGroup)
+	tr.AddCustomTranslator(translateVerification)
+	tr.Translate(&old.Ignition, &ret.Ignition)
+	tr.Translate(&old.KernelArguments, &ret.KernelArguments)
+	tr.Translate(&old.Passwd, &ret.Passwd)
+	// storage gained loops, so translate the old fields individually with
+	// the custom translators still registered
+	tr.Translate(&old.Storage.Directories, &ret.Storage.Directories)
+	tr.Translate(&old.Storage.Disks, &ret.Storage.Disks)
+	tr.Translate(&old.Storage.Files, &ret.Storage.Files)
+	tr.Translate(&old.Storage.Filesystems, &ret.Storage.Filesystems)
+	tr.Translate(&old.Storage.Links, &ret.Storage.Links)
+	tr.Translate(&old.Storage.Luks, &ret.Storage.Luks)
+	tr.Translate(&old.Storage.Raid, &ret.Storage.Raid)
+	tr.Translate(&old.Systemd, &ret.Systemd)
 	return
 }
@@ -29,6 +29,24 @@ func (c Clevis) IsPresent() bool {
 		c.Threshold != nil && *c.Threshold != 0
 }
 
+func (cl Clevis) Validate(c path.ContextPath) (r report.Report) {
+	if util.NotEmpty(cl.Custom.Pin) || cl.Threshold == nil || *cl.Threshold == 0 {
+		// custom pins are validated separately, and an unset
+		// threshold defaults to 1
+		return
+	}
+	pins := len(cl.Tang)
+	if util.IsTrue(cl.Tpm2) {
+		pins++
+	}
+	if pins == 0 {
+		r.AddOnError(c.Append("threshold"), errors.ErrClevisNoPins)
+	} else if *cl.Threshold > pins {
+		r.AddOnError(c.Append("threshold"), errors.ErrClevisThresholdTooHigh)
+	}
+	return
+}
+
 func (cu ClevisCustom) Validate(c path.ContextPath) (r report.Report) {
 	if util.NilOrEmpty(cu.Pin) && util.NilOrEmpty(cu.Config) && !util.IsTrue(cu.NeedsNetwork) {
 		return
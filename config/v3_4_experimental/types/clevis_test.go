@@ -25,6 +25,65 @@ import (
 	"github.com/coreos/vcontext/report"
 )
 
+func TestClevisValidate(t *testing.T) {
+	tests := []struct {
+		in  Clevis
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in:  Clevis{},
+			out: nil,
+		},
+		{
+			in: Clevis{
+				Tang: []Tang{{URL: "http://tang.example.com", Thumbprint: util.StrToPtr("abc")}},
+			},
+			out: nil,
+		},
+		{
+			in: Clevis{
+				Tang:      []Tang{{URL: "http://tang.example.com", Thumbprint: util.StrToPtr("abc")}},
+				Threshold: util.IntToPtr(2),
+				Tpm2:      util.BoolToPtr(true),
+			},
+			out: nil,
+		},
+		{
+			in: Clevis{
+				Threshold: util.IntToPtr(1),
+			},
+			at:  path.New("", "threshold"),
+			out: errors.ErrClevisNoPins,
+		},
+		{
+			in: Clevis{
+				Threshold: util.IntToPtr(1),
+				Tpm2:      util.BoolToPtr(false),
+			},
+			at:  path.New("", "threshold"),
+			out: errors.ErrClevisNoPins,
+		},
+		{
+			in: Clevis{
+				Tang:      []Tang{{URL: "http://tang.example.com", Thumbprint: util.StrToPtr("abc")}},
+				Threshold: util.IntToPtr(2),
+			},
+			at:  path.New("", "threshold"),
+			out: errors.ErrClevisThresholdTooHigh,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
+
 func TestClevisCustomValidate(t *testing.T) {
 	tests := []struct {
 		in  ClevisCustom
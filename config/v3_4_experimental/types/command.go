@@ -0,0 +1,41 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"github.com/coreos/ignition/v2/config/shared/errors"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func (c Command) Key() string {
+	return c.Name
+}
+
+func (c Command) Validate(p path.ContextPath) (r report.Report) {
+	if c.Name == "" {
+		r.AddOnError(p.Append("name"), errors.ErrCommandNameRequired)
+	}
+	if c.Path == nil {
+		r.AddOnError(p.Append("path"), errors.ErrNoPath)
+	} else {
+		r.AddOnError(p.Append("path"), validatePath(*c.Path))
+	}
+	if c.Timeout != nil && *c.Timeout < 0 {
+		r.AddOnError(p.Append("timeout"), errors.ErrNegativeTimeout)
+	}
+	return
+}
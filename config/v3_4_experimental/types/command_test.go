@@ -0,0 +1,70 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestCommandValidate(t *testing.T) {
+	tests := []struct {
+		in  Command
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: Command{Name: "hello", Path: util.StrToPtr("/usr/bin/echo"), Args: []string{"hello"}},
+		},
+		{
+			in: Command{Name: "hello", Path: util.StrToPtr("/usr/bin/true"), Timeout: util.IntToPtr(0)},
+		},
+		{
+			in:  Command{Path: util.StrToPtr("/usr/bin/true")},
+			at:  path.New("", "name"),
+			out: errors.ErrCommandNameRequired,
+		},
+		{
+			in:  Command{Name: "hello"},
+			at:  path.New("", "path"),
+			out: errors.ErrNoPath,
+		},
+		{
+			in:  Command{Name: "hello", Path: util.StrToPtr("echo")},
+			at:  path.New("", "path"),
+			out: errors.ErrPathRelative,
+		},
+		{
+			in:  Command{Name: "hello", Path: util.StrToPtr("/usr/bin/true"), Timeout: util.IntToPtr(-1)},
+			at:  path.New("", "timeout"),
+			out: errors.ErrNegativeTimeout,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
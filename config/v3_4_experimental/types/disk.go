@@ -93,7 +93,7 @@ func (p Partition) end() int {
 // partitionsOverlap returns true if any explicitly dimensioned partitions overlap. It also returns the index of
 // the overlapping partition
 func (n Disk) partitionsOverlap() (bool, int) {
-	for _, p := range n.Partitions {
+	for j, p := range n.Partitions {
 		// Starts of 0 are placed by sgdisk into the "largest available block" at that time.
 		// We aren't going to check those for overlap since we don't have the disk geometry.
 		if p.StartMiB == nil || p.SizeMiB == nil || *p.StartMiB == 0 {
@@ -101,7 +101,7 @@ func (n Disk) partitionsOverlap() (bool, int) {
 		}
 
 		for i, o := range n.Partitions {
-			if o.StartMiB == nil || o.SizeMiB == nil || p == o || *o.StartMiB == 0 {
+			if o.StartMiB == nil || o.SizeMiB == nil || i == j || *o.StartMiB == 0 {
 				continue
 			}
 
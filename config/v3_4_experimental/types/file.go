@@ -15,17 +15,30 @@
 package types
 
 import (
+	"net/url"
+	"strings"
+
 	"github.com/coreos/ignition/v2/config/shared/errors"
 	"github.com/coreos/ignition/v2/config/util"
 
 	"github.com/coreos/vcontext/path"
 	"github.com/coreos/vcontext/report"
+	"github.com/vincent-petithory/dataurl"
 )
 
 func (f File) Validate(c path.ContextPath) (r report.Report) {
 	r.Merge(f.Node.Validate(c))
 	r.AddOnError(c.Append("mode"), validateMode(f.Mode))
 	r.AddOnError(c.Append("overwrite"), f.validateOverwrite())
+	r.AddOnError(c.Append("template"), f.validateTemplate())
+	r.AddOnError(c.Append("target"), f.validateTarget())
+	if f.SelinuxContext != nil {
+		for i, x := range f.Xattrs {
+			if x.Name == selinuxXattr {
+				r.AddOnError(c.Append("xattrs", i, "name"), errors.ErrXattrSelinuxConflict)
+			}
+		}
+	}
 	return
 }
 
@@ -36,8 +49,118 @@ func (f File) validateOverwrite() error {
 	return nil
 }
 
+// validateTemplate checks that templated contents are inline, since they're
+// meant to be read and written by the config author rather than fetched.
+func (f File) validateTemplate() error {
+	if !util.IsTrue(f.Template) || f.Contents.Source == nil {
+		return nil
+	}
+	u, err := url.Parse(*f.Contents.Source)
+	if err != nil {
+		// reported by the resource's own validation
+		return nil
+	}
+	if u.Scheme != "data" {
+		return errors.ErrTemplateNotInline
+	}
+	return nil
+}
+
+// validateTarget checks the target, and that the owner of a file targeting
+// the initramfs doesn't need to be looked up, since the sysroot's user
+// database would be used.
+func (f File) validateTarget() error {
+	if f.Target == nil {
+		return nil
+	}
+	switch *f.Target {
+	case FileTargetSysroot:
+		return nil
+	case FileTargetInitramfs:
+		if util.NotEmpty(f.User.Name) || util.NotEmpty(f.Group.Name) {
+			return errors.ErrInitramfsFileOwnerName
+		}
+		return nil
+	default:
+		return errors.ErrFileTargetInvalid
+	}
+}
+
+// TargetsInitramfs returns whether the file is written to the root of the
+// running initramfs rather than the sysroot.
+func (f File) TargetsInitramfs() bool {
+	return f.Target != nil && *f.Target == FileTargetInitramfs
+}
+
 func (f FileEmbedded1) IgnoreDuplicates() map[string]struct{} {
 	return map[string]struct{}{
 		"Append": {},
 	}
 }
+
+const (
+	FileTargetSysroot   = "sysroot"
+	FileTargetInitramfs = "initramfs"
+)
+
+const (
+	FileFlagImmutable  FileFlag = "immutable"
+	FileFlagAppendOnly FileFlag = "append-only"
+)
+
+func (f FileFlag) Key() string {
+	return string(f)
+}
+
+func (f FileFlag) Validate(c path.ContextPath) (r report.Report) {
+	switch f {
+	case FileFlagImmutable, FileFlagAppendOnly:
+	default:
+		r.AddOnError(c, errors.ErrFileFlagInvalid)
+	}
+	return
+}
+
+// selinuxXattr holds a file's SELinux context, which can also be set with
+// Node.SelinuxContext.
+const selinuxXattr = "security.selinux"
+
+var xattrNamespaces = []string{"security.", "system.", "trusted.", "user."}
+
+func (x FileXattr) Key() string {
+	return x.Name
+}
+
+func (x FileXattr) Validate(c path.ContextPath) (r report.Report) {
+	r.AddOnError(c.Append("name"), validateXattrName(x.Name))
+	r.AddOnError(c.Append("value"), validateXattrValue(x.Value))
+	return
+}
+
+func validateXattrName(name string) error {
+	if name == "" {
+		return errors.ErrXattrNameRequired
+	}
+	for _, ns := range xattrNamespaces {
+		if strings.HasPrefix(name, ns) && len(name) > len(ns) {
+			return nil
+		}
+	}
+	return errors.ErrXattrNamespace
+}
+
+// validateXattrValue checks that value is a data URL, so that binary values
+// such as security.capability can be given.
+func validateXattrValue(value *string) error {
+	if value == nil {
+		return nil
+	}
+	u, err := url.Parse(*value)
+	if err != nil || u.Scheme != "data" {
+		return errors.ErrXattrValueNotDataURL
+	}
+	if _, err := dataurl.DecodeString(*value); err != nil {
+		return errors.ErrXattrValueNotDataURL
+	}
+	return nil
+}
@@ -15,10 +15,14 @@
 package types
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/coreos/ignition/v2/config/shared/errors"
 	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
 )
 
 func TestFileValidateOverwrite(t *testing.T) {
@@ -64,6 +68,41 @@ func TestFileValidateOverwrite(t *testing.T) {
 			},
 			nil,
 		},
+		{
+			// appending to a file being overwritten requires
+			// replacement contents
+			File{
+				Node: Node{
+					Overwrite: util.BoolToPtr(true),
+				},
+				FileEmbedded1: FileEmbedded1{
+					Append: []Resource{
+						{
+							Source: util.StrToPtr("http://example.com"),
+						},
+					},
+				},
+			},
+			errors.ErrOverwriteAndNilSource,
+		},
+		{
+			File{
+				Node: Node{
+					Overwrite: util.BoolToPtr(true),
+				},
+				FileEmbedded1: FileEmbedded1{
+					Append: []Resource{
+						{
+							Source: util.StrToPtr("http://example.com/append"),
+						},
+					},
+					Contents: Resource{
+						Source: util.StrToPtr("http://example.com"),
+					},
+				},
+			},
+			nil,
+		},
 	}
 
 	for i, test := range tests {
@@ -74,6 +113,147 @@ func TestFileValidateOverwrite(t *testing.T) {
 	}
 }
 
+func TestFileValidateTemplate(t *testing.T) {
+	tests := []struct {
+		in  File
+		out error
+	}{
+		{
+			File{},
+			nil,
+		},
+		{
+			File{
+				FileEmbedded1: FileEmbedded1{
+					Template: util.BoolToPtr(true),
+				},
+			},
+			nil,
+		},
+		{
+			File{
+				FileEmbedded1: FileEmbedded1{
+					Contents: Resource{
+						Source: util.StrToPtr("data:,hostname%3D%7B%7B%20hostname%20%7D%7D"),
+					},
+					Template: util.BoolToPtr(true),
+				},
+			},
+			nil,
+		},
+		{
+			File{
+				FileEmbedded1: FileEmbedded1{
+					Contents: Resource{
+						Source: util.StrToPtr("http://example.com/template"),
+					},
+					Template: util.BoolToPtr(true),
+				},
+			},
+			errors.ErrTemplateNotInline,
+		},
+		{
+			File{
+				FileEmbedded1: FileEmbedded1{
+					Contents: Resource{
+						Source: util.StrToPtr("http://example.com/template"),
+					},
+					Template: util.BoolToPtr(false),
+				},
+			},
+			nil,
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.validateTemplate()
+		if test.out != err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out, err)
+		}
+	}
+}
+
+func TestFileValidateTarget(t *testing.T) {
+	tests := []struct {
+		in  File
+		out error
+	}{
+		{
+			File{},
+			nil,
+		},
+		{
+			File{
+				FileEmbedded1: FileEmbedded1{
+					Target: util.StrToPtr("sysroot"),
+				},
+			},
+			nil,
+		},
+		{
+			File{
+				Node: Node{
+					User:  NodeUser{ID: util.IntToPtr(0)},
+					Group: NodeGroup{ID: util.IntToPtr(0)},
+				},
+				FileEmbedded1: FileEmbedded1{
+					Target: util.StrToPtr("initramfs"),
+				},
+			},
+			nil,
+		},
+		{
+			File{
+				Node: Node{
+					User: NodeUser{Name: util.StrToPtr("core")},
+				},
+				FileEmbedded1: FileEmbedded1{
+					Target: util.StrToPtr("initramfs"),
+				},
+			},
+			errors.ErrInitramfsFileOwnerName,
+		},
+		{
+			File{
+				Node: Node{
+					Group: NodeGroup{Name: util.StrToPtr("wheel")},
+				},
+				FileEmbedded1: FileEmbedded1{
+					Target: util.StrToPtr("initramfs"),
+				},
+			},
+			errors.ErrInitramfsFileOwnerName,
+		},
+		// names are fine in the sysroot
+		{
+			File{
+				Node: Node{
+					User: NodeUser{Name: util.StrToPtr("core")},
+				},
+				FileEmbedded1: FileEmbedded1{
+					Target: util.StrToPtr("sysroot"),
+				},
+			},
+			nil,
+		},
+		{
+			File{
+				FileEmbedded1: FileEmbedded1{
+					Target: util.StrToPtr("real-root"),
+				},
+			},
+			errors.ErrFileTargetInvalid,
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.validateTarget()
+		if test.out != err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out, err)
+		}
+	}
+}
+
 func TestFileContentsValidate(t *testing.T) {
 	tests := []struct {
 		in  Resource
@@ -115,3 +295,173 @@ func TestFileContentsValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestFileXattrValidate(t *testing.T) {
+	tests := []struct {
+		in  string
+		out error
+	}{
+		{
+			"user.comment",
+			nil,
+		},
+		{
+			"security.capability",
+			nil,
+		},
+		{
+			"trusted.overlay.opaque",
+			nil,
+		},
+		{
+			"system.posix_acl_access",
+			nil,
+		},
+		{
+			"",
+			errors.ErrXattrNameRequired,
+		},
+		{
+			"comment",
+			errors.ErrXattrNamespace,
+		},
+		{
+			"user.",
+			errors.ErrXattrNamespace,
+		},
+		{
+			"users.comment",
+			errors.ErrXattrNamespace,
+		},
+	}
+
+	for i, test := range tests {
+		r := FileXattr{Name: test.in}.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(path.New("", "name"), test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out, r)
+		}
+	}
+}
+
+func TestFileXattrValidateValue(t *testing.T) {
+	tests := []struct {
+		in  *string
+		out error
+	}{
+		{
+			nil,
+			nil,
+		},
+		{
+			util.StrToPtr("data:,provisioned%20by%20ignition"),
+			nil,
+		},
+		{
+			util.StrToPtr("data:;base64,AQAAAgAgAAAAAAAAAAAAAAAAAAA="),
+			nil,
+		},
+		{
+			util.StrToPtr("provisioned by ignition"),
+			errors.ErrXattrValueNotDataURL,
+		},
+		{
+			util.StrToPtr("https://example.com/value"),
+			errors.ErrXattrValueNotDataURL,
+		},
+		{
+			util.StrToPtr("data:;base64,%%%"),
+			errors.ErrXattrValueNotDataURL,
+		},
+	}
+
+	for i, test := range tests {
+		r := FileXattr{Name: "user.a", Value: test.in}.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(path.New("", "value"), test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out, r)
+		}
+	}
+}
+
+func TestFileValidateXattrSelinuxConflict(t *testing.T) {
+	tests := []struct {
+		in  File
+		out report.Report
+	}{
+		{
+			in: File{
+				Node: Node{Path: "/a"},
+				FileEmbedded1: FileEmbedded1{
+					Xattrs: []FileXattr{{Name: "security.selinux"}},
+				},
+			},
+		},
+		{
+			in: File{
+				Node: Node{Path: "/a", SelinuxContext: util.StrToPtr("system_u:object_r:etc_t:s0")},
+				FileEmbedded1: FileEmbedded1{
+					Xattrs: []FileXattr{{Name: "user.a"}},
+				},
+			},
+		},
+		{
+			in: File{
+				Node: Node{Path: "/a", SelinuxContext: util.StrToPtr("system_u:object_r:etc_t:s0")},
+				FileEmbedded1: FileEmbedded1{
+					Xattrs: []FileXattr{{Name: "user.a"}, {Name: "security.selinux"}},
+				},
+			},
+			out: func() (r report.Report) {
+				r.AddOnError(path.New("", "xattrs", 1, "name"), errors.ErrXattrSelinuxConflict)
+				return
+			}(),
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		if !reflect.DeepEqual(test.out, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out, r)
+		}
+	}
+}
+
+func TestFileFlagValidate(t *testing.T) {
+	tests := []struct {
+		in  FileFlag
+		out error
+	}{
+		{
+			FileFlagImmutable,
+			nil,
+		},
+		{
+			FileFlagAppendOnly,
+			nil,
+		},
+		{
+			"",
+			errors.ErrFileFlagInvalid,
+		},
+		{
+			"append",
+			errors.ErrFileFlagInvalid,
+		},
+		{
+			"Immutable",
+			errors.ErrFileFlagInvalid,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(path.ContextPath{}, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out, r)
+		}
+	}
+}
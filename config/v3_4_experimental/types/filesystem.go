@@ -15,13 +15,38 @@
 package types
 
 import (
+	"path"
+	"regexp"
+	"strings"
+
 	"github.com/coreos/ignition/v2/config/shared/errors"
 	"github.com/coreos/ignition/v2/config/util"
 
-	"github.com/coreos/vcontext/path"
+	vpath "github.com/coreos/vcontext/path"
 	"github.com/coreos/vcontext/report"
 )
 
+var (
+	fsUUIDRegex     = regexp.MustCompile("^[[:xdigit:]]{8}-[[:xdigit:]]{4}-[[:xdigit:]]{4}-[[:xdigit:]]{4}-[[:xdigit:]]{12}$")
+	vfatVolumeRegex = regexp.MustCompile("^[[:xdigit:]]{4}-?[[:xdigit:]]{4}$")
+
+	// mkfs flags Ignition passes itself when uuid or label is specified.
+	// xfs takes its UUID as a -m suboption and is handled separately.
+	fsUUIDFlags = map[string][]string{
+		"btrfs": {"-U", "--uuid"},
+		"ext4":  {"-U"},
+		"swap":  {"-U", "--uuid"},
+		"vfat":  {"-i"},
+	}
+	fsLabelFlags = map[string][]string{
+		"btrfs": {"-L", "--label"},
+		"ext4":  {"-L"},
+		"xfs":   {"-L"},
+		"swap":  {"-L", "--label"},
+		"vfat":  {"-n"},
+	}
+)
+
 func (f Filesystem) Key() string {
 	return f.Device
 }
@@ -33,11 +58,26 @@ func (f Filesystem) IgnoreDuplicates() map[string]struct{} {
 	}
 }
 
-func (f Filesystem) Validate(c path.ContextPath) (r report.Report) {
+func (f Filesystem) Validate(c vpath.ContextPath) (r report.Report) {
 	r.AddOnError(c.Append("path"), f.validatePath())
 	r.AddOnError(c.Append("device"), validatePath(f.Device))
 	r.AddOnError(c.Append("format"), f.validateFormat())
 	r.AddOnError(c.Append("label"), f.validateLabel())
+	r.AddOnError(c.Append("uuid"), f.validateUUID())
+	r.AddOnError(c.Append("options"), f.validateOptions())
+	r.AddOnError(c.Append("mountOptions"), f.validateMountOptions())
+	r.AddOnError(c.Append("withMountUnit"), f.validateMountUnit())
+	r.AddOnError(c.Append("resize"), f.validateResize())
+	r.AddOnError(c.Append("fsck"), f.validateFsck())
+	r.AddOnError(c.Append("subvolumes"), f.validateSubvolumesFormat())
+	for i, sv := range f.Subvolumes {
+		r.AddOnError(c.Append("subvolumes", i), sv.Validate())
+	}
+	for i, o := range f.MountOptions {
+		if strings.Contains(string(o), ",") {
+			r.AddOnWarn(c.Append("mountOptions", i), errors.ErrMountOptionContainsComma)
+		}
+	}
 	return
 }
 
@@ -65,6 +105,80 @@ func (f Filesystem) validateFormat() error {
 	return nil
 }
 
+func (f Filesystem) validateMountOptions() error {
+	// swap filesystems are never mounted
+	if util.NotEmpty(f.Format) && *f.Format == "swap" && len(f.MountOptions) != 0 {
+		return errors.ErrMountOptionsWithSwap
+	}
+	return nil
+}
+
+func (f Filesystem) validateMountUnit() error {
+	if !util.IsTrue(f.WithMountUnit) {
+		return nil
+	}
+	if util.NilOrEmpty(f.Format) || *f.Format == "none" {
+		return errors.ErrMountUnitNoFormat
+	}
+	if *f.Format != "swap" && util.NilOrEmpty(f.Path) {
+		return errors.ErrMountUnitNoPath
+	}
+	return nil
+}
+
+func (f Filesystem) validateResize() error {
+	if !util.IsTrue(f.Resize) {
+		return nil
+	}
+	if util.NilOrEmpty(f.Format) {
+		return errors.ErrResizeUnsupportedFormat
+	}
+	switch *f.Format {
+	case "ext4", "xfs", "btrfs":
+		return nil
+	default:
+		return errors.ErrResizeUnsupportedFormat
+	}
+}
+
+// validateFsck checks that fsck is only requested for mounted filesystems
+// whose checker can repair them unattended. xfs and btrfs recover their
+// journals when mounted, and their fsck helpers do nothing.
+func (f Filesystem) validateFsck() error {
+	if !util.IsTrue(f.Fsck) {
+		return nil
+	}
+	if util.NilOrEmpty(f.Format) || (*f.Format != "ext4" && *f.Format != "vfat") {
+		return errors.ErrFsckUnsupportedFormat
+	}
+	if util.NilOrEmpty(f.Path) {
+		return errors.ErrFsckNoPath
+	}
+	return nil
+}
+
+func (f Filesystem) validateSubvolumesFormat() error {
+	if len(f.Subvolumes) != 0 && (util.NilOrEmpty(f.Format) || *f.Format != "btrfs") {
+		return errors.ErrSubvolumesNeedBtrfs
+	}
+	return nil
+}
+
+// Validate checks that the subvolume is a path relative to the top level of
+// the filesystem which can't escape it.
+func (s Subvolume) Validate() error {
+	p := string(s)
+	if p == "" || strings.HasPrefix(p, "/") || path.Clean(p) != p {
+		return errors.ErrSubvolumePathInvalid
+	}
+	for _, component := range strings.Split(p, "/") {
+		if component == ".." || component == "." {
+			return errors.ErrSubvolumePathInvalid
+		}
+	}
+	return nil
+}
+
 func (f Filesystem) validateLabel() error {
 	if util.NilOrEmpty(f.Label) {
 		return nil
@@ -101,6 +215,96 @@ func (f Filesystem) validateLabel() error {
 			// source: man mkfs.fat
 			return errors.ErrVfatLabelTooLong
 		}
+		// source: mkfs.fat rejects these characters in volume labels
+		if strings.ContainsAny(*f.Label, `"*+,./:;<=>?[\]|`) {
+			return errors.ErrVfatLabelInvalidChars
+		}
+	}
+	return nil
+}
+
+func (f Filesystem) validateUUID() error {
+	if util.NilOrEmpty(f.UUID) || util.NilOrEmpty(f.Format) {
+		return nil
+	}
+
+	switch *f.Format {
+	case "vfat":
+		// FAT uses a 32-bit volume ID instead of a UUID
+		if !vfatVolumeRegex.MatchString(*f.UUID) {
+			return errors.ErrVfatUUIDInvalid
+		}
+	case "ext4":
+		// source: man mkfs.ext4
+		switch *f.UUID {
+		case "clear", "random", "time":
+			return nil
+		}
+		fallthrough
+	case "btrfs", "xfs", "swap":
+		if !fsUUIDRegex.MatchString(*f.UUID) {
+			return errors.ErrFilesystemUUIDInvalid
+		}
 	}
 	return nil
 }
+
+// validateOptions rejects options which would conflict with the arguments
+// Ignition generates from uuid and label.
+func (f Filesystem) validateOptions() error {
+	if util.NilOrEmpty(f.Format) {
+		return nil
+	}
+	if util.NotEmpty(f.UUID) {
+		if *f.Format == "xfs" && xfsOptionsSetUUID(f.Options) {
+			return errors.ErrFilesystemOptionsUUID
+		}
+		if optionsContainFlag(f.Options, fsUUIDFlags[*f.Format]) {
+			return errors.ErrFilesystemOptionsUUID
+		}
+	}
+	if util.NotEmpty(f.Label) && optionsContainFlag(f.Options, fsLabelFlags[*f.Format]) {
+		return errors.ErrFilesystemOptionsLabel
+	}
+	return nil
+}
+
+// optionsContainFlag reports whether any of flags appears in opts, either
+// alone or with its value attached (e.g. "-Lfoo" or "--label=foo").
+func optionsContainFlag(opts []FilesystemOption, flags []string) bool {
+	for _, o := range opts {
+		for _, flag := range flags {
+			switch {
+			case string(o) == flag:
+				return true
+			case strings.HasPrefix(flag, "--") && strings.HasPrefix(string(o), flag+"="):
+				return true
+			case !strings.HasPrefix(flag, "--") && strings.HasPrefix(string(o), flag):
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// xfsOptionsSetUUID reports whether opts set the uuid metadata suboption,
+// e.g. "-m", "crc=1,uuid=..." or "-muuid=...".
+func xfsOptionsSetUUID(opts []FilesystemOption) bool {
+	for i, o := range opts {
+		var subopts string
+		switch {
+		case string(o) == "-m" && i+1 < len(opts):
+			subopts = string(opts[i+1])
+		case strings.HasPrefix(string(o), "-m"):
+			subopts = strings.TrimPrefix(string(o), "-m")
+		default:
+			continue
+		}
+		for _, subopt := range strings.Split(subopts, ",") {
+			if strings.HasPrefix(strings.TrimSpace(subopt), "uuid=") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -15,10 +15,13 @@
 package types
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/coreos/ignition/v2/config/shared/errors"
 	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
 )
 
 func TestFilesystemValidateFormat(t *testing.T) {
@@ -111,6 +114,339 @@ func TestFilesystemValidatePath(t *testing.T) {
 	}
 }
 
+func TestFilesystemValidateMountOptions(t *testing.T) {
+	tests := []struct {
+		in  Filesystem
+		out error
+	}{
+		{
+			Filesystem{Format: util.StrToPtr("ext4"), MountOptions: []MountOption{"noatime"}},
+			nil,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("swap")},
+			nil,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("swap"), MountOptions: []MountOption{"discard"}},
+			errors.ErrMountOptionsWithSwap,
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.validateMountOptions()
+		if test.out != err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out, err)
+		}
+	}
+}
+
+func TestFilesystemValidateMountOptionCommas(t *testing.T) {
+	tests := []struct {
+		in  Filesystem
+		out string
+	}{
+		{
+			Filesystem{Device: "/dev/sda", Format: util.StrToPtr("ext4"), MountOptions: []MountOption{"noatime", "subvol=root"}},
+			"",
+		},
+		{
+			Filesystem{Device: "/dev/sda", Format: util.StrToPtr("ext4"), MountOptions: []MountOption{"noatime,nodev"}},
+			"warning at $.mountOptions.0: mount option contains a comma and will be passed to mount as multiple options\n",
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.New("json"))
+		if test.out != r.String() {
+			t.Errorf("#%d: bad report: want %q, got %q", i, test.out, r.String())
+		}
+	}
+}
+
+func TestFilesystemValidateSubvolumes(t *testing.T) {
+	invalid := "error at $.subvolumes.%d: " + errors.ErrSubvolumePathInvalid.Error() + "\n"
+	tests := []struct {
+		in  Filesystem
+		out string
+	}{
+		{
+			Filesystem{Device: "/dev/sda", Format: util.StrToPtr("btrfs"), Subvolumes: []Subvolume{"root", "var/lib/containers"}},
+			"",
+		},
+		{
+			Filesystem{Device: "/dev/sda", Format: util.StrToPtr("xfs"), Subvolumes: []Subvolume{"root"}},
+			"error at $.subvolumes: " + errors.ErrSubvolumesNeedBtrfs.Error() + "\n",
+		},
+		{
+			Filesystem{Device: "/dev/sda", Format: util.StrToPtr("btrfs"), Subvolumes: []Subvolume{"root", "/home"}},
+			fmt.Sprintf(invalid, 1),
+		},
+		{
+			Filesystem{Device: "/dev/sda", Format: util.StrToPtr("btrfs"), Subvolumes: []Subvolume{"root/../../escape"}},
+			fmt.Sprintf(invalid, 0),
+		},
+		{
+			Filesystem{Device: "/dev/sda", Format: util.StrToPtr("btrfs"), Subvolumes: []Subvolume{"root/"}},
+			fmt.Sprintf(invalid, 0),
+		},
+		{
+			Filesystem{Device: "/dev/sda", Format: util.StrToPtr("btrfs"), Subvolumes: []Subvolume{""}},
+			fmt.Sprintf(invalid, 0),
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.New("json"))
+		if test.out != r.String() {
+			t.Errorf("#%d: bad report: want %q, got %q", i, test.out, r.String())
+		}
+	}
+}
+
+func TestFilesystemValidateResize(t *testing.T) {
+	unsupported := "error at $.resize: " + errors.ErrResizeUnsupportedFormat.Error() + "\n"
+	tests := []struct {
+		in  Filesystem
+		out string
+	}{
+		{
+			Filesystem{Device: "/dev/sda", Format: util.StrToPtr("ext4"), Resize: util.BoolToPtr(true)},
+			"",
+		},
+		{
+			Filesystem{Device: "/dev/sda", Format: util.StrToPtr("xfs"), Resize: util.BoolToPtr(true)},
+			"",
+		},
+		{
+			Filesystem{Device: "/dev/sda", Format: util.StrToPtr("btrfs"), Resize: util.BoolToPtr(true)},
+			"",
+		},
+		{
+			Filesystem{Device: "/dev/sda", Format: util.StrToPtr("vfat"), Resize: util.BoolToPtr(false)},
+			"",
+		},
+		{
+			Filesystem{Device: "/dev/sda", Format: util.StrToPtr("vfat"), Resize: util.BoolToPtr(true)},
+			unsupported,
+		},
+		{
+			Filesystem{Device: "/dev/sda", Format: util.StrToPtr("swap"), Resize: util.BoolToPtr(true)},
+			unsupported,
+		},
+		{
+			Filesystem{Device: "/dev/sda", Format: util.StrToPtr("none"), Resize: util.BoolToPtr(true)},
+			unsupported,
+		},
+		{
+			Filesystem{Device: "/dev/sda", Resize: util.BoolToPtr(true)},
+			unsupported,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.New("json"))
+		if test.out != r.String() {
+			t.Errorf("#%d: bad report: want %q, got %q", i, test.out, r.String())
+		}
+	}
+}
+
+func TestFilesystemValidateFsck(t *testing.T) {
+	tests := []struct {
+		in  Filesystem
+		out error
+	}{
+		{
+			Filesystem{Format: util.StrToPtr("ext4"), Path: util.StrToPtr("/var"), Fsck: util.BoolToPtr(true)},
+			nil,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("vfat"), Path: util.StrToPtr("/boot/efi"), Fsck: util.BoolToPtr(true)},
+			nil,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("xfs"), Fsck: util.BoolToPtr(false)},
+			nil,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("xfs"), Path: util.StrToPtr("/var"), Fsck: util.BoolToPtr(true)},
+			errors.ErrFsckUnsupportedFormat,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("swap"), Fsck: util.BoolToPtr(true)},
+			errors.ErrFsckUnsupportedFormat,
+		},
+		{
+			Filesystem{Fsck: util.BoolToPtr(true)},
+			errors.ErrFsckUnsupportedFormat,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("ext4"), Fsck: util.BoolToPtr(true)},
+			errors.ErrFsckNoPath,
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.validateFsck()
+		if test.out != err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out, err)
+		}
+	}
+}
+
+func TestFilesystemValidateMountUnit(t *testing.T) {
+	tests := []struct {
+		in  Filesystem
+		out error
+	}{
+		{
+			Filesystem{},
+			nil,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("ext4"), Path: util.StrToPtr("/var"), WithMountUnit: util.BoolToPtr(true)},
+			nil,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("swap"), WithMountUnit: util.BoolToPtr(true)},
+			nil,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("ext4"), WithMountUnit: util.BoolToPtr(true)},
+			errors.ErrMountUnitNoPath,
+		},
+		{
+			Filesystem{Path: util.StrToPtr("/var"), WithMountUnit: util.BoolToPtr(true)},
+			errors.ErrMountUnitNoFormat,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("none"), Path: util.StrToPtr("/var"), WithMountUnit: util.BoolToPtr(true)},
+			errors.ErrMountUnitNoFormat,
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.validateMountUnit()
+		if test.out != err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out, err)
+		}
+	}
+}
+
+func TestFilesystemValidateUUID(t *testing.T) {
+	tests := []struct {
+		in  Filesystem
+		out error
+	}{
+		{
+			Filesystem{Format: util.StrToPtr("ext4")},
+			nil,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("ext4"), UUID: util.StrToPtr("f63bf118-f6d7-40a3-b64c-a92b05a7f9ee")},
+			nil,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("ext4"), UUID: util.StrToPtr("random")},
+			nil,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("ext4"), UUID: util.StrToPtr("2e24ec82")},
+			errors.ErrFilesystemUUIDInvalid,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("xfs"), UUID: util.StrToPtr("F63BF118-F6D7-40A3-B64C-A92B05A7F9EE")},
+			nil,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("xfs"), UUID: util.StrToPtr("random")},
+			errors.ErrFilesystemUUIDInvalid,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("vfat"), UUID: util.StrToPtr("2e24ec82")},
+			nil,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("vfat"), UUID: util.StrToPtr("2E24-EC82")},
+			nil,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("vfat"), UUID: util.StrToPtr("f63bf118-f6d7-40a3-b64c-a92b05a7f9ee")},
+			errors.ErrVfatUUIDInvalid,
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.validateUUID()
+		if test.out != err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out, err)
+		}
+	}
+}
+
+func TestFilesystemValidateOptions(t *testing.T) {
+	const uuid = "f63bf118-f6d7-40a3-b64c-a92b05a7f9ee"
+	tests := []struct {
+		in  Filesystem
+		out error
+	}{
+		{
+			Filesystem{Format: util.StrToPtr("xfs"), Options: []FilesystemOption{"-m", "crc=1", "-d", "su=64k,sw=4"}},
+			nil,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("xfs"), UUID: util.StrToPtr(uuid), Options: []FilesystemOption{"-m", "crc=1", "-d", "su=64k,sw=4"}},
+			nil,
+		},
+		// uuid without the uuid field is fine
+		{
+			Filesystem{Format: util.StrToPtr("xfs"), Options: []FilesystemOption{"-m", "uuid=" + uuid}},
+			nil,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("xfs"), UUID: util.StrToPtr(uuid), Options: []FilesystemOption{"-m", "crc=1,uuid=" + uuid}},
+			errors.ErrFilesystemOptionsUUID,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("xfs"), UUID: util.StrToPtr(uuid), Options: []FilesystemOption{"-muuid=" + uuid}},
+			errors.ErrFilesystemOptionsUUID,
+		},
+		// -d doesn't take a uuid
+		{
+			Filesystem{Format: util.StrToPtr("xfs"), UUID: util.StrToPtr(uuid), Options: []FilesystemOption{"-d", "uuid=" + uuid}},
+			nil,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("xfs"), Label: util.StrToPtr("data"), Options: []FilesystemOption{"-L", "other"}},
+			errors.ErrFilesystemOptionsLabel,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("ext4"), UUID: util.StrToPtr(uuid), Options: []FilesystemOption{"-U", uuid}},
+			errors.ErrFilesystemOptionsUUID,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("btrfs"), Label: util.StrToPtr("data"), Options: []FilesystemOption{"--label=other"}},
+			errors.ErrFilesystemOptionsLabel,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("btrfs"), Label: util.StrToPtr("data"), Options: []FilesystemOption{"--labelled"}},
+			nil,
+		},
+		{
+			Filesystem{Format: util.StrToPtr("vfat"), Label: util.StrToPtr("data"), Options: []FilesystemOption{"-nOTHER"}},
+			errors.ErrFilesystemOptionsLabel,
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.validateOptions()
+		if test.out != err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out, err)
+		}
+	}
+}
+
 func TestLabelValidate(t *testing.T) {
 	type in struct {
 		filesystem Filesystem
@@ -183,6 +519,18 @@ func TestLabelValidate(t *testing.T) {
 			in:  in{filesystem: Filesystem{Format: util.StrToPtr("vfat"), Label: util.StrToPtr("thislabelistoolong")}},
 			out: out{err: errors.ErrVfatLabelTooLong},
 		},
+		{
+			in:  in{filesystem: Filesystem{Format: util.StrToPtr("vfat"), Label: util.StrToPtr("EFI-SYSTEM")}},
+			out: out{},
+		},
+		{
+			in:  in{filesystem: Filesystem{Format: util.StrToPtr("vfat"), Label: util.StrToPtr("efi:boot")}},
+			out: out{err: errors.ErrVfatLabelInvalidChars},
+		},
+		{
+			in:  in{filesystem: Filesystem{Format: util.StrToPtr("vfat"), Label: util.StrToPtr("a/b")}},
+			out: out{err: errors.ErrVfatLabelInvalidChars},
+		},
 	}
 
 	for i, test := range tests {
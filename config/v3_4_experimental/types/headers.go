@@ -60,6 +60,8 @@ func (h HTTPHeader) validateValue() error {
 	return nil
 }
 
+// Key returns the canonical form of the header name, since header names
+// are case-insensitive.
 func (h HTTPHeader) Key() string {
-	return h.Name
+	return http.CanonicalHeaderKey(h.Name)
 }
@@ -0,0 +1,56 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+// hostnameLabelRegex matches one dot-separated label of an RFC 1123
+// hostname
+var hostnameLabelRegex = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+
+// maxHostnameLength is the kernel's limit, which is stricter than RFC 1123's
+const maxHostnameLength = 64
+
+func (h Hostname) Validate(c path.ContextPath) (r report.Report) {
+	if h.Name == nil {
+		if util.IsTrue(h.UpdateHosts) {
+			r.AddOnError(c.Append("name"), errors.ErrHostnameRequired)
+		}
+		return
+	}
+	r.AddOnError(c.Append("name"), validateHostname(*h.Name))
+	return
+}
+
+func validateHostname(name string) error {
+	if name == "" || len(name) > maxHostnameLength {
+		return errors.ErrHostnameInvalid
+	}
+	for _, label := range strings.Split(name, ".") {
+		if !hostnameLabelRegex.MatchString(label) {
+			return errors.ErrHostnameInvalid
+		}
+	}
+	return nil
+}
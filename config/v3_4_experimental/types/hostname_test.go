@@ -0,0 +1,105 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestHostnameValidate(t *testing.T) {
+	tests := []struct {
+		in  Hostname
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: Hostname{},
+		},
+		{
+			in: Hostname{Name: util.StrToPtr("node1")},
+		},
+		{
+			in: Hostname{Name: util.StrToPtr("Node-1.example.com"), UpdateHosts: util.BoolToPtr(true)},
+		},
+		{
+			in: Hostname{Name: util.StrToPtr("1node")},
+		},
+		{
+			in: Hostname{Name: util.StrToPtr(strings.Repeat("a", 63))},
+		},
+		{
+			in:  Hostname{UpdateHosts: util.BoolToPtr(true)},
+			at:  path.New("", "name"),
+			out: errors.ErrHostnameRequired,
+		},
+		{
+			in:  Hostname{Name: util.StrToPtr("")},
+			at:  path.New("", "name"),
+			out: errors.ErrHostnameInvalid,
+		},
+		{
+			in:  Hostname{Name: util.StrToPtr("-node")},
+			at:  path.New("", "name"),
+			out: errors.ErrHostnameInvalid,
+		},
+		{
+			in:  Hostname{Name: util.StrToPtr("node-")},
+			at:  path.New("", "name"),
+			out: errors.ErrHostnameInvalid,
+		},
+		{
+			in:  Hostname{Name: util.StrToPtr("node_1")},
+			at:  path.New("", "name"),
+			out: errors.ErrHostnameInvalid,
+		},
+		{
+			in:  Hostname{Name: util.StrToPtr("node..example.com")},
+			at:  path.New("", "name"),
+			out: errors.ErrHostnameInvalid,
+		},
+		{
+			in:  Hostname{Name: util.StrToPtr("node.example.com.")},
+			at:  path.New("", "name"),
+			out: errors.ErrHostnameInvalid,
+		},
+		{
+			in:  Hostname{Name: util.StrToPtr(strings.Repeat("a", 64))},
+			at:  path.New("", "name"),
+			out: errors.ErrHostnameInvalid,
+		},
+		{
+			in:  Hostname{Name: util.StrToPtr(strings.Repeat("abcdefgh.", 7) + "ab")},
+			at:  path.New("", "name"),
+			out: errors.ErrHostnameInvalid,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
@@ -14,9 +14,28 @@
 
 package types
 
+import (
+	"strings"
+	"unicode"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
 func (k KernelArguments) MergedKeys() map[string]string {
 	return map[string]string{
 		"ShouldExist":    "KernelArgument",
 		"ShouldNotExist": "KernelArgument",
 	}
 }
+
+func (k KernelArgument) Validate(c path.ContextPath) (r report.Report) {
+	if k == "" {
+		r.AddOnError(c, errors.ErrKernelArgumentEmpty)
+	} else if strings.IndexFunc(string(k), unicode.IsSpace) != -1 {
+		r.AddOnError(c, errors.ErrKernelArgumentWhitespace)
+	}
+	return
+}
@@ -40,6 +40,22 @@ func TestKernelArgumentsValidate(t *testing.T) {
 			},
 			"error at $.shouldNotExist.1: duplicate entry defined\n",
 		},
+		{
+			KernelArguments{
+				ShouldExist: []KernelArgument{
+					"foo=bar baz",
+				},
+			},
+			"error at $.shouldExist.0: kernel argument cannot contain whitespace; use a separate entry for each argument\n",
+		},
+		{
+			KernelArguments{
+				ShouldNotExist: []KernelArgument{
+					"",
+				},
+			},
+			"error at $.shouldNotExist.0: kernel argument cannot be empty\n",
+		},
 	}
 
 	for i, test := range tests {
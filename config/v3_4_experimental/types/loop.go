@@ -0,0 +1,60 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"path/filepath"
+	"regexp"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+var loopDeviceRegex = regexp.MustCompile(`^/dev/loop[0-9]+$`)
+
+func (l Loop) Key() string {
+	return l.Device
+}
+
+func (l Loop) Validate(c path.ContextPath) (r report.Report) {
+	if !loopDeviceRegex.MatchString(l.Device) {
+		r.AddOnError(c.Append("device"), errors.ErrLoopDeviceInvalid)
+	}
+	if l.File == nil {
+		r.AddOnError(c.Append("file"), errors.ErrNoPath)
+	} else {
+		r.AddOnError(c.Append("file"), validatePath(*l.File))
+	}
+	if l.SizeMiB != nil && *l.SizeMiB <= 0 {
+		r.AddOnError(c.Append("sizeMiB"), errors.ErrLoopSizeInvalid)
+	}
+	return
+}
+
+// BackingFile returns the entry of files which declares l's backing file,
+// or nil if the backing file isn't declared.
+func (l Loop) BackingFile(files []File) *File {
+	if l.File == nil {
+		return nil
+	}
+	for i, f := range files {
+		if filepath.Clean(f.Path) == filepath.Clean(*l.File) {
+			return &files[i]
+		}
+	}
+	return nil
+}
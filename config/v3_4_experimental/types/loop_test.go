@@ -0,0 +1,106 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestLoopValidate(t *testing.T) {
+	tests := []struct {
+		in  Loop
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: Loop{
+				Device: "/dev/loop0",
+				File:   util.StrToPtr("/var/lib/disk.img"),
+			},
+		},
+		{
+			in: Loop{
+				Device:   "/dev/loop12",
+				File:     util.StrToPtr("/var/lib/disk.img"),
+				ReadOnly: util.BoolToPtr(true),
+				SizeMiB:  util.IntToPtr(512),
+			},
+		},
+		{
+			in: Loop{
+				Device: "/dev/sda",
+				File:   util.StrToPtr("/var/lib/disk.img"),
+			},
+			at:  path.New("", "device"),
+			out: errors.ErrLoopDeviceInvalid,
+		},
+		{
+			in: Loop{
+				Device: "/dev/loop",
+				File:   util.StrToPtr("/var/lib/disk.img"),
+			},
+			at:  path.New("", "device"),
+			out: errors.ErrLoopDeviceInvalid,
+		},
+		{
+			in: Loop{
+				Device: "/dev/loop0p1",
+				File:   util.StrToPtr("/var/lib/disk.img"),
+			},
+			at:  path.New("", "device"),
+			out: errors.ErrLoopDeviceInvalid,
+		},
+		{
+			in: Loop{
+				Device: "/dev/loop0",
+			},
+			at:  path.New("", "file"),
+			out: errors.ErrNoPath,
+		},
+		{
+			in: Loop{
+				Device: "/dev/loop0",
+				File:   util.StrToPtr("disk.img"),
+			},
+			at:  path.New("", "file"),
+			out: errors.ErrPathRelative,
+		},
+		{
+			in: Loop{
+				Device:  "/dev/loop0",
+				File:    util.StrToPtr("/var/lib/disk.img"),
+				SizeMiB: util.IntToPtr(0),
+			},
+			at:  path.New("", "sizeMiB"),
+			out: errors.ErrLoopSizeInvalid,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"regexp"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+// machineIDRegex matches the format described in machine-id(5)
+var machineIDRegex = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+func (m MachineID) Validate(c path.ContextPath) (r report.Report) {
+	if m.Value == nil {
+		return
+	}
+	if util.IsTrue(m.Clear) {
+		r.AddOnError(c.Append("value"), errors.ErrMachineIDClearAndValue)
+	}
+	if !machineIDRegex.MatchString(*m.Value) {
+		r.AddOnError(c.Append("value"), errors.ErrMachineIDInvalid)
+	}
+	return
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestMachineIDValidate(t *testing.T) {
+	tests := []struct {
+		in  MachineID
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: MachineID{},
+		},
+		{
+			in: MachineID{Clear: util.BoolToPtr(true)},
+		},
+		{
+			in: MachineID{Value: util.StrToPtr("0123456789abcdef0123456789abcdef")},
+		},
+		{
+			in: MachineID{Clear: util.BoolToPtr(false), Value: util.StrToPtr("0123456789abcdef0123456789abcdef")},
+		},
+		{
+			in:  MachineID{Clear: util.BoolToPtr(true), Value: util.StrToPtr("0123456789abcdef0123456789abcdef")},
+			at:  path.New("", "value"),
+			out: errors.ErrMachineIDClearAndValue,
+		},
+		{
+			in:  MachineID{Value: util.StrToPtr("")},
+			at:  path.New("", "value"),
+			out: errors.ErrMachineIDInvalid,
+		},
+		{
+			in:  MachineID{Value: util.StrToPtr("0123456789abcdef0123456789abcde")},
+			at:  path.New("", "value"),
+			out: errors.ErrMachineIDInvalid,
+		},
+		{
+			in:  MachineID{Value: util.StrToPtr("0123456789ABCDEF0123456789ABCDEF")},
+			at:  path.New("", "value"),
+			out: errors.ErrMachineIDInvalid,
+		},
+		{
+			in:  MachineID{Value: util.StrToPtr("0123456789abcdef0123456789abcdeg")},
+			at:  path.New("", "value"),
+			out: errors.ErrMachineIDInvalid,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
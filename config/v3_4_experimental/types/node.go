@@ -16,6 +16,7 @@ package types
 
 import (
 	"path"
+	"regexp"
 
 	"github.com/coreos/ignition/v2/config/shared/errors"
 	"github.com/coreos/ignition/v2/config/util"
@@ -24,12 +25,22 @@ import (
 	"github.com/coreos/vcontext/report"
 )
 
+var selinuxContextRegex = regexp.MustCompile(`^[A-Za-z0-9_.]+:[A-Za-z0-9_.]+:[A-Za-z0-9_.]+(:[A-Za-z0-9_.,:-]+)?$`)
+
 func (n Node) Key() string {
 	return n.Path
 }
 
+func (n Node) ReplacedLists() map[string]struct{} {
+	return map[string]struct{}{
+		"Platforms": {},
+	}
+}
+
 func (n Node) Validate(c vpath.ContextPath) (r report.Report) {
 	r.AddOnError(c.Append("path"), validatePath(n.Path))
+	r.Merge(validatePlatforms(c, n.Platforms))
+	r.AddOnError(c.Append("selinuxContext"), validateSelinuxContext(n.SelinuxContext))
 	return
 }
 
@@ -41,6 +52,13 @@ func (n Node) Depth() int {
 	return count
 }
 
+func validateSelinuxContext(ctx *string) error {
+	if ctx != nil && !selinuxContextRegex.MatchString(*ctx) {
+		return errors.ErrSelinuxContextInvalid
+	}
+	return nil
+}
+
 func validateIDorName(id *int, name *string) error {
 	if id != nil && util.NotEmpty(name) {
 		return errors.ErrBothIDAndNameSet
@@ -34,6 +34,60 @@ func TestNodeValidatePath(t *testing.T) {
 	}
 }
 
+func TestNodeValidateSelinuxContext(t *testing.T) {
+	tests := []struct {
+		in  *string
+		out error
+	}{
+		{
+			nil,
+			nil,
+		},
+		{
+			util.StrToPtr("system_u:object_r:etc_t:s0"),
+			nil,
+		},
+		{
+			util.StrToPtr("system_u:object_r:container_file_t:s0:c1,c2"),
+			nil,
+		},
+		{
+			util.StrToPtr("system_u:object_r:etc_t:s0-s0:c0.c1023"),
+			nil,
+		},
+		// level is optional without MLS
+		{
+			util.StrToPtr("system_u:object_r:etc_t"),
+			nil,
+		},
+		{
+			util.StrToPtr(""),
+			errors.ErrSelinuxContextInvalid,
+		},
+		{
+			util.StrToPtr("etc_t"),
+			errors.ErrSelinuxContextInvalid,
+		},
+		{
+			util.StrToPtr("system_u::etc_t:s0"),
+			errors.ErrSelinuxContextInvalid,
+		},
+		{
+			util.StrToPtr("system_u:object_r:etc t:s0"),
+			errors.ErrSelinuxContextInvalid,
+		},
+	}
+
+	for i, test := range tests {
+		r := Node{Path: "/a", SelinuxContext: test.in}.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(path.New("", "selinuxContext"), test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v got %v", i, test.out, r)
+		}
+	}
+}
+
 func TestNodeValidateUser(t *testing.T) {
 	tests := []struct {
 		in  NodeUser
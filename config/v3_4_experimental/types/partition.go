@@ -44,9 +44,15 @@ func (p Partition) Key() string {
 	}
 }
 
+func (p Partition) IgnoreDuplicates() map[string]struct{} {
+	return map[string]struct{}{
+		"Attributes": {},
+	}
+}
+
 func (p Partition) Validate(c path.ContextPath) (r report.Report) {
 	if util.IsFalse(p.ShouldExist) &&
-		(p.Label != nil || util.NotEmpty(p.TypeGUID) || util.NotEmpty(p.GUID) || p.StartMiB != nil || p.SizeMiB != nil) {
+		(p.Label != nil || util.NotEmpty(p.TypeGUID) || util.NotEmpty(p.GUID) || p.StartMiB != nil || p.SizeMiB != nil || util.IsTrue(p.Resize) || len(p.Attributes) > 0) {
 		r.AddOnError(c, errors.ErrShouldNotExistWithOthers)
 	}
 	if p.Number == 0 && p.Label == nil {
@@ -79,6 +85,14 @@ func (p Partition) validateLabel() error {
 	return nil
 }
 
+func (a PartitionAttribute) Validate(c path.ContextPath) (r report.Report) {
+	// GPT partition entries have a 64-bit attribute field
+	if a < 0 || a > 63 {
+		r.AddOnError(c, errors.ErrPartitionAttributeRange)
+	}
+	return
+}
+
 func validateGUID(guidPointer *string) error {
 	if guidPointer == nil {
 		return nil
@@ -19,8 +19,52 @@ import (
 
 	"github.com/coreos/ignition/v2/config/shared/errors"
 	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
 )
 
+func TestPartitionValidateShouldExist(t *testing.T) {
+	tests := []struct {
+		in  Partition
+		out error
+	}{
+		{
+			Partition{Number: 1, ShouldExist: util.BoolToPtr(false)},
+			nil,
+		},
+		{
+			Partition{Number: 1, Resize: util.BoolToPtr(true)},
+			nil,
+		},
+		{
+			Partition{Number: 1, ShouldExist: util.BoolToPtr(false), Resize: util.BoolToPtr(false)},
+			nil,
+		},
+		{
+			Partition{Number: 1, ShouldExist: util.BoolToPtr(false), Resize: util.BoolToPtr(true)},
+			errors.ErrShouldNotExistWithOthers,
+		},
+		{
+			Partition{Number: 1, ShouldExist: util.BoolToPtr(false), SizeMiB: util.IntToPtr(10)},
+			errors.ErrShouldNotExistWithOthers,
+		},
+		{
+			Partition{Number: 1, ShouldExist: util.BoolToPtr(false), Attributes: []PartitionAttribute{2}},
+			errors.ErrShouldNotExistWithOthers,
+		},
+	}
+
+	for i, test := range tests {
+		var expected report.Report
+		expected.AddOnError(path.New("test"), test.out)
+		r := test.in.Validate(path.New("test"))
+		if expected.String() != r.String() {
+			t.Errorf("#%d: bad report: want %q, got %q", i, expected.String(), r.String())
+		}
+	}
+}
+
 func TestValidateLabel(t *testing.T) {
 	tests := []struct {
 		in  *string
@@ -92,3 +136,41 @@ func TestValidateGUID(t *testing.T) {
 		}
 	}
 }
+
+func TestPartitionAttributeValidate(t *testing.T) {
+	tests := []struct {
+		in  PartitionAttribute
+		out error
+	}{
+		{
+			0,
+			nil,
+		},
+		// legacy BIOS bootable
+		{
+			2,
+			nil,
+		},
+		{
+			63,
+			nil,
+		},
+		{
+			64,
+			errors.ErrPartitionAttributeRange,
+		},
+		{
+			-1,
+			errors.ErrPartitionAttributeRange,
+		},
+	}
+
+	for i, test := range tests {
+		var expected report.Report
+		expected.AddOnError(path.New("test"), test.out)
+		r := test.in.Validate(path.New("test"))
+		if expected.String() != r.String() {
+			t.Errorf("#%d: bad report: want %q, got %q", i, expected.String(), r.String())
+		}
+	}
+}
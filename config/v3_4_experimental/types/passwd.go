@@ -14,10 +14,145 @@
 
 package types
 
+import (
+	"regexp"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+var (
+	// traditional DES crypt: a 2 character salt and 11 character hash
+	desCryptRegex = regexp.MustCompile(`^[./0-9A-Za-z]{13}$`)
+	// the $id$ prefix of modular crypt formats
+	modularCryptRegex = regexp.MustCompile(`^\$([0-9a-z]+)\$[^$]`)
+)
+
 func (p PasswdUser) Key() string {
 	return p.Name
 }
 
+func (p PasswdUser) ReplacedLists() map[string]struct{} {
+	return map[string]struct{}{
+		"Platforms": {},
+	}
+}
+
+func (p PasswdUser) Validate(c path.ContextPath) (r report.Report) {
+	if util.IsTrue(p.MustExist) && util.IsFalse(p.ShouldExist) {
+		r.AddOnError(c.Append("mustExist"), errors.ErrMustExistWithShouldExist)
+	}
+	r.AddOnError(c.Append("passwordHash"), validatePasswordHash(p.PasswordHash))
+	r.AddOnWarn(c.Append("passwordHash"), warnPasswordHash(p.PasswordHash))
+	if p.PasswordMaxDays != nil && *p.PasswordMaxDays < -1 {
+		r.AddOnError(c.Append("passwordMaxDays"), errors.ErrPasswordMaxDaysInvalid)
+	}
+	if util.IsTrue(p.Locked) && util.IsTrue(p.ForcePasswordChange) {
+		r.AddOnError(c.Append("forcePasswordChange"), errors.ErrLockedForcePasswordChange)
+	}
+	r.AddOnError(c.Append("skeletonDir"), validatePathNilOK(p.SkeletonDir))
+	r.AddOnError(c.Append("skeletonDir"), p.validateSkeleton())
+	r.Merge(validatePlatforms(c, p.Platforms))
+	for i, g := range p.RemoveFromGroups {
+		r.AddOnError(c.Append("removeFromGroups", i), p.validateRemovedGroup(g))
+	}
+	for i, src := range p.SSHAuthorizedKeysSources {
+		r.AddOnError(c.Append("sshAuthorizedKeysSources", i), src.validateRequiredSource())
+	}
+	return
+}
+
+func (g PasswdGroup) Validate(c path.ContextPath) (r report.Report) {
+	r.AddOnError(c.Append("passwordHash"), validatePasswordHash(g.PasswordHash))
+	r.AddOnWarn(c.Append("passwordHash"), warnPasswordHash(g.PasswordHash))
+	r.Merge(validatePlatforms(c, g.Platforms))
+	return
+}
+
+// validateSkeleton checks that the skeleton options are only set when the
+// home directory is created, and don't conflict with each other.
+func (p PasswdUser) validateSkeleton() error {
+	if p.SkeletonDir == nil && !util.IsTrue(p.NoCopySkeleton) {
+		return nil
+	}
+	if util.IsTrue(p.NoCreateHome) {
+		return errors.ErrSkeletonNoCreateHome
+	}
+	if p.SkeletonDir != nil && util.IsTrue(p.NoCopySkeleton) {
+		return errors.ErrSkeletonDirAndNoCopy
+	}
+	return nil
+}
+
+// validateRemovedGroup checks that the user isn't both added to and removed
+// from g. Primary groups can't be removed, since they aren't memberships.
+// Groups are compared as given, so a group listed by name in one list and
+// by GID in the other is only caught when the files stage resolves it.
+func (p PasswdUser) validateRemovedGroup(g Group) error {
+	if util.NotEmpty(p.PrimaryGroup) && *p.PrimaryGroup == string(g) {
+		return errors.ErrRemovedGroupConflict
+	}
+	for _, added := range p.Groups {
+		if added == g {
+			return errors.ErrRemovedGroupConflict
+		}
+	}
+	return nil
+}
+
+// passwordHashScheme returns the crypt(3) scheme of the hash, "des" for
+// traditional DES crypt, or "" if the hash isn't in crypt(3) format. Locked
+// hashes ("*", or prefixed with "!") report the scheme of the locked hash.
+func passwordHashScheme(hash string) string {
+	hash = strings.TrimLeft(hash, "!")
+	switch {
+	case hash == "" || hash == "*":
+		return "locked"
+	case desCryptRegex.MatchString(hash):
+		return "des"
+	}
+	if m := modularCryptRegex.FindStringSubmatch(hash); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+func validatePasswordHash(hash *string) error {
+	// empty hashes are replaced with "*" when applied
+	if hash == nil || *hash == "" {
+		return nil
+	}
+	if passwordHashScheme(*hash) == "" {
+		return errors.ErrPasswordHashPlaintext
+	}
+	return nil
+}
+
+// warnPasswordHash warns about hashes which many systems refuse to
+// authenticate against, since they'd silently lock the account.
+func warnPasswordHash(hash *string) error {
+	if hash == nil {
+		return nil
+	}
+	switch passwordHashScheme(*hash) {
+	case "", "locked":
+	case "y", "gy", "7", "6", "5", "2a", "2b", "2y":
+	default:
+		return errors.ErrPasswordHashWeak
+	}
+	return nil
+}
+
 func (g PasswdGroup) Key() string {
 	return g.Name
 }
+
+func (g PasswdGroup) ReplacedLists() map[string]struct{} {
+	return map[string]struct{}{
+		"Platforms": {},
+	}
+}
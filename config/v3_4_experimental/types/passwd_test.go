@@ -0,0 +1,214 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestPasswdUserValidate(t *testing.T) {
+	tests := []struct {
+		in  PasswdUser
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in:  PasswdUser{Name: "core"},
+			out: nil,
+		},
+		{
+			in: PasswdUser{
+				Name: "core",
+				SSHAuthorizedKeysSources: []Resource{
+					{Source: util.StrToPtr("https://example.com/keys")},
+				},
+			},
+			out: nil,
+		},
+		{
+			in: PasswdUser{
+				Name: "core",
+				SSHAuthorizedKeysSources: []Resource{
+					{Source: util.StrToPtr("https://example.com/keys")},
+					{},
+				},
+			},
+			at:  path.New("", "sshAuthorizedKeysSources", 1),
+			out: errors.ErrSourceRequired,
+		},
+		{
+			in: PasswdUser{
+				Name:      "core",
+				MustExist: util.BoolToPtr(true),
+			},
+			out: nil,
+		},
+		{
+			in: PasswdUser{
+				Name:        "core",
+				MustExist:   util.BoolToPtr(true),
+				ShouldExist: util.BoolToPtr(false),
+			},
+			at:  path.New("", "mustExist"),
+			out: errors.ErrMustExistWithShouldExist,
+		},
+		{
+			in: PasswdUser{
+				Name:             "core",
+				Groups:           []Group{"docker"},
+				RemoveFromGroups: []Group{"wheel", "10"},
+			},
+			out: nil,
+		},
+		{
+			in: PasswdUser{
+				Name:             "core",
+				Groups:           []Group{"docker", "wheel"},
+				RemoveFromGroups: []Group{"adm", "wheel"},
+			},
+			at:  path.New("", "removeFromGroups", 1),
+			out: errors.ErrRemovedGroupConflict,
+		},
+		{
+			in: PasswdUser{
+				Name:             "core",
+				PrimaryGroup:     util.StrToPtr("core"),
+				RemoveFromGroups: []Group{"core"},
+			},
+			at:  path.New("", "removeFromGroups", 0),
+			out: errors.ErrRemovedGroupConflict,
+		},
+		{
+			in: PasswdUser{
+				Name:        "core",
+				SkeletonDir: util.StrToPtr("/etc/skel.core"),
+			},
+			out: nil,
+		},
+		{
+			in: PasswdUser{
+				Name:           "core",
+				NoCopySkeleton: util.BoolToPtr(true),
+			},
+			out: nil,
+		},
+		{
+			in: PasswdUser{
+				Name:        "core",
+				SkeletonDir: util.StrToPtr("etc/skel.core"),
+			},
+			at:  path.New("", "skeletonDir"),
+			out: errors.ErrPathRelative,
+		},
+		{
+			in: PasswdUser{
+				Name:           "core",
+				SkeletonDir:    util.StrToPtr("/etc/skel.core"),
+				NoCopySkeleton: util.BoolToPtr(true),
+			},
+			at:  path.New("", "skeletonDir"),
+			out: errors.ErrSkeletonDirAndNoCopy,
+		},
+		{
+			in: PasswdUser{
+				Name:           "core",
+				NoCopySkeleton: util.BoolToPtr(true),
+				NoCreateHome:   util.BoolToPtr(true),
+			},
+			at:  path.New("", "skeletonDir"),
+			out: errors.ErrSkeletonNoCreateHome,
+		},
+		{
+			in: PasswdUser{
+				Name:                "core",
+				Locked:              util.BoolToPtr(true),
+				PasswordMaxDays:     util.IntToPtr(-1),
+				ForcePasswordChange: util.BoolToPtr(false),
+			},
+			out: nil,
+		},
+		{
+			in: PasswdUser{
+				Name:            "core",
+				PasswordMaxDays: util.IntToPtr(-2),
+			},
+			at:  path.New("", "passwordMaxDays"),
+			out: errors.ErrPasswordMaxDaysInvalid,
+		},
+		{
+			in: PasswdUser{
+				Name:                "core",
+				Locked:              util.BoolToPtr(true),
+				ForcePasswordChange: util.BoolToPtr(true),
+			},
+			at:  path.New("", "forcePasswordChange"),
+			out: errors.ErrLockedForcePasswordChange,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
+
+func TestPasswdUserValidatePasswordHash(t *testing.T) {
+	tests := []struct {
+		in   *string
+		err  error
+		warn error
+	}{
+		{in: nil},
+		{in: util.StrToPtr("")},
+		{in: util.StrToPtr("*")},
+		{in: util.StrToPtr("!")},
+		// sha512crypt
+		{in: util.StrToPtr("$6$rounds=4096$saltsalt$Jx7VOB6FSZQOHGB1bYxxGeBpzNl6y1bkk4X8EOG6mm8iTChHJb9XGe0ORydl8EoNzBzKt2Ra9nF7SyqwRRRO/.")},
+		// yescrypt
+		{in: util.StrToPtr("$y$j9T$F5Jx5fExrKuPp53xLKQ..1$X3DX6M94c7o.9agCG9G317fhZg9SqC.5i5rd.RhAtQ7")},
+		// locked sha512crypt
+		{in: util.StrToPtr("!$6$saltsalt$Jx7VOB6FSZQOHGB1bYxxGeBpzNl6y1bkk4X8EOG6mm8iTChHJb9XGe0ORydl8EoNzBzKt2Ra9nF7SyqwRRRO/.")},
+		// md5crypt
+		{in: util.StrToPtr("$1$saltsalt$qjXMvbEw8oaL.CzflDugX/"), warn: errors.ErrPasswordHashWeak},
+		// DES crypt
+		{in: util.StrToPtr("zJW/EKqqIk44o"), warn: errors.ErrPasswordHashWeak},
+		// unknown scheme
+		{in: util.StrToPtr("$foo$bar"), warn: errors.ErrPasswordHashWeak},
+		// plaintext
+		{in: util.StrToPtr("hunter2"), err: errors.ErrPasswordHashPlaintext},
+		{in: util.StrToPtr("$superSecretPasswordHash."), err: errors.ErrPasswordHashPlaintext},
+	}
+
+	for i, test := range tests {
+		r := PasswdUser{Name: "core", PasswordHash: test.in}.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(path.New("", "passwordHash"), test.err)
+		expected.AddOnWarn(path.New("", "passwordHash"), test.warn)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
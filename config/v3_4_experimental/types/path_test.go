@@ -46,6 +46,23 @@ func TestPathValidate(t *testing.T) {
 			"relative/path",
 			errors.ErrPathRelative,
 		},
+		// traversal out of the root
+		{
+			"../etc/passwd",
+			errors.ErrPathRelative,
+		},
+		{
+			"/../etc/passwd",
+			errors.ErrDirtyPath,
+		},
+		{
+			"/etc/../../etc/passwd",
+			errors.ErrDirtyPath,
+		},
+		{
+			"/etc/..",
+			errors.ErrDirtyPath,
+		},
 	}
 
 	for i, test := range tests {
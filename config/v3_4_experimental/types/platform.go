@@ -0,0 +1,79 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"github.com/coreos/ignition/v2/config/shared/errors"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+// KnownPlatforms lists the platforms which entries can be restricted to.
+// It must be kept in sync with the platforms Ignition supports.
+var KnownPlatforms = []string{
+	"aliyun",
+	"aws",
+	"azure",
+	"azurestack",
+	"brightbox",
+	"cloudstack",
+	"digitalocean",
+	"exoscale",
+	"file",
+	"gcp",
+	"ibmcloud",
+	"metal",
+	"nutanix",
+	"openstack",
+	"packet",
+	"powervs",
+	"qemu",
+	"virtualbox",
+	"vmware",
+	"vultr",
+	"zvm",
+}
+
+// AppliesToPlatform returns whether an entry restricted to platforms should
+// be applied on the named platform. Entries without any platforms apply
+// everywhere.
+func AppliesToPlatform(platforms []string, name string) bool {
+	if len(platforms) == 0 {
+		return true
+	}
+	for _, p := range platforms {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+func validatePlatforms(c path.ContextPath, platforms []string) (r report.Report) {
+	for i, p := range platforms {
+		r.AddOnError(c.Append("platforms", i), validatePlatform(p))
+	}
+	return
+}
+
+func validatePlatform(name string) error {
+	for _, p := range KnownPlatforms {
+		if p == name {
+			return nil
+		}
+	}
+	return errors.ErrUnknownPlatform
+}
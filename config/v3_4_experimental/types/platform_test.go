@@ -0,0 +1,77 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestValidatePlatforms(t *testing.T) {
+	tests := []struct {
+		in  []string
+		out report.Report
+	}{
+		{
+			in:  nil,
+			out: report.Report{},
+		},
+		{
+			in:  []string{"aws", "gcp"},
+			out: report.Report{},
+		},
+		{
+			in: []string{"aws", "amazon"},
+			out: func() report.Report {
+				r := report.Report{}
+				r.AddOnError(path.New("", "platforms", 1), errors.ErrUnknownPlatform)
+				return r
+			}(),
+		},
+	}
+
+	for i, test := range tests {
+		r := validatePlatforms(path.ContextPath{}, test.in)
+		if !reflect.DeepEqual(test.out, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out, r)
+		}
+	}
+}
+
+func TestAppliesToPlatform(t *testing.T) {
+	tests := []struct {
+		platforms []string
+		name      string
+		out       bool
+	}{
+		{nil, "aws", true},
+		{[]string{}, "metal", true},
+		{[]string{"aws"}, "aws", true},
+		{[]string{"gcp", "aws"}, "aws", true},
+		{[]string{"gcp"}, "aws", false},
+	}
+
+	for i, test := range tests {
+		out := AppliesToPlatform(test.platforms, test.name)
+		if out != test.out {
+			t.Errorf("#%d: bad result: want %v, got %v", i, test.out, out)
+		}
+	}
+}
@@ -15,6 +15,8 @@
 package types
 
 import (
+	"strings"
+
 	"github.com/coreos/ignition/v2/config/shared/errors"
 	"github.com/coreos/ignition/v2/config/util"
 
@@ -37,9 +39,37 @@ func (ra Raid) Validate(c path.ContextPath) (r report.Report) {
 	if len(ra.Devices) == 0 {
 		r.AddOnError(c.Append("devices"), errors.ErrRaidDevicesRequired)
 	}
+	for i, o := range ra.Options {
+		r.AddOnError(c.Append("options", i), validateRaidOption(o))
+	}
 	return
 }
 
+// raidComputedOptions are the mdadm options Ignition derives from the level,
+// devices, and spares fields. They map short options to their long forms.
+var raidComputedOptions = map[string][]string{
+	"-l": {"--level"},
+	"-n": {"--raid-devices", "--raid-disks"},
+	"-x": {"--spare-devices", "--spare-disks"},
+}
+
+func validateRaidOption(o RaidOption) error {
+	opt := string(o)
+	// mdadm accepts any unambiguous abbreviation of a long option
+	name := strings.SplitN(opt, "=", 2)[0]
+	for short, longs := range raidComputedOptions {
+		if strings.HasPrefix(opt, short) {
+			return errors.ErrRaidOptionConflict
+		}
+		for _, long := range longs {
+			if len(name) > len("--") && strings.HasPrefix(long, name) {
+				return errors.ErrRaidOptionConflict
+			}
+		}
+	}
+	return nil
+}
+
 func (r Raid) validateLevel() error {
 	if util.NilOrEmpty(r.Level) {
 		return errors.ErrRaidLevelRequired
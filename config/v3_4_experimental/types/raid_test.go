@@ -75,6 +75,96 @@ func TestRaidValidate(t *testing.T) {
 			at:  path.New("", "devices"),
 			out: errors.ErrRaidDevicesRequired,
 		},
+		{
+			in: Raid{
+				Name:    "name",
+				Level:   util.StrToPtr("raid1"),
+				Devices: []Device{"/dev/fd0", "/dev/fd1"},
+				Options: []RaidOption{"--chunk=64", "--bitmap", "internal", "--layout=n2"},
+			},
+			out: nil,
+		},
+		{
+			in: Raid{
+				Name:    "name",
+				Level:   util.StrToPtr("raid1"),
+				Devices: []Device{"/dev/fd0", "/dev/fd1"},
+				Options: []RaidOption{"--chunk=64", "--raid-devices=3"},
+			},
+			at:  path.New("", "options", 1),
+			out: errors.ErrRaidOptionConflict,
+		},
+		{
+			in: Raid{
+				Name:    "name",
+				Level:   util.StrToPtr("raid1"),
+				Devices: []Device{"/dev/fd0", "/dev/fd1"},
+				Options: []RaidOption{"-x1"},
+			},
+			at:  path.New("", "options", 0),
+			out: errors.ErrRaidOptionConflict,
+		},
+		{
+			in: Raid{
+				Name:    "name",
+				Level:   util.StrToPtr("raid1"),
+				Devices: []Device{"/dev/fd0", "/dev/fd1"},
+				Options: []RaidOption{"--level"},
+			},
+			at:  path.New("", "options", 0),
+			out: errors.ErrRaidOptionConflict,
+		},
+		// aliases, short forms, and abbreviations
+		{
+			in: Raid{
+				Name:    "name",
+				Level:   util.StrToPtr("raid1"),
+				Devices: []Device{"/dev/fd0", "/dev/fd1"},
+				Options: []RaidOption{"--raid-disks=3"},
+			},
+			at:  path.New("", "options", 0),
+			out: errors.ErrRaidOptionConflict,
+		},
+		{
+			in: Raid{
+				Name:    "name",
+				Level:   util.StrToPtr("raid1"),
+				Devices: []Device{"/dev/fd0", "/dev/fd1"},
+				Options: []RaidOption{"--spare-disks", "1"},
+			},
+			at:  path.New("", "options", 0),
+			out: errors.ErrRaidOptionConflict,
+		},
+		{
+			in: Raid{
+				Name:    "name",
+				Level:   util.StrToPtr("raid1"),
+				Devices: []Device{"/dev/fd0", "/dev/fd1"},
+				Options: []RaidOption{"--spare-devices=1"},
+			},
+			at:  path.New("", "options", 0),
+			out: errors.ErrRaidOptionConflict,
+		},
+		{
+			in: Raid{
+				Name:    "name",
+				Level:   util.StrToPtr("raid1"),
+				Devices: []Device{"/dev/fd0", "/dev/fd1"},
+				Options: []RaidOption{"-n", "3"},
+			},
+			at:  path.New("", "options", 0),
+			out: errors.ErrRaidOptionConflict,
+		},
+		{
+			in: Raid{
+				Name:    "name",
+				Level:   util.StrToPtr("raid1"),
+				Devices: []Device{"/dev/fd0", "/dev/fd1"},
+				Options: []RaidOption{"--raid-dev=3"},
+			},
+			at:  path.New("", "options", 0),
+			out: errors.ErrRaidOptionConflict,
+		},
 	}
 
 	for i, test := range tests {
@@ -0,0 +1,86 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/validate"
+)
+
+func TestResourceValidateHTTPHeaders(t *testing.T) {
+	tests := []struct {
+		in  Resource
+		out string
+	}{
+		{
+			Resource{
+				Source: util.StrToPtr("https://example.com/file"),
+				HTTPHeaders: HTTPHeaders{
+					{Name: "Authorization", Value: util.StrToPtr("Basic dXNlcjpwYXNz")},
+					{Name: "X-Custom", Value: util.StrToPtr("value")},
+				},
+			},
+			"",
+		},
+		{
+			Resource{
+				Source: util.StrToPtr("https://example.com/file"),
+				HTTPHeaders: HTTPHeaders{
+					{Name: "Authorization", Value: util.StrToPtr("one")},
+					{Name: "Authorization", Value: util.StrToPtr("two")},
+				},
+			},
+			"error at $.httpHeaders.1: duplicate entry defined\n",
+		},
+		// header names are case-insensitive
+		{
+			Resource{
+				Source: util.StrToPtr("https://example.com/file"),
+				HTTPHeaders: HTTPHeaders{
+					{Name: "Authorization", Value: util.StrToPtr("one")},
+					{Name: "authorization", Value: util.StrToPtr("two")},
+				},
+			},
+			"error at $.httpHeaders.1: duplicate entry defined\n",
+		},
+		{
+			Resource{
+				Source: util.StrToPtr("https://example.com/file"),
+				HTTPHeaders: HTTPHeaders{
+					{Value: util.StrToPtr("value")},
+				},
+			},
+			"error at $.httpHeaders.0.name: HTTP header name can't be empty\n",
+		},
+		{
+			Resource{
+				Source: util.StrToPtr("data:,hello"),
+				HTTPHeaders: HTTPHeaders{
+					{Name: "X-Custom", Value: util.StrToPtr("value")},
+				},
+			},
+			"error at $.httpHeaders: cannot use HTTP headers with this source scheme\n",
+		},
+	}
+
+	for i, test := range tests {
+		r := validate.ValidateWithContext(test.in, nil)
+		if test.out != r.String() {
+			t.Errorf("#%d: bad error: want %q, got %q", i, test.out, r.String())
+		}
+	}
+}
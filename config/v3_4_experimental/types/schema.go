@@ -15,9 +15,19 @@ type ClevisCustom struct {
 	Pin          *string `json:"pin,omitempty"`
 }
 
+type Command struct {
+	Args    []string `json:"args,omitempty"`
+	Name    string   `json:"name"`
+	Path    *string  `json:"path,omitempty"`
+	Timeout *int     `json:"timeout,omitempty"`
+}
+
 type Config struct {
+	Commands        []Command       `json:"commands,omitempty"`
+	Hostname        Hostname        `json:"hostname,omitempty"`
 	Ignition        Ignition        `json:"ignition"`
 	KernelArguments KernelArguments `json:"kernelArguments,omitempty"`
+	MachineID       MachineID       `json:"machineId,omitempty"`
 	Passwd          Passwd          `json:"passwd,omitempty"`
 	Storage         Storage         `json:"storage,omitempty"`
 	Systemd         Systemd         `json:"systemd,omitempty"`
@@ -31,7 +41,8 @@ type Directory struct {
 }
 
 type DirectoryEmbedded1 struct {
-	Mode *int `json:"mode,omitempty"`
+	Mode      *int  `json:"mode,omitempty"`
+	Recursive *bool `json:"recursive,omitempty"`
 }
 
 type Disk struct {
@@ -51,20 +62,36 @@ type File struct {
 }
 
 type FileEmbedded1 struct {
-	Append   []Resource `json:"append,omitempty"`
-	Contents Resource   `json:"contents,omitempty"`
-	Mode     *int       `json:"mode,omitempty"`
+	Append      []Resource  `json:"append,omitempty"`
+	Contents    Resource    `json:"contents,omitempty"`
+	Flags       []FileFlag  `json:"flags,omitempty"`
+	Mode        *int        `json:"mode,omitempty"`
+	Preallocate *bool       `json:"preallocate,omitempty"`
+	Target      *string     `json:"target,omitempty"`
+	Template    *bool       `json:"template,omitempty"`
+	Xattrs      []FileXattr `json:"xattrs,omitempty"`
+}
+
+type FileFlag string
+
+type FileXattr struct {
+	Name  string  `json:"name"`
+	Value *string `json:"value,omitempty"`
 }
 
 type Filesystem struct {
 	Device         string             `json:"device"`
 	Format         *string            `json:"format,omitempty"`
+	Fsck           *bool              `json:"fsck,omitempty"`
 	Label          *string            `json:"label,omitempty"`
 	MountOptions   []MountOption      `json:"mountOptions,omitempty"`
 	Options        []FilesystemOption `json:"options,omitempty"`
 	Path           *string            `json:"path,omitempty"`
+	Resize         *bool              `json:"resize,omitempty"`
+	Subvolumes     []Subvolume        `json:"subvolumes,omitempty"`
 	UUID           *string            `json:"uuid,omitempty"`
 	WipeFilesystem *bool              `json:"wipeFilesystem,omitempty"`
+	WithMountUnit  *bool              `json:"withMountUnit,omitempty"`
 }
 
 type FilesystemOption string
@@ -78,6 +105,11 @@ type HTTPHeader struct {
 
 type HTTPHeaders []HTTPHeader
 
+type Hostname struct {
+	Name        *string `json:"name,omitempty"`
+	UpdateHosts *bool   `json:"updateHosts,omitempty"`
+}
+
 type Ignition struct {
 	Config   IgnitionConfig `json:"config,omitempty"`
 	Proxy    Proxy          `json:"proxy,omitempty"`
@@ -108,6 +140,13 @@ type LinkEmbedded1 struct {
 	Target *string `json:"target,omitempty"`
 }
 
+type Loop struct {
+	Device   string  `json:"device"`
+	File     *string `json:"file,omitempty"`
+	ReadOnly *bool   `json:"readOnly,omitempty"`
+	SizeMiB  *int    `json:"sizeMiB,omitempty"`
+}
+
 type Luks struct {
 	Clevis     Clevis       `json:"clevis,omitempty"`
 	Device     *string      `json:"device,omitempty"`
@@ -121,15 +160,22 @@ type Luks struct {
 
 type LuksOption string
 
+type MachineID struct {
+	Clear *bool   `json:"clear,omitempty"`
+	Value *string `json:"value,omitempty"`
+}
+
 type MountOption string
 
 type NoProxyItem string
 
 type Node struct {
-	Group     NodeGroup `json:"group,omitempty"`
-	Overwrite *bool     `json:"overwrite,omitempty"`
-	Path      string    `json:"path"`
-	User      NodeUser  `json:"user,omitempty"`
+	Group          NodeGroup `json:"group,omitempty"`
+	Overwrite      *bool     `json:"overwrite,omitempty"`
+	Path           string    `json:"path"`
+	Platforms      []string  `json:"platforms,omitempty"`
+	SelinuxContext *string   `json:"selinuxContext,omitempty"`
+	User           NodeUser  `json:"user,omitempty"`
 }
 
 type NodeGroup struct {
@@ -143,45 +189,58 @@ type NodeUser struct {
 }
 
 type Partition struct {
-	GUID               *string `json:"guid,omitempty"`
-	Label              *string `json:"label,omitempty"`
-	Number             int     `json:"number,omitempty"`
-	Resize             *bool   `json:"resize,omitempty"`
-	ShouldExist        *bool   `json:"shouldExist,omitempty"`
-	SizeMiB            *int    `json:"sizeMiB,omitempty"`
-	StartMiB           *int    `json:"startMiB,omitempty"`
-	TypeGUID           *string `json:"typeGuid,omitempty"`
-	WipePartitionEntry *bool   `json:"wipePartitionEntry,omitempty"`
+	Attributes         []PartitionAttribute `json:"attributes,omitempty"`
+	GUID               *string              `json:"guid,omitempty"`
+	Label              *string              `json:"label,omitempty"`
+	Number             int                  `json:"number,omitempty"`
+	Resize             *bool                `json:"resize,omitempty"`
+	ShouldExist        *bool                `json:"shouldExist,omitempty"`
+	SizeMiB            *int                 `json:"sizeMiB,omitempty"`
+	StartMiB           *int                 `json:"startMiB,omitempty"`
+	TypeGUID           *string              `json:"typeGuid,omitempty"`
+	WipePartitionEntry *bool                `json:"wipePartitionEntry,omitempty"`
 }
 
+type PartitionAttribute int
+
 type Passwd struct {
 	Groups []PasswdGroup `json:"groups,omitempty"`
 	Users  []PasswdUser  `json:"users,omitempty"`
 }
 
 type PasswdGroup struct {
-	Gid          *int    `json:"gid,omitempty"`
-	Name         string  `json:"name"`
-	PasswordHash *string `json:"passwordHash,omitempty"`
-	ShouldExist  *bool   `json:"shouldExist,omitempty"`
-	System       *bool   `json:"system,omitempty"`
+	Gid          *int     `json:"gid,omitempty"`
+	Name         string   `json:"name"`
+	PasswordHash *string  `json:"passwordHash,omitempty"`
+	Platforms    []string `json:"platforms,omitempty"`
+	ShouldExist  *bool    `json:"shouldExist,omitempty"`
+	System       *bool    `json:"system,omitempty"`
 }
 
 type PasswdUser struct {
-	Gecos             *string            `json:"gecos,omitempty"`
-	Groups            []Group            `json:"groups,omitempty"`
-	HomeDir           *string            `json:"homeDir,omitempty"`
-	Name              string             `json:"name"`
-	NoCreateHome      *bool              `json:"noCreateHome,omitempty"`
-	NoLogInit         *bool              `json:"noLogInit,omitempty"`
-	NoUserGroup       *bool              `json:"noUserGroup,omitempty"`
-	PasswordHash      *string            `json:"passwordHash,omitempty"`
-	PrimaryGroup      *string            `json:"primaryGroup,omitempty"`
-	SSHAuthorizedKeys []SSHAuthorizedKey `json:"sshAuthorizedKeys,omitempty"`
-	Shell             *string            `json:"shell,omitempty"`
-	ShouldExist       *bool              `json:"shouldExist,omitempty"`
-	System            *bool              `json:"system,omitempty"`
-	UID               *int               `json:"uid,omitempty"`
+	ForcePasswordChange      *bool              `json:"forcePasswordChange,omitempty"`
+	Gecos                    *string            `json:"gecos,omitempty"`
+	Groups                   []Group            `json:"groups,omitempty"`
+	HomeDir                  *string            `json:"homeDir,omitempty"`
+	Locked                   *bool              `json:"locked,omitempty"`
+	MustExist                *bool              `json:"mustExist,omitempty"`
+	Name                     string             `json:"name"`
+	NoCopySkeleton           *bool              `json:"noCopySkeleton,omitempty"`
+	NoCreateHome             *bool              `json:"noCreateHome,omitempty"`
+	NoLogInit                *bool              `json:"noLogInit,omitempty"`
+	NoUserGroup              *bool              `json:"noUserGroup,omitempty"`
+	PasswordHash             *string            `json:"passwordHash,omitempty"`
+	PasswordMaxDays          *int               `json:"passwordMaxDays,omitempty"`
+	Platforms                []string           `json:"platforms,omitempty"`
+	PrimaryGroup             *string            `json:"primaryGroup,omitempty"`
+	RemoveFromGroups         []Group            `json:"removeFromGroups,omitempty"`
+	SSHAuthorizedKeys        []SSHAuthorizedKey `json:"sshAuthorizedKeys,omitempty"`
+	SSHAuthorizedKeysSources []Resource         `json:"sshAuthorizedKeysSources,omitempty"`
+	Shell                    *string            `json:"shell,omitempty"`
+	ShouldExist              *bool              `json:"shouldExist,omitempty"`
+	SkeletonDir              *string            `json:"skeletonDir,omitempty"`
+	System                   *bool              `json:"system,omitempty"`
+	UID                      *int               `json:"uid,omitempty"`
 }
 
 type Proxy struct {
@@ -219,10 +278,13 @@ type Storage struct {
 	Files       []File       `json:"files,omitempty"`
 	Filesystems []Filesystem `json:"filesystems,omitempty"`
 	Links       []Link       `json:"links,omitempty"`
+	Loops       []Loop       `json:"loops,omitempty"`
 	Luks        []Luks       `json:"luks,omitempty"`
 	Raid        []Raid       `json:"raid,omitempty"`
 }
 
+type Subvolume string
+
 type Systemd struct {
 	Units []Unit `json:"units,omitempty"`
 }
@@ -237,18 +299,24 @@ type Tang struct {
 }
 
 type Timeouts struct {
+	HTTPMaxAttempts     *int `json:"httpMaxAttempts,omitempty"`
 	HTTPResponseHeaders *int `json:"httpResponseHeaders,omitempty"`
+	HTTPRetryDelay      *int `json:"httpRetryDelay,omitempty"`
 	HTTPTotal           *int `json:"httpTotal,omitempty"`
 }
 
 type Unit struct {
-	Contents *string  `json:"contents,omitempty"`
-	Dropins  []Dropin `json:"dropins,omitempty"`
-	Enabled  *bool    `json:"enabled,omitempty"`
-	Mask     *bool    `json:"mask,omitempty"`
-	Name     string   `json:"name"`
+	Contents   *string  `json:"contents,omitempty"`
+	Dropins    []Dropin `json:"dropins,omitempty"`
+	Enabled    *bool    `json:"enabled,omitempty"`
+	Mask       *bool    `json:"mask,omitempty"`
+	Name       string   `json:"name"`
+	Platforms  []string `json:"platforms,omitempty"`
+	RequiredBy []string `json:"requiredBy,omitempty"`
+	WantedBy   []string `json:"wantedBy,omitempty"`
 }
 
 type Verification struct {
-	Hash *string `json:"hash,omitempty"`
+	Hash   *string  `json:"hash,omitempty"`
+	Hashes []string `json:"hashes,omitempty"`
 }
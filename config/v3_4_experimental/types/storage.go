@@ -47,6 +47,19 @@ func (s Storage) Validate(c vpath.ContextPath) (r report.Report) {
 				r.AddOnError(c.Append("files", i), errors.ErrFileUsedSymlink)
 			}
 		}
+		if !f.TargetsInitramfs() {
+			continue
+		}
+		// filesystems are mounted within the sysroot, so in the
+		// initramfs the path would be on a different filesystem
+		for _, fs := range s.Filesystems {
+			if util.NilOrEmpty(fs.Path) || *fs.Path == "/" {
+				continue
+			}
+			if f.Path == *fs.Path || strings.HasPrefix(f.Path, *fs.Path+"/") {
+				r.AddOnError(c.Append("files", i, "target"), errors.ErrInitramfsFileOnFilesystem)
+			}
+		}
 	}
 	for i, l1 := range s.Links {
 		for _, l2 := range s.Links {
@@ -71,5 +84,20 @@ func (s Storage) Validate(c vpath.ContextPath) (r report.Report) {
 			}
 		}
 	}
+	for i, l := range s.Loops {
+		// loop devices are attached before the sysroot is mounted, so
+		// a declared backing file is written to the initramfs by the
+		// disks stage
+		f := l.BackingFile(s.Files)
+		if f == nil {
+			continue
+		}
+		if !f.TargetsInitramfs() {
+			r.AddOnError(c.Append("loops", i, "file"), errors.ErrLoopFileNotInitramfs)
+		}
+		if l.SizeMiB != nil {
+			r.AddOnError(c.Append("loops", i, "sizeMiB"), errors.ErrLoopSizeWithDeclaredFile)
+		}
+	}
 	return
 }
@@ -169,6 +169,140 @@ func TestStorageValidate(t *testing.T) {
 			out: errors.ErrHardLinkToDirectory,
 			at:  path.New("", "links", 0),
 		},
+		// raid1 over partitions created on two different disks
+		{
+			in: Storage{
+				Disks: []Disk{
+					{
+						Device:     "/dev/vda",
+						Partitions: []Partition{{Label: util.StrToPtr("raid.1"), Number: 1}},
+					},
+					{
+						Device:     "/dev/vdb",
+						Partitions: []Partition{{Label: util.StrToPtr("raid.2"), Number: 1}},
+					},
+				},
+				Raid: []Raid{
+					{
+						Name:    "md-root",
+						Level:   util.StrToPtr("raid1"),
+						Devices: []Device{"/dev/disk/by-partlabel/raid.1", "/dev/disk/by-partlabel/raid.2"},
+					},
+				},
+				Filesystems: []Filesystem{
+					{
+						Device: "/dev/md/md-root",
+						Format: util.StrToPtr("xfs"),
+					},
+				},
+			},
+			out: nil,
+		},
+		// files targeting the initramfs can't be on mounted filesystems
+		{
+			in: Storage{
+				Filesystems: []Filesystem{
+					{
+						Device: "/dev/vda4",
+						Format: util.StrToPtr("xfs"),
+						Path:   util.StrToPtr("/"),
+					},
+					{
+						Device: "/dev/vdb1",
+						Format: util.StrToPtr("xfs"),
+						Path:   util.StrToPtr("/var"),
+					},
+				},
+				Files: []File{
+					{
+						Node:          Node{Path: "/etc/NetworkManager/system-connections/eth0.nmconnection"},
+						FileEmbedded1: FileEmbedded1{Target: util.StrToPtr("initramfs")},
+					},
+					{
+						Node:          Node{Path: "/var/lib/foo"},
+						FileEmbedded1: FileEmbedded1{Target: util.StrToPtr("sysroot")},
+					},
+					{
+						Node:          Node{Path: "/variable"},
+						FileEmbedded1: FileEmbedded1{Target: util.StrToPtr("initramfs")},
+					},
+				},
+			},
+			out: nil,
+		},
+		{
+			in: Storage{
+				Filesystems: []Filesystem{
+					{
+						Device: "/dev/vdb1",
+						Format: util.StrToPtr("xfs"),
+						Path:   util.StrToPtr("/var"),
+					},
+				},
+				Files: []File{
+					{
+						Node:          Node{Path: "/var/lib/foo"},
+						FileEmbedded1: FileEmbedded1{Target: util.StrToPtr("initramfs")},
+					},
+				},
+			},
+			out: errors.ErrInitramfsFileOnFilesystem,
+			at:  path.New("", "files", 0, "target"),
+		},
+		// loops backed by declared files
+		{
+			in: Storage{
+				Loops: []Loop{
+					{
+						Device: "/dev/loop0",
+						File:   util.StrToPtr("/run/disk.img"),
+					},
+				},
+				Files: []File{
+					{
+						Node:          Node{Path: "/run/disk.img"},
+						FileEmbedded1: FileEmbedded1{Target: util.StrToPtr("initramfs")},
+					},
+				},
+			},
+			out: nil,
+		},
+		{
+			in: Storage{
+				Loops: []Loop{
+					{
+						Device: "/dev/loop0",
+						File:   util.StrToPtr("/run/disk.img"),
+					},
+				},
+				Files: []File{
+					{
+						Node: Node{Path: "/run/disk.img"},
+					},
+				},
+			},
+			out: errors.ErrLoopFileNotInitramfs,
+			at:  path.New("", "loops", 0, "file"),
+		},
+		{
+			in: Storage{
+				Loops: []Loop{
+					{
+						Device:  "/dev/loop0",
+						File:    util.StrToPtr("/run/disk.img"),
+						SizeMiB: util.IntToPtr(64),
+					},
+				},
+				Files: []File{
+					{
+						Node:          Node{Path: "/run/disk.img"},
+						FileEmbedded1: FileEmbedded1{Target: util.StrToPtr("initramfs")},
+					},
+				},
+			},
+			out: errors.ErrLoopSizeWithDeclaredFile,
+			at:  path.New("", "loops", 0, "sizeMiB"),
+		},
 	}
 
 	for i, test := range tests {
@@ -0,0 +1,39 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"github.com/coreos/ignition/v2/config/shared/errors"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func (t Timeouts) Validate(c path.ContextPath) (r report.Report) {
+	if t.HTTPMaxAttempts != nil && *t.HTTPMaxAttempts < 0 {
+		r.AddOnError(c.Append("httpMaxAttempts"), errors.ErrNegativeAttempts)
+	}
+	r.AddOnError(c.Append("httpResponseHeaders"), validateTimeout(t.HTTPResponseHeaders))
+	r.AddOnError(c.Append("httpRetryDelay"), validateTimeout(t.HTTPRetryDelay))
+	r.AddOnError(c.Append("httpTotal"), validateTimeout(t.HTTPTotal))
+	return
+}
+
+func validateTimeout(timeout *int) error {
+	if timeout != nil && *timeout < 0 {
+		return errors.ErrNegativeTimeout
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestValidateTimeout(t *testing.T) {
+	tests := []struct {
+		in  *int
+		out error
+	}{
+		{
+			nil,
+			nil,
+		},
+		{
+			util.IntToPtr(0),
+			nil,
+		},
+		{
+			util.IntToPtr(30),
+			nil,
+		},
+		{
+			util.IntToPtr(-1),
+			errors.ErrNegativeTimeout,
+		},
+	}
+
+	for i, test := range tests {
+		err := validateTimeout(test.in)
+		if test.out != err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out, err)
+		}
+	}
+}
+
+func TestTimeoutsValidate(t *testing.T) {
+	tests := []struct {
+		in  Timeouts
+		out error
+		at  path.ContextPath
+	}{
+		{
+			in:  Timeouts{},
+			out: nil,
+		},
+		{
+			in: Timeouts{
+				HTTPMaxAttempts: util.IntToPtr(3),
+				HTTPRetryDelay:  util.IntToPtr(500),
+			},
+			out: nil,
+		},
+		{
+			in:  Timeouts{HTTPMaxAttempts: util.IntToPtr(-1)},
+			out: errors.ErrNegativeAttempts,
+			at:  path.New("", "httpMaxAttempts"),
+		},
+		{
+			in:  Timeouts{HTTPRetryDelay: util.IntToPtr(-1)},
+			out: errors.ErrNegativeTimeout,
+			at:  path.New("", "httpRetryDelay"),
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
@@ -32,21 +32,57 @@ func (u Unit) Key() string {
 	return u.Name
 }
 
+func (u Unit) ReplacedLists() map[string]struct{} {
+	return map[string]struct{}{
+		"Platforms": {},
+	}
+}
+
 func (d Dropin) Key() string {
 	return d.Name
 }
 
 func (u Unit) Validate(c cpath.ContextPath) (r report.Report) {
 	r.AddOnError(c.Append("name"), validateName(u.Name))
+	r.Merge(validatePlatforms(c, u.Platforms))
+	for i, t := range u.WantedBy {
+		r.AddOnError(c.Append("wantedBy", i), validateInstallTarget(t))
+	}
+	for i, t := range u.RequiredBy {
+		r.AddOnError(c.Append("requiredBy", i), validateInstallTarget(t))
+	}
+
 	c = c.Append("contents")
 	opts, err := validateUnitContent(u.Contents)
 	r.AddOnError(c, err)
 
-	r.AddOnWarn(c, validations.ValidateInstallSection(u.Name, util.IsTrue(u.Enabled), util.NilOrEmpty(u.Contents), opts))
+	hasTargets := len(u.WantedBy) != 0 || len(u.RequiredBy) != 0
+	if hasTargets && HasInstallSection(opts) {
+		r.AddOnWarn(c, errors.ErrInstallTargetsIgnored)
+	}
+	r.AddOnWarn(c, validations.ValidateInstallSection(u.Name, util.IsTrue(u.Enabled) && !hasTargets, util.NilOrEmpty(u.Contents), opts))
 
 	return
 }
 
+func validateInstallTarget(target string) error {
+	if path.Ext(target) != ".target" {
+		return errors.ErrInstallTargetNotTarget
+	}
+	return nil
+}
+
+// HasInstallSection returns whether the parsed unit contents include an
+// [Install] section.
+func HasInstallSection(opts []*unit.UnitOption) bool {
+	for _, opt := range opts {
+		if opt.Section == "Install" {
+			return true
+		}
+	}
+	return false
+}
+
 func validateName(name string) error {
 	switch path.Ext(name) {
 	case ".service", ".socket", ".device", ".mount", ".automount", ".swap", ".target", ".path", ".timer", ".snapshot", ".slice", ".scope":
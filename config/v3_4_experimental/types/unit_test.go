@@ -82,6 +82,62 @@ func TestSystemdUnitValidateName(t *testing.T) {
 	}
 }
 
+func TestSystemdUnitValidateInstallTargets(t *testing.T) {
+	tests := []struct {
+		in  Unit
+		out report.Report
+	}{
+		{
+			in: Unit{
+				Name:       "test.service",
+				Contents:   util.StrToPtr("[Service]\nType=oneshot"),
+				Enabled:    util.BoolToPtr(true),
+				WantedBy:   []string{"multi-user.target"},
+				RequiredBy: []string{"local-fs.target"},
+			},
+			out: report.Report{},
+		},
+		{
+			in: Unit{
+				Name:     "test.service",
+				WantedBy: []string{"multi-user.target", "sshd.service"},
+			},
+			out: func() (r report.Report) {
+				r.AddOnError(path.New("", "wantedBy", 1), errors.ErrInstallTargetNotTarget)
+				return
+			}(),
+		},
+		{
+			in: Unit{
+				Name:       "test.service",
+				RequiredBy: []string{"local-fs"},
+			},
+			out: func() (r report.Report) {
+				r.AddOnError(path.New("", "requiredBy", 0), errors.ErrInstallTargetNotTarget)
+				return
+			}(),
+		},
+		{
+			in: Unit{
+				Name:     "test.service",
+				Contents: util.StrToPtr("[Service]\nType=oneshot\n[Install]\nWantedBy=multi-user.target"),
+				WantedBy: []string{"multi-user.target"},
+			},
+			out: func() (r report.Report) {
+				r.AddOnWarn(path.New("", "contents"), errors.ErrInstallTargetsIgnored)
+				return
+			}(),
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		if !reflect.DeepEqual(test.out, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out, r)
+		}
+	}
+}
+
 func TestSystemdUnitDropInValidate(t *testing.T) {
 	tests := []struct {
 		in  Dropin
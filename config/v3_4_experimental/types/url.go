@@ -16,6 +16,7 @@ package types
 
 import (
 	"net/url"
+	"path"
 
 	"github.com/vincent-petithory/dataurl"
 
@@ -44,6 +45,13 @@ func validateURL(s string) error {
 			return err
 		}
 		return nil
+	case "file":
+		// file urls refer to the machine running Ignition, so a remote
+		// host would never be meaningful
+		if (u.Host != "" && u.Host != "localhost") || u.Opaque != "" || !path.IsAbs(u.Path) {
+			return errors.ErrInvalidFileURL
+		}
+		return nil
 	default:
 		return errors.ErrInvalidScheme
 	}
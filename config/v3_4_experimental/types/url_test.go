@@ -70,6 +70,26 @@ func TestURLValidate(t *testing.T) {
 			util.StrToPtr("gs://bucket/object"),
 			nil,
 		},
+		{
+			util.StrToPtr("file:///srv/build/motd"),
+			nil,
+		},
+		{
+			util.StrToPtr("file://localhost/srv/build/motd"),
+			nil,
+		},
+		{
+			util.StrToPtr("file://build/motd"),
+			errors.ErrInvalidFileURL,
+		},
+		{
+			util.StrToPtr("file:srv/build/motd"),
+			errors.ErrInvalidFileURL,
+		},
+		{
+			util.StrToPtr("file://"),
+			errors.ErrInvalidFileURL,
+		},
 	}
 
 	for i, test := range tests {
@@ -41,16 +41,21 @@ func (v Verification) HashParts() (string, string, error) {
 }
 
 func (v Verification) Validate(c path.ContextPath) (r report.Report) {
-	c = c.Append("hash")
-	if v.Hash == nil {
-		// The hash can be nil
-		return
+	if v.Hash != nil {
+		r.AddOnError(c.Append("hash"), validateHash(*v.Hash))
 	}
+	for i, h := range v.Hashes {
+		r.AddOnError(c.Append("hashes", i), validateHash(h))
+	}
+	return
+}
 
-	function, sum, err := v.HashParts()
+// validateHash checks that h is of the form <function>-<sum> with a
+// supported function and a sum of the right length.
+func validateHash(h string) error {
+	function, sum, err := Verification{Hash: &h}.HashParts()
 	if err != nil {
-		r.AddOnError(c, err)
-		return
+		return err
 	}
 	var hash crypto.Hash
 	switch function {
@@ -59,13 +64,11 @@ func (v Verification) Validate(c path.ContextPath) (r report.Report) {
 	case "sha256":
 		hash = crypto.SHA256
 	default:
-		r.AddOnError(c, errors.ErrHashUnrecognized)
-		return
+		return errors.ErrHashUnrecognized
 	}
 
 	if len(sum) != hex.EncodedLen(hash.Size()) {
-		r.AddOnError(c, errors.ErrHashWrongSize)
+		return errors.ErrHashWrongSize
 	}
-
-	return
+	return nil
 }
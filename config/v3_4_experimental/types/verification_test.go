@@ -100,3 +100,41 @@ func TestHashValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestHashesValidate(t *testing.T) {
+	good := "sha256-0519a9826023338828942b081814355d55301b9bc82042390f9afaf75cd3a707"
+
+	tests := []struct {
+		in  Verification
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: Verification{Hash: &good, Hashes: []string{good, "sha512-0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"}},
+		},
+		{
+			in:  Verification{Hashes: []string{good, "xor-abcdef"}},
+			at:  path.New("", "hashes", 1),
+			out: errors.ErrHashUnrecognized,
+		},
+		{
+			in:  Verification{Hashes: []string{"sha256-345"}},
+			at:  path.New("", "hashes", 0),
+			out: errors.ErrHashWrongSize,
+		},
+		{
+			in:  Verification{Hashes: []string{"sha256:0519a9826023338828942b081814355d55301b9bc82042390f9afaf75cd3a707"}},
+			at:  path.New("", "hashes", 0),
+			out: errors.ErrHashMalformed,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, expected, r)
+		}
+	}
+}
@@ -17,6 +17,7 @@ package validate
 import (
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/coreos/ignition/v2/config/shared/errors"
 	"github.com/coreos/ignition/v2/config/util"
@@ -116,3 +117,45 @@ func ValidateWithContext(cfg interface{}, raw []byte) report.Report {
 	}
 	return r
 }
+
+// Diagnostic is a single validation finding, located by a JSON pointer
+// (RFC 6901) into the config so that editors can highlight the offending
+// field.
+type Diagnostic struct {
+	Pointer  string `json:"pointer"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	// Line and Column are only set if the report was correlated with
+	// the raw config.
+	Line   int64 `json:"line,omitempty"`
+	Column int64 `json:"column,omitempty"`
+}
+
+// Diagnostics converts the entries of r into a list of diagnostics.
+func Diagnostics(r report.Report) []Diagnostic {
+	var ds []Diagnostic
+	for _, e := range r.Entries {
+		d := Diagnostic{
+			Pointer:  JSONPointer(e.Context),
+			Severity: e.Kind.String(),
+			Message:  e.Message,
+		}
+		if e.Marker.StartP != nil {
+			d.Line, d.Column = e.Marker.Start()
+		}
+		ds = append(ds, d)
+	}
+	return ds
+}
+
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// JSONPointer returns the RFC 6901 JSON pointer for c.
+func JSONPointer(c path.ContextPath) string {
+	var b strings.Builder
+	for _, e := range c.Path {
+		b.WriteString("/")
+		b.WriteString(jsonPointerEscaper.Replace(fmt.Sprint(e)))
+	}
+	return b.String()
+}
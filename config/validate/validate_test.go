@@ -164,3 +164,51 @@ func TestValidateWithContext(t *testing.T) {
 		}
 	}
 }
+
+func TestJSONPointer(t *testing.T) {
+	tests := []struct {
+		in  path.ContextPath
+		out string
+	}{
+		{
+			in:  path.New("json"),
+			out: "",
+		},
+		{
+			in:  path.New("json", "storage", "files", 2, "path"),
+			out: "/storage/files/2/path",
+		},
+		{
+			in:  path.New("json", tree.Key("a/b~c")),
+			out: "/a~1b~0c",
+		},
+	}
+
+	for i, test := range tests {
+		if out := JSONPointer(test.in); out != test.out {
+			t.Errorf("#%d: bad pointer: want %q, got %q", i, test.out, out)
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	r := mkReport(dummy, path.New("json", "foobar"), report.Error, 1, 13)
+	r.AddOnWarn(path.New("json", "dups", 1), ignerrors.ErrDuplicate)
+	want := []Diagnostic{
+		{
+			Pointer:  "/foobar",
+			Severity: "error",
+			Message:  dummy.Error(),
+			Line:     1,
+			Column:   13,
+		},
+		{
+			Pointer:  "/dups/1",
+			Severity: "warning",
+			Message:  ignerrors.ErrDuplicate.Error(),
+		},
+	}
+	if out := Diagnostics(r); !reflect.DeepEqual(want, out) {
+		t.Errorf("bad diagnostics: want %+v, got %+v", want, out)
+	}
+}
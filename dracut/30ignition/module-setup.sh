@@ -24,6 +24,8 @@ install() {
     # (e.g. on embedded systems), so only add applications which are actually
     # present
     inst_multiple -o \
+        chage \
+        gpasswd \
         groupadd \
         groupdel \
         mkfs.btrfs \
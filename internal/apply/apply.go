@@ -41,6 +41,10 @@ type Flags struct {
 	Root              string
 	IgnoreUnsupported bool
 	Offline           bool
+	DryRun            bool
+	// Platform selects the entries restricted to specific platforms which
+	// are applied; if empty, none are.
+	Platform string
 }
 
 func inContainer() bool {
@@ -62,10 +66,17 @@ func inContainer() bool {
 }
 
 func Run(cfg types.Config, flags Flags, logger *log.Logger) error {
-	if !inContainer() {
+	logger.SetDryRun(flags.DryRun)
+
+	// a dry run doesn't modify the system, so it's safe to run on a host
+	if !flags.DryRun && !inContainer() {
 		return errors.New("this tool is not designed to run on a host system; reprovision the machine instead")
 	}
 
+	if flags.Platform != "" && !util.StrSliceContains(types.KnownPlatforms, flags.Platform) {
+		return fmt.Errorf("unknown platform %q", flags.Platform)
+	}
+
 	// make absolute because our code assumes that
 	var err error
 	if flags.Root, err = filepath.Abs(flags.Root); err != nil {
@@ -73,8 +84,11 @@ func Run(cfg types.Config, flags Flags, logger *log.Logger) error {
 	}
 
 	fetcher := resource.Fetcher{
-		Logger:  logger,
-		Offline: flags.Offline,
+		Logger:        logger,
+		Offline:       flags.Offline,
+		Cache:         resource.NewCache(),
+		MaxBufferSize: exec.DefaultMaxConfigSize,
+		MaxTFTPSize:   exec.DefaultMaxTFTPSize,
 	}
 
 	state := state.State{}
@@ -88,6 +102,7 @@ func Run(cfg types.Config, flags Flags, logger *log.Logger) error {
 	if err != nil {
 		return err
 	}
+	finalCfg = exec.FilterForPlatform(finalCfg, flags.Platform)
 
 	// verify upfront if we'll need networking but we're not allowed
 	if flags.Offline {
@@ -0,0 +1,119 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apply
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+func TestRunPlatformFilter(t *testing.T) {
+	file := func(path string, platforms ...string) types.File {
+		return types.File{
+			Node: types.Node{
+				Path:      path,
+				Platforms: platforms,
+			},
+			FileEmbedded1: types.FileEmbedded1{
+				Contents: types.Resource{
+					Source: cutil.StrToPtr("data:,hello"),
+				},
+			},
+		}
+	}
+	cfg := types.Config{
+		Ignition: types.Ignition{Version: types.MaxVersion.String()},
+		Storage: types.Storage{
+			Files: []types.File{
+				file("/everywhere"),
+				file("/aws", "aws"),
+				file("/gcp-or-azure", "gcp", "azure"),
+			},
+		},
+	}
+
+	tests := []struct {
+		platform string
+		written  []string
+		skipped  []string
+		hasErr   bool
+	}{
+		{
+			platform: "aws",
+			written:  []string{"/everywhere", "/aws"},
+			skipped:  []string{"/gcp-or-azure"},
+		},
+		{
+			platform: "azure",
+			written:  []string{"/everywhere", "/gcp-or-azure"},
+			skipped:  []string{"/aws"},
+		},
+		// without a platform, only unrestricted entries are applied
+		{
+			written: []string{"/everywhere"},
+			skipped: []string{"/aws", "/gcp-or-azure"},
+		},
+		{
+			platform: "nonexistent",
+			hasErr:   true,
+		},
+	}
+
+	// Run refuses to modify a host system
+	if val, ok := os.LookupEnv("container"); ok {
+		defer os.Setenv("container", val)
+	} else {
+		defer os.Unsetenv("container")
+	}
+	os.Setenv("container", "test")
+
+	logger := log.New(true)
+	defer logger.Close()
+	for i, test := range tests {
+		root, err := ioutil.TempDir("", "ignition-apply-test")
+		if err != nil {
+			t.Fatalf("creating temp dir: %v", err)
+		}
+		defer os.RemoveAll(root)
+
+		err = Run(cfg, Flags{Root: root, Offline: true, Platform: test.platform}, &logger)
+		if test.hasErr {
+			if err == nil {
+				t.Errorf("#%d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		for _, path := range test.written {
+			if _, err := os.Stat(filepath.Join(root, path)); err != nil {
+				t.Errorf("#%d: %s wasn't written: %v", i, path, err)
+			}
+		}
+		for _, path := range test.skipped {
+			if _, err := os.Stat(filepath.Join(root, path)); !os.IsNotExist(err) {
+				t.Errorf("#%d: %s was written", i, path)
+			}
+		}
+	}
+}
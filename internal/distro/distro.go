@@ -32,11 +32,16 @@ var (
 	systemConfigDir = "/usr/lib/ignition"
 
 	// Helper programs
+	chageCmd    = "chage"
+	chrootCmd   = "chroot"
+	gpasswdCmd  = "gpasswd"
 	groupaddCmd = "groupadd"
 	groupdelCmd = "groupdel"
+	losetupCmd  = "losetup"
 	mdadmCmd    = "mdadm"
 	mountCmd    = "mount"
 	sgdiskCmd   = "sgdisk"
+	btrfsCmd    = "btrfs"
 	modprobeCmd = "modprobe"
 	udevadmCmd  = "udevadm"
 	usermodCmd  = "usermod"
@@ -51,12 +56,19 @@ var (
 	swapMkfsCmd  = "mkswap"
 	vfatMkfsCmd  = "mkfs.fat"
 	xfsMkfsCmd   = "mkfs.xfs"
+	e2fsckCmd    = "e2fsck"
+	vfatFsckCmd  = "fsck.fat"
+	resize2fsCmd = "resize2fs"
+	xfsGrowfsCmd = "xfs_growfs"
 
 	//zVM programs
 	vmurCmd      = "vmur"
 	chccwdevCmd  = "chccwdev"
 	cioIgnoreCmd = "cio_ignore"
 
+	// VMware programs
+	vmwareRpctoolCmd = "vmware-rpctool"
+
 	// LUKS programs
 	clevisCmd     = "clevis"
 	cryptsetupCmd = "cryptsetup"
@@ -64,6 +76,14 @@ var (
 	// kargs programs
 	kargsCmd = "ignition-kargs-helper"
 
+	// Config signature verification
+	gpgvCmd = "gpgv"
+	// configSigningKeyring is the path to a binary OpenPGP keyring in the
+	// initramfs. If set, fetched configs must carry a detached signature
+	// made by a key in the keyring, and the keyring can't be overridden
+	// from the kernel command line.
+	configSigningKeyring = ""
+
 	// Flags
 	selinuxRelabel  = "true"
 	blackboxTesting = "false"
@@ -76,6 +96,17 @@ var (
 	// Special file paths in the real root
 	luksRealRootKeyFilePath = "/etc/luks/"
 	resultFilePath          = "/etc/.ignition-result.json"
+	// relabelManifestPath, if set, is where the files stage lists the
+	// paths it wrote, so the system can relabel just those paths later
+	// (e.g. with "restorecon -R -f") instead of the whole filesystem.
+	relabelManifestPath = ""
+	// checksumManifestPath, if set, is where the files stage records the
+	// SHA-256 of each file it wrote, in the format read by "sha256sum -c".
+	checksumManifestPath = ""
+	// mountNamespacePath, if set, is where the mount stage pins the
+	// private mount namespace it mounts filesystems in. The files and
+	// umount stages enter it, so the mounts aren't visible elsewhere.
+	mountNamespacePath = ""
 )
 
 func DiskByLabelDir() string { return diskByLabelDir }
@@ -84,11 +115,16 @@ func KernelCmdlinePath() string { return kernelCmdlinePath }
 func BootIDPath() string        { return bootIDPath }
 func SystemConfigDir() string   { return fromEnv("SYSTEM_CONFIG_DIR", systemConfigDir) }
 
+func ChageCmd() string    { return chageCmd }
+func ChrootCmd() string   { return chrootCmd }
+func GpasswdCmd() string  { return gpasswdCmd }
 func GroupaddCmd() string { return groupaddCmd }
 func GroupdelCmd() string { return groupdelCmd }
+func LosetupCmd() string  { return losetupCmd }
 func MdadmCmd() string    { return mdadmCmd }
 func MountCmd() string    { return mountCmd }
 func SgdiskCmd() string   { return sgdiskCmd }
+func BtrfsCmd() string    { return btrfsCmd }
 func ModprobeCmd() string { return modprobeCmd }
 func UdevadmCmd() string  { return udevadmCmd }
 func UsermodCmd() string  { return usermodCmd }
@@ -102,18 +138,36 @@ func Ext4MkfsCmd() string  { return ext4MkfsCmd }
 func SwapMkfsCmd() string  { return swapMkfsCmd }
 func VfatMkfsCmd() string  { return vfatMkfsCmd }
 func XfsMkfsCmd() string   { return xfsMkfsCmd }
+func E2fsckCmd() string    { return e2fsckCmd }
+func VfatFsckCmd() string  { return vfatFsckCmd }
+func Resize2fsCmd() string { return resize2fsCmd }
+func XfsGrowfsCmd() string { return xfsGrowfsCmd }
 
 func VmurCmd() string      { return vmurCmd }
 func ChccwdevCmd() string  { return chccwdevCmd }
 func CioIgnoreCmd() string { return cioIgnoreCmd }
 
+func VmwareRpctoolCmd() string { return vmwareRpctoolCmd }
+
 func ClevisCmd() string     { return clevisCmd }
 func CryptsetupCmd() string { return cryptsetupCmd }
 
 func KargsCmd() string { return kargsCmd }
 
+func GpgvCmd() string              { return gpgvCmd }
+func ConfigSigningKeyring() string { return configSigningKeyring }
+
 func LuksRealRootKeyFilePath() string { return luksRealRootKeyFilePath }
 func ResultFilePath() string          { return resultFilePath }
+func RelabelManifestPath() string {
+	return fromEnv("RELABEL_MANIFEST_PATH", relabelManifestPath)
+}
+func ChecksumManifestPath() string {
+	return fromEnv("CHECKSUM_MANIFEST_PATH", checksumManifestPath)
+}
+func MountNamespacePath() string {
+	return fromEnv("MOUNT_NAMESPACE_PATH", mountNamespacePath)
+}
 
 func SelinuxRelabel() bool  { return bakedStringToBool(selinuxRelabel) && !BlackboxTesting() }
 func BlackboxTesting() bool { return bakedStringToBool(blackboxTesting) }
@@ -17,6 +17,7 @@ package exec
 import (
 	"crypto/sha512"
 	"encoding/hex"
+	"fmt"
 	"net/http"
 	"net/url"
 
@@ -31,6 +32,11 @@ import (
 	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
 )
 
+// maxConfigReferenceDepth is the maximum number of nested
+// "ignition.config.replace" and "ignition.config.merge" references that will
+// be followed before giving up.
+const maxConfigReferenceDepth = 10
+
 type ConfigFetcher struct {
 	Logger  *log.Logger
 	Fetcher *resource.Fetcher
@@ -43,8 +49,19 @@ type ConfigFetcher struct {
 // "ignition.config.merge" is set, each of the referenced configs will be
 // evaluated and merged into the provided config. If neither option is set, the
 // provided config will be returned unmodified. An updated fetcher will be
-// returned with any new timeouts set.
+// returned with any new timeouts set. Nested references are followed up to
+// maxConfigReferenceDepth levels deep, after which ErrConfigReferenceDepth is
+// returned.
 func (f *ConfigFetcher) RenderConfig(cfg types.Config) (types.Config, error) {
+	return f.renderConfig(cfg, 0)
+}
+
+func (f *ConfigFetcher) renderConfig(cfg types.Config, depth int) (types.Config, error) {
+	if depth >= maxConfigReferenceDepth && (cfg.Ignition.Config.Replace.Source != nil || len(cfg.Ignition.Config.Merge) > 0) {
+		f.Logger.Crit("giving up after following %d nested config references", depth)
+		return types.Config{}, errors.ErrConfigReferenceDepth
+	}
+
 	if cfgRef := cfg.Ignition.Config.Replace; cfgRef.Source != nil {
 		newCfg, err := f.fetchReferencedConfig(cfgRef)
 		if err != nil {
@@ -58,7 +75,7 @@ func (f *ConfigFetcher) RenderConfig(cfg types.Config) (types.Config, error) {
 			return types.Config{}, err
 		}
 
-		return f.RenderConfig(newCfg)
+		return f.renderConfig(newCfg, depth+1)
 	}
 
 	mergedCfg := cfg
@@ -77,7 +94,7 @@ func (f *ConfigFetcher) RenderConfig(cfg types.Config) (types.Config, error) {
 			return types.Config{}, err
 		}
 
-		newCfg, err = f.RenderConfig(newCfg)
+		newCfg, err = f.renderConfig(newCfg, depth+1)
 		if err != nil {
 			return types.Config{}, err
 		}
@@ -119,14 +136,23 @@ func (f *ConfigFetcher) fetchReferencedConfig(cfgRef types.Resource) (types.Conf
 	}
 
 	hash := sha512.Sum512(rawCfg)
+	f.Logger.Debug("fetched referenced config %s with SHA512: %s", describeConfigReference(*u), hex.EncodeToString(hash[:]))
+
+	// verify before parsing, so a tampered config is never interpreted
+	if err := util.AssertValid(cfgRef.Verification, rawCfg); err != nil {
+		f.Logger.Crit("failed to verify referenced config %s: %v", describeConfigReference(*u), err)
+		return types.Config{}, fmt.Errorf("verifying referenced config %s: %w", describeConfigReference(*u), err)
+	}
+
+	// data URLs are covered by the signature of the config embedding them
 	if u.Scheme != "data" {
-		f.Logger.Debug("fetched referenced config at %s with SHA512: %s", *cfgRef.Source, hex.EncodeToString(hash[:]))
-	} else {
-		// data url's might contain secrets
-		f.Logger.Debug("fetched referenced config from data url with SHA512: %s", hex.EncodeToString(hash[:]))
+		if err := f.verifySignature(*u, headers, rawCfg); err != nil {
+			return types.Config{}, err
+		}
 	}
 
-	if err := util.AssertValid(cfgRef.Verification, rawCfg); err != nil {
+	rawCfg, err = util.GunzipIfCompressed(rawCfg, f.Fetcher.MaxBufferSize)
+	if err != nil {
 		return types.Config{}, err
 	}
 
@@ -144,3 +170,50 @@ func (f *ConfigFetcher) fetchReferencedConfig(cfgRef types.Resource) (types.Conf
 
 	return cfg, nil
 }
+
+// describeConfigReference names the referenced config at u for logs and
+// errors. Data URLs aren't included since they might contain secrets.
+func describeConfigReference(u url.URL) string {
+	if u.Scheme == "data" {
+		return "from data url"
+	}
+	return "at " + redactURL(u)
+}
+
+// redactURL returns u without its user info and query, which may contain
+// credentials such as passwords or the signatures of presigned URLs.
+func redactURL(u url.URL) string {
+	u.User = nil
+	u.RawQuery = ""
+	u.ForceQuery = false
+	return u.String()
+}
+
+// verifySignature checks the detached signature of the config at u if a
+// signing keyring is configured. The signature is fetched from the same
+// location as the config, with ".sig" appended to the path.
+func (f *ConfigFetcher) verifySignature(u url.URL, headers http.Header, rawCfg []byte) error {
+	keyring, err := util.ConfigSigningKeyring()
+	if err != nil {
+		return err
+	}
+	if keyring == "" {
+		return nil
+	}
+	sigURL := util.SignatureURL(u)
+	sig, err := f.Fetcher.FetchToBuffer(sigURL, resource.FetchOptions{
+		Headers: headers,
+	})
+	if err == resource.ErrNotFound {
+		f.Logger.Crit("config signature %s not found", redactURL(sigURL))
+		return util.ErrSignatureMissing
+	} else if err != nil {
+		return err
+	}
+	if err := util.VerifySignature(keyring, rawCfg, sig); err != nil {
+		f.Logger.Crit("failed to verify config signature: %v", err)
+		return err
+	}
+	f.Logger.Info("verified signature of referenced config %s", redactURL(u))
+	return nil
+}
@@ -36,6 +36,7 @@ import (
 	"github.com/coreos/ignition/v2/internal/providers/system"
 	"github.com/coreos/ignition/v2/internal/resource"
 	"github.com/coreos/ignition/v2/internal/state"
+	"github.com/coreos/ignition/v2/internal/util"
 
 	"github.com/coreos/vcontext/report"
 	"github.com/coreos/vcontext/validate"
@@ -44,6 +45,12 @@ import (
 
 const (
 	DefaultFetchTimeout = 2 * time.Minute
+	// DefaultMaxConfigSize is the default limit on the size of fetched
+	// configs, including referenced configs.
+	DefaultMaxConfigSize = 10 * 1024 * 1024
+	// DefaultMaxTFTPSize is the default limit on the size of resources
+	// fetched over TFTP.
+	DefaultMaxTFTPSize = 1024 * 1024 * 1024
 	// This variable will help to identify ignition journal messages
 	// related to the user/base config.
 	ignitionFetchedConfigMsgId = "57124006b5c94805b77ce473e92a8aeb"
@@ -109,7 +116,11 @@ func (e Engine) Run(stageName string) error {
 		return fmt.Errorf("initializing platform config: %v", err)
 	}
 
-	cfg, err := e.acquireConfig(stageName)
+	// the provider config is merged over these, so they may contain
+	// templated files too
+	baseConfigs := latest.Merge(baseConfig, systemBaseConfig)
+
+	cfg, err := e.acquireConfig(stageName, baseConfigs)
 	if err == resource.ErrNeedNet && stageName == "fetch-offline" {
 		err = e.signalNeedNet()
 		if err != nil {
@@ -126,7 +137,8 @@ func (e Engine) Run(stageName string) error {
 	e.Logger.PushPrefix(stageName)
 	defer e.Logger.PopPrefix()
 
-	fullConfig := latest.Merge(baseConfig, latest.Merge(systemBaseConfig, cfg))
+	fullConfig := latest.Merge(baseConfigs, cfg)
+	fullConfig = FilterForPlatform(fullConfig, e.PlatformConfig.Name())
 	err = stages.Get(stageName).Create(e.Logger, e.Root, *e.Fetcher, e.State).Run(fullConfig)
 	if err == resource.ErrNeedNet && stageName == "fetch-offline" {
 		err = e.signalNeedNet()
@@ -135,6 +147,7 @@ func (e Engine) Run(stageName string) error {
 		}
 		// fall through
 	}
+	err = stages.WrapError(stageName, err)
 	if err != nil {
 		// e.Logger could be nil
 		fmt.Fprintf(os.Stderr, "%s failed\n", stageName)
@@ -174,11 +187,12 @@ func logStructuredJournalEntry(cfgInfo state.FetchedConfig) error {
 // acquireConfig will perform differently based on the stage it is being
 // called from. In fetch stages it will attempt to fetch the provider
 // config (writing an empty provider config if it is empty). In all other
-// stages it will attempt to fetch from the local cache only.
-func (e *Engine) acquireConfig(stageName string) (cfg types.Config, err error) {
+// stages it will attempt to fetch from the local cache only. baseConfigs
+// are the configs the provider config will be merged over.
+func (e *Engine) acquireConfig(stageName string, baseConfigs types.Config) (cfg types.Config, err error) {
 	switch {
 	case strings.HasPrefix(stageName, "fetch"):
-		cfg, err = e.acquireProviderConfig()
+		cfg, err = e.acquireProviderConfig(baseConfigs)
 
 		// if we've successfully fetched and cached the configs, log about them
 		if err == nil {
@@ -218,7 +232,7 @@ func (e *Engine) acquireCachedConfig() (cfg types.Config, err error) {
 
 // acquireProviderConfig attempts to fetch the configuration from the
 // provider.
-func (e *Engine) acquireProviderConfig() (cfg types.Config, err error) {
+func (e *Engine) acquireProviderConfig(baseConfigs types.Config) (cfg types.Config, err error) {
 	// Create a new http client and fetcher with the timeouts set via the flags,
 	// since we don't have a config with timeout values we can use
 	timeout := int(e.FetchTimeout.Seconds())
@@ -263,6 +277,22 @@ func (e *Engine) acquireProviderConfig() (cfg types.Config, err error) {
 		return
 	}
 
+	// Fetch metadata now, while the fetch stages know whether networking
+	// is available, and pass it to the files stage in the state file.
+	// Templated files may come from the base configs, so check the config
+	// the files stage will actually see.
+	fullConfig := FilterForPlatform(latest.Merge(baseConfigs, cfg), e.PlatformConfig.Name())
+	e.State.Metadata, err = fetchTemplateMetadata(e.Fetcher, fullConfig, e.PlatformConfig.MetadataFunc())
+	if err != nil {
+		e.Logger.Warning("failed to fetch instance metadata for templated files: %v", err)
+		return
+	}
+
+	if e.Logger.DryRun() {
+		e.Logger.Info("dry run: not writing config cache %q", e.ConfigCache)
+		return
+	}
+
 	// Populate the config cache.
 	b, err := json.Marshal(cfg)
 	if err != nil {
@@ -313,6 +343,20 @@ func (e *Engine) fetchProviderConfig() (types.Config, error) {
 		return types.Config{}, err
 	}
 
+	// the cmdline provider verifies its own signature and the system config
+	// ships in the initramfs alongside the keyring; platform providers have
+	// nowhere to fetch a signature from
+	if providerKey != "cmdline" && providerKey != "system" {
+		keyring, err := util.ConfigSigningKeyring()
+		if err != nil {
+			return types.Config{}, err
+		}
+		if keyring != "" {
+			e.Logger.Crit("refusing unsigned config from the %s provider", providerKey)
+			return types.Config{}, util.ErrSignatureMissing
+		}
+	}
+
 	e.State.FetchedConfigs = append(e.State.FetchedConfigs, state.FetchedConfig{
 		Kind:       "user",
 		Source:     providerKey,
@@ -335,7 +379,14 @@ func (e *Engine) fetchProviderConfig() (types.Config, error) {
 	return configFetcher.RenderConfig(cfg)
 }
 
+// signalNeedNet writes the neednet flag file, which tells the initramfs to
+// bring up networking and run the fetch stage. In dry-run mode it's only
+// logged.
 func (e *Engine) signalNeedNet() error {
+	if e.Logger.DryRun() {
+		e.Logger.Info("dry run: not writing neednet flag %q", e.NeedNet)
+		return nil
+	}
 	if err := executil.MkdirForFile(e.NeedNet); err != nil {
 		return err
 	}
@@ -0,0 +1,50 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+func TestSignalNeedNet(t *testing.T) {
+	for _, dryRun := range []bool{false, true} {
+		tmp, err := ioutil.TempDir("", "ignition-exec-test")
+		if err != nil {
+			t.Fatalf("creating temp dir: %v", err)
+		}
+		defer os.RemoveAll(tmp)
+
+		logger := log.New(true)
+		logger.SetDryRun(dryRun)
+		e := Engine{
+			Logger:  &logger,
+			NeedNet: filepath.Join(tmp, "ignition", "neednet"),
+		}
+		if err := e.signalNeedNet(); err != nil {
+			t.Fatalf("dry run %v: signaling neednet: %v", dryRun, err)
+		}
+		_, err = os.Stat(e.NeedNet)
+		if dryRun && !os.IsNotExist(err) {
+			t.Errorf("dry run: flag written: %v", err)
+		} else if !dryRun && err != nil {
+			t.Errorf("flag not written: %v", err)
+		}
+	}
+}
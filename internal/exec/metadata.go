@@ -0,0 +1,41 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/providers"
+	"github.com/coreos/ignition/v2/internal/resource"
+)
+
+// fetchTemplateMetadata fetches the instance metadata for the config's
+// templated files, or returns nil if it has none. Metadata is only fetched
+// when needed so platforms without a metadata service aren't contacted.
+func fetchTemplateMetadata(f *resource.Fetcher, cfg types.Config, fetch providers.FuncFetchMetadata) (map[string]string, error) {
+	if !hasTemplatedFiles(cfg) {
+		return nil, nil
+	}
+	return fetch(f)
+}
+
+func hasTemplatedFiles(cfg types.Config) bool {
+	for _, f := range cfg.Storage.Files {
+		if util.IsTrue(f.Template) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/resource"
+)
+
+func TestFetchTemplateMetadata(t *testing.T) {
+	metadata := map[string]string{
+		"hostname":    "node1.example.com",
+		"instance_id": "i-0123456789",
+	}
+	errMetadata := errors.New("metadata service unavailable")
+
+	templated := types.Config{
+		Storage: types.Storage{
+			Files: []types.File{
+				{
+					Node: types.Node{Path: "/etc/plain"},
+				},
+				{
+					Node: types.Node{Path: "/etc/templated"},
+					FileEmbedded1: types.FileEmbedded1{
+						Contents: types.Resource{
+							Source: util.StrToPtr(dataURL("{{ hostname }}")),
+						},
+						Template: util.BoolToPtr(true),
+					},
+				},
+			},
+		},
+	}
+	plain := types.Config{
+		Storage: types.Storage{
+			Files: []types.File{
+				{
+					Node: types.Node{Path: "/etc/plain"},
+					FileEmbedded1: types.FileEmbedded1{
+						Contents: types.Resource{
+							Source: util.StrToPtr(dataURL("{{ hostname }}")),
+						},
+						Template: util.BoolToPtr(false),
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		cfg     types.Config
+		fail    bool
+		fetched bool
+		out     map[string]string
+		err     error
+	}{
+		{
+			cfg: types.Config{},
+		},
+		{
+			cfg: plain,
+		},
+		{
+			cfg:     templated,
+			fetched: true,
+			out:     metadata,
+		},
+		{
+			cfg:     templated,
+			fail:    true,
+			fetched: true,
+			err:     errMetadata,
+		},
+	}
+
+	logger := log.New(true)
+	defer logger.Close()
+	f := resource.Fetcher{Logger: &logger}
+	for i, test := range tests {
+		fetched := false
+		mockProvider := func(*resource.Fetcher) (map[string]string, error) {
+			fetched = true
+			if test.fail {
+				return nil, errMetadata
+			}
+			return metadata, nil
+		}
+		out, err := fetchTemplateMetadata(&f, test.cfg, mockProvider)
+		if err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+		}
+		if fetched != test.fetched {
+			t.Errorf("#%d: bad fetch: want %v, got %v", i, test.fetched, fetched)
+		}
+		if !reflect.DeepEqual(out, test.out) {
+			t.Errorf("#%d: bad metadata: want %v, got %v", i, test.out, out)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+)
+
+// FilterForPlatform returns a copy of cfg without the entries which are
+// restricted to platforms other than the named one. If the name is empty,
+// all restricted entries are dropped.
+func FilterForPlatform(cfg types.Config, platform string) types.Config {
+	var files []types.File
+	for _, f := range cfg.Storage.Files {
+		if types.AppliesToPlatform(f.Platforms, platform) {
+			files = append(files, f)
+		}
+	}
+	cfg.Storage.Files = files
+
+	var dirs []types.Directory
+	for _, d := range cfg.Storage.Directories {
+		if types.AppliesToPlatform(d.Platforms, platform) {
+			dirs = append(dirs, d)
+		}
+	}
+	cfg.Storage.Directories = dirs
+
+	var links []types.Link
+	for _, l := range cfg.Storage.Links {
+		if types.AppliesToPlatform(l.Platforms, platform) {
+			links = append(links, l)
+		}
+	}
+	cfg.Storage.Links = links
+
+	var units []types.Unit
+	for _, u := range cfg.Systemd.Units {
+		if types.AppliesToPlatform(u.Platforms, platform) {
+			units = append(units, u)
+		}
+	}
+	cfg.Systemd.Units = units
+
+	var users []types.PasswdUser
+	for _, u := range cfg.Passwd.Users {
+		if types.AppliesToPlatform(u.Platforms, platform) {
+			users = append(users, u)
+		}
+	}
+	cfg.Passwd.Users = users
+
+	var groups []types.PasswdGroup
+	for _, g := range cfg.Passwd.Groups {
+		if types.AppliesToPlatform(g.Platforms, platform) {
+			groups = append(groups, g)
+		}
+	}
+	cfg.Passwd.Groups = groups
+
+	return cfg
+}
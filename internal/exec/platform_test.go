@@ -0,0 +1,142 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+)
+
+func TestFilterForPlatform(t *testing.T) {
+	in := types.Config{
+		Passwd: types.Passwd{
+			Users: []types.PasswdUser{
+				{Name: "core"},
+				{Name: "ec2-user", Platforms: []string{"aws"}},
+			},
+			Groups: []types.PasswdGroup{
+				{Name: "gce", Platforms: []string{"gcp"}},
+			},
+		},
+		Storage: types.Storage{
+			Files: []types.File{
+				{Node: types.Node{Path: "/etc/aws", Platforms: []string{"aws"}}},
+				{Node: types.Node{Path: "/etc/cloud", Platforms: []string{"aws", "gcp"}}},
+				{Node: types.Node{Path: "/etc/motd"}},
+			},
+			Directories: []types.Directory{
+				{Node: types.Node{Path: "/var/gcp", Platforms: []string{"gcp"}}},
+			},
+			Links: []types.Link{
+				{Node: types.Node{Path: "/etc/metal", Platforms: []string{"metal"}}},
+			},
+		},
+		Systemd: types.Systemd{
+			Units: []types.Unit{
+				{Name: "aws.service", Platforms: []string{"aws"}},
+				{Name: "all.service"},
+			},
+		},
+	}
+
+	tests := []struct {
+		platform string
+		out      types.Config
+	}{
+		{
+			platform: "aws",
+			out: types.Config{
+				Passwd: types.Passwd{
+					Users: []types.PasswdUser{
+						{Name: "core"},
+						{Name: "ec2-user", Platforms: []string{"aws"}},
+					},
+				},
+				Storage: types.Storage{
+					Files: []types.File{
+						{Node: types.Node{Path: "/etc/aws", Platforms: []string{"aws"}}},
+						{Node: types.Node{Path: "/etc/cloud", Platforms: []string{"aws", "gcp"}}},
+						{Node: types.Node{Path: "/etc/motd"}},
+					},
+				},
+				Systemd: types.Systemd{
+					Units: []types.Unit{
+						{Name: "aws.service", Platforms: []string{"aws"}},
+						{Name: "all.service"},
+					},
+				},
+			},
+		},
+		{
+			platform: "gcp",
+			out: types.Config{
+				Passwd: types.Passwd{
+					Users: []types.PasswdUser{
+						{Name: "core"},
+					},
+					Groups: []types.PasswdGroup{
+						{Name: "gce", Platforms: []string{"gcp"}},
+					},
+				},
+				Storage: types.Storage{
+					Files: []types.File{
+						{Node: types.Node{Path: "/etc/cloud", Platforms: []string{"aws", "gcp"}}},
+						{Node: types.Node{Path: "/etc/motd"}},
+					},
+					Directories: []types.Directory{
+						{Node: types.Node{Path: "/var/gcp", Platforms: []string{"gcp"}}},
+					},
+				},
+				Systemd: types.Systemd{
+					Units: []types.Unit{
+						{Name: "all.service"},
+					},
+				},
+			},
+		},
+		{
+			platform: "metal",
+			out: types.Config{
+				Passwd: types.Passwd{
+					Users: []types.PasswdUser{
+						{Name: "core"},
+					},
+				},
+				Storage: types.Storage{
+					Files: []types.File{
+						{Node: types.Node{Path: "/etc/motd"}},
+					},
+					Links: []types.Link{
+						{Node: types.Node{Path: "/etc/metal", Platforms: []string{"metal"}}},
+					},
+				},
+				Systemd: types.Systemd{
+					Units: []types.Unit{
+						{Name: "all.service"},
+					},
+				},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		out := FilterForPlatform(in, test.platform)
+		if !reflect.DeepEqual(test.out, out) {
+			t.Errorf("#%d: bad config: want %+v, got %+v", i, test.out, out)
+		}
+	}
+}
@@ -21,6 +21,7 @@ package disks
 import (
 	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 
 	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
@@ -51,6 +52,7 @@ func (creator) Create(logger *log.Logger, root string, f resource.Fetcher, state
 			Fetcher: f,
 			State:   state,
 		},
+		initramfsDir: "/",
 	}
 }
 
@@ -60,6 +62,8 @@ func (creator) Name() string {
 
 type stage struct {
 	util.Util
+
+	initramfsDir string // root for backing files declared in storage.files
 }
 
 func (stage) Name() string {
@@ -67,7 +71,8 @@ func (stage) Name() string {
 }
 
 func isNoOp(config types.Config) bool {
-	return len(config.Storage.Disks) == 0 &&
+	return len(config.Storage.Loops) == 0 &&
+		len(config.Storage.Disks) == 0 &&
 		len(config.Storage.Raid) == 0 &&
 		len(config.Storage.Filesystems) == 0 &&
 		len(config.Storage.Luks) == 0
@@ -89,20 +94,44 @@ func (s stage) Run(config types.Config) error {
 		return nil
 	}
 
-	if err := s.createPartitions(config); err != nil {
-		return fmt.Errorf("create partitions failed: %v", err)
+	// Devices must be created before anything which uses them, e.g.
+	// partitions, then a raid array over them, then a filesystem on the
+	// array. Loop devices come first, since they only need their
+	// backing files.
+	steps, err := planDiskSteps(config.Storage)
+	if err != nil {
+		return fmt.Errorf("ordering storage operations: %v", err)
 	}
-
-	if err := s.createRaids(config); err != nil {
-		return fmt.Errorf("failed to create raids: %v", err)
+	if len(config.Storage.Luks) > 0 && !s.Logger.DryRun() {
+		// LUKS volumes may be created over several steps
+		s.State.LuksPersistKeyFiles = make(map[string]string)
 	}
-
-	if err := s.createLuks(config); err != nil {
-		return fmt.Errorf("failed to create luks: %v", err)
-	}
-
-	if err := s.createFilesystems(config); err != nil {
-		return fmt.Errorf("failed to create filesystems: %v", err)
+	for _, step := range steps {
+		stepConfig := types.Config{Storage: step.storage}
+		switch step.kind {
+		case stepLoops:
+			// backing files may be declared in storage.files
+			stepConfig.Storage.Files = config.Storage.Files
+			if err := s.createLoops(stepConfig); err != nil {
+				return fmt.Errorf("failed to create loop devices: %w", err)
+			}
+		case stepPartitions:
+			if err := s.createPartitions(stepConfig); err != nil {
+				return fmt.Errorf("create partitions failed: %w", err)
+			}
+		case stepRaid:
+			if err := s.createRaids(stepConfig); err != nil {
+				return fmt.Errorf("failed to create raids: %w", err)
+			}
+		case stepLuks:
+			if err := s.createLuks(stepConfig); err != nil {
+				return fmt.Errorf("failed to create luks: %w", err)
+			}
+		case stepFilesystems:
+			if err := s.createFilesystems(stepConfig); err != nil {
+				return fmt.Errorf("failed to create filesystems: %w", err)
+			}
+		}
 	}
 
 	// udevd registers an IN_CLOSE_WRITE inotify watch on block device
@@ -166,7 +195,21 @@ func (s stage) createDeviceAliases(devs []string) error {
 }
 
 // waitOnDevicesAndCreateAliases simply wraps waitOnDevices and createDeviceAliases.
+// In dry-run mode, devices that would have been created by an earlier step
+// don't exist, so only aliases for existing devices are created.
 func (s stage) waitOnDevicesAndCreateAliases(devs []string, ctxt string) error {
+	if s.Logger.DryRun() {
+		var existing []string
+		for _, dev := range devs {
+			if _, err := os.Stat(dev); err == nil {
+				existing = append(existing, dev)
+			} else {
+				s.Logger.Info("dry run: not waiting for missing %s device %q", ctxt, dev)
+			}
+		}
+		return s.createDeviceAliases(existing)
+	}
+
 	if err := s.waitOnDevices(devs, ctxt); err != nil {
 		return err
 	}
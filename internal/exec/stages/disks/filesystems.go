@@ -21,18 +21,33 @@ package disks
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"syscall"
 
 	cutil "github.com/coreos/ignition/v2/config/util"
 	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
 	"github.com/coreos/ignition/v2/internal/distro"
+	"github.com/coreos/ignition/v2/internal/exec/stages"
 	"github.com/coreos/ignition/v2/internal/exec/util"
+	ut "github.com/coreos/ignition/v2/internal/util"
 )
 
 var (
 	ErrBadFilesystem = errors.New("filesystem is not of the correct type")
+	ErrNotSubvolume  = errors.New("path exists but is not a btrfs subvolume")
+
+	// isSubvolume is replaced by tests, since they can't create subvolumes.
+	isSubvolume = func(info os.FileInfo) bool {
+		// the root directory of every btrfs subvolume has inode 256
+		st, ok := info.Sys().(*syscall.Stat_t)
+		return ok && info.IsDir() && st.Ino == 256
+	}
 )
 
 // createFilesystems creates the filesystems described in config.Storage.Filesystems.
@@ -46,6 +61,19 @@ func (s stage) createFilesystems(config types.Config) error {
 	s.Logger.PushPrefix("createFilesystems")
 	defer s.Logger.PopPrefix()
 
+	if s.Logger.DryRun() {
+		// the devices may not exist yet, so they can't be probed
+		for _, fs := range fss {
+			if fs.Format != nil && *fs.Format != "" && *fs.Format != "none" {
+				s.Logger.Info("dry run: would create %s filesystem on %q", *fs.Format, fs.Device)
+			}
+			for _, sv := range fs.Subvolumes {
+				s.Logger.Info("dry run: would create subvolume %q on %q unless it exists", sv, fs.Device)
+			}
+		}
+		return nil
+	}
+
 	devs := []string{}
 	for _, fs := range fss {
 		devs = append(devs, string(fs.Device))
@@ -57,13 +85,22 @@ func (s stage) createFilesystems(config types.Config) error {
 
 	// Create filesystems concurrently up to GOMAXPROCS
 	concurrency := runtime.GOMAXPROCS(-1)
+	type result struct {
+		device  string
+		created bool
+		err     error
+	}
 	work := make(chan types.Filesystem, len(fss))
-	results := make(chan error)
+	results := make(chan result)
 
 	for i := 0; i < concurrency; i++ {
 		go func() {
 			for fs := range work {
-				results <- s.createFilesystem(fs)
+				created, err := s.createFilesystem(fs)
+				if err != nil {
+					err = stages.NewError(name, fs.Device, err)
+				}
+				results <- result{device: fs.Device, created: created, err: err}
 			}
 		}()
 	}
@@ -73,24 +110,39 @@ func (s stage) createFilesystems(config types.Config) error {
 	}
 	close(work)
 
-	// Return combined errors
-	var errs []string
+	// Return combined errors. A lone failure keeps its device; there's no
+	// single resource to report if several filesystems failed.
+	var errs []error
 	for range fss {
-		if err := <-results; err != nil {
-			errs = append(errs, err.Error())
+		res := <-results
+		if res.err != nil {
+			errs = append(errs, res.err)
+		} else if res.created {
+			// record it for the mount stage, which only checks reused
+			// filesystems
+			s.State.CreatedFilesystems = append(s.State.CreatedFilesystems, res.device)
 		}
 	}
 
-	if len(errs) > 0 {
-		return errors.New(strings.Join(errs, "\n"))
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		msgs := make([]string, len(errs))
+		for i, err := range errs {
+			msgs[i] = err.Error()
+		}
+		return errors.New(strings.Join(msgs, "\n"))
 	}
-
-	return nil
 }
 
-func (s stage) createFilesystem(fs types.Filesystem) error {
+// createFilesystem creates the filesystem described by fs unless a matching
+// one can be reused. It returns whether a filesystem was created.
+func (s stage) createFilesystem(fs types.Filesystem) (bool, error) {
 	if fs.Format == nil {
-		return nil
+		return false, nil
 	}
 	devAlias := util.DeviceAlias(string(fs.Device))
 
@@ -115,26 +167,19 @@ func (s stage) createFilesystem(fs types.Filesystem) error {
 		"determining filesystem type of %q", fs.Device,
 	)
 	if err != nil {
-		return err
+		return false, err
 	}
 	s.Logger.Info("found %s filesystem at %q with uuid %q and label %q", info.Type, fs.Device, info.UUID, info.Label)
 
-	if !cutil.IsTrue(fs.WipeFilesystem) {
-		fileSystemFormat := *fs.Format
-		if fileSystemFormat == "none" {
-			fileSystemFormat = ""
-		}
-		// If the filesystem isn't forcefully being created, then we need
-		// to check if it is of the correct type or that no filesystem exists.
-		if info.Type == fileSystemFormat &&
-			(fs.Label == nil || info.Label == *fs.Label) &&
-			(fs.UUID == nil || canonicalizeFilesystemUUID(info.Type, info.UUID) == canonicalizeFilesystemUUID(fileSystemFormat, *fs.UUID)) {
-			s.Logger.Info("filesystem at %q is already correctly formatted. Skipping mkfs...", fs.Device)
-			return nil
-		} else if info.Type != "" {
-			s.Logger.Err("filesystem at %q is not of the correct type, label, or UUID (found %s, %q, %s) and a filesystem wipe was not requested", fs.Device, info.Type, info.Label, info.UUID)
-			return ErrBadFilesystem
+	if create, err := shouldCreateFilesystem(fs, info); err != nil {
+		s.Logger.Err("filesystem at %q is not of the correct type, label, or UUID (found %s, %q, %s) and a filesystem wipe was not requested", fs.Device, info.Type, info.Label, info.UUID)
+		return false, err
+	} else if !create {
+		s.Logger.Info("filesystem at %q is already correctly formatted. Skipping mkfs...", fs.Device)
+		if err := s.growFilesystem(fs, devAlias); err != nil {
+			return false, err
 		}
+		return false, s.createSubvolumes(fs, devAlias)
 	}
 
 	if _, err := s.Logger.LogCmd(
@@ -142,9 +187,200 @@ func (s stage) createFilesystem(fs types.Filesystem) error {
 		"wiping filesystem signatures from %q",
 		devAlias,
 	); err != nil {
-		return fmt.Errorf("wipefs failed: %v", err)
+		return false, fmt.Errorf("wipefs failed: %v", err)
+	}
+
+	mkfs, args, err := mkfsCommand(fs, devAlias)
+	if err != nil {
+		return false, err
+	}
+	if mkfs == "" {
+		// The user specifies format "none" to skip the creation of a
+		// filesystem on a block device.
+		return false, nil
+	}
+	if _, err := s.Logger.LogCmd(
+		exec.Command(mkfs, args...),
+		"creating %q filesystem on %q",
+		*fs.Format, devAlias,
+	); err != nil {
+		return false, fmt.Errorf("mkfs failed: %v", err)
+	}
+
+	return true, s.createSubvolumes(fs, devAlias)
+}
+
+// growFilesystem grows the existing filesystem on devAlias to fill the
+// device if fs requests it.
+func (s stage) growFilesystem(fs types.Filesystem, devAlias string) error {
+	if !cutil.IsTrue(fs.Resize) {
+		return nil
+	}
+
+	if *fs.Format == "ext4" {
+		// resize2fs won't grow an unmounted filesystem which hasn't
+		// just been checked
+		code, err := s.Logger.LogCmd(
+			exec.Command(distro.E2fsckCmd(), "-f", "-p", devAlias),
+			"checking %q before resizing", devAlias,
+		)
+		// 1 means errors were corrected
+		if err != nil && code != 1 {
+			return fmt.Errorf("e2fsck failed: %v", err)
+		}
+	}
+
+	cmd, args, mounted, err := growCommand(*fs.Format)
+	if err != nil {
+		return err
+	}
+	grow := func(target string) error {
+		if _, err := s.Logger.LogCmd(
+			exec.Command(cmd, append(args, target)...),
+			"resizing %q filesystem on %q", *fs.Format, devAlias,
+		); err != nil {
+			return fmt.Errorf("resizing filesystem failed: %v", err)
+		}
+		return nil
+	}
+	if !mounted {
+		return grow(devAlias)
+	}
+	return s.withTempMount(devAlias, *fs.Format, "resize", grow)
+}
+
+// growCommand returns the command and arguments which grow a filesystem of
+// the given format to fill its device. The device, or the mountpoint if
+// mounted is true, must be appended to the arguments.
+func growCommand(format string) (cmd string, args []string, mounted bool, err error) {
+	switch format {
+	case "ext4":
+		return distro.Resize2fsCmd(), nil, false, nil
+	case "xfs":
+		return distro.XfsGrowfsCmd(), nil, true, nil
+	case "btrfs":
+		return distro.BtrfsCmd(), []string{"filesystem", "resize", "max"}, true, nil
+	default:
+		return "", nil, false, fmt.Errorf("resizing %q filesystems is not supported", format)
+	}
+}
+
+// withTempMount mounts the top level of the filesystem on devAlias on a
+// temporary directory, calls f with the mountpoint, and unmounts it again.
+// In dry-run mode nothing is mounted and f isn't called.
+func (s stage) withTempMount(devAlias, format, purpose string, f func(mnt string) error) error {
+	if s.Logger.DryRun() {
+		s.Logger.Info("dry run: would mount %q to %s", devAlias, purpose)
+		return nil
+	}
+
+	mnt, err := ioutil.TempDir("", "ignition-"+format)
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	defer os.Remove(mnt)
+
+	args := []string{"-t", format}
+	if format == "btrfs" {
+		// subvolid=5 is always the top level, even if another default
+		// subvolume has been set
+		args = append(args, "-o", "subvolid=5")
+	}
+	if _, err := s.Logger.LogCmd(
+		exec.Command(distro.MountCmd(), append(args, devAlias, mnt)...),
+		"mounting %q to %s", devAlias, purpose,
+	); err != nil {
+		return fmt.Errorf("mounting %q failed: %v", devAlias, err)
+	}
+	defer func() {
+		_ = s.Logger.LogOp(
+			func() error { return ut.UmountPath(mnt) },
+			"unmounting %q at %q", devAlias, mnt,
+		)
+	}()
+
+	return f(mnt)
+}
+
+// createSubvolumes creates the btrfs subvolumes listed in fs which don't
+// already exist. The top level of the filesystem is temporarily mounted to
+// do so.
+func (s stage) createSubvolumes(fs types.Filesystem, devAlias string) error {
+	if len(fs.Subvolumes) == 0 {
+		return nil
 	}
 
+	return s.withTempMount(devAlias, "btrfs", "create subvolumes", func(mnt string) error {
+		paths, err := subvolumesToCreate(mnt, fs.Subvolumes)
+		if err != nil {
+			return err
+		}
+		for _, p := range paths {
+			if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+				return fmt.Errorf("creating parent directory of subvolume %q: %v", p, err)
+			}
+			if _, err := s.Logger.LogCmd(
+				exec.Command(distro.BtrfsCmd(), "subvolume", "create", p),
+				"creating subvolume %q on %q", strings.TrimPrefix(p, mnt+"/"), devAlias,
+			); err != nil {
+				return fmt.Errorf("creating subvolume failed: %v", err)
+			}
+		}
+		return nil
+	})
+}
+
+// subvolumesToCreate returns the paths under mnt of the subvolumes which
+// don't exist yet, ordered so that subvolumes nested in other subvolumes come
+// after them. A subvolume path which exists but isn't a subvolume, such as a
+// plain directory, results in ErrNotSubvolume.
+func subvolumesToCreate(mnt string, subvolumes []types.Subvolume) ([]string, error) {
+	var paths []string
+	for _, sv := range subvolumes {
+		p := filepath.Join(mnt, string(sv))
+		if info, err := os.Lstat(p); err == nil {
+			if !isSubvolume(info) {
+				return nil, fmt.Errorf("%q: %w", sv, ErrNotSubvolume)
+			}
+			continue
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// shouldCreateFilesystem determines whether the filesystem described by fs
+// needs to be created on a device currently holding the filesystem described
+// by info. Unless wipeFilesystem is set, an existing filesystem that doesn't
+// match fs results in ErrBadFilesystem.
+func shouldCreateFilesystem(fs types.Filesystem, info util.FilesystemInfo) (bool, error) {
+	if cutil.IsTrue(fs.WipeFilesystem) {
+		return true, nil
+	}
+
+	fileSystemFormat := *fs.Format
+	if fileSystemFormat == "none" {
+		fileSystemFormat = ""
+	}
+	// If the filesystem isn't forcefully being created, then we need
+	// to check if it is of the correct type or that no filesystem exists.
+	if info.Type == fileSystemFormat &&
+		(fs.Label == nil || info.Label == *fs.Label) &&
+		(fs.UUID == nil || canonicalizeFilesystemUUID(info.Type, info.UUID) == canonicalizeFilesystemUUID(fileSystemFormat, *fs.UUID)) {
+		return false, nil
+	} else if info.Type != "" {
+		return false, ErrBadFilesystem
+	}
+	return true, nil
+}
+
+// mkfsCommand returns the command and arguments needed to create the
+// filesystem described by fs on devAlias. An empty command is returned if no
+// filesystem should be created.
+func mkfsCommand(fs types.Filesystem, devAlias string) (string, []string, error) {
 	mkfs := ""
 	args := translateOptionSliceToStringSlice(fs.Options)
 	switch *fs.Format {
@@ -195,22 +431,13 @@ func (s stage) createFilesystem(fs types.Filesystem) error {
 			args = append(args, "-n", *fs.Label)
 		}
 	case "none":
-		// The user specifies this format to skip the creation of a filesystem on a block device.
-		return nil
+		return "", nil, nil
 	default:
-		return fmt.Errorf("unsupported filesystem format: %q", *fs.Format)
+		return "", nil, fmt.Errorf("unsupported filesystem format: %q", *fs.Format)
 	}
 
 	args = append(args, devAlias)
-	if _, err := s.Logger.LogCmd(
-		exec.Command(mkfs, args...),
-		"creating %q filesystem on %q",
-		*fs.Format, devAlias,
-	); err != nil {
-		return fmt.Errorf("mkfs failed: %v", err)
-	}
-
-	return nil
+	return mkfs, args, nil
 }
 
 // golang--
@@ -0,0 +1,400 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+	eutil "github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+func TestMkfsCommand(t *testing.T) {
+	type out struct {
+		mkfs string
+		args []string
+	}
+	tests := []struct {
+		in  types.Filesystem
+		out out
+	}{
+		{
+			in: types.Filesystem{Format: util.StrToPtr("swap")},
+			out: out{
+				mkfs: distro.SwapMkfsCmd(),
+				args: []string{"-f", "/dev/vda1"},
+			},
+		},
+		{
+			in: types.Filesystem{
+				Format: util.StrToPtr("swap"),
+				Label:  util.StrToPtr("swap"),
+				UUID:   util.StrToPtr("8a2b6aec-21a3-4d1a-97f2-1fbb8e6e76e5"),
+			},
+			out: out{
+				mkfs: distro.SwapMkfsCmd(),
+				args: []string{"-f", "-U", "8a2b6aec-21a3-4d1a-97f2-1fbb8e6e76e5", "-L", "swap", "/dev/vda1"},
+			},
+		},
+		{
+			in: types.Filesystem{
+				Format:  util.StrToPtr("swap"),
+				Options: []types.FilesystemOption{"-p", "4096"},
+			},
+			out: out{
+				mkfs: distro.SwapMkfsCmd(),
+				args: []string{"-p", "4096", "-f", "/dev/vda1"},
+			},
+		},
+		{
+			in: types.Filesystem{
+				Format: util.StrToPtr("ext4"),
+				Label:  util.StrToPtr("root"),
+			},
+			out: out{
+				mkfs: distro.Ext4MkfsCmd(),
+				args: []string{"-F", "-L", "root", "/dev/vda1"},
+			},
+		},
+		// options precede the arguments Ignition adds and the device
+		{
+			in: types.Filesystem{
+				Format:  util.StrToPtr("xfs"),
+				Label:   util.StrToPtr("data"),
+				UUID:    util.StrToPtr("8a2b6aec-21a3-4d1a-97f2-1fbb8e6e76e5"),
+				Options: []types.FilesystemOption{"-m", "crc=1", "-d", "su=64k,sw=4"},
+			},
+			out: out{
+				mkfs: distro.XfsMkfsCmd(),
+				args: []string{"-m", "crc=1", "-d", "su=64k,sw=4", "-f", "-m", "uuid=8a2b6aec-21a3-4d1a-97f2-1fbb8e6e76e5", "-L", "data", "/dev/vda1"},
+			},
+		},
+		{
+			in:  types.Filesystem{Format: util.StrToPtr("none")},
+			out: out{},
+		},
+	}
+
+	for i, test := range tests {
+		mkfs, args, err := mkfsCommand(test.in, "/dev/vda1")
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if mkfs != test.out.mkfs {
+			t.Errorf("#%d: bad command: want %q, got %q", i, test.out.mkfs, mkfs)
+		}
+		if !reflect.DeepEqual(args, test.out.args) {
+			t.Errorf("#%d: bad args: want %v, got %v", i, test.out.args, args)
+		}
+	}
+}
+
+func TestShouldCreateFilesystemDifferentFilesystem(t *testing.T) {
+	type out struct {
+		create bool
+		err    error
+	}
+	tests := []struct {
+		fs   types.Filesystem
+		info eutil.FilesystemInfo
+		out  out
+	}{
+		// different format, no wipe
+		{
+			fs:   types.Filesystem{Format: util.StrToPtr("xfs")},
+			info: eutil.FilesystemInfo{Type: "ext4"},
+			out:  out{err: ErrBadFilesystem},
+		},
+		// different format, wipe
+		{
+			fs: types.Filesystem{
+				Format:         util.StrToPtr("xfs"),
+				WipeFilesystem: util.BoolToPtr(true),
+			},
+			info: eutil.FilesystemInfo{Type: "ext4"},
+			out:  out{create: true},
+		},
+		// existing filesystem, none requested, no wipe
+		{
+			fs:   types.Filesystem{Format: util.StrToPtr("none")},
+			info: eutil.FilesystemInfo{Type: "ext4"},
+			out:  out{err: ErrBadFilesystem},
+		},
+		// existing filesystem, none requested, wipe
+		{
+			fs: types.Filesystem{
+				Format:         util.StrToPtr("none"),
+				WipeFilesystem: util.BoolToPtr(true),
+			},
+			info: eutil.FilesystemInfo{Type: "ext4"},
+			out:  out{create: true},
+		},
+		// explicitly not wiping behaves like the default
+		{
+			fs: types.Filesystem{
+				Format:         util.StrToPtr("btrfs"),
+				WipeFilesystem: util.BoolToPtr(false),
+			},
+			info: eutil.FilesystemInfo{Type: "vfat"},
+			out:  out{err: ErrBadFilesystem},
+		},
+	}
+
+	for i, test := range tests {
+		create, err := shouldCreateFilesystem(test.fs, test.info)
+		if err != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if create != test.out.create {
+			t.Errorf("#%d: bad create: want %v, got %v", i, test.out.create, create)
+		}
+	}
+}
+
+func TestShouldCreateFilesystemPreserve(t *testing.T) {
+	type out struct {
+		create bool
+		err    error
+	}
+	tests := []struct {
+		fs   types.Filesystem
+		info eutil.FilesystemInfo
+		out  out
+	}{
+		// empty device is formatted
+		{
+			fs:   types.Filesystem{Format: util.StrToPtr("ext4")},
+			info: eutil.FilesystemInfo{},
+			out:  out{create: true},
+		},
+		// matching format is preserved
+		{
+			fs:   types.Filesystem{Format: util.StrToPtr("ext4")},
+			info: eutil.FilesystemInfo{Type: "ext4", Label: "data", UUID: "8a2b6aec-21a3-4d1a-97f2-1fbb8e6e76e5"},
+			out:  out{create: false},
+		},
+		// matching format, label, and UUID is preserved
+		{
+			fs: types.Filesystem{
+				Format: util.StrToPtr("ext4"),
+				Label:  util.StrToPtr("data"),
+				UUID:   util.StrToPtr("8A2B6AEC-21A3-4D1A-97F2-1FBB8E6E76E5"),
+			},
+			info: eutil.FilesystemInfo{Type: "ext4", Label: "data", UUID: "8a2b6aec-21a3-4d1a-97f2-1fbb8e6e76e5"},
+			out:  out{create: false},
+		},
+		// vfat volume IDs match with or without the dash
+		{
+			fs: types.Filesystem{
+				Format: util.StrToPtr("vfat"),
+				UUID:   util.StrToPtr("a1b2c3d4"),
+			},
+			info: eutil.FilesystemInfo{Type: "vfat", UUID: "A1B2-C3D4"},
+			out:  out{create: false},
+		},
+		// none matches an empty device
+		{
+			fs:   types.Filesystem{Format: util.StrToPtr("none")},
+			info: eutil.FilesystemInfo{},
+			out:  out{create: false},
+		},
+		// label mismatch, no wipe
+		{
+			fs: types.Filesystem{
+				Format: util.StrToPtr("ext4"),
+				Label:  util.StrToPtr("root"),
+			},
+			info: eutil.FilesystemInfo{Type: "ext4", Label: "data"},
+			out:  out{err: ErrBadFilesystem},
+		},
+		// UUID mismatch, no wipe
+		{
+			fs: types.Filesystem{
+				Format: util.StrToPtr("xfs"),
+				UUID:   util.StrToPtr("8a2b6aec-21a3-4d1a-97f2-1fbb8e6e76e5"),
+			},
+			info: eutil.FilesystemInfo{Type: "xfs", UUID: "0c8a9a7f-1b2c-4d3e-8f90-a1b2c3d4e5f6"},
+			out:  out{err: ErrBadFilesystem},
+		},
+		// label mismatch, wipe reformats
+		{
+			fs: types.Filesystem{
+				Format:         util.StrToPtr("ext4"),
+				Label:          util.StrToPtr("root"),
+				WipeFilesystem: util.BoolToPtr(true),
+			},
+			info: eutil.FilesystemInfo{Type: "ext4", Label: "data"},
+			out:  out{create: true},
+		},
+		// matching filesystem, wipe still reformats
+		{
+			fs: types.Filesystem{
+				Format:         util.StrToPtr("ext4"),
+				WipeFilesystem: util.BoolToPtr(true),
+			},
+			info: eutil.FilesystemInfo{Type: "ext4"},
+			out:  out{create: true},
+		},
+	}
+
+	for i, test := range tests {
+		create, err := shouldCreateFilesystem(test.fs, test.info)
+		if err != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if create != test.out.create {
+			t.Errorf("#%d: bad create: want %v, got %v", i, test.out.create, create)
+		}
+	}
+}
+
+func TestSubvolumesToCreate(t *testing.T) {
+	mnt, err := ioutil.TempDir("", "ignition-disks-test")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(mnt)
+	for _, dir := range []string{"existing", "plain"} {
+		if err := os.Mkdir(filepath.Join(mnt, dir), 0755); err != nil {
+			t.Fatalf("creating directory: %v", err)
+		}
+	}
+	// pretend that "existing" is a subvolume
+	oldIsSubvolume := isSubvolume
+	isSubvolume = func(info os.FileInfo) bool {
+		return info.Name() == "existing"
+	}
+	defer func() { isSubvolume = oldIsSubvolume }()
+
+	tests := []struct {
+		in  []types.Subvolume
+		out []string
+		err error
+	}{
+		{
+			in: nil,
+		},
+		{
+			in:  []types.Subvolume{"root", "home"},
+			out: []string{"home", "root"},
+		},
+		// parents are created before nested subvolumes
+		{
+			in:  []types.Subvolume{"var/lib/containers", "var"},
+			out: []string{"var", "var/lib/containers"},
+		},
+		{
+			in:  []types.Subvolume{"existing", "home"},
+			out: []string{"home"},
+		},
+		// a directory isn't a subvolume
+		{
+			in:  []types.Subvolume{"plain", "home"},
+			err: ErrNotSubvolume,
+		},
+	}
+
+	for i, test := range tests {
+		paths, err := subvolumesToCreate(mnt, test.in)
+		if !errors.Is(err, test.err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+			continue
+		}
+		var out []string
+		for _, p := range paths {
+			rel, err := filepath.Rel(mnt, p)
+			if err != nil {
+				t.Fatalf("#%d: bad path %q: %v", i, p, err)
+			}
+			out = append(out, rel)
+		}
+		if !reflect.DeepEqual(test.out, out) {
+			t.Errorf("#%d: bad subvolumes: want %v, got %v", i, test.out, out)
+		}
+	}
+}
+
+func TestWithTempMountDryRun(t *testing.T) {
+	logger := log.New(true)
+	logger.SetDryRun(true)
+	s := stage{Util: eutil.Util{Logger: &logger}}
+	err := s.withTempMount("/dev/null", "btrfs", "create subvolumes", func(mnt string) error {
+		t.Errorf("called with %q in dry-run mode", mnt)
+		return nil
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestGrowCommand(t *testing.T) {
+	tests := []struct {
+		format  string
+		cmd     string
+		args    []string
+		mounted bool
+		hasErr  bool
+	}{
+		{
+			format: "ext4",
+			cmd:    distro.Resize2fsCmd(),
+		},
+		{
+			format:  "xfs",
+			cmd:     distro.XfsGrowfsCmd(),
+			mounted: true,
+		},
+		{
+			format:  "btrfs",
+			cmd:     distro.BtrfsCmd(),
+			args:    []string{"filesystem", "resize", "max"},
+			mounted: true,
+		},
+		{
+			format: "vfat",
+			hasErr: true,
+		},
+		{
+			format: "swap",
+			hasErr: true,
+		},
+	}
+
+	for i, test := range tests {
+		cmd, args, mounted, err := growCommand(test.format)
+		if test.hasErr {
+			if err == nil {
+				t.Errorf("#%d: expected error for %q", i, test.format)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if cmd != test.cmd || !reflect.DeepEqual(test.args, args) || mounted != test.mounted {
+			t.Errorf("#%d: bad command: want %s %v (mounted %v), got %s %v (mounted %v)", i, test.cmd, test.args, test.mounted, cmd, args, mounted)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+	"github.com/coreos/ignition/v2/internal/exec/stages"
+	"github.com/coreos/ignition/v2/internal/exec/util"
+)
+
+var (
+	// sysBlockDir is where the kernel exposes block device attributes;
+	// tests replace it.
+	sysBlockDir = "/sys/block"
+	// losetupCommand is replaced by tests.
+	losetupCommand = exec.Command
+)
+
+// createLoops attaches the loop devices described in config.Storage.Loops
+// to their backing files. Backing files declared in config.Storage.Files
+// are written first; others are created if requested.
+func (s stage) createLoops(config types.Config) error {
+	if len(config.Storage.Loops) == 0 {
+		return nil
+	}
+	s.Logger.PushPrefix("createLoops")
+	defer s.Logger.PopPrefix()
+
+	devs := []string{}
+	for _, loop := range config.Storage.Loops {
+		attached, err := loopBackingFile(loop.Device)
+		if err != nil {
+			return stages.NewError(name, loop.Device, fmt.Errorf("checking %q: %w", loop.Device, err))
+		}
+		if attached == *loop.File {
+			s.Logger.Info("%q is already attached to %q", loop.Device, attached)
+			devs = append(devs, loop.Device)
+			continue
+		} else if attached != "" {
+			return stages.NewError(name, loop.Device, fmt.Errorf("%q is attached to %q, not %q", loop.Device, attached, *loop.File))
+		}
+
+		if err := s.attachLoop(loop, config.Storage.Files); err != nil {
+			return stages.NewError(name, loop.Device, err)
+		}
+		devs = append(devs, loop.Device)
+	}
+
+	return s.waitOnDevicesAndCreateAliases(devs, "loops")
+}
+
+// attachLoop prepares loop's backing file and attaches loop to it. The
+// files stage runs after the disks stage, so a backing file declared in
+// files is written here rather than there.
+func (s stage) attachLoop(loop types.Loop, files []types.File) error {
+	if f := loop.BackingFile(files); f != nil {
+		if err := s.writeBackingFile(*f); err != nil {
+			return fmt.Errorf("writing backing file %q: %w", f.Path, err)
+		}
+	} else if err := s.createBackingFile(loop); err != nil {
+		return err
+	}
+	if _, err := s.Logger.LogCmd(
+		losetupCommand(distro.LosetupCmd(), losetupArgs(loop)...),
+		"attaching %q to %q", loop.Device, *loop.File,
+	); err != nil {
+		return fmt.Errorf("losetup failed: %w", err)
+	}
+	return nil
+}
+
+// writeBackingFile writes a backing file declared in storage.files to the
+// initramfs.
+func (s stage) writeBackingFile(f types.File) error {
+	if s.Logger.DryRun() {
+		s.Logger.Info("dry run: would write backing file %q", f.Path)
+		return nil
+	}
+	u := s.Util
+	u.DestDir = s.initramfsDir
+	path, err := u.JoinPath(f.Path)
+	if err != nil {
+		return err
+	}
+	f.Path = path
+	if f.Contents.Source == nil && len(f.Append) == 0 {
+		empty := ""
+		f.Contents.Source = &empty
+	}
+	fetchOps, err := u.PrepareFetches(s.Logger, f)
+	if err != nil {
+		return err
+	}
+	for _, op := range fetchOps {
+		if err := s.Logger.LogOp(func() error {
+			return u.PerformFetch(op)
+		}, "writing backing file %q", f.Path); err != nil {
+			return err
+		}
+	}
+	return u.SetPermissions(f.Mode, f.Node)
+}
+
+// createBackingFile creates a sparse backing file of loop.SizeMiB if the
+// file doesn't exist. Existing files are left alone.
+func (s stage) createBackingFile(loop types.Loop) error {
+	if loop.SizeMiB == nil {
+		return nil
+	}
+	if _, err := os.Stat(*loop.File); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if s.Logger.DryRun() {
+		s.Logger.Info("dry run: would create %d MiB backing file %q", *loop.SizeMiB, *loop.File)
+		return nil
+	}
+
+	return s.Logger.LogOp(func() error {
+		if err := util.MkdirForFile(*loop.File); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(*loop.File, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return f.Truncate(int64(*loop.SizeMiB) * 1024 * 1024)
+	}, "creating backing file %q", *loop.File)
+}
+
+// loopBackingFile returns the file dev is attached to, or "" if dev isn't
+// attached.
+func loopBackingFile(dev string) (string, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(sysBlockDir, filepath.Base(dev), "loop", "backing_file"))
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(contents), "\n"), nil
+}
+
+// losetupArgs returns the arguments to losetup for attaching the given
+// loop device.
+func losetupArgs(loop types.Loop) []string {
+	var args []string
+	if cutil.IsTrue(loop.ReadOnly) {
+		args = append(args, "--read-only")
+	}
+	return append(args, loop.Device, *loop.File)
+}
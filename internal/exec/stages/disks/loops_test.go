@@ -0,0 +1,174 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	eutil "github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/resource"
+)
+
+func TestLosetupArgs(t *testing.T) {
+	tests := []struct {
+		in  types.Loop
+		out []string
+	}{
+		{
+			in: types.Loop{
+				Device: "/dev/loop0",
+				File:   util.StrToPtr("/run/disk.img"),
+			},
+			out: []string{"/dev/loop0", "/run/disk.img"},
+		},
+		{
+			in: types.Loop{
+				Device:   "/dev/loop3",
+				File:     util.StrToPtr("/run/disk.img"),
+				ReadOnly: util.BoolToPtr(true),
+			},
+			out: []string{"--read-only", "/dev/loop3", "/run/disk.img"},
+		},
+	}
+
+	for i, test := range tests {
+		if out := losetupArgs(test.in); !reflect.DeepEqual(test.out, out) {
+			t.Errorf("#%d: bad args: want %v, got %v", i, test.out, out)
+		}
+	}
+}
+
+func TestLoopBackingFile(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ignition-disks-test")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+	oldSysBlockDir := sysBlockDir
+	sysBlockDir = tmp
+	defer func() { sysBlockDir = oldSysBlockDir }()
+
+	if err := os.MkdirAll(filepath.Join(tmp, "loop0", "loop"), 0755); err != nil {
+		t.Fatalf("creating loop0: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmp, "loop0", "loop", "backing_file"), []byte("/run/disk.img\n"), 0644); err != nil {
+		t.Fatalf("writing backing_file: %v", err)
+	}
+	// detached loop devices have no loop directory
+	if err := os.MkdirAll(filepath.Join(tmp, "loop1"), 0755); err != nil {
+		t.Fatalf("creating loop1: %v", err)
+	}
+
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"/dev/loop0", "/run/disk.img"},
+		{"/dev/loop1", ""},
+		{"/dev/loop2", ""},
+	}
+
+	for i, test := range tests {
+		out, err := loopBackingFile(test.in)
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if out != test.out {
+			t.Errorf("#%d: bad backing file: want %q, got %q", i, test.out, out)
+		}
+	}
+}
+
+func TestAttachLoopDeclaredFile(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ignition-disks-test")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	// record the backing file's contents when losetup runs
+	var calls [][]string
+	var contents []string
+	oldLosetupCommand := losetupCommand
+	losetupCommand = func(name string, args ...string) *exec.Cmd {
+		calls = append(calls, args)
+		data, err := ioutil.ReadFile(filepath.Join(tmp, args[len(args)-1]))
+		if err != nil {
+			t.Errorf("backing file missing when attaching %s: %v", args[len(args)-2], err)
+		}
+		contents = append(contents, string(data))
+		return exec.Command("true")
+	}
+	defer func() { losetupCommand = oldLosetupCommand }()
+
+	logger := log.New(true)
+	s := stage{
+		Util: eutil.Util{
+			DestDir: filepath.Join(tmp, "sysroot"),
+			Logger:  &logger,
+			Fetcher: resource.Fetcher{Logger: &logger},
+		},
+		initramfsDir: tmp,
+	}
+	files := []types.File{
+		{
+			Node: types.Node{Path: "/run/disk.img"},
+			FileEmbedded1: types.FileEmbedded1{
+				Contents: types.Resource{Source: util.StrToPtr("data:,hello")},
+				Target:   util.StrToPtr("initramfs"),
+			},
+		},
+	}
+	loops := []types.Loop{
+		{
+			Device: "/dev/loop0",
+			File:   util.StrToPtr("/run/disk.img"),
+		},
+		{
+			Device:   "/dev/loop1",
+			File:     util.StrToPtr("/run/disk.img"),
+			ReadOnly: util.BoolToPtr(true),
+		},
+	}
+	for _, loop := range loops {
+		if err := s.attachLoop(loop, files); err != nil {
+			t.Fatalf("attaching %s: %v", loop.Device, err)
+		}
+	}
+
+	expectedCalls := [][]string{
+		{"/dev/loop0", "/run/disk.img"},
+		{"--read-only", "/dev/loop1", "/run/disk.img"},
+	}
+	if !reflect.DeepEqual(expectedCalls, calls) {
+		t.Errorf("bad losetup calls: want %v, got %v", expectedCalls, calls)
+	}
+	expectedContents := []string{"hello", "hello"}
+	if !reflect.DeepEqual(expectedContents, contents) {
+		t.Errorf("bad backing file contents: want %v, got %v", expectedContents, contents)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "sysroot")); !os.IsNotExist(err) {
+		t.Errorf("backing file written to the sysroot: %v", err)
+	}
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/coreos/ignition/v2/config/util"
 	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
 	"github.com/coreos/ignition/v2/internal/distro"
+	"github.com/coreos/ignition/v2/internal/exec/stages"
 	execUtil "github.com/coreos/ignition/v2/internal/exec/util"
 	"github.com/coreos/ignition/v2/internal/resource"
 
@@ -75,6 +76,36 @@ type Clevis struct {
 	Threshold int `json:"t"`
 }
 
+// clevisPinConfig returns the clevis pin and its JSON configuration for the
+// given binding.
+func clevisPinConfig(clevis types.Clevis) (string, string, error) {
+	if util.NotEmpty(clevis.Custom.Pin) {
+		return *clevis.Custom.Pin, *clevis.Custom.Config, nil
+	}
+
+	// if the override pin is empty the config must also be empty
+	c := Clevis{
+		Threshold: 1,
+	}
+	if clevis.Threshold != nil {
+		c.Threshold = *clevis.Threshold
+	}
+	for _, tang := range clevis.Tang {
+		c.Pins.Tang = append(c.Pins.Tang, Tang{
+			URL:        tang.URL,
+			Thumbprint: *tang.Thumbprint,
+		})
+	}
+	if clevis.Tpm2 != nil {
+		c.Pins.Tpm = *clevis.Tpm2
+	}
+	clevisJson, err := json.Marshal(c)
+	if err != nil {
+		return "", "", fmt.Errorf("creating clevis json: %v", err)
+	}
+	return "sss", string(clevisJson), nil
+}
+
 // Initially tested generating keyfiles via dd'ing to a file from /dev/urandom
 // however while cryptsetup had no problem with these keyfiles clevis seemed to
 // die on them while keyfiles generated via openssl rand -hex would work...
@@ -96,6 +127,13 @@ func (s *stage) createLuks(config types.Config) error {
 	s.Logger.PushPrefix("createLuks")
 	defer s.Logger.PopPrefix()
 
+	if s.Logger.DryRun() {
+		for _, luks := range config.Storage.Luks {
+			s.Logger.Info("dry run: would create LUKS volume %q on %q", luks.Name, *luks.Device)
+		}
+		return nil
+	}
+
 	devs := []string{}
 	for _, luks := range config.Storage.Luks {
 		devs = append(devs, *luks.Device)
@@ -105,242 +143,231 @@ func (s *stage) createLuks(config types.Config) error {
 		return err
 	}
 
-	s.State.LuksPersistKeyFiles = make(map[string]string)
-
 	for _, luks := range config.Storage.Luks {
-		// TODO: allow Ignition generated KeyFiles for
-		// non-clevis devices that can be persisted.
-		// TODO: create devices in parallel.
-		// track whether Ignition creates the KeyFile
-		// so that it can be removed
-		var ignitionCreatedKeyFile bool
-		// create keyfile, remove on the way out
-		keyFile, err := ioutil.TempFile("", "ignition-luks-")
+		if err := s.createLuksVolume(luks); err != nil {
+			return stages.NewError(name, luks.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// createLuksVolume formats and opens a single LUKS volume, or reuses it if
+// it's already suitable.
+func (s *stage) createLuksVolume(luks types.Luks) error {
+	// TODO: allow Ignition generated KeyFiles for
+	// non-clevis devices that can be persisted.
+	// TODO: create devices in parallel.
+	// track whether Ignition creates the KeyFile
+	// so that it can be removed
+	var ignitionCreatedKeyFile bool
+	// create keyfile, remove on the way out
+	keyFile, err := ioutil.TempFile("", "ignition-luks-")
+	if err != nil {
+		return fmt.Errorf("creating keyfile: %w", err)
+	}
+	keyFilePath := keyFile.Name()
+	keyFile.Close()
+	defer os.Remove(keyFilePath)
+	devAlias := execUtil.DeviceAlias(*luks.Device)
+	if util.NilOrEmpty(luks.KeyFile.Source) {
+		// generate keyfile contents
+		key, err := randHex(4096)
 		if err != nil {
-			return fmt.Errorf("creating keyfile: %w", err)
+			return fmt.Errorf("generating keyfile: %v", err)
 		}
-		keyFilePath := keyFile.Name()
-		keyFile.Close()
-		defer os.Remove(keyFilePath)
-		devAlias := execUtil.DeviceAlias(*luks.Device)
-		if util.NilOrEmpty(luks.KeyFile.Source) {
-			// generate keyfile contents
-			key, err := randHex(4096)
-			if err != nil {
-				return fmt.Errorf("generating keyfile: %v", err)
-			}
-			if err := ioutil.WriteFile(keyFilePath, []byte(key), 0400); err != nil {
-				return fmt.Errorf("creating keyfile: %v", err)
-			}
-			ignitionCreatedKeyFile = true
-		} else {
-			f := types.File{
-				Node: types.Node{
-					Path: keyFilePath,
-				},
-				FileEmbedded1: types.FileEmbedded1{
-					Contents: luks.KeyFile,
-				},
-			}
-			fetchOps, err := s.Util.PrepareFetches(s.Util.Logger, f)
-			if err != nil {
-				return fmt.Errorf("failed to resolve keyfile %q: %v", f.Path, err)
-			}
-			for _, op := range fetchOps {
-				if err := s.Util.Logger.LogOp(
-					func() error {
-						return s.Util.PerformFetch(op)
-					}, "writing file %q", f.Path,
-				); err != nil {
-					return fmt.Errorf("failed to create keyfile %q: %v", op.Node.Path, err)
-				}
-			}
+		if err := ioutil.WriteFile(keyFilePath, []byte(key), 0400); err != nil {
+			return fmt.Errorf("creating keyfile: %v", err)
 		}
-		// store the key to be persisted into the real root
-		// do this here so device reuse works correctly
-		key, err := ioutil.ReadFile(keyFilePath)
+		ignitionCreatedKeyFile = true
+	} else {
+		f := types.File{
+			Node: types.Node{
+				Path: keyFilePath,
+			},
+			FileEmbedded1: types.FileEmbedded1{
+				Contents: luks.KeyFile,
+			},
+		}
+		fetchOps, err := s.Util.PrepareFetches(s.Util.Logger, f)
 		if err != nil {
-			return fmt.Errorf("failed to read keyfile %q: %w", keyFilePath, err)
+			return fmt.Errorf("failed to resolve keyfile %q: %v", f.Path, err)
 		}
-		s.State.LuksPersistKeyFiles[luks.Name] = dataurl.EncodeBytes(key)
-
-		if !util.IsTrue(luks.WipeVolume) {
-			// If the volume isn't forcefully being created, then we need
-			// to check if it is of the correct type or that no volume exists.
-
-			if s.isLuksDevice(*luks.Device) {
-				// try to reuse the LUKS device; device will be opened
-				// if successful.
-				if err := s.reuseLuksDevice(luks, keyFilePath); err != nil {
-					s.Logger.Err("volume wipe was not requested and luks device %q could not be reused: %v", *luks.Device, err)
-					return ErrBadVolume
-				}
-				// Re-used devices cannot have Ignition generated key-files or be clevis devices so we cannot
-				// leak any key files when exiting the loop early
-				s.Logger.Info("volume at %q is already correctly formatted. Skipping...", *luks.Device)
-				continue
-			}
-
-			var info execUtil.FilesystemInfo
-			err := s.Logger.LogOp(
+		for _, op := range fetchOps {
+			if err := s.Util.Logger.LogOp(
 				func() error {
-					var err error
-					info, err = execUtil.GetFilesystemInfo(devAlias, false)
-					if err != nil {
-						// Try again, allowing multiple filesystem
-						// fingerprints this time.  If successful,
-						// log a warning and continue.
-						var err2 error
-						info, err2 = execUtil.GetFilesystemInfo(devAlias, true)
-						if err2 == nil {
-							s.Logger.Warning("%v", err)
-						}
-						err = err2
-					}
-					return err
-				},
-				"determining volume type of %q", *luks.Device,
-			)
-			if err != nil {
-				return err
-			}
-			s.Logger.Info("found %s at %q with uuid %q and label %q", info.Type, *luks.Device, info.UUID, info.Label)
-			if info.Type != "" {
-				s.Logger.Err("volume at %q is not of the correct type (found %s) and a volume wipe was not requested", *luks.Device, info.Type)
-				return ErrBadVolume
-			}
-		} else {
-			if _, err := s.Logger.LogCmd(
-				exec.Command(distro.WipefsCmd(), "-a", devAlias),
-				"wiping filesystem signatures from %q",
-				devAlias,
+					return s.Util.PerformFetch(op)
+				}, "writing file %q", f.Path,
 			); err != nil {
-				return fmt.Errorf("wipefs failed: %v", err)
+				return fmt.Errorf("failed to create keyfile %q: %v", op.Node.Path, err)
 			}
 		}
+	}
+	// store the key to be persisted into the real root
+	// do this here so device reuse works correctly
+	key, err := ioutil.ReadFile(keyFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read keyfile %q: %w", keyFilePath, err)
+	}
+	s.State.LuksPersistKeyFiles[luks.Name] = dataurl.EncodeBytes(key)
 
-		args := []string{
-			"luksFormat",
-			"--type", "luks2",
-			"--key-file", keyFilePath,
+	if !util.IsTrue(luks.WipeVolume) {
+		// If the volume isn't forcefully being created, then we need
+		// to check if it is of the correct type or that no volume exists.
+
+		if s.isLuksDevice(*luks.Device) {
+			// try to reuse the LUKS device; device will be opened
+			// if successful.
+			if err := s.reuseLuksDevice(luks, keyFilePath); err != nil {
+				s.Logger.Err("volume wipe was not requested and luks device %q could not be reused: %v", *luks.Device, err)
+				return ErrBadVolume
+			}
+			// Re-used devices cannot have Ignition generated key-files or be clevis devices so we cannot
+			// leak any key files when exiting the loop early
+			s.Logger.Info("volume at %q is already correctly formatted. Skipping...", *luks.Device)
+			return nil
 		}
 
-		if !util.NilOrEmpty(luks.Label) {
-			args = append(args, "--label", *luks.Label)
+		var info execUtil.FilesystemInfo
+		err := s.Logger.LogOp(
+			func() error {
+				var err error
+				info, err = execUtil.GetFilesystemInfo(devAlias, false)
+				if err != nil {
+					// Try again, allowing multiple filesystem
+					// fingerprints this time.  If successful,
+					// log a warning and continue.
+					var err2 error
+					info, err2 = execUtil.GetFilesystemInfo(devAlias, true)
+					if err2 == nil {
+						s.Logger.Warning("%v", err)
+					}
+					err = err2
+				}
+				return err
+			},
+			"determining volume type of %q", *luks.Device,
+		)
+		if err != nil {
+			return err
 		}
+		s.Logger.Info("found %s at %q with uuid %q and label %q", info.Type, *luks.Device, info.UUID, info.Label)
+		if info.Type != "" {
+			s.Logger.Err("volume at %q is not of the correct type (found %s) and a volume wipe was not requested", *luks.Device, info.Type)
+			return ErrBadVolume
+		}
+	} else {
+		if _, err := s.Logger.LogCmd(
+			exec.Command(distro.WipefsCmd(), "-a", devAlias),
+			"wiping filesystem signatures from %q",
+			devAlias,
+		); err != nil {
+			return fmt.Errorf("wipefs failed: %v", err)
+		}
+	}
+
+	if _, err := s.Logger.LogCmd(
+		exec.Command(distro.CryptsetupCmd(), luksFormatArgs(luks, devAlias, keyFilePath)...),
+		"creating %q", luks.Name,
+	); err != nil {
+		return fmt.Errorf("cryptsetup failed: %v", err)
+	}
 
-		if !util.NilOrEmpty(luks.UUID) {
-			args = append(args, "--uuid", *luks.UUID)
+	// open the device
+	if _, err := s.Logger.LogCmd(
+		exec.Command(distro.CryptsetupCmd(), luksOpenArgs(luks, devAlias, keyFilePath)...),
+		"opening luks device %v", luks.Name,
+	); err != nil {
+		return fmt.Errorf("opening luks device: %v", err)
+	}
+
+	if luks.Clevis.IsPresent() {
+		pin, config, err := clevisPinConfig(luks.Clevis)
+		if err != nil {
+			return err
 		}
 
-		if len(luks.Options) > 0 {
-			// golang's a really great language...
-			for _, option := range luks.Options {
-				args = append(args, string(option))
+		// We cannot guarantee that networking is up yet, loop
+		// through each tang device and fetch the server
+		// advertisement to utilize Ignition's retry logic before we
+		// pass the device to clevis. We have to loop each device as
+		// the devices could be on different NICs that haven't come
+		// up yet.
+		for _, tang := range luks.Clevis.Tang {
+			u, err := url.Parse(tang.URL)
+			if err != nil {
+				return fmt.Errorf("parsing tang URL: %v", err)
+			}
+			u.Path = path.Join(u.Path, "adv")
+			_, err = s.Fetcher.FetchToBuffer(*u, resource.FetchOptions{})
+			if err != nil {
+				return fmt.Errorf("fetching tang advertisement: %v", err)
 			}
 		}
 
-		args = append(args, devAlias)
+		if _, err := s.Logger.LogCmd(
+			exec.Command(distro.ClevisCmd(), "luks", "bind", "-f", "-k", keyFilePath, "-d", devAlias, pin, config), "Clevis bind",
+		); err != nil {
+			return fmt.Errorf("binding clevis device: %v", err)
+		}
 
+		// close & re-open Clevis devices to make sure that we can unlock them
 		if _, err := s.Logger.LogCmd(
-			exec.Command(distro.CryptsetupCmd(), args...),
-			"creating %q", luks.Name,
+			exec.Command(distro.CryptsetupCmd(), "luksClose", luks.Name),
+			"closing clevis luks device %v", luks.Name,
 		); err != nil {
-			return fmt.Errorf("cryptsetup failed: %v", err)
+			return fmt.Errorf("closing luks device: %v", err)
 		}
+		if _, err := s.Logger.LogCmd(
+			exec.Command(distro.ClevisCmd(), "luks", "unlock", "-d", devAlias, "-n", luks.Name),
+			"reopening clevis luks device %s", luks.Name,
+		); err != nil {
+			return fmt.Errorf("reopening luks device %s: %v", luks.Name, err)
+		}
+	}
 
-		// open the device
+	if ignitionCreatedKeyFile && luks.Clevis.IsPresent() {
+		// assume the user does not want the generated key & remove it
 		if _, err := s.Logger.LogCmd(
-			exec.Command(distro.CryptsetupCmd(), "luksOpen", devAlias, luks.Name, "--key-file", keyFilePath),
-			"opening luks device %v", luks.Name,
+			exec.Command(distro.CryptsetupCmd(), "luksRemoveKey", devAlias, keyFilePath),
+			"removing key file for %v", luks.Name,
 		); err != nil {
-			return fmt.Errorf("opening luks device: %v", err)
+			return fmt.Errorf("removing key file from luks device: %v", err)
 		}
+		delete(s.State.LuksPersistKeyFiles, luks.Name)
+	}
 
-		if luks.Clevis.IsPresent() {
-			var pin string
-			var config string
-
-			if util.NotEmpty(luks.Clevis.Custom.Pin) {
-				pin = *luks.Clevis.Custom.Pin
-				config = *luks.Clevis.Custom.Config
-			} else {
-				// if the override pin is empty the config must also be empty
-				pin = "sss"
-				c := Clevis{
-					Threshold: 1,
-				}
-				if luks.Clevis.Threshold != nil {
-					c.Threshold = *luks.Clevis.Threshold
-				}
-				for _, tang := range luks.Clevis.Tang {
-					c.Pins.Tang = append(c.Pins.Tang, Tang{
-						URL:        tang.URL,
-						Thumbprint: *tang.Thumbprint,
-					})
-				}
-				if luks.Clevis.Tpm2 != nil {
-					c.Pins.Tpm = *luks.Clevis.Tpm2
-				}
-				clevisJson, err := json.Marshal(c)
-				if err != nil {
-					return fmt.Errorf("creating clevis json: %v", err)
-				}
-				config = string(clevisJson)
-			}
+	return nil
+}
 
-			// We cannot guarantee that networking is up yet, loop
-			// through each tang device and fetch the server
-			// advertisement to utilize Ignition's retry logic before we
-			// pass the device to clevis. We have to loop each device as
-			// the devices could be on different NICs that haven't come
-			// up yet.
-			for _, tang := range luks.Clevis.Tang {
-				u, err := url.Parse(tang.URL)
-				if err != nil {
-					return fmt.Errorf("parsing tang URL: %v", err)
-				}
-				u.Path = path.Join(u.Path, "adv")
-				_, err = s.Fetcher.FetchToBuffer(*u, resource.FetchOptions{})
-				if err != nil {
-					return fmt.Errorf("fetching tang advertisement: %v", err)
-				}
-			}
+// luksFormatArgs returns the cryptsetup arguments used to format the device
+// at devAlias as a LUKS2 volume unlockable with keyFilePath.
+func luksFormatArgs(luks types.Luks, devAlias, keyFilePath string) []string {
+	args := []string{
+		"luksFormat",
+		"--type", "luks2",
+		"--key-file", keyFilePath,
+	}
 
-			if _, err := s.Logger.LogCmd(
-				exec.Command(distro.ClevisCmd(), "luks", "bind", "-f", "-k", keyFilePath, "-d", devAlias, pin, config), "Clevis bind",
-			); err != nil {
-				return fmt.Errorf("binding clevis device: %v", err)
-			}
+	if !util.NilOrEmpty(luks.Label) {
+		args = append(args, "--label", *luks.Label)
+	}
 
-			// close & re-open Clevis devices to make sure that we can unlock them
-			if _, err := s.Logger.LogCmd(
-				exec.Command(distro.CryptsetupCmd(), "luksClose", luks.Name),
-				"closing clevis luks device %v", luks.Name,
-			); err != nil {
-				return fmt.Errorf("closing luks device: %v", err)
-			}
-			if _, err := s.Logger.LogCmd(
-				exec.Command(distro.ClevisCmd(), "luks", "unlock", "-d", devAlias, "-n", luks.Name),
-				"reopening clevis luks device %s", luks.Name,
-			); err != nil {
-				return fmt.Errorf("reopening luks device %s: %v", luks.Name, err)
-			}
-		}
+	if !util.NilOrEmpty(luks.UUID) {
+		args = append(args, "--uuid", *luks.UUID)
+	}
 
-		if ignitionCreatedKeyFile && luks.Clevis.IsPresent() {
-			// assume the user does not want the generated key & remove it
-			if _, err := s.Logger.LogCmd(
-				exec.Command(distro.CryptsetupCmd(), "luksRemoveKey", devAlias, keyFilePath),
-				"removing key file for %v", luks.Name,
-			); err != nil {
-				return fmt.Errorf("removing key file from luks device: %v", err)
-			}
-			delete(s.State.LuksPersistKeyFiles, luks.Name)
-		}
+	for _, option := range luks.Options {
+		args = append(args, string(option))
 	}
 
-	return nil
+	return append(args, devAlias)
+}
+
+// luksOpenArgs returns the cryptsetup arguments used to open the device at
+// devAlias as /dev/mapper/<name>.
+func luksOpenArgs(luks types.Luks, devAlias, keyFilePath string) []string {
+	return []string{"luksOpen", devAlias, luks.Name, "--key-file", keyFilePath}
 }
 
 func (s *stage) isLuksDevice(device string) bool {
@@ -394,7 +421,7 @@ func (s *stage) reuseLuksDevice(luks types.Luks, keyFilePath string) error {
 
 	// open the device to make sure the keyfile is valid
 	if _, err := s.Logger.LogCmd(
-		exec.Command(distro.CryptsetupCmd(), "luksOpen", devAlias, luks.Name, "--key-file", keyFilePath),
+		exec.Command(distro.CryptsetupCmd(), luksOpenArgs(luks, devAlias, keyFilePath)...),
 		"opening luks device %v", luks.Name,
 	); err != nil {
 		return fmt.Errorf("failed to open device using specified keyfile")
@@ -0,0 +1,130 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+)
+
+func TestLuksArgs(t *testing.T) {
+	tests := []struct {
+		in         types.Luks
+		formatArgs []string
+		openArgs   []string
+	}{
+		// inline keyfile
+		{
+			in: types.Luks{
+				Name:   "data",
+				Device: util.StrToPtr("/dev/vdb"),
+				KeyFile: types.Resource{
+					Source: util.StrToPtr("data:,secret"),
+				},
+			},
+			formatArgs: []string{"luksFormat", "--type", "luks2", "--key-file", "/tmp/keyfile", "/run/ignition/dev_aliases/dev/vdb"},
+			openArgs:   []string{"luksOpen", "/run/ignition/dev_aliases/dev/vdb", "data", "--key-file", "/tmp/keyfile"},
+		},
+		{
+			in: types.Luks{
+				Name:    "data",
+				Device:  util.StrToPtr("/dev/vdb"),
+				Label:   util.StrToPtr("crypt"),
+				UUID:    util.StrToPtr("f0d9a2a2-1c8d-4a4c-9c5f-1f2d4b6e8a10"),
+				Options: []types.LuksOption{"--cipher", "aes-xts-plain64"},
+				KeyFile: types.Resource{
+					Source: util.StrToPtr("data:,secret"),
+				},
+			},
+			formatArgs: []string{"luksFormat", "--type", "luks2", "--key-file", "/tmp/keyfile", "--label", "crypt", "--uuid", "f0d9a2a2-1c8d-4a4c-9c5f-1f2d4b6e8a10", "--cipher", "aes-xts-plain64", "/run/ignition/dev_aliases/dev/vdb"},
+			openArgs:   []string{"luksOpen", "/run/ignition/dev_aliases/dev/vdb", "data", "--key-file", "/tmp/keyfile"},
+		},
+	}
+
+	for i, test := range tests {
+		devAlias := "/run/ignition/dev_aliases" + *test.in.Device
+		formatArgs := luksFormatArgs(test.in, devAlias, "/tmp/keyfile")
+		if !reflect.DeepEqual(test.formatArgs, formatArgs) {
+			t.Errorf("#%d: bad format args: want %v, got %v", i, test.formatArgs, formatArgs)
+		}
+		openArgs := luksOpenArgs(test.in, devAlias, "/tmp/keyfile")
+		if !reflect.DeepEqual(test.openArgs, openArgs) {
+			t.Errorf("#%d: bad open args: want %v, got %v", i, test.openArgs, openArgs)
+		}
+	}
+}
+
+func TestClevisPinConfig(t *testing.T) {
+	tests := []struct {
+		in     types.Clevis
+		pin    string
+		config string
+	}{
+		// single tang server
+		{
+			in: types.Clevis{
+				Tang: []types.Tang{
+					{
+						URL:        "http://tang.example.com",
+						Thumbprint: util.StrToPtr("jBwPdLrjKnSqGmhTZ2RidCs0fYI"),
+					},
+				},
+			},
+			pin:    "sss",
+			config: `{"pins":{"tang":[{"url":"http://tang.example.com","thp":"jBwPdLrjKnSqGmhTZ2RidCs0fYI"}]},"t":1}`,
+		},
+		{
+			in: types.Clevis{
+				Tang: []types.Tang{
+					{
+						URL:        "http://tang.example.com",
+						Thumbprint: util.StrToPtr("jBwPdLrjKnSqGmhTZ2RidCs0fYI"),
+					},
+				},
+				Threshold: util.IntToPtr(2),
+				Tpm2:      util.BoolToPtr(true),
+			},
+			pin:    "sss",
+			config: `{"pins":{"tang":[{"url":"http://tang.example.com","thp":"jBwPdLrjKnSqGmhTZ2RidCs0fYI"}],"tpm2":{}},"t":2}`,
+		},
+		{
+			in: types.Clevis{
+				Custom: types.ClevisCustom{
+					Pin:    util.StrToPtr("tpm2"),
+					Config: util.StrToPtr(`{"pcr_ids":"7"}`),
+				},
+			},
+			pin:    "tpm2",
+			config: `{"pcr_ids":"7"}`,
+		},
+	}
+
+	for i, test := range tests {
+		pin, config, err := clevisPinConfig(test.in)
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if test.pin != pin {
+			t.Errorf("#%d: bad pin: want %q, got %q", i, test.pin, pin)
+		}
+		if test.config != config {
+			t.Errorf("#%d: bad config: want %q, got %q", i, test.config, config)
+		}
+	}
+}
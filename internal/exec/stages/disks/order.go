@@ -0,0 +1,217 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+)
+
+// stepKind is a type of disks stage operation. Kinds are listed in the
+// order they run when nothing forces otherwise.
+type stepKind int
+
+const (
+	stepLoops stepKind = iota
+	stepPartitions
+	stepRaid
+	stepLuks
+	stepFilesystems
+)
+
+// partitionSuffixRegex matches what the kernel appends to a disk's name to
+// name its partitions, e.g. "1" for /dev/vda1 and "p1" for /dev/nvme0n1p1.
+// The "p" is used when the disk's name ends in a digit.
+var partitionSuffixRegex = regexp.MustCompile("^p?[0-9]+$")
+
+// diskNode is one loop device, disk, raid array, LUKS volume, or filesystem along with
+// the devices it creates and the devices it needs.
+type diskNode struct {
+	kind      stepKind
+	storage   types.Storage
+	produces  []string
+	prefix    string // disks also produce devices named after them
+	consumes  []string
+	dependsOn []int
+	name      string
+}
+
+// diskStep is a batch of operations of a single kind which can run together.
+type diskStep struct {
+	kind    stepKind
+	storage types.Storage
+}
+
+func (n diskNode) provides(dev string) bool {
+	dev = filepath.Clean(dev)
+	for _, p := range n.produces {
+		if dev == p {
+			return true
+		}
+	}
+	if n.prefix == "" || !strings.HasPrefix(dev, n.prefix) {
+		return false
+	}
+	suffix := strings.TrimPrefix(dev, n.prefix)
+	if last := n.prefix[len(n.prefix)-1]; last >= '0' && last <= '9' && !strings.HasPrefix(suffix, "p") {
+		// e.g. /dev/loop10 isn't a partition of /dev/loop1
+		return false
+	}
+	return partitionSuffixRegex.MatchString(suffix)
+}
+
+// buildDiskGraph returns a node for each storage item, with dependencies on
+// the nodes which create the devices it uses.
+func buildDiskGraph(storage types.Storage) []diskNode {
+	var nodes []diskNode
+	for _, loop := range storage.Loops {
+		nodes = append(nodes, diskNode{
+			kind:     stepLoops,
+			storage:  types.Storage{Loops: []types.Loop{loop}},
+			produces: []string{filepath.Clean(loop.Device)},
+			prefix:   filepath.Clean(loop.Device),
+			name:     fmt.Sprintf("loop %q", loop.Device),
+		})
+	}
+	for _, disk := range storage.Disks {
+		n := diskNode{
+			kind:     stepPartitions,
+			storage:  types.Storage{Disks: []types.Disk{disk}},
+			prefix:   filepath.Clean(disk.Device),
+			consumes: []string{disk.Device},
+			name:     fmt.Sprintf("disk %q", disk.Device),
+		}
+		for _, p := range disk.Partitions {
+			if p.Label != nil {
+				n.produces = append(n.produces, "/dev/disk/by-partlabel/"+*p.Label)
+			}
+		}
+		nodes = append(nodes, n)
+	}
+	for _, md := range storage.Raid {
+		n := diskNode{
+			kind:     stepRaid,
+			storage:  types.Storage{Raid: []types.Raid{md}},
+			produces: []string{"/dev/md/" + md.Name},
+			name:     fmt.Sprintf("raid %q", md.Name),
+		}
+		for _, dev := range md.Devices {
+			n.consumes = append(n.consumes, string(dev))
+		}
+		nodes = append(nodes, n)
+	}
+	for _, luks := range storage.Luks {
+		n := diskNode{
+			kind:    stepLuks,
+			storage: types.Storage{Luks: []types.Luks{luks}},
+			produces: []string{
+				"/dev/mapper/" + luks.Name,
+				"/dev/disk/by-id/dm-name-" + luks.Name,
+			},
+			name: fmt.Sprintf("luks %q", luks.Name),
+		}
+		if luks.Device != nil {
+			n.consumes = []string{*luks.Device}
+		}
+		nodes = append(nodes, n)
+	}
+	for _, fs := range storage.Filesystems {
+		nodes = append(nodes, diskNode{
+			kind:     stepFilesystems,
+			storage:  types.Storage{Filesystems: []types.Filesystem{fs}},
+			consumes: []string{fs.Device},
+			name:     fmt.Sprintf("filesystem %q", fs.Device),
+		})
+	}
+
+	for i := range nodes {
+		for _, dev := range nodes[i].consumes {
+			for j := range nodes {
+				if i != j && nodes[j].provides(dev) {
+					nodes[i].dependsOn = append(nodes[i].dependsOn, j)
+				}
+			}
+		}
+	}
+	return nodes
+}
+
+// planDiskSteps orders the storage section into batches such that every
+// device is created before anything which uses it. Among the operations
+// which are ready, those of the earliest kind are batched together, so a
+// config without cross-kind dependencies runs loop devices, then
+// partitions, then raid, then LUKS, then filesystems, as a single batch
+// each.
+func planDiskSteps(storage types.Storage) ([]diskStep, error) {
+	nodes := buildDiskGraph(storage)
+	done := make([]bool, len(nodes))
+	remaining := len(nodes)
+
+	var steps []diskStep
+	for remaining > 0 {
+		var ready []int
+		for i, n := range nodes {
+			if done[i] {
+				continue
+			}
+			blocked := false
+			for _, dep := range n.dependsOn {
+				if !done[dep] {
+					blocked = true
+					break
+				}
+			}
+			if !blocked {
+				ready = append(ready, i)
+			}
+		}
+		if len(ready) == 0 {
+			var cycle []string
+			for i, n := range nodes {
+				if !done[i] {
+					cycle = append(cycle, n.name)
+				}
+			}
+			return nil, fmt.Errorf("dependency cycle between %s", strings.Join(cycle, ", "))
+		}
+
+		kind := nodes[ready[0]].kind
+		for _, i := range ready {
+			if nodes[i].kind < kind {
+				kind = nodes[i].kind
+			}
+		}
+		step := diskStep{kind: kind}
+		for _, i := range ready {
+			n := nodes[i]
+			if n.kind != kind {
+				continue
+			}
+			step.storage.Loops = append(step.storage.Loops, n.storage.Loops...)
+			step.storage.Disks = append(step.storage.Disks, n.storage.Disks...)
+			step.storage.Raid = append(step.storage.Raid, n.storage.Raid...)
+			step.storage.Luks = append(step.storage.Luks, n.storage.Luks...)
+			step.storage.Filesystems = append(step.storage.Filesystems, n.storage.Filesystems...)
+			done[i] = true
+			remaining--
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
@@ -0,0 +1,249 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+)
+
+func TestPlanDiskSteps(t *testing.T) {
+	// describe each step as its kind and the names of its items
+	type step struct {
+		kind  stepKind
+		items []string
+	}
+	describe := func(steps []diskStep) []step {
+		var ret []step
+		for _, s := range steps {
+			d := step{kind: s.kind}
+			for _, loop := range s.storage.Loops {
+				d.items = append(d.items, loop.Device)
+			}
+			for _, disk := range s.storage.Disks {
+				d.items = append(d.items, disk.Device)
+			}
+			for _, md := range s.storage.Raid {
+				d.items = append(d.items, md.Name)
+			}
+			for _, luks := range s.storage.Luks {
+				d.items = append(d.items, luks.Name)
+			}
+			for _, fs := range s.storage.Filesystems {
+				d.items = append(d.items, fs.Device)
+			}
+			ret = append(ret, d)
+		}
+		return ret
+	}
+
+	mirror := types.Raid{
+		Name:    "mirror",
+		Level:   util.StrToPtr("raid1"),
+		Devices: []types.Device{"/dev/disk/by-partlabel/a", "/dev/vdb1"},
+	}
+	disks := []types.Disk{
+		{Device: "/dev/vda", Partitions: []types.Partition{{Label: util.StrToPtr("a")}}},
+		{Device: "/dev/vdb", Partitions: []types.Partition{{Number: 1}}},
+	}
+
+	tests := []struct {
+		in     types.Storage
+		out    []step
+		hasErr bool
+	}{
+		{
+			in: types.Storage{},
+		},
+		// partition -> raid -> filesystem, listed backwards
+		{
+			in: types.Storage{
+				Filesystems: []types.Filesystem{{Device: "/dev/md/mirror", Format: util.StrToPtr("xfs")}},
+				Raid:        []types.Raid{mirror},
+				Disks:       disks,
+			},
+			out: []step{
+				{stepPartitions, []string{"/dev/vda", "/dev/vdb"}},
+				{stepRaid, []string{"mirror"}},
+				{stepFilesystems, []string{"/dev/md/mirror"}},
+			},
+		},
+		// raid1 over partitions created on two disks, referenced by
+		// kernel name and with the array listed first
+		{
+			in: types.Storage{
+				Raid: []types.Raid{{
+					Name:    "data",
+					Level:   util.StrToPtr("raid1"),
+					Devices: []types.Device{"/dev/sda1", "/dev/sdb1"},
+				}},
+				Disks: []types.Disk{
+					{Device: "/dev/sda", Partitions: []types.Partition{{Number: 1, SizeMiB: util.IntToPtr(1024)}}},
+					{Device: "/dev/sdb", Partitions: []types.Partition{{Number: 1, SizeMiB: util.IntToPtr(1024)}}},
+				},
+			},
+			out: []step{
+				{stepPartitions, []string{"/dev/sda", "/dev/sdb"}},
+				{stepRaid, []string{"data"}},
+			},
+		},
+		// partitions which already exist don't need a partitioning step
+		{
+			in: types.Storage{
+				Raid: []types.Raid{{
+					Name:    "data",
+					Level:   util.StrToPtr("raid1"),
+					Devices: []types.Device{"/dev/sda1", "/dev/sdb1"},
+				}},
+			},
+			out: []step{
+				{stepRaid, []string{"data"}},
+			},
+		},
+		// raid over LUKS runs LUKS first
+		{
+			in: types.Storage{
+				Raid: []types.Raid{{
+					Name:    "crypt-mirror",
+					Level:   util.StrToPtr("raid1"),
+					Devices: []types.Device{"/dev/mapper/one", "/dev/disk/by-id/dm-name-two"},
+				}},
+				Luks: []types.Luks{
+					{Name: "one", Device: util.StrToPtr("/dev/vda1")},
+					{Name: "two", Device: util.StrToPtr("/dev/vdb1")},
+				},
+				Filesystems: []types.Filesystem{
+					{Device: "/dev/md/crypt-mirror", Format: util.StrToPtr("ext4")},
+					{Device: "/dev/vdc", Format: util.StrToPtr("ext4")},
+				},
+			},
+			out: []step{
+				{stepLuks, []string{"one", "two"}},
+				{stepRaid, []string{"crypt-mirror"}},
+				{stepFilesystems, []string{"/dev/md/crypt-mirror", "/dev/vdc"}},
+			},
+		},
+		// partitioning a raid array
+		{
+			in: types.Storage{
+				Disks: []types.Disk{{Device: "/dev/md/mirror", Partitions: []types.Partition{{Number: 1}}}},
+				Raid: []types.Raid{{
+					Name:    "mirror",
+					Level:   util.StrToPtr("raid1"),
+					Devices: []types.Device{"/dev/vda", "/dev/vdb"},
+				}},
+				Filesystems: []types.Filesystem{{Device: "/dev/md/mirror1", Format: util.StrToPtr("xfs")}},
+			},
+			out: []step{
+				{stepRaid, []string{"mirror"}},
+				{stepPartitions, []string{"/dev/md/mirror"}},
+				{stepFilesystems, []string{"/dev/md/mirror1"}},
+			},
+		},
+		// loop devices are attached before their partitions, arrays,
+		// and filesystems
+		{
+			in: types.Storage{
+				Filesystems: []types.Filesystem{
+					{Device: "/dev/md/loops", Format: util.StrToPtr("xfs")},
+					{Device: "/dev/loop10", Format: util.StrToPtr("ext4")},
+				},
+				Raid: []types.Raid{{
+					Name:    "loops",
+					Level:   util.StrToPtr("raid1"),
+					Devices: []types.Device{"/dev/loop0", "/dev/loop1p1"},
+				}},
+				Disks: []types.Disk{{Device: "/dev/loop1", Partitions: []types.Partition{{Number: 1}}}},
+				Loops: []types.Loop{
+					{Device: "/dev/loop0", File: util.StrToPtr("/run/a.img")},
+					{Device: "/dev/loop1", File: util.StrToPtr("/run/b.img")},
+					{Device: "/dev/loop10", File: util.StrToPtr("/run/c.img")},
+				},
+			},
+			out: []step{
+				{stepLoops, []string{"/dev/loop0", "/dev/loop1", "/dev/loop10"}},
+				{stepPartitions, []string{"/dev/loop1"}},
+				{stepRaid, []string{"loops"}},
+				{stepFilesystems, []string{"/dev/md/loops", "/dev/loop10"}},
+			},
+		},
+		// filesystems on existing partitions of a loop device
+		{
+			in: types.Storage{
+				Loops:       []types.Loop{{Device: "/dev/loop3", File: util.StrToPtr("/run/d.img")}},
+				Filesystems: []types.Filesystem{{Device: "/dev/loop3p2", Format: util.StrToPtr("xfs")}},
+			},
+			out: []step{
+				{stepLoops, []string{"/dev/loop3"}},
+				{stepFilesystems, []string{"/dev/loop3p2"}},
+			},
+		},
+		// each array is built from the other
+		{
+			in: types.Storage{
+				Raid: []types.Raid{
+					{Name: "a", Level: util.StrToPtr("raid1"), Devices: []types.Device{"/dev/md/b", "/dev/vda"}},
+					{Name: "b", Level: util.StrToPtr("raid1"), Devices: []types.Device{"/dev/md/a", "/dev/vdb"}},
+				},
+				Filesystems: []types.Filesystem{{Device: "/dev/vdc", Format: util.StrToPtr("xfs")}},
+			},
+			hasErr: true,
+		},
+	}
+
+	for i, test := range tests {
+		steps, err := planDiskSteps(test.in)
+		if test.hasErr {
+			if err == nil {
+				t.Errorf("#%d: expected error, got steps %v", i, describe(steps))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if out := describe(steps); !reflect.DeepEqual(test.out, out) {
+			t.Errorf("#%d: bad steps: want %v, got %v", i, test.out, out)
+		}
+	}
+}
+
+func TestDiskNodeProvides(t *testing.T) {
+	tests := []struct {
+		prefix string
+		dev    string
+		out    bool
+	}{
+		{"/dev/vda", "/dev/vda", false},
+		{"/dev/vda", "/dev/vda1", true},
+		{"/dev/vda", "/dev/vda12", true},
+		{"/dev/vda", "/dev/vdab", false},
+		{"/dev/nvme0n1", "/dev/nvme0n1p1", true},
+		{"/dev/nvme0n1", "/dev/nvme0n11", false},
+		{"/dev/loop1", "/dev/loop1p2", true},
+		{"/dev/loop1", "/dev/loop10", false},
+	}
+
+	for i, test := range tests {
+		n := diskNode{prefix: test.prefix}
+		if out := n.provides(test.dev); out != test.out {
+			t.Errorf("#%d: bad result for %q: want %v, got %v", i, test.dev, test.out, out)
+		}
+	}
+}
@@ -28,14 +28,29 @@ import (
 
 	cutil "github.com/coreos/ignition/v2/config/util"
 	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/exec/stages"
 	"github.com/coreos/ignition/v2/internal/exec/util"
 	"github.com/coreos/ignition/v2/internal/sgdisk"
 )
 
 var (
 	ErrBadSgdiskOutput = errors.New("sgdisk had unexpected output")
+
+	// dumpDisk is replaced by tests.
+	dumpDisk = util.DumpDisk
 )
 
+// ErrPartitionOverlap is returned when a partition to be created would
+// overlap a partition which is being kept in the existing table.
+type ErrPartitionOverlap struct {
+	Number   int
+	Existing int
+}
+
+func (e ErrPartitionOverlap) Error() string {
+	return fmt.Sprintf("partition %d overlaps existing partition %d", e.Number, e.Existing)
+}
+
 // createPartitions creates the partitions described in config.Storage.Disks.
 func (s stage) createPartitions(config types.Config) error {
 	if len(config.Storage.Disks) == 0 {
@@ -60,13 +75,62 @@ func (s stage) createPartitions(config types.Config) error {
 			return s.partitionDisk(dev, devAlias)
 		}, "partitioning %q", devAlias)
 		if err != nil {
-			return err
+			return stages.NewError(name, string(dev.Device), err)
 		}
 	}
 
 	return nil
 }
 
+type partitionAction int
+
+const (
+	partitionAbsent partitionAction = iota
+	partitionKeep
+	partitionCreate
+	partitionDelete
+	partitionResize
+	partitionRecreate
+)
+
+// choosePartitionAction decides what to do with the partition entry whose
+// number matches part. Only that entry is considered, so wiping it with
+// wipePartitionEntry leaves the other partitions on the disk intact.
+func choosePartitionAction(diskInfo util.DiskInfo, part sgdisk.Partition) (partitionAction, error) {
+	shouldExist := partitionShouldExist(part)
+	info, exists := diskInfo.GetPartition(part.Number)
+	var matchErr error
+	if exists {
+		matchErr = partitionMatches(info, part)
+	}
+	matches := exists && matchErr == nil
+	wipeEntry := cutil.IsTrue(part.WipePartitionEntry)
+
+	// This is a translation of the matrix in the operator notes.
+	switch {
+	case !exists && !shouldExist:
+		return partitionAbsent, nil
+	case !exists && shouldExist:
+		return partitionCreate, nil
+	case exists && !shouldExist && !wipeEntry:
+		return 0, fmt.Errorf("partition %d exists but is specified as nonexistant and wipePartitionEntry is false", part.Number)
+	case exists && !shouldExist && wipeEntry:
+		return partitionDelete, nil
+	case exists && shouldExist && matches:
+		return partitionKeep, nil
+	case exists && shouldExist && !wipeEntry && !matches:
+		if partitionMatchesResize(info, part) {
+			return partitionResize, nil
+		}
+		return 0, fmt.Errorf("Partition %d didn't match: %v", part.Number, matchErr)
+	case exists && shouldExist && wipeEntry && !matches:
+		return partitionRecreate, nil
+	default:
+		// unfortunatey, golang doesn't check that all cases are handled exhaustively
+		return 0, fmt.Errorf("Unreachable code reached when processing partition %d. golang--", part.Number)
+	}
+}
+
 // partitionMatches determines if the existing partition matches the spec given. See doc/operator notes for what
 // what it means for an existing partition to match the spec. spec must have non-zero Start and Size.
 func partitionMatches(existing util.PartitionInfo, spec sgdisk.Partition) error {
@@ -103,6 +167,11 @@ func partitionMatchesCommon(existing util.PartitionInfo, spec sgdisk.Partition)
 	if spec.Label != nil && *spec.Label != existing.Label {
 		return fmt.Errorf("label did not match (specified %q, got %q)", *spec.Label, existing.Label)
 	}
+	for _, attr := range spec.Attributes {
+		if existing.Attributes&(1<<uint(attr)) == 0 {
+			return fmt.Errorf("attribute bit %d is not set", attr)
+		}
+	}
 	return nil
 }
 
@@ -124,18 +193,25 @@ func convertMiBToSectors(mib *int, sectorSize int) *int64 {
 	}
 }
 
+// toSgdiskPartitions converts the start and size of each partition from MiB
+// to sectors. Zero values are kept, for sgdisk to resolve.
+func toSgdiskPartitions(partitions []types.Partition, sectorSize int) []sgdisk.Partition {
+	ret := []sgdisk.Partition{}
+	for _, cpart := range partitions {
+		ret = append(ret, sgdisk.Partition{
+			Partition:     cpart,
+			StartSector:   convertMiBToSectors(cpart.StartMiB, sectorSize),
+			SizeInSectors: convertMiBToSectors(cpart.SizeMiB, sectorSize),
+		})
+	}
+	return ret
+}
+
 // getRealStartAndSize returns a map of partition numbers to a struct that contains what their real start
 // and end sector should be. It runs sgdisk --pretend to determine what the partitions would look like if
 // everything specified were to be (re)created.
 func (s stage) getRealStartAndSize(dev types.Disk, devAlias string, diskInfo util.DiskInfo) ([]sgdisk.Partition, error) {
-	partitions := []sgdisk.Partition{}
-	for _, cpart := range dev.Partitions {
-		partitions = append(partitions, sgdisk.Partition{
-			Partition:     cpart,
-			StartSector:   convertMiBToSectors(cpart.StartMiB, diskInfo.LogicalSectorSize),
-			SizeInSectors: convertMiBToSectors(cpart.SizeMiB, diskInfo.LogicalSectorSize),
-		})
-	}
+	partitions := toSgdiskPartitions(dev.Partitions, diskInfo.LogicalSectorSize)
 
 	op := sgdisk.Begin(s.Logger, devAlias)
 	for _, part := range partitions {
@@ -290,7 +366,7 @@ func (s stage) getPartitionMap(device string) (util.DiskInfo, error) {
 	err := s.Logger.LogOp(
 		func() error {
 			var err error
-			info, err = util.DumpDisk(device)
+			info, err = dumpDisk(device)
 			return err
 		}, "reading partition table of %q", device)
 	if err != nil {
@@ -317,6 +393,90 @@ func (p PartitionList) Swap(i, j int) {
 	p[i], p[j] = p[j], p[i]
 }
 
+// maxGPTPartitions is the number of entries in a standard GPT partition array.
+const maxGPTPartitions = 128
+
+// assignPartitionNumbers returns a copy of partitions with each partition
+// number 0 replaced by the lowest number not used by an existing partition
+// in diskInfo or by an explicitly numbered partition. Numbers are handed out
+// in the order the partitions appear.
+func assignPartitionNumbers(partitions []types.Partition, diskInfo util.DiskInfo) ([]types.Partition, error) {
+	used := map[int]bool{}
+	for _, info := range diskInfo.Partitions {
+		used[info.Number] = true
+	}
+	for _, part := range partitions {
+		used[part.Number] = true
+	}
+
+	ret := make([]types.Partition, len(partitions))
+	next := 1
+	for i, part := range partitions {
+		if part.Number == 0 {
+			for used[next] {
+				next++
+			}
+			if next > maxGPTPartitions {
+				return nil, fmt.Errorf("no free partition numbers left (maximum %d)", maxGPTPartitions)
+			}
+			part.Number = next
+			used[next] = true
+		}
+		ret[i] = part
+	}
+	return ret, nil
+}
+
+// checkPartitionOverlaps returns ErrPartitionOverlap if a partition in parts
+// which will be created, recreated or resized would overlap a partition in
+// diskInfo that's being kept. parts are unresolved, so that this can run
+// before sgdisk --pretend, which fails outright on an overlap. An existing
+// partition may be replaced if its entry sets wipePartitionEntry or resize,
+// and otherwise is kept. Partitions without a start sector, or with start
+// sector 0, are placed in free space by sgdisk and can't overlap, and a
+// partition without a size is checked at its start sector only.
+func checkPartitionOverlaps(diskInfo util.DiskInfo, parts []sgdisk.Partition) error {
+	replaced := map[int]bool{}
+	for _, part := range parts {
+		if _, exists := diskInfo.GetPartition(part.Number); exists &&
+			(cutil.IsTrue(part.WipePartitionEntry) || cutil.IsTrue(part.Resize)) {
+			replaced[part.Number] = true
+		}
+	}
+
+	for _, part := range parts {
+		if !partitionShouldExist(part) {
+			continue
+		}
+		start := part.StartSector
+		if info, exists := diskInfo.GetPartition(part.Number); exists {
+			if !replaced[part.Number] {
+				continue
+			}
+			if start == nil && !cutil.IsTrue(part.WipePartitionEntry) {
+				// resized in place
+				start = &info.StartSector
+			}
+		}
+		if start == nil || *start == 0 {
+			continue
+		}
+		end := *start + 1
+		if part.SizeInSectors != nil && *part.SizeInSectors > 0 {
+			end = *start + *part.SizeInSectors
+		}
+		for _, info := range diskInfo.Partitions {
+			if replaced[info.Number] {
+				continue
+			}
+			if *start < info.StartSector+info.SizeInSectors && info.StartSector < end {
+				return ErrPartitionOverlap{Number: part.Number, Existing: info.Number}
+			}
+		}
+	}
+	return nil
+}
+
 // partitionDisk partitions devAlias according to the spec given by dev
 func (s stage) partitionDisk(dev types.Disk, devAlias string) error {
 	if cutil.IsTrue(dev.WipeTable) {
@@ -338,6 +498,21 @@ func (s stage) partitionDisk(dev types.Disk, devAlias string) error {
 		return err
 	}
 
+	// resolve partition number 0 up front so the numbers used below are
+	// deterministic and don't collide with explicitly numbered partitions
+	dev.Partitions, err = assignPartitionNumbers(dev.Partitions, diskInfo)
+	if err != nil {
+		return err
+	}
+
+	// new partitions are added to the free space of the existing table, so
+	// make sure they don't collide with a partition we're keeping. sgdisk
+	// would refuse to create them, but without saying which partition is in
+	// the way.
+	if err := checkPartitionOverlaps(diskInfo, toSgdiskPartitions(dev.Partitions, diskInfo.LogicalSectorSize)); err != nil {
+		return err
+	}
+
 	// get a list of parititions that have size and start 0 replaced with the real sizes
 	// that would be used if all specified partitions were to be created anew.
 	// Also calculate sectors for all of the start/size values.
@@ -346,48 +521,38 @@ func (s stage) partitionDisk(dev types.Disk, devAlias string) error {
 		return err
 	}
 
-	for _, part := range resolvedPartitions {
-		shouldExist := partitionShouldExist(part)
-		info, exists := diskInfo.GetPartition(part.Number)
-		var matchErr error
-		if exists {
-			matchErr = partitionMatches(info, part)
+	actions := make([]partitionAction, len(resolvedPartitions))
+	for i, part := range resolvedPartitions {
+		actions[i], err = choosePartitionAction(diskInfo, part)
+		if err != nil {
+			return err
 		}
-		matches := exists && matchErr == nil
-		wipeEntry := cutil.IsTrue(part.WipePartitionEntry)
+	}
 
-		// This is a translation of the matrix in the operator notes.
-		switch {
-		case !exists && !shouldExist:
+	for i, part := range resolvedPartitions {
+		switch actions[i] {
+		case partitionAbsent:
 			s.Logger.Info("partition %d specified as nonexistant and no partition was found. Success.", part.Number)
-		case !exists && shouldExist:
+		case partitionKeep:
+			s.Logger.Info("partition %d found with correct specifications", part.Number)
+		case partitionCreate:
 			op.CreatePartition(part)
-		case exists && !shouldExist && !wipeEntry:
-			return fmt.Errorf("partition %d exists but is specified as nonexistant and wipePartitionEntry is false", part.Number)
-		case exists && !shouldExist && wipeEntry:
+		case partitionDelete:
 			op.DeletePartition(part.Number)
-		case exists && shouldExist && matches:
-			s.Logger.Info("partition %d found with correct specifications", part.Number)
-		case exists && shouldExist && !wipeEntry && !matches:
-			if partitionMatchesResize(info, part) {
-				s.Logger.Info("resizing partition %d", part.Number)
-				op.DeletePartition(part.Number)
-				part.Number = info.Number
-				part.GUID = &info.GUID
-				part.TypeGUID = &info.TypeGUID
-				part.Label = &info.Label
-				part.StartSector = &info.StartSector
-				op.CreatePartition(part)
-			} else {
-				return fmt.Errorf("Partition %d didn't match: %v", part.Number, matchErr)
-			}
-		case exists && shouldExist && wipeEntry && !matches:
+		case partitionResize:
+			s.Logger.Info("resizing partition %d", part.Number)
+			info, _ := diskInfo.GetPartition(part.Number)
+			op.DeletePartition(part.Number)
+			part.Number = info.Number
+			part.GUID = &info.GUID
+			part.TypeGUID = &info.TypeGUID
+			part.Label = &info.Label
+			part.StartSector = &info.StartSector
+			op.CreatePartition(part)
+		case partitionRecreate:
 			s.Logger.Info("partition %d did not meet specifications, wiping partition entry and recreating", part.Number)
 			op.DeletePartition(part.Number)
 			op.CreatePartition(part)
-		default:
-			// unfortunatey, golang doesn't check that all cases are handled exhaustively
-			return fmt.Errorf("Unreachable code reached when processing partition %d. golang--", part.Number)
 		}
 	}
 
@@ -0,0 +1,425 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/sgdisk"
+)
+
+func int64ToPtr(x int64) *int64 {
+	return &x
+}
+
+func TestChoosePartitionAction(t *testing.T) {
+	diskInfo := util.DiskInfo{
+		LogicalSectorSize: 512,
+		Partitions: []util.PartitionInfo{
+			{
+				Number:        1,
+				Label:         "EFI-SYSTEM",
+				TypeGUID:      "C12A7328-F81F-11D2-BA4B-00A0C93EC93B",
+				GUID:          "3D9C5B2A-4E8F-4A1B-9C7D-2E6F8A0B1C3D",
+				StartSector:   2048,
+				SizeInSectors: 262144,
+				Attributes:    1 << 2,
+			},
+			{
+				Number:        2,
+				Label:         "data",
+				TypeGUID:      "0FC63DAF-8483-4772-8E79-3D69D8477DE4",
+				GUID:          "7A1E4C2B-9D3F-4B6A-8E5C-1F0D2A3B4C5D",
+				StartSector:   264192,
+				SizeInSectors: 1048576,
+			},
+		},
+	}
+
+	tests := []struct {
+		in     sgdisk.Partition
+		out    partitionAction
+		hasErr bool
+	}{
+		// the ESP matches and is left alone
+		{
+			in: sgdisk.Partition{
+				Partition: types.Partition{
+					Number: 1,
+					Label:  cutil.StrToPtr("EFI-SYSTEM"),
+				},
+				StartSector:   int64ToPtr(2048),
+				SizeInSectors: int64ToPtr(262144),
+			},
+			out: partitionKeep,
+		},
+		// set attribute bits are checked
+		{
+			in: sgdisk.Partition{
+				Partition: types.Partition{
+					Number:     1,
+					Label:      cutil.StrToPtr("EFI-SYSTEM"),
+					Attributes: []types.PartitionAttribute{2},
+				},
+				StartSector:   int64ToPtr(2048),
+				SizeInSectors: int64ToPtr(262144),
+			},
+			out: partitionKeep,
+		},
+		{
+			in: sgdisk.Partition{
+				Partition: types.Partition{
+					Number:     1,
+					Label:      cutil.StrToPtr("EFI-SYSTEM"),
+					Attributes: []types.PartitionAttribute{0},
+				},
+				StartSector:   int64ToPtr(2048),
+				SizeInSectors: int64ToPtr(262144),
+			},
+			hasErr: true,
+		},
+		// the data partition doesn't match and is selectively wiped
+		{
+			in: sgdisk.Partition{
+				Partition: types.Partition{
+					Number:             2,
+					Label:              cutil.StrToPtr("var"),
+					WipePartitionEntry: cutil.BoolToPtr(true),
+				},
+				StartSector:   int64ToPtr(264192),
+				SizeInSectors: int64ToPtr(1048576),
+			},
+			out: partitionRecreate,
+		},
+		// without wipePartitionEntry a mismatch is an error
+		{
+			in: sgdisk.Partition{
+				Partition: types.Partition{
+					Number: 2,
+					Label:  cutil.StrToPtr("var"),
+				},
+				StartSector:   int64ToPtr(264192),
+				SizeInSectors: int64ToPtr(1048576),
+			},
+			hasErr: true,
+		},
+		{
+			in: sgdisk.Partition{
+				Partition: types.Partition{
+					Number:             2,
+					ShouldExist:        cutil.BoolToPtr(false),
+					WipePartitionEntry: cutil.BoolToPtr(true),
+				},
+			},
+			out: partitionDelete,
+		},
+		{
+			in: sgdisk.Partition{
+				Partition: types.Partition{
+					Number: 3,
+				},
+			},
+			out: partitionCreate,
+		},
+		{
+			in: sgdisk.Partition{
+				Partition: types.Partition{
+					Number:      3,
+					ShouldExist: cutil.BoolToPtr(false),
+				},
+			},
+			out: partitionAbsent,
+		},
+		{
+			in: sgdisk.Partition{
+				Partition: types.Partition{
+					Number: 2,
+					Resize: cutil.BoolToPtr(true),
+				},
+				StartSector:   int64ToPtr(264192),
+				SizeInSectors: int64ToPtr(2097152),
+			},
+			out: partitionResize,
+		},
+	}
+
+	for i, test := range tests {
+		out, err := choosePartitionAction(diskInfo, test.in)
+		if test.hasErr {
+			if err == nil {
+				t.Errorf("#%d: expected error, got action %v", i, out)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if test.out != out {
+			t.Errorf("#%d: bad action: want %v, got %v", i, test.out, out)
+		}
+	}
+}
+
+func TestAssignPartitionNumbers(t *testing.T) {
+	auto := func(label string) types.Partition {
+		return types.Partition{Label: cutil.StrToPtr(label)}
+	}
+	numbered := func(n int) types.Partition {
+		return types.Partition{Number: n}
+	}
+	existing := util.DiskInfo{
+		Partitions: []util.PartitionInfo{{Number: 1}, {Number: 3}},
+	}
+	full := util.DiskInfo{}
+	for i := 1; i <= maxGPTPartitions; i++ {
+		full.Partitions = append(full.Partitions, util.PartitionInfo{Number: i})
+	}
+
+	tests := []struct {
+		in       []types.Partition
+		diskInfo util.DiskInfo
+		out      []int
+		hasErr   bool
+	}{
+		{
+			in:  []types.Partition{auto("a"), auto("b"), auto("c")},
+			out: []int{1, 2, 3},
+		},
+		// explicit numbers are reserved regardless of order
+		{
+			in:  []types.Partition{auto("a"), numbered(1), auto("b"), numbered(3)},
+			out: []int{2, 1, 4, 3},
+		},
+		// existing partitions aren't reused
+		{
+			in:       []types.Partition{auto("a"), auto("b")},
+			diskInfo: existing,
+			out:      []int{2, 4},
+		},
+		{
+			in:       []types.Partition{numbered(2), auto("a"), numbered(5), auto("b")},
+			diskInfo: existing,
+			out:      []int{2, 4, 5, 6},
+		},
+		{
+			in:       []types.Partition{auto("a")},
+			diskInfo: full,
+			hasErr:   true,
+		},
+	}
+
+	for i, test := range tests {
+		out, err := assignPartitionNumbers(test.in, test.diskInfo)
+		if test.hasErr {
+			if err == nil {
+				t.Errorf("#%d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		numbers := []int{}
+		for _, p := range out {
+			numbers = append(numbers, p.Number)
+		}
+		if !reflect.DeepEqual(test.out, numbers) {
+			t.Errorf("#%d: bad numbers: want %v, got %v", i, test.out, numbers)
+		}
+		for j, p := range test.in {
+			if p.Number != 0 && p.Number != out[j].Number {
+				t.Errorf("#%d: explicit number %d changed to %d", i, p.Number, out[j].Number)
+			}
+		}
+	}
+}
+
+func TestCheckPartitionOverlaps(t *testing.T) {
+	// the ESP and a data partition, with free space after the data
+	// partition and a gap between them
+	diskInfo := util.DiskInfo{
+		LogicalSectorSize: 512,
+		Partitions: []util.PartitionInfo{
+			{Number: 1, StartSector: 2048, SizeInSectors: 262144},
+			{Number: 3, StartSector: 526336, SizeInSectors: 1048576},
+		},
+	}
+	part := func(number int, start, size *int64) sgdisk.Partition {
+		return sgdisk.Partition{
+			Partition:     types.Partition{Number: number},
+			StartSector:   start,
+			SizeInSectors: size,
+		}
+	}
+
+	tests := []struct {
+		parts []sgdisk.Partition
+		err   error
+	}{
+		// added after the existing partitions
+		{
+			parts: []sgdisk.Partition{part(4, int64ToPtr(1574912), int64ToPtr(1048576))},
+		},
+		// added in the gap between them
+		{
+			parts: []sgdisk.Partition{part(2, int64ToPtr(264192), int64ToPtr(262144))},
+		},
+		// placed by sgdisk
+		{
+			parts: []sgdisk.Partition{part(2, nil, int64ToPtr(262144))},
+		},
+		{
+			parts: []sgdisk.Partition{part(2, int64ToPtr(0), int64ToPtr(262144))},
+		},
+		// overlapping the end of the data partition
+		{
+			parts: []sgdisk.Partition{part(4, int64ToPtr(1574911), int64ToPtr(2048))},
+			err:   ErrPartitionOverlap{Number: 4, Existing: 3},
+		},
+		// spilling out of the gap into the data partition
+		{
+			parts: []sgdisk.Partition{part(2, int64ToPtr(264192), int64ToPtr(262145))},
+			err:   ErrPartitionOverlap{Number: 2, Existing: 3},
+		},
+		// starting inside the ESP, with no size
+		{
+			parts: []sgdisk.Partition{part(2, int64ToPtr(4096), nil)},
+			err:   ErrPartitionOverlap{Number: 2, Existing: 1},
+		},
+		// the space of a deleted partition can be reused
+		{
+			parts: []sgdisk.Partition{
+				{
+					Partition: types.Partition{
+						Number:             3,
+						ShouldExist:        cutil.BoolToPtr(false),
+						WipePartitionEntry: cutil.BoolToPtr(true),
+					},
+				},
+				part(4, int64ToPtr(526336), int64ToPtr(2048)),
+			},
+		},
+		// as can the space of a recreated one
+		{
+			parts: []sgdisk.Partition{
+				{
+					Partition: types.Partition{
+						Number:             3,
+						WipePartitionEntry: cutil.BoolToPtr(true),
+					},
+					StartSector:   int64ToPtr(264192),
+					SizeInSectors: int64ToPtr(1310720),
+				},
+			},
+		},
+		// a resize can't grow into the next partition
+		{
+			parts: []sgdisk.Partition{
+				{
+					Partition: types.Partition{
+						Number: 1,
+						Resize: cutil.BoolToPtr(true),
+					},
+					SizeInSectors: int64ToPtr(524288 + 1),
+				},
+			},
+			err: ErrPartitionOverlap{Number: 1, Existing: 3},
+		},
+		// kept partitions aren't checked
+		{
+			parts: []sgdisk.Partition{part(3, int64ToPtr(526336), int64ToPtr(1048576))},
+		},
+	}
+
+	for i, test := range tests {
+		err := checkPartitionOverlaps(diskInfo, test.parts)
+		if test.err != err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+func TestPartitionDiskOverlap(t *testing.T) {
+	oldDumpDisk := dumpDisk
+	dumpDisk = func(device string) (util.DiskInfo, error) {
+		return util.DiskInfo{
+			LogicalSectorSize: 512,
+			Partitions: []util.PartitionInfo{
+				{Number: 1, StartSector: 2048, SizeInSectors: 262144},
+			},
+		}, nil
+	}
+	defer func() { dumpDisk = oldDumpDisk }()
+
+	logger := log.New(true)
+	s := stage{Util: util.Util{Logger: &logger}}
+	// starts in the middle of partition 1. The overlap must be reported
+	// before sgdisk runs, since sgdisk --pretend would fail on it too.
+	dev := types.Disk{
+		Device: "/dev/null",
+		Partitions: []types.Partition{
+			{Number: 2, StartMiB: cutil.IntToPtr(64), SizeMiB: cutil.IntToPtr(64)},
+		},
+	}
+	err := s.partitionDisk(dev, "/dev/null")
+	var overlap ErrPartitionOverlap
+	if !errors.As(err, &overlap) {
+		t.Fatalf("bad error: want ErrPartitionOverlap, got %v", err)
+	}
+	if want := (ErrPartitionOverlap{Number: 2, Existing: 1}); overlap != want {
+		t.Errorf("bad error: want %v, got %v", want, overlap)
+	}
+}
+
+func TestConvertMiBToSectors(t *testing.T) {
+	tests := []struct {
+		mib        *int
+		sectorSize int
+		out        *int64
+	}{
+		{nil, 512, nil},
+		{cutil.IntToPtr(0), 512, int64ToPtr(0)},
+		{cutil.IntToPtr(1), 512, int64ToPtr(2048)},
+		{cutil.IntToPtr(1024), 512, int64ToPtr(2097152)},
+		{cutil.IntToPtr(0), 4096, int64ToPtr(0)},
+		{cutil.IntToPtr(1), 4096, int64ToPtr(256)},
+		{cutil.IntToPtr(1024), 4096, int64ToPtr(262144)},
+		// large enough to overflow 32-bit sector counts
+		{cutil.IntToPtr(4 * 1024 * 1024), 512, int64ToPtr(8589934592)},
+	}
+
+	for i, test := range tests {
+		out := convertMiBToSectors(test.mib, test.sectorSize)
+		if !reflect.DeepEqual(test.out, out) {
+			t.Errorf("#%d: bad sectors: want %v, got %v", i, fmtInt64Ptr(test.out), fmtInt64Ptr(out))
+		}
+	}
+}
+
+func fmtInt64Ptr(p *int64) string {
+	if p == nil {
+		return "nil"
+	}
+	return strconv.FormatInt(*p, 10)
+}
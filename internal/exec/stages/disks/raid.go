@@ -25,6 +25,7 @@ import (
 
 	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
 	"github.com/coreos/ignition/v2/internal/distro"
+	"github.com/coreos/ignition/v2/internal/exec/stages"
 	"github.com/coreos/ignition/v2/internal/exec/util"
 )
 
@@ -47,38 +48,44 @@ func (s stage) createRaids(config types.Config) error {
 	}
 
 	for _, md := range config.Storage.Raid {
-		if md.Spares == nil {
-			zero := 0
-			md.Spares = &zero
-		}
-		args := []string{
-			"--create", md.Name,
-			"--force",
-			"--run",
-			"--homehost", "any",
-			"--level", *md.Level,
-			"--raid-devices", fmt.Sprintf("%d", len(md.Devices)-*md.Spares),
-		}
-
-		if *md.Spares > 0 {
-			args = append(args, "--spare-devices", fmt.Sprintf("%d", *md.Spares))
-		}
-
-		for _, o := range md.Options {
-			args = append(args, string(o))
-		}
-
-		for _, dev := range md.Devices {
-			args = append(args, util.DeviceAlias(string(dev)))
-		}
-
 		if _, err := s.Logger.LogCmd(
-			exec.Command(distro.MdadmCmd(), args...),
+			exec.Command(distro.MdadmCmd(), mdadmArgs(md)...),
 			"creating %q", md.Name,
 		); err != nil {
-			return fmt.Errorf("mdadm failed: %v", err)
+			return stages.NewError(name, md.Name, fmt.Errorf("mdadm failed: %w", err))
 		}
 	}
 
 	return nil
 }
+
+// mdadmArgs returns the arguments to mdadm for creating the given array.
+// Any additional options are passed through in order, ahead of the member
+// devices.
+func mdadmArgs(md types.Raid) []string {
+	spares := 0
+	if md.Spares != nil {
+		spares = *md.Spares
+	}
+	args := []string{
+		"--create", md.Name,
+		"--force",
+		"--run",
+		"--homehost", "any",
+		"--level", *md.Level,
+		"--raid-devices", fmt.Sprintf("%d", len(md.Devices)-spares),
+	}
+
+	if spares > 0 {
+		args = append(args, "--spare-devices", fmt.Sprintf("%d", spares))
+	}
+
+	for _, o := range md.Options {
+		args = append(args, string(o))
+	}
+
+	for _, dev := range md.Devices {
+		args = append(args, util.DeviceAlias(string(dev)))
+	}
+	return args
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	eutil "github.com/coreos/ignition/v2/internal/exec/util"
+)
+
+func TestMdadmArgs(t *testing.T) {
+	tests := []struct {
+		in  types.Raid
+		out []string
+	}{
+		{
+			in: types.Raid{
+				Name:    "md0",
+				Level:   util.StrToPtr("raid1"),
+				Devices: []types.Device{"/dev/vda", "/dev/vdb"},
+			},
+			out: []string{
+				"--create", "md0", "--force", "--run", "--homehost", "any",
+				"--level", "raid1", "--raid-devices", "2",
+				eutil.DeviceAlias("/dev/vda"), eutil.DeviceAlias("/dev/vdb"),
+			},
+		},
+		{
+			in: types.Raid{
+				Name:    "md0",
+				Level:   util.StrToPtr("raid5"),
+				Devices: []types.Device{"/dev/vda", "/dev/vdb", "/dev/vdc", "/dev/vdd"},
+				Spares:  util.IntToPtr(1),
+				Options: []types.RaidOption{"--chunk=64", "--bitmap", "internal", "--layout=left-symmetric"},
+			},
+			out: []string{
+				"--create", "md0", "--force", "--run", "--homehost", "any",
+				"--level", "raid5", "--raid-devices", "3", "--spare-devices", "1",
+				"--chunk=64", "--bitmap", "internal", "--layout=left-symmetric",
+				eutil.DeviceAlias("/dev/vda"), eutil.DeviceAlias("/dev/vdb"),
+				eutil.DeviceAlias("/dev/vdc"), eutil.DeviceAlias("/dev/vdd"),
+			},
+		},
+	}
+
+	for i, test := range tests {
+		args := mdadmArgs(test.in)
+		if !reflect.DeepEqual(test.out, args) {
+			t.Errorf("#%d: bad args: want %v, got %v", i, test.out, args)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stages
+
+import (
+	"errors"
+)
+
+// Sentinels matching any Error from the corresponding stage, for use with
+// errors.Is.
+var (
+	ErrDiskStage         = errors.New("disks stage failed")
+	ErrFetchStage        = errors.New("fetch stage failed")
+	ErrFetchOfflineStage = errors.New("fetch-offline stage failed")
+	ErrFilesStage        = errors.New("files stage failed")
+	ErrKargsStage        = errors.New("kargs stage failed")
+	ErrMountStage        = errors.New("mount stage failed")
+	ErrUmountStage       = errors.New("umount stage failed")
+)
+
+var stageErrors = map[string]error{
+	"disks":         ErrDiskStage,
+	"fetch":         ErrFetchStage,
+	"fetch-offline": ErrFetchOfflineStage,
+	"files":         ErrFilesStage,
+	"kargs":         ErrKargsStage,
+	"mount":         ErrMountStage,
+	"umount":        ErrUmountStage,
+}
+
+// Error is returned when a stage fails. Resource identifies what was being
+// provisioned when the failure happened, e.g. a device, a path, or a unit
+// name, and is empty if the failure wasn't specific to one resource.
+type Error struct {
+	Stage    string
+	Resource string
+	Err      error
+}
+
+// NewError wraps err, the cause of the failure to provision resource in the
+// named stage.
+func NewError(stage, resource string, err error) *Error {
+	return &Error{
+		Stage:    stage,
+		Resource: resource,
+		Err:      err,
+	}
+}
+
+// Error returns the message of the underlying error, which is expected to
+// already describe the resource.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the sentinel error for e's stage.
+func (e *Error) Is(target error) bool {
+	sentinel, ok := stageErrors[e.Stage]
+	return ok && target == sentinel
+}
+
+// WrapError returns err with the named stage attached, unless err already
+// carries an Error.
+func WrapError(stage string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var stageErr *Error
+	if errors.As(err, &stageErr) {
+		return err
+	}
+	return NewError(stage, "", err)
+}
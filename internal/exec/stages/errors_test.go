@@ -0,0 +1,78 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stages
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestError(t *testing.T) {
+	cause := os.ErrPermission
+
+	tests := []struct {
+		err      error
+		sentinel error
+		resource string
+	}{
+		{
+			err:      NewError("disks", "/dev/vda", cause),
+			sentinel: ErrDiskStage,
+			resource: "/dev/vda",
+		},
+		{
+			err:      fmt.Errorf("failed to create files: %w", NewError("files", "/etc/motd", cause)),
+			sentinel: ErrFilesStage,
+			resource: "/etc/motd",
+		},
+		{
+			err:      WrapError("mount", fmt.Errorf("failed: %w", NewError("mount", "/var", cause))),
+			sentinel: ErrMountStage,
+			resource: "/var",
+		},
+		{
+			err:      WrapError("kargs", cause),
+			sentinel: ErrKargsStage,
+		},
+	}
+
+	for i, test := range tests {
+		if !errors.Is(test.err, test.sentinel) {
+			t.Errorf("#%d: error %v doesn't match %v", i, test.err, test.sentinel)
+		}
+		for _, other := range stageErrors {
+			if other != test.sentinel && errors.Is(test.err, other) {
+				t.Errorf("#%d: error %v unexpectedly matches %v", i, test.err, other)
+			}
+		}
+		if !errors.Is(test.err, cause) {
+			t.Errorf("#%d: error %v doesn't wrap %v", i, test.err, cause)
+		}
+		var stageErr *Error
+		if !errors.As(test.err, &stageErr) {
+			t.Errorf("#%d: error %v isn't a stage error", i, test.err)
+			continue
+		}
+		if stageErr.Resource != test.resource {
+			t.Errorf("#%d: bad resource: want %q, got %q", i, test.resource, stageErr.Resource)
+		}
+	}
+
+	if WrapError("files", nil) != nil {
+		t.Errorf("wrapping nil error returned non-nil")
+	}
+}
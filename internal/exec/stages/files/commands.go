@@ -0,0 +1,67 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+	"github.com/coreos/ignition/v2/internal/exec/stages"
+)
+
+// commandContext creates the process for a command; tests replace it.
+var commandContext = exec.CommandContext
+
+// runCommands runs the commands from the config, in order, chrooted into
+// the target root. The first failure aborts the stage. Files written by the
+// commands aren't tracked, so they're only relabeled if they're beneath a
+// directory created by this stage.
+func (s *stage) runCommands(config types.Config) error {
+	if len(config.Commands) == 0 {
+		return nil
+	}
+	s.Logger.PushPrefix("runCommands")
+	defer s.Logger.PopPrefix()
+
+	for _, c := range config.Commands {
+		if err := s.runCommand(c); err != nil {
+			return stages.NewError(name, c.Name, fmt.Errorf("command %q failed: %w", c.Name, err))
+		}
+	}
+	return nil
+}
+
+func (s *stage) runCommand(c types.Command) error {
+	ctx := context.Background()
+	if c.Timeout != nil && *c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(*c.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	args := append([]string{s.DestDir, *c.Path}, c.Args...)
+	cmd := commandContext(ctx, distro.ChrootCmd(), args...)
+	if _, err := s.Logger.LogCmd(cmd, "running command %q", c.Name); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timed out after %d seconds: %w", *c.Timeout, err)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+	"github.com/coreos/ignition/v2/internal/exec/stages"
+	"github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+func TestRunCommands(t *testing.T) {
+	// the mock runs a shell snippet named by the command's path instead
+	// of chrooting
+	scripts := map[string]string{
+		"/bin/ok":    "exit 0",
+		"/bin/fail":  "echo 'something broke' >&2; exit 3",
+		"/bin/sleep": "exec sleep 5",
+	}
+	var ran [][]string
+	defer func(orig func(context.Context, string, ...string) *exec.Cmd) {
+		commandContext = orig
+	}(commandContext)
+	commandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		ran = append(ran, append([]string{name}, args...))
+		return exec.CommandContext(ctx, "sh", "-c", scripts[args[1]])
+	}
+
+	tests := []struct {
+		in  []types.Command
+		ran [][]string
+		err string
+	}{
+		{
+			in: nil,
+		},
+		{
+			in: []types.Command{
+				{Name: "first", Path: cutil.StrToPtr("/bin/ok"), Args: []string{"a", "b c"}},
+				{Name: "second", Path: cutil.StrToPtr("/bin/ok")},
+			},
+			ran: [][]string{
+				{distro.ChrootCmd(), "/sysroot", "/bin/ok", "a", "b c"},
+				{distro.ChrootCmd(), "/sysroot", "/bin/ok"},
+			},
+		},
+		// failures abort, surfacing stderr
+		{
+			in: []types.Command{
+				{Name: "broken", Path: cutil.StrToPtr("/bin/fail")},
+				{Name: "skipped", Path: cutil.StrToPtr("/bin/ok")},
+			},
+			ran: [][]string{
+				{distro.ChrootCmd(), "/sysroot", "/bin/fail"},
+			},
+			err: "something broke",
+		},
+		{
+			in: []types.Command{
+				{Name: "slow", Path: cutil.StrToPtr("/bin/sleep"), Timeout: cutil.IntToPtr(1)},
+			},
+			ran: [][]string{
+				{distro.ChrootCmd(), "/sysroot", "/bin/sleep"},
+			},
+			err: "timed out after 1 seconds",
+		},
+	}
+
+	logger := log.New(true)
+	s := stage{
+		Util: util.Util{
+			DestDir: "/sysroot",
+			Logger:  &logger,
+		},
+	}
+	for i, test := range tests {
+		ran = nil
+		err := s.runCommands(types.Config{Commands: test.in})
+		if test.err == "" && err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+		} else if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("#%d: bad error: want %q, got %v", i, test.err, err)
+		} else if test.err != "" && !errors.Is(err, stages.ErrFilesStage) {
+			t.Errorf("#%d: error isn't a files stage error: %v", i, err)
+		}
+		if !reflect.DeepEqual(test.ran, ran) {
+			t.Errorf("#%d: bad commands run: want %q, got %q", i, test.ran, ran)
+		}
+	}
+}
@@ -17,8 +17,12 @@ package files
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"sort"
 
+	cutil "github.com/coreos/ignition/v2/config/util"
 	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
 	"github.com/coreos/ignition/v2/internal/distro"
 	"github.com/coreos/ignition/v2/internal/exec/stages"
@@ -50,6 +54,7 @@ func (creator) Create(logger *log.Logger, root string, f resource.Fetcher, state
 			Fetcher: f,
 			State:   state,
 		},
+		initramfsDir: "/",
 	}
 }
 
@@ -59,7 +64,12 @@ func (creator) Name() string {
 
 type stage struct {
 	util.Util
-	toRelabel map[string]struct{}
+	initramfsDir string // root for files targeting the initramfs
+	toRelabel    map[string]struct{}
+	written      map[string]struct{} // relative to DestDir, for the relabel manifest
+	checksums    map[string]string   // relative to DestDir, for the checksum manifest
+	toLabel      map[string]string
+	toFlag       map[string][]types.FileFlag
 }
 
 func (stage) Name() string {
@@ -75,10 +85,24 @@ func (s stage) Run(config types.Config) error {
 }
 
 func (s stage) runImpl(config types.Config, isApply bool, applyIgnoreUnsupported bool) error {
+	if s.Logger.DryRun() {
+		return s.logPlan(config, isApply)
+	}
+
 	if !isApply {
+		// !isApply: the mount stage doesn't run in container flows
+		if err := s.enterMountNamespace(); err != nil {
+			return err
+		}
+
 		// !isApply: SELinux is handled differently in container flows
 		if err := s.checkRelabeling(); err != nil {
-			return fmt.Errorf("failed to check if SELinux labeling required: %v", err)
+			return fmt.Errorf("failed to check if SELinux labeling required: %w", err)
+		}
+
+		// !isApply: the manifest describes the provisioned root
+		if distro.ChecksumManifestPath() != "" {
+			s.checksums = make(map[string]string)
 		}
 	}
 
@@ -88,43 +112,187 @@ func (s stage) runImpl(config types.Config, isApply bool, applyIgnoreUnsupported
 		if !applyIgnoreUnsupported && (len(config.Passwd.Users) > 0 || len(config.Passwd.Groups) > 0) {
 			return errors.New("cannot apply passwd live")
 		}
+		// there's no initramfs on a running system
+		if hasInitramfsFiles(config) {
+			if !applyIgnoreUnsupported {
+				return errors.New("cannot apply files targeting the initramfs live")
+			}
+			config.Storage.Files = sysrootFiles(config)
+		}
 	} else {
 		if err := s.createPasswd(config); err != nil {
-			return fmt.Errorf("failed to create users/groups: %v", err)
+			return fmt.Errorf("failed to create users/groups: %w", err)
 		}
 	}
 
 	if err := s.createFilesystemsEntries(config); err != nil {
-		return fmt.Errorf("failed to create files: %v", err)
+		return fmt.Errorf("failed to create files: %w", err)
+	}
+
+	// after files, so it replaces any /etc/machine-id among them
+	if err := s.writeMachineID(config); err != nil {
+		return fmt.Errorf("failed to write machine ID: %w", err)
+	}
+
+	// after files, so it replaces any /etc/hostname among them
+	if err := s.writeHostname(config); err != nil {
+		return fmt.Errorf("failed to write hostname: %w", err)
 	}
 
 	if err := s.createUnits(config); err != nil {
-		return fmt.Errorf("failed to create units: %v", err)
+		return fmt.Errorf("failed to create units: %w", err)
+	}
+
+	if err := s.runCommands(config); err != nil {
+		return fmt.Errorf("failed to run commands: %w", err)
 	}
 
 	if !isApply {
 		// !isApply: we don't support LUKS, so this isn't necessary
 		if err := s.createCrypttabEntries(config); err != nil {
-			return fmt.Errorf("creating crypttab entries: %v", err)
+			return fmt.Errorf("creating crypttab entries: %w", err)
 		}
 
 		// !isApply: we support running Ignition multiple times
 		if err := s.createResultFile(); err != nil {
-			return fmt.Errorf("creating result file: %v", err)
+			return fmt.Errorf("creating result file: %w", err)
+		}
+
+		// !isApply: after the other writes, so it lists them
+		if err := s.createChecksumManifest(); err != nil {
+			return fmt.Errorf("creating checksum manifest: %w", err)
+		}
+
+		// !isApply: last write, so it lists everything
+		if err := s.createRelabelManifest(); err != nil {
+			return fmt.Errorf("creating relabel manifest: %w", err)
 		}
 
 		// !isApply: SELinux is handled differently in container flows
 		if err := s.relabelFiles(); err != nil {
-			return fmt.Errorf("failed to handle relabeling: %v", err)
+			return fmt.Errorf("failed to handle relabeling: %w", err)
 		}
 	}
 
+	// after relabeling, so explicit contexts aren't overwritten
+	if err := s.labelFiles(); err != nil {
+		return fmt.Errorf("failed to set SELinux contexts: %w", err)
+	}
+
+	// last, since immutable files can't be relabeled
+	if err := s.flagFiles(); err != nil {
+		return fmt.Errorf("failed to set file flags: %w", err)
+	}
+
+	return nil
+}
+
+// logPlan logs the changes the stage would make without making them. Paths
+// are still resolved against the root so that conflicting entries are
+// reported.
+func (s stage) logPlan(config types.Config, isApply bool) error {
+	if !isApply {
+		for _, g := range config.Passwd.Groups {
+			s.Logger.Info("dry run: would create group %q", g.Name)
+		}
+		for _, u := range config.Passwd.Users {
+			if cutil.IsFalse(u.ShouldExist) {
+				s.Logger.Info("dry run: would delete user %q", u.Name)
+			} else {
+				s.Logger.Info("dry run: would create or update user %q", u.Name)
+			}
+		}
+	}
+
+	entries, err := s.getOrderedCreationList(config)
+	if err != nil {
+		return fmt.Errorf("failed to create files: %v", err)
+	}
+	initramfsEntries, err := s.getInitramfsCreationList(config)
+	if err != nil {
+		return fmt.Errorf("failed to create files: %v", err)
+	}
+	for _, e := range initramfsEntries {
+		s.Logger.Info("dry run: would write file %q in the initramfs", e.node().Path)
+	}
+	for _, e := range entries {
+		switch e.(type) {
+		case dirEntry:
+			s.Logger.Info("dry run: would create directory %q", e.node().Path)
+		case linkEntry:
+			s.Logger.Info("dry run: would create link %q", e.node().Path)
+		case fileEntry:
+			s.Logger.Info("dry run: would write file %q", e.node().Path)
+			for _, x := range e.(fileEntry).Xattrs {
+				s.Logger.Info("dry run: would set extended attribute %q on %q", x.Name, e.node().Path)
+			}
+			for _, flag := range e.(fileEntry).Flags {
+				s.Logger.Info("dry run: would set flag %q on %q", flag, e.node().Path)
+			}
+		default:
+			s.Logger.Info("dry run: would write file %q", e.node().Path)
+		}
+		if ctx := e.node().SelinuxContext; ctx != nil {
+			s.Logger.Info("dry run: would set SELinux context of %q to %q", e.node().Path, *ctx)
+		}
+	}
+
+	for _, unit := range config.Systemd.Units {
+		if unit.Contents != nil || len(unit.Dropins) > 0 {
+			s.Logger.Info("dry run: would write unit %q", unit.Name)
+		}
+		if unit.Enabled != nil {
+			s.Logger.Info("dry run: would set unit %q enabled to %t", unit.Name, *unit.Enabled)
+		}
+		if cutil.IsTrue(unit.Mask) {
+			s.Logger.Info("dry run: would mask unit %q", unit.Name)
+		}
+	}
+
+	if cutil.IsTrue(config.MachineID.Clear) {
+		s.Logger.Info("dry run: would clear machine ID")
+	} else if config.MachineID.Value != nil {
+		s.Logger.Info("dry run: would write machine ID")
+	}
+
+	if config.Hostname.Name != nil {
+		s.Logger.Info("dry run: would set hostname to %q", *config.Hostname.Name)
+		if cutil.IsTrue(config.Hostname.UpdateHosts) {
+			s.Logger.Info("dry run: would add hostname %q to %s", *config.Hostname.Name, hostsPath)
+		}
+	}
+
+	for _, c := range config.Commands {
+		s.Logger.Info("dry run: would run command %q", c.Name)
+	}
+	return nil
+}
+
+// enterMountNamespace moves the stage into the mount namespace created by
+// the mount stage, if there is one, so the files are written to the
+// filesystems mounted there.
+func (s *stage) enterMountNamespace() error {
+	path := distro.MountNamespacePath()
+	if path == "" {
+		return nil
+	}
+	entered, err := util.EnterMountNamespace(path)
+	if err != nil {
+		return fmt.Errorf("entering mount namespace at %q: %w", path, err)
+	}
+	if entered {
+		s.Logger.Info("entered mount namespace at %q", path)
+	}
 	return nil
 }
 
 // checkRelabeling determines whether relabeling is supported/requested so that
 // we only collect filenames if we need to.
 func (s *stage) checkRelabeling() error {
+	if distro.RelabelManifestPath() != "" {
+		s.written = make(map[string]struct{})
+	}
+
 	if !distro.SelinuxRelabel() {
 		s.Logger.Debug("compiled without relabeling support, skipping")
 		return nil
@@ -141,13 +309,74 @@ func (s *stage) relabeling() bool {
 	return s.toRelabel != nil
 }
 
+// trackingWrites returns true if written paths need to be recorded, either
+// for relabeling or for the relabel manifest.
+func (s *stage) trackingWrites() bool {
+	return s.relabeling() || s.written != nil
+}
+
 // relabel adds one or more paths to the list of paths that need relabeling.
 func (s *stage) relabel(paths ...string) {
-	if s.toRelabel != nil {
-		for _, path := range paths {
+	for _, path := range paths {
+		if s.toRelabel != nil {
 			s.toRelabel[filepath.Join(s.DestDir, path)] = struct{}{}
 		}
+		if s.written != nil {
+			s.written[path] = struct{}{}
+		}
+	}
+}
+
+// recordChecksum records the SHA-256 of the file at path, which must be
+// under DestDir, for the checksum manifest. A later write to the same path
+// replaces the earlier checksum.
+func (s *stage) recordChecksum(path string) error {
+	if s.checksums == nil {
+		return nil
+	}
+	sum, err := util.FileSHA256(path)
+	if err != nil {
+		return fmt.Errorf("computing checksum of %s: %v", path, err)
+	}
+	s.checksums[path[len(s.DestDir):]] = sum
+	return nil
+}
+
+// writeEtcFile replaces the file at relpath, relative to DestDir, with one
+// containing contents, and marks it for relabeling. Renaming a new file
+// into place works even if the existing file is read-only, which opening
+// it for writing doesn't unless running as root.
+func (s *stage) writeEtcFile(relpath string, contents []byte, mode os.FileMode) error {
+	path, err := s.JoinPath(relpath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := tmp.Write(contents); err != nil {
+		return err
+	}
+	// ioutil.TempFile defaults to 0600
+	if err := tmp.Chmod(mode); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
 	}
+	s.relabel(relpath)
+	return s.recordChecksum(path)
 }
 
 // relabelFiles relabels all the files that were marked for relabeling using
@@ -169,3 +398,49 @@ func (s *stage) relabelFiles() error {
 	}
 	return s.RelabelFiles(keys)
 }
+
+// label records that path should be given the SELinux context ctx.
+func (s *stage) label(path, ctx string) {
+	if s.toLabel == nil {
+		s.toLabel = make(map[string]string)
+	}
+	s.toLabel[path] = ctx
+}
+
+// flag records that path should be given the inode flags in flags.
+func (s *stage) flag(path string, flags []types.FileFlag) {
+	if s.toFlag == nil {
+		s.toFlag = make(map[string][]types.FileFlag)
+	}
+	s.toFlag[path] = flags
+}
+
+// flagFiles sets the inode flags requested in the config.
+func (s *stage) flagFiles() error {
+	paths := make([]string, 0, len(s.toFlag))
+	for path := range s.toFlag {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := s.SetFileFlags(path, s.toFlag[path]); err != nil {
+			return stages.NewError(name, path, err)
+		}
+	}
+	return nil
+}
+
+// labelFiles applies the SELinux contexts explicitly requested in the config.
+func (s *stage) labelFiles() error {
+	paths := make([]string, 0, len(s.toLabel))
+	for path := range s.toLabel {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := s.SetSelinuxContext(path, s.toLabel[path]); err != nil {
+			return stages.NewError(name, path, err)
+		}
+	}
+	return nil
+}
@@ -15,14 +15,41 @@
 package files
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
-	"sort"
 	"testing"
 
+	cutil "github.com/coreos/ignition/v2/config/util"
 	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/exec/stages"
 	"github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/resource"
+	ut "github.com/coreos/ignition/v2/internal/util"
+
+	"github.com/vincent-petithory/dataurl"
+	"golang.org/x/sys/unix"
 )
 
+// newTempStage returns a stage writing to a new temporary directory, and
+// the directory.
+func newTempStage(t *testing.T) (stage, string) {
+	tmp := t.TempDir()
+	logger := log.New(true)
+	return stage{
+		Util: util.Util{
+			DestDir: tmp,
+			Logger:  &logger,
+		},
+	}, tmp
+}
+
 func TestEntrySort(t *testing.T) {
 	type in struct {
 		data []types.Directory
@@ -201,7 +228,7 @@ func TestEntrySort(t *testing.T) {
 		for _, entry := range test.in.data {
 			entries = append(entries, dirEntry(entry))
 		}
-		sort.Slice(entries, func(i, j int) bool { return util.Depth(entries[i].node().Path) < util.Depth(entries[j].node().Path) })
+		sortEntries(entries)
 		outpaths := make([]types.Directory, len(test.in.data))
 		for j, dir := range entries {
 			outpaths[j].Node.Path = dir.node().Path
@@ -211,3 +238,1114 @@ func TestEntrySort(t *testing.T) {
 		}
 	}
 }
+
+func TestGetOrderedCreationList(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ignition-files-test")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	dir := func(path string) types.Directory {
+		return types.Directory{Node: types.Node{Path: path}}
+	}
+	file := func(path string) types.File {
+		return types.File{Node: types.Node{Path: path}}
+	}
+	link := func(path, target string, hard bool) types.Link {
+		return types.Link{
+			Node:          types.Node{Path: path},
+			LinkEmbedded1: types.LinkEmbedded1{Target: cutil.StrToPtr(target), Hard: cutil.BoolToPtr(hard)},
+		}
+	}
+	// each contained node is declared before its directory
+	config := types.Config{
+		Storage: types.Storage{
+			Directories: []types.Directory{
+				dir("/etc/foo/bar"),
+				dir("/var/lib/foo"),
+				dir("/etc/foo"),
+				dir("/etc/baz"),
+			},
+			Files: []types.File{
+				file("/etc/foo/bar/config"),
+				file("/var/lib/foo/state"),
+				file("/etc/foo/config"),
+				file("/etc/motd"),
+			},
+			Links: []types.Link{
+				link("/etc/foo/bar/hard", "/var/lib/foo/state", true),
+				link("/etc/foo/bar/current", "config", false),
+				link("/etc/hard", "/etc/motd", true),
+				link("/etc/current", "foo", false),
+				// a chain of hard links, shallowest last
+				link("/z/y", "/etc/foo/bar/hard", true),
+				link("/a", "/z/y", true),
+			},
+		},
+	}
+	expected := []string{
+		"/etc/baz",
+		"/etc/current",
+		"/etc/foo",
+		"/etc/motd",
+		"/etc/foo/bar",
+		"/etc/foo/config",
+		"/var/lib/foo",
+		"/etc/foo/bar/config",
+		"/etc/foo/bar/current",
+		"/var/lib/foo/state",
+		// hard links last, so their targets exist, and after any hard
+		// link they target
+		"/etc/foo/bar/hard",
+		"/z/y",
+		"/a",
+		"/etc/hard",
+	}
+
+	logger := log.New(true)
+	s := stage{
+		Util: util.Util{
+			DestDir: tmp,
+			Logger:  &logger,
+		},
+	}
+	// the order is the same however the config is scrambled
+	for i := 0; i < 4; i++ {
+		entries, err := s.getOrderedCreationList(config)
+		if err != nil {
+			t.Fatalf("#%d: getting creation list: %v", i, err)
+		}
+		paths := make([]string, len(entries))
+		for j, e := range entries {
+			paths[j] = e.node().Path[len(tmp):]
+		}
+		if !reflect.DeepEqual(expected, paths) {
+			t.Errorf("#%d: bad order: want %v, got %v", i, expected, paths)
+		}
+
+		storage := &config.Storage
+		storage.Directories = append(storage.Directories[1:], storage.Directories[0])
+		storage.Files = append(storage.Files[2:], storage.Files[:2]...)
+		storage.Links[0], storage.Links[3] = storage.Links[3], storage.Links[0]
+		storage.Links[1], storage.Links[2] = storage.Links[2], storage.Links[1]
+		storage.Links[4], storage.Links[5] = storage.Links[5], storage.Links[4]
+	}
+}
+
+func TestDirEntryCreate(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ignition-files-test")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := os.Mkdir(filepath.Join(tmp, "dir"), 0755); err != nil {
+		t.Fatalf("creating directory: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmp, "file"), nil, 0644); err != nil {
+		t.Fatalf("creating file: %v", err)
+	}
+
+	tests := []struct {
+		in      types.Directory
+		mode    os.FileMode
+		wantErr bool
+	}{
+		// new directory, including parents
+		{
+			in: types.Directory{
+				Node:               types.Node{Path: filepath.Join(tmp, "new/nested")},
+				DirectoryEmbedded1: types.DirectoryEmbedded1{Mode: cutil.IntToPtr(0700)},
+			},
+			mode: 0700,
+		},
+		// existing directory
+		{
+			in: types.Directory{
+				Node:               types.Node{Path: filepath.Join(tmp, "dir")},
+				DirectoryEmbedded1: types.DirectoryEmbedded1{Mode: cutil.IntToPtr(0750)},
+			},
+			mode: 0750,
+		},
+		// existing file
+		{
+			in: types.Directory{
+				Node: types.Node{Path: filepath.Join(tmp, "file")},
+			},
+			wantErr: true,
+		},
+	}
+
+	logger := log.New(true)
+	u := util.Util{Logger: &logger}
+	for i, test := range tests {
+		err := dirEntry(test.in).create(&logger, u)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("#%d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		st, err := os.Stat(test.in.Path)
+		if err != nil {
+			t.Errorf("#%d: stat failed: %v", i, err)
+			continue
+		}
+		if !st.IsDir() || st.Mode().Perm() != test.mode {
+			t.Errorf("#%d: bad directory: want mode %v, got %v", i, test.mode, st.Mode())
+		}
+	}
+}
+
+func TestDirEntryCreateRecursive(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ignition-files-test")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	existing := filepath.Join(tmp, "existing")
+	if err := os.Mkdir(existing, 0755); err != nil {
+		t.Fatalf("creating directory: %v", err)
+	}
+
+	d := types.Directory{
+		Node: types.Node{Path: filepath.Join(existing, "new/a/b")},
+		DirectoryEmbedded1: types.DirectoryEmbedded1{
+			Mode:      cutil.IntToPtr(0700),
+			Recursive: cutil.BoolToPtr(true),
+		},
+	}
+	logger := log.New(true)
+	u := util.Util{Logger: &logger}
+	if err := dirEntry(d).create(&logger, u); err != nil {
+		t.Fatalf("creating directory: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		mode os.FileMode
+	}{
+		{existing, 0755},
+		{filepath.Join(existing, "new"), 0700},
+		{filepath.Join(existing, "new/a"), 0700},
+		{filepath.Join(existing, "new/a/b"), 0700},
+	}
+	for i, test := range tests {
+		st, err := os.Stat(test.path)
+		if err != nil {
+			t.Errorf("#%d: stat failed: %v", i, err)
+			continue
+		}
+		if st.Mode().Perm() != test.mode {
+			t.Errorf("#%d: bad mode for %s: want %v, got %v", i, test.path, test.mode, st.Mode().Perm())
+		}
+	}
+}
+
+func TestLinkEntryCreate(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ignition-files-test")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := ioutil.WriteFile(filepath.Join(tmp, "target"), nil, 0644); err != nil {
+		t.Fatalf("creating file: %v", err)
+	}
+	if err := os.Symlink("target", filepath.Join(tmp, "existing")); err != nil {
+		t.Fatalf("creating symlink: %v", err)
+	}
+
+	tests := []struct {
+		in      types.Link
+		wantErr bool
+	}{
+		// relative symlink
+		{
+			in: types.Link{
+				Node:          types.Node{Path: filepath.Join(tmp, "relative")},
+				LinkEmbedded1: types.LinkEmbedded1{Target: cutil.StrToPtr("target")},
+			},
+		},
+		// absolute symlink
+		{
+			in: types.Link{
+				Node:          types.Node{Path: filepath.Join(tmp, "absolute")},
+				LinkEmbedded1: types.LinkEmbedded1{Target: cutil.StrToPtr(filepath.Join(tmp, "target"))},
+			},
+		},
+		// dangling symlinks are permitted
+		{
+			in: types.Link{
+				Node:          types.Node{Path: filepath.Join(tmp, "sub/dangling")},
+				LinkEmbedded1: types.LinkEmbedded1{Target: cutil.StrToPtr("/does/not/exist")},
+			},
+		},
+		// matching symlink already exists
+		{
+			in: types.Link{
+				Node:          types.Node{Path: filepath.Join(tmp, "existing")},
+				LinkEmbedded1: types.LinkEmbedded1{Target: cutil.StrToPtr("target")},
+			},
+		},
+		// symlink with another target already exists
+		{
+			in: types.Link{
+				Node:          types.Node{Path: filepath.Join(tmp, "existing")},
+				LinkEmbedded1: types.LinkEmbedded1{Target: cutil.StrToPtr("other")},
+			},
+			wantErr: true,
+		},
+		// hard link, target relative to the root
+		{
+			in: types.Link{
+				Node:          types.Node{Path: filepath.Join(tmp, "hard")},
+				LinkEmbedded1: types.LinkEmbedded1{Target: cutil.StrToPtr("/target"), Hard: cutil.BoolToPtr(true)},
+			},
+		},
+	}
+
+	logger := log.New(true)
+	u := util.Util{Logger: &logger, DestDir: tmp}
+	for i, test := range tests {
+		err := linkEntry(test.in).create(&logger, u)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("#%d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if cutil.IsTrue(test.in.Hard) {
+			st, err := os.Stat(test.in.Path)
+			if err != nil {
+				t.Errorf("#%d: stat failed: %v", i, err)
+				continue
+			}
+			targetSt, err := os.Stat(filepath.Join(tmp, *test.in.Target))
+			if err != nil {
+				t.Errorf("#%d: stat failed: %v", i, err)
+				continue
+			}
+			if !os.SameFile(st, targetSt) {
+				t.Errorf("#%d: %s is not a hard link to %s", i, test.in.Path, *test.in.Target)
+			}
+			continue
+		}
+		target, err := os.Readlink(test.in.Path)
+		if err != nil {
+			t.Errorf("#%d: reading link failed: %v", i, err)
+			continue
+		}
+		if target != *test.in.Target {
+			t.Errorf("#%d: bad target: want %q, got %q", i, *test.in.Target, target)
+		}
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ignition-files-test")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	logger := log.New(true)
+	logger.SetDryRun(true)
+	s := stage{
+		Util: util.Util{
+			DestDir: tmp,
+			Logger:  &logger,
+		},
+	}
+	config := types.Config{
+		Storage: types.Storage{
+			Directories: []types.Directory{
+				{Node: types.Node{Path: "/etc/dir"}},
+			},
+			Files: []types.File{
+				{
+					Node: types.Node{Path: "/etc/file"},
+					FileEmbedded1: types.FileEmbedded1{
+						Contents: types.Resource{Source: cutil.StrToPtr("data:,hello")},
+					},
+				},
+			},
+			Links: []types.Link{
+				{
+					Node:          types.Node{Path: "/etc/link"},
+					LinkEmbedded1: types.LinkEmbedded1{Target: cutil.StrToPtr("/etc/file")},
+				},
+			},
+		},
+		Systemd: types.Systemd{
+			Units: []types.Unit{
+				{
+					Name:     "example.service",
+					Contents: cutil.StrToPtr("[Service]\nExecStart=/bin/true\n"),
+					Enabled:  cutil.BoolToPtr(true),
+				},
+			},
+		},
+	}
+	if err := s.Apply(config, false); err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	entries, err := ioutil.ReadDir(tmp)
+	if err != nil {
+		t.Fatalf("reading root: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("dry run modified the root: found %d entries", len(entries))
+	}
+}
+
+func TestCreateEntriesSelinuxContext(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ignition-files-test")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	// setting security.* xattrs needs privileges and filesystem support
+	probe := filepath.Join(tmp, "probe")
+	if err := ioutil.WriteFile(probe, nil, 0644); err != nil {
+		t.Fatalf("creating file: %v", err)
+	}
+	if err := unix.Lsetxattr(probe, "security.selinux", []byte("system_u:object_r:etc_t:s0\x00"), 0); err != nil {
+		t.Skipf("can't set SELinux contexts here: %v", err)
+	}
+
+	logger := log.New(true)
+	s := stage{
+		Util: util.Util{
+			DestDir: tmp,
+			Fetcher: resource.Fetcher{Logger: &logger},
+			Logger:  &logger,
+		},
+	}
+	entries := []filesystemEntry{
+		dirEntry(types.Directory{
+			Node: types.Node{
+				Path:           filepath.Join(tmp, "dir"),
+				SelinuxContext: cutil.StrToPtr("system_u:object_r:etc_t:s0"),
+			},
+		}),
+		fileEntry(types.File{
+			Node: types.Node{
+				Path:           filepath.Join(tmp, "dir/file"),
+				SelinuxContext: cutil.StrToPtr("system_u:object_r:bin_t:s0"),
+			},
+			FileEmbedded1: types.FileEmbedded1{
+				Contents: types.Resource{Source: cutil.StrToPtr("data:,hello")},
+			},
+		}),
+		linkEntry(types.Link{
+			Node: types.Node{
+				Path:           filepath.Join(tmp, "link"),
+				SelinuxContext: cutil.StrToPtr("system_u:object_r:usr_t:s0"),
+			},
+			LinkEmbedded1: types.LinkEmbedded1{Target: cutil.StrToPtr("dir/file")},
+		}),
+		// unlabeled
+		fileEntry(types.File{
+			Node: types.Node{Path: filepath.Join(tmp, "other")},
+		}),
+	}
+	if err := s.createEntries(entries); err != nil {
+		t.Fatalf("creating entries: %v", err)
+	}
+	if err := s.labelFiles(); err != nil {
+		t.Fatalf("labeling entries: %v", err)
+	}
+
+	for i, e := range entries {
+		buf := make([]byte, 256)
+		n, err := unix.Lgetxattr(e.node().Path, "security.selinux", buf)
+		if e.node().SelinuxContext == nil {
+			if err != unix.ENODATA {
+				t.Errorf("#%d: expected no context, got %q (%v)", i, buf[:n], err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: reading context: %v", i, err)
+			continue
+		}
+		if got := string(buf[:n]); got != *e.node().SelinuxContext+"\x00" {
+			t.Errorf("#%d: bad context: want %q, got %q", i, *e.node().SelinuxContext, got)
+		}
+	}
+}
+
+func TestFileEntryCreateXattrs(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ignition-files-test")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	probe := filepath.Join(tmp, "probe")
+	if err := ioutil.WriteFile(probe, nil, 0644); err != nil {
+		t.Fatalf("creating file: %v", err)
+	}
+	if err := unix.Setxattr(probe, "user.probe", nil, 0); err != nil {
+		t.Skipf("can't set user xattrs here: %v", err)
+	}
+
+	logger := log.New(true)
+	u := util.Util{
+		Fetcher: resource.Fetcher{Logger: &logger},
+		Logger:  &logger,
+	}
+	xattrs := []types.FileXattr{
+		{Name: "user.comment", Value: cutil.StrToPtr("data:,provisioned%20by%20ignition")},
+		{Name: "user.binary", Value: cutil.StrToPtr("data:;base64,AAEC/w==")},
+		{Name: "user.empty"},
+	}
+	want := []string{"provisioned by ignition", "\x00\x01\x02\xff", ""}
+	f := types.File{
+		Node: types.Node{Path: filepath.Join(tmp, "file")},
+		FileEmbedded1: types.FileEmbedded1{
+			Contents: types.Resource{Source: cutil.StrToPtr("data:,hello")},
+			Xattrs:   xattrs,
+		},
+	}
+	if err := fileEntry(f).create(&logger, u); err != nil {
+		t.Fatalf("creating file: %v", err)
+	}
+
+	for i, x := range xattrs {
+		buf := make([]byte, 256)
+		n, err := unix.Getxattr(f.Path, x.Name, buf)
+		if err != nil {
+			t.Errorf("#%d: reading %s: %v", i, x.Name, err)
+			continue
+		}
+		if got := string(buf[:n]); got != want[i] {
+			t.Errorf("#%d: bad value for %s: want %q, got %q", i, x.Name, want[i], got)
+		}
+	}
+}
+
+func TestCreateEntriesUnchangedFile(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ignition-files-test")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	helloSum := sha256.Sum256([]byte("hello"))
+	helloHash := "sha256-" + hex.EncodeToString(helloSum[:])
+
+	tests := []struct {
+		existing     string
+		source       string
+		verification types.Verification
+		rewritten    bool
+	}{
+		// matching verification hash, no fetch needed
+		{
+			existing:     "hello",
+			source:       "data:,hello",
+			verification: types.Verification{Hash: &helloHash},
+		},
+		// matching one of several hashes
+		{
+			existing: "hello",
+			source:   "data:,hello",
+			verification: types.Verification{
+				Hash:   cutil.StrToPtr("sha256-0519a9826023338828942b081814355d55301b9bc82042390f9afaf75cd3a707"),
+				Hashes: []string{helloHash},
+			},
+		},
+		// no verification, fetched contents compared
+		{
+			existing: "hello",
+			source:   "data:,hello",
+		},
+		// mismatches
+		{
+			existing:     "goodbye",
+			source:       "data:,hello",
+			verification: types.Verification{Hash: &helloHash},
+			rewritten:    true,
+		},
+		{
+			existing:  "goodbye",
+			source:    "data:,hello",
+			rewritten: true,
+		},
+		{
+			existing:  "hell",
+			source:    "data:,hello",
+			rewritten: true,
+		},
+	}
+
+	logger := log.New(true)
+	s := stage{
+		Util: util.Util{
+			DestDir: tmp,
+			Fetcher: resource.Fetcher{Logger: &logger},
+			Logger:  &logger,
+		},
+	}
+	for i, test := range tests {
+		path := filepath.Join(tmp, fmt.Sprintf("file%d", i))
+		if err := ioutil.WriteFile(path, []byte(test.existing), 0644); err != nil {
+			t.Fatalf("#%d: writing file: %v", i, err)
+		}
+		var before unix.Stat_t
+		if err := unix.Stat(path, &before); err != nil {
+			t.Fatalf("#%d: stat: %v", i, err)
+		}
+
+		e := fileEntry(types.File{
+			Node: types.Node{
+				Path:      path,
+				Overwrite: cutil.BoolToPtr(true),
+			},
+			FileEmbedded1: types.FileEmbedded1{
+				Contents: types.Resource{
+					Source:       &test.source,
+					Verification: test.verification,
+				},
+			},
+		})
+		if err := s.createEntries([]filesystemEntry{e}); err != nil {
+			t.Errorf("#%d: creating entry: %v", i, err)
+			continue
+		}
+
+		var after unix.Stat_t
+		if err := unix.Stat(path, &after); err != nil {
+			t.Fatalf("#%d: stat: %v", i, err)
+		}
+		if rewritten := before.Ino != after.Ino; rewritten != test.rewritten {
+			t.Errorf("#%d: bad rewrite: want %v, got %v", i, test.rewritten, rewritten)
+		}
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("#%d: reading file: %v", i, err)
+		}
+		if string(contents) != "hello" {
+			t.Errorf("#%d: bad contents: want %q, got %q", i, "hello", contents)
+		}
+	}
+}
+
+func TestCreateEntriesError(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ignition-files-test")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	logger := log.New(true)
+	s := stage{
+		Util: util.Util{
+			DestDir: tmp,
+			Fetcher: resource.Fetcher{Logger: &logger},
+			Logger:  &logger,
+		},
+	}
+	path := filepath.Join(tmp, "file")
+	e := fileEntry(types.File{
+		Node: types.Node{
+			Path: path,
+		},
+		FileEmbedded1: types.FileEmbedded1{
+			Contents: types.Resource{
+				Source: cutil.StrToPtr("data:,hello"),
+				Verification: types.Verification{
+					Hash: cutil.StrToPtr("sha256-0519a9826023338828942b081814355d55301b9bc82042390f9afaf75cd3a707"),
+				},
+			},
+		},
+	})
+
+	err = s.createEntries([]filesystemEntry{e})
+	if !errors.Is(err, stages.ErrFilesStage) {
+		t.Fatalf("bad error: want %v, got %v", stages.ErrFilesStage, err)
+	}
+	var stageErr *stages.Error
+	if !errors.As(err, &stageErr) {
+		t.Fatalf("error %v doesn't carry a stage error", err)
+	}
+	if stageErr.Resource != path {
+		t.Errorf("bad resource: want %q, got %q", path, stageErr.Resource)
+	}
+	var hashErr ut.ErrHashMismatch
+	if !errors.As(err, &hashErr) {
+		t.Errorf("error %v doesn't wrap the hash mismatch", err)
+	}
+}
+
+func TestCreateEntriesFileURL(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ignition-files-test")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	// the source is read from the machine running Ignition, even if the
+	// same path exists in the root being provisioned
+	root := filepath.Join(tmp, "root")
+	source := filepath.Join(tmp, "build", "motd")
+	for path, data := range map[string]string{
+		source:                      "from the build host\n",
+		filepath.Join(root, source): "from the target\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sum := sha256.Sum256([]byte("from the build host\n"))
+
+	logger := log.New(true)
+	s := stage{
+		Util: util.Util{
+			DestDir: root,
+			Fetcher: resource.Fetcher{Logger: &logger},
+			Logger:  &logger,
+		},
+	}
+	e := fileEntry(types.File{
+		Node: types.Node{
+			Path: filepath.Join(root, "etc/motd"),
+		},
+		FileEmbedded1: types.FileEmbedded1{
+			Contents: types.Resource{
+				Source: cutil.StrToPtr("file://" + source),
+				Verification: types.Verification{
+					Hash: cutil.StrToPtr("sha256-" + hex.EncodeToString(sum[:])),
+				},
+			},
+		},
+	})
+	if err := s.createEntries([]filesystemEntry{e}); err != nil {
+		t.Fatalf("creating entries: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(root, "etc/motd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "from the build host\n" {
+		t.Errorf("bad contents: want %q, got %q", "from the build host\n", data)
+	}
+}
+
+func TestCreateFilesystemsEntriesTarget(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ignition-files-test")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+	sysroot := filepath.Join(tmp, "sysroot")
+	initramfs := filepath.Join(tmp, "initramfs")
+
+	config := types.Config{
+		Storage: types.Storage{
+			Files: []types.File{
+				{
+					Node: types.Node{Path: "/etc/motd"},
+					FileEmbedded1: types.FileEmbedded1{
+						Contents: types.Resource{Source: cutil.StrToPtr("data:,sysroot")},
+					},
+				},
+				{
+					Node: types.Node{Path: "/etc/hostname"},
+					FileEmbedded1: types.FileEmbedded1{
+						Contents: types.Resource{Source: cutil.StrToPtr("data:,sysroot")},
+						Target:   cutil.StrToPtr("sysroot"),
+					},
+				},
+				{
+					Node: types.Node{Path: "/etc/NetworkManager/system-connections/eth0.nmconnection"},
+					FileEmbedded1: types.FileEmbedded1{
+						Contents: types.Resource{Source: cutil.StrToPtr("data:,initramfs")},
+						Target:   cutil.StrToPtr("initramfs"),
+					},
+				},
+				// written by the disks stage
+				{
+					Node: types.Node{Path: "/run/disk.img"},
+					FileEmbedded1: types.FileEmbedded1{
+						Contents: types.Resource{Source: cutil.StrToPtr("data:,loop")},
+						Target:   cutil.StrToPtr("initramfs"),
+					},
+				},
+			},
+			Loops: []types.Loop{
+				{
+					Device: "/dev/loop0",
+					File:   cutil.StrToPtr("/run/disk.img"),
+				},
+			},
+		},
+	}
+
+	logger := log.New(true)
+	s := stage{
+		Util: util.Util{
+			DestDir: sysroot,
+			Fetcher: resource.Fetcher{Logger: &logger},
+			Logger:  &logger,
+		},
+		initramfsDir: initramfs,
+	}
+	if err := s.createFilesystemsEntries(config); err != nil {
+		t.Fatalf("creating entries: %v", err)
+	}
+
+	tests := []struct {
+		path    string
+		written string
+		missing string
+	}{
+		{"/etc/motd", sysroot, initramfs},
+		{"/etc/hostname", sysroot, initramfs},
+		{"/etc/NetworkManager/system-connections/eth0.nmconnection", initramfs, sysroot},
+	}
+	for i, test := range tests {
+		data, err := ioutil.ReadFile(filepath.Join(test.written, test.path))
+		if err != nil {
+			t.Errorf("#%d: reading %s: %v", i, test.path, err)
+		} else if want := filepath.Base(test.written); string(data) != want {
+			t.Errorf("#%d: bad contents of %s: want %q, got %q", i, test.path, want, data)
+		}
+		if _, err := os.Stat(filepath.Join(test.missing, test.path)); !os.IsNotExist(err) {
+			t.Errorf("#%d: %s written to %s", i, test.path, test.missing)
+		}
+	}
+	for _, dir := range []string{sysroot, initramfs} {
+		if _, err := os.Stat(filepath.Join(dir, "/run/disk.img")); !os.IsNotExist(err) {
+			t.Errorf("loop backing file written to %s by the files stage", dir)
+		}
+	}
+}
+
+func TestApplyInitramfsFiles(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ignition-files-test")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	config := types.Config{
+		Storage: types.Storage{
+			Files: []types.File{
+				{
+					Node: types.Node{Path: "/etc/motd"},
+					FileEmbedded1: types.FileEmbedded1{
+						Contents: types.Resource{Source: cutil.StrToPtr("data:,sysroot")},
+					},
+				},
+				{
+					Node: types.Node{Path: "/etc/initramfs"},
+					FileEmbedded1: types.FileEmbedded1{
+						Target: cutil.StrToPtr("initramfs"),
+					},
+				},
+			},
+		},
+	}
+
+	logger := log.New(true)
+	s := stage{
+		Util: util.Util{
+			DestDir: filepath.Join(tmp, "sysroot"),
+			Fetcher: resource.Fetcher{Logger: &logger},
+			Logger:  &logger,
+		},
+		initramfsDir: filepath.Join(tmp, "initramfs"),
+	}
+	if err := s.Apply(config, false); err == nil {
+		t.Errorf("applying files targeting the initramfs succeeded")
+	}
+	if err := s.Apply(config, true); err != nil {
+		t.Fatalf("applying while ignoring unsupported: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "sysroot", "etc/motd")); err != nil {
+		t.Errorf("sysroot file not written: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "initramfs")); !os.IsNotExist(err) {
+		t.Errorf("initramfs written while applying: %v", err)
+	}
+}
+
+func TestCreateRelabelManifest(t *testing.T) {
+	const manifestPath = "/etc/.ignition-relabel-paths"
+
+	for _, enabled := range []bool{false, true} {
+		tmp, err := ioutil.TempDir("", "ignition-files-test")
+		if err != nil {
+			t.Fatalf("creating temp dir: %v", err)
+		}
+		defer os.RemoveAll(tmp)
+		if err := os.Mkdir(filepath.Join(tmp, "etc"), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if enabled {
+			os.Setenv("IGNITION_RELABEL_MANIFEST_PATH", manifestPath)
+		} else {
+			os.Unsetenv("IGNITION_RELABEL_MANIFEST_PATH")
+		}
+		logger := log.New(true)
+		s := stage{
+			Util: util.Util{
+				DestDir: tmp,
+				Fetcher: resource.Fetcher{Logger: &logger},
+				Logger:  &logger,
+			},
+		}
+		if err := s.checkRelabeling(); err != nil {
+			t.Fatal(err)
+		}
+
+		var entries []filesystemEntry
+		for _, path := range []string{"/etc/foo/bar/baz", "/etc/motd"} {
+			entries = append(entries, fileEntry(types.File{
+				Node: types.Node{
+					Path: filepath.Join(tmp, path),
+				},
+			}))
+		}
+		// explicitly labeled and flagged paths are left out
+		entries = append(entries, fileEntry(types.File{
+			Node: types.Node{
+				Path:           filepath.Join(tmp, "/etc/labeled"),
+				SelinuxContext: cutil.StrToPtr("system_u:object_r:etc_t:s0"),
+			},
+		}), fileEntry(types.File{
+			Node: types.Node{
+				Path: filepath.Join(tmp, "/etc/immutable"),
+			},
+			FileEmbedded1: types.FileEmbedded1{
+				Flags: []types.FileFlag{"immutable"},
+			},
+		}))
+		if err := s.createEntries(entries); err != nil {
+			t.Fatalf("creating entries: %v", err)
+		}
+		// as done for files written by useradd and friends
+		s.relabel("/etc/passwd")
+		if err := s.createRelabelManifest(); err != nil {
+			t.Fatalf("creating manifest: %v", err)
+		}
+
+		manifest, err := ioutil.ReadFile(filepath.Join(tmp, manifestPath))
+		if !enabled {
+			if !os.IsNotExist(err) {
+				t.Errorf("manifest written when disabled: %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("reading manifest: %v", err)
+		}
+		expected := "/etc/.ignition-relabel-paths\n/etc/foo\n/etc/motd\n/etc/passwd\n"
+		if string(manifest) != expected {
+			t.Errorf("bad manifest: want %q, got %q", expected, manifest)
+		}
+	}
+	os.Unsetenv("IGNITION_RELABEL_MANIFEST_PATH")
+}
+
+func TestCreateChecksumManifest(t *testing.T) {
+	const manifestPath = "/etc/.ignition-checksums"
+
+	for _, enabled := range []bool{false, true} {
+		tmp, err := ioutil.TempDir("", "ignition-files-test")
+		if err != nil {
+			t.Fatalf("creating temp dir: %v", err)
+		}
+		defer os.RemoveAll(tmp)
+
+		logger := log.New(true)
+		s := stage{
+			Util: util.Util{
+				DestDir: tmp,
+				Fetcher: resource.Fetcher{Logger: &logger},
+				Logger:  &logger,
+			},
+		}
+		if enabled {
+			s.checksums = make(map[string]string)
+			os.Setenv("IGNITION_CHECKSUM_MANIFEST_PATH", manifestPath)
+		} else {
+			os.Unsetenv("IGNITION_CHECKSUM_MANIFEST_PATH")
+		}
+
+		contents := map[string]string{
+			"/etc/motd":        "hello\n",
+			"/etc/foo/bar/baz": "",
+			"/var/lib/data":    "some data",
+		}
+		entries := []filesystemEntry{
+			dirEntry(types.Directory{
+				Node: types.Node{
+					Path: filepath.Join(tmp, "/var/empty"),
+				},
+			}),
+		}
+		for path, data := range contents {
+			source := dataurl.EncodeBytes([]byte(data))
+			entries = append(entries, fileEntry(types.File{
+				Node: types.Node{
+					Path: filepath.Join(tmp, path),
+				},
+				FileEmbedded1: types.FileEmbedded1{
+					Contents: types.Resource{
+						Source: &source,
+					},
+				},
+			}))
+		}
+		if err := s.createEntries(entries); err != nil {
+			t.Fatalf("creating entries: %v", err)
+		}
+		if err := s.createChecksumManifest(); err != nil {
+			t.Fatalf("creating manifest: %v", err)
+		}
+
+		manifest, err := ioutil.ReadFile(filepath.Join(tmp, manifestPath))
+		if !enabled {
+			if !os.IsNotExist(err) {
+				t.Errorf("manifest written when disabled: %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("reading manifest: %v", err)
+		}
+		expected := ""
+		for _, path := range []string{"/etc/foo/bar/baz", "/etc/motd", "/var/lib/data"} {
+			written, err := ioutil.ReadFile(filepath.Join(tmp, path))
+			if err != nil {
+				t.Fatalf("reading %s: %v", path, err)
+			}
+			if string(written) != contents[path] {
+				t.Fatalf("bad contents of %s: want %q, got %q", path, contents[path], written)
+			}
+			sum := sha256.Sum256(written)
+			expected += hex.EncodeToString(sum[:]) + "  " + path + "\n"
+		}
+		if string(manifest) != expected {
+			t.Errorf("bad manifest: want %q, got %q", expected, manifest)
+		}
+	}
+	os.Unsetenv("IGNITION_CHECKSUM_MANIFEST_PATH")
+}
+
+func TestFlagFiles(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ignition-files-test")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	// setting inode flags needs CAP_LINUX_IMMUTABLE and filesystem support
+	clearFlags := func(path string) {
+		if f, err := os.Open(path); err == nil {
+			_ = unix.IoctlSetPointerInt(int(f.Fd()), fsIocSetflags, 0)
+			f.Close()
+		}
+	}
+	probe := filepath.Join(tmp, "probe")
+	if err := ioutil.WriteFile(probe, nil, 0644); err != nil {
+		t.Fatalf("creating file: %v", err)
+	}
+	logger := log.New(true)
+	u := util.Util{
+		DestDir: tmp,
+		Fetcher: resource.Fetcher{Logger: &logger},
+		Logger:  &logger,
+	}
+	if err := u.SetFileFlags(probe, []types.FileFlag{types.FileFlagImmutable}); err != nil {
+		t.Skipf("can't set inode flags here: %v", err)
+	}
+	clearFlags(probe)
+
+	tests := []struct {
+		flags []types.FileFlag
+		want  uint32
+	}{
+		{
+			flags: []types.FileFlag{types.FileFlagImmutable},
+			want:  0x10,
+		},
+		{
+			flags: []types.FileFlag{types.FileFlagAppendOnly},
+			want:  0x20,
+		},
+		{
+			flags: []types.FileFlag{types.FileFlagImmutable, types.FileFlagAppendOnly},
+			want:  0x30,
+		},
+		{
+			want: 0,
+		},
+	}
+
+	for i, test := range tests {
+		path := filepath.Join(tmp, fmt.Sprintf("file%d", i))
+		defer clearFlags(path)
+		s := stage{Util: u}
+		err := s.createEntries([]filesystemEntry{
+			fileEntry(types.File{
+				Node: types.Node{Path: path},
+				FileEmbedded1: types.FileEmbedded1{
+					Contents: types.Resource{Source: cutil.StrToPtr("data:,hello")},
+					Flags:    test.flags,
+				},
+			}),
+		})
+		if err != nil {
+			t.Errorf("#%d: creating file: %v", i, err)
+			continue
+		}
+		if err := s.flagFiles(); err != nil {
+			t.Errorf("#%d: setting flags: %v", i, err)
+			continue
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			t.Errorf("#%d: opening file: %v", i, err)
+			continue
+		}
+		got, err := unix.IoctlGetUint32(int(f.Fd()), unix.FS_IOC_GETFLAGS)
+		f.Close()
+		if err != nil {
+			t.Errorf("#%d: getting flags: %v", i, err)
+			continue
+		}
+		if got&0x30 != test.want {
+			t.Errorf("#%d: bad flags: want %#x, got %#x", i, test.want, got&0x30)
+		}
+		if test.want&0x10 != 0 {
+			if err := ioutil.WriteFile(path, []byte("changed"), 0644); err == nil {
+				t.Errorf("#%d: immutable file was modified", i)
+			}
+		}
+	}
+}
+
+// fsIocSetflags matches the unexported value in internal/exec/util.
+const fsIocSetflags = (unix.FS_IOC_GETFLAGS ^ 0xc0000000) + 1
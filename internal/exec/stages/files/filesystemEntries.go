@@ -28,6 +28,7 @@ import (
 	cutil "github.com/coreos/ignition/v2/config/util"
 	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
 	"github.com/coreos/ignition/v2/internal/distro"
+	"github.com/coreos/ignition/v2/internal/exec/stages"
 	"github.com/coreos/ignition/v2/internal/exec/util"
 	"github.com/coreos/ignition/v2/internal/log"
 
@@ -221,6 +222,106 @@ func (s *stage) createResultFile() error {
 	return nil
 }
 
+// createRelabelManifest writes the paths written by this stage, one per
+// line and relative to the root, to the distro's relabel manifest. Each
+// directory listed was created by Ignition and should be relabeled
+// recursively. The manifest lists itself. Paths given an explicit SELinux
+// context or inode flags are left out, since relabeling them would override
+// the context or fail on an immutable file.
+func (s *stage) createRelabelManifest() error {
+	if s.written == nil {
+		return nil
+	}
+
+	s.Logger.PushPrefix("createRelabelManifest")
+	defer s.Logger.PopPrefix()
+
+	path, err := s.JoinPath(distro.RelabelManifestPath())
+	if err != nil {
+		return fmt.Errorf("building relabel manifest path: %w", err)
+	}
+	if err := s.relabelPath(path); err != nil {
+		return err
+	}
+	paths := make([]string, 0, len(s.written))
+	for p := range s.written {
+		if _, ok := s.toLabel[filepath.Join(s.DestDir, p)]; ok {
+			continue
+		}
+		if _, ok := s.toFlag[filepath.Join(s.DestDir, p)]; ok {
+			continue
+		}
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	contentsUri := dataurl.EncodeBytes([]byte(strings.Join(paths, "\n") + "\n"))
+	entries := []filesystemEntry{
+		fileEntry{
+			types.Node{
+				Path:      path,
+				Overwrite: cutil.BoolToPtr(true),
+			},
+			types.FileEmbedded1{
+				Contents: types.Resource{
+					Source: &contentsUri,
+				},
+				Mode: cutil.IntToPtr(0644),
+			},
+		},
+	}
+	if err := s.createEntries(entries); err != nil {
+		return fmt.Errorf("adding relabel manifest: %w", err)
+	}
+	return nil
+}
+
+// createChecksumManifest writes the SHA-256 of each file written by this
+// stage to the distro's checksum manifest, one "<hash>  <path>" line per
+// file sorted by path, so it can be checked with "sha256sum -c" from the
+// root. Files modified after Ignition wrote them will fail the check.
+func (s *stage) createChecksumManifest() error {
+	if s.checksums == nil {
+		return nil
+	}
+
+	s.Logger.PushPrefix("createChecksumManifest")
+	defer s.Logger.PopPrefix()
+
+	path, err := s.JoinPath(distro.ChecksumManifestPath())
+	if err != nil {
+		return fmt.Errorf("building checksum manifest path: %w", err)
+	}
+	paths := make([]string, 0, len(s.checksums))
+	for p := range s.checksums {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var contents strings.Builder
+	for _, p := range paths {
+		fmt.Fprintf(&contents, "%s  %s\n", s.checksums[p], p)
+	}
+	contentsUri := dataurl.EncodeBytes([]byte(contents.String()))
+	entries := []filesystemEntry{
+		fileEntry{
+			types.Node{
+				Path:      path,
+				Overwrite: cutil.BoolToPtr(true),
+			},
+			types.FileEmbedded1{
+				Contents: types.Resource{
+					Source: &contentsUri,
+				},
+				// hashes of low-entropy secrets can be brute forced
+				Mode: cutil.IntToPtr(0600),
+			},
+		},
+	}
+	if err := s.createEntries(entries); err != nil {
+		return fmt.Errorf("adding checksum manifest: %w", err)
+	}
+	return nil
+}
+
 // createFilesystemsEntries creates the files described in config.Storage.{Files,Directories}.
 func (s *stage) createFilesystemsEntries(config types.Config) error {
 	s.Logger.PushPrefix("createFilesystemsFiles")
@@ -232,7 +333,15 @@ func (s *stage) createFilesystemsEntries(config types.Config) error {
 	}
 
 	if err := s.createEntries(entries); err != nil {
-		return fmt.Errorf("failed to create files: %v", err)
+		return fmt.Errorf("failed to create files: %w", err)
+	}
+
+	initramfsEntries, err := s.getInitramfsCreationList(config)
+	if err != nil {
+		return err
+	}
+	if err := s.createEntriesIn(s.initramfsUtil(), initramfsEntries); err != nil {
+		return fmt.Errorf("failed to create files in the initramfs: %w", err)
 	}
 
 	return nil
@@ -241,7 +350,8 @@ func (s *stage) createFilesystemsEntries(config types.Config) error {
 // filesystemEntry represent a thing that knows how to create itself.
 type filesystemEntry interface {
 	// create creates the entry if specified. It assumes that if overwrite=true then any existing
-	// files at the path will have been deleted.
+	// files at the path will have been deleted, except for a regular file about to be replaced by
+	// a file entry's contents, which is kept until the new contents are known to differ.
 	create(l *log.Logger, u util.Util) error
 	node() types.Node
 }
@@ -270,6 +380,21 @@ func (tmp fileEntry) create(l *log.Logger, u util.Util) error {
 	// Cases where there is file there
 	case !regular:
 		return fmt.Errorf("error creating file %q: A non regular file exists there already and overwrite is false", f.Path)
+	case f.Contents.Source != nil && tmp.replacesInPlace():
+		// removePathOnOverwrite kept the file so unchanged contents can
+		// be detected. The hash of a template doesn't describe the
+		// rendered file, so those are compared after rendering instead.
+		if cutil.IsTrue(f.Template) {
+			break
+		}
+		unchanged, err := util.FileMatchesVerification(f.Path, f.Contents.Verification)
+		if err != nil {
+			return fmt.Errorf("error checking existing file %q: %v", f.Path, err)
+		}
+		if unchanged {
+			l.Info("file %q matches its verification hash; skipping write", f.Path)
+			f.Contents.Source = nil
+		}
 	case f.Contents.Source != nil:
 		return fmt.Errorf("error creating file %q: A file exists there already and overwrite is false", f.Path)
 	case regular && f.Contents.Source == nil:
@@ -293,15 +418,30 @@ func (tmp fileEntry) create(l *log.Logger, u util.Util) error {
 				return u.PerformFetch(op)
 			}, msg, f.Path,
 		); err != nil {
-			return fmt.Errorf("failed to create file %q: %v", op.Node.Path, err)
+			return fmt.Errorf("failed to create file %q: %w", op.Node.Path, err)
 		}
 	}
 	if err := u.SetPermissions(f.Mode, f.Node); err != nil {
 		return fmt.Errorf("error setting file permissions for %s: %v", f.Path, err)
 	}
+	if err := u.SetXattrs(f.Path, f.Xattrs); err != nil {
+		return fmt.Errorf("error setting extended attributes for %s: %v", f.Path, err)
+	}
 	return nil
 }
 
+// replacesInPlace reports whether the entry's contents replace an existing
+// regular file wholesale, so the file can be kept until the new contents
+// are known to differ.
+func (tmp fileEntry) replacesInPlace() bool {
+	f := types.File(tmp)
+	if !cutil.IsTrue(f.Overwrite) || f.Contents.Source == nil || len(f.Append) > 0 {
+		return false
+	}
+	st, err := os.Lstat(f.Path)
+	return err == nil && st.Mode().IsRegular()
+}
+
 type dirEntry types.Directory
 
 func (tmp dirEntry) node() types.Node {
@@ -310,9 +450,14 @@ func (tmp dirEntry) node() types.Node {
 
 func (tmp dirEntry) create(l *log.Logger, u util.Util) error {
 	d := types.Directory(tmp)
+	// the first directory created for this entry, if any
+	created := ""
 	st, err := os.Lstat(d.Path)
 	switch {
 	case os.IsNotExist(err):
+		if created, err = util.FindFirstMissingPathComponent(d.Path); err != nil {
+			return fmt.Errorf("failed to find missing parents of %s: %v", d.Path, err)
+		}
 		// use default perms, we'll fix it later
 		if err := os.MkdirAll(d.Path, util.DefaultDirectoryPermissions); err != nil {
 			return fmt.Errorf("Failed to create directory %s: %v", d.Path, err)
@@ -326,6 +471,16 @@ func (tmp dirEntry) create(l *log.Logger, u util.Util) error {
 	if err := u.SetPermissions(d.Mode, d.Node); err != nil {
 		return fmt.Errorf("error setting directory permissions for %s: %v", d.Path, err)
 	}
+	if cutil.IsTrue(d.Recursive) && created != "" {
+		// only touch the parents we just created
+		for parent := filepath.Dir(d.Path); len(parent) >= len(created); parent = filepath.Dir(parent) {
+			node := d.Node
+			node.Path = parent
+			if err := u.SetPermissions(d.Mode, node); err != nil {
+				return fmt.Errorf("error setting directory permissions for %s: %v", parent, err)
+			}
+		}
+	}
 	return nil
 }
 
@@ -387,7 +542,7 @@ func (tmp linkEntry) create(l *log.Logger, u util.Util) error {
 
 // getOrderedCreationList resolves all symlinks in the node paths and sets the path to be
 // prepended by the sysroot. It orders the list from shallowest (e.g. /a) to deepeset
-// (e.g. /a/b/c/d/e).
+// (e.g. /a/b/c/d/e), regardless of the order of the config.
 func (s stage) getOrderedCreationList(config types.Config) ([]filesystemEntry, error) {
 	entries := []filesystemEntry{}
 	// Map from paths in the config to where they resolve for duplicate checking
@@ -406,7 +561,7 @@ func (s stage) getOrderedCreationList(config types.Config) ([]filesystemEntry, e
 		entries = append(entries, dirEntry(d))
 	}
 
-	for _, f := range config.Storage.Files {
+	for _, f := range sysrootFiles(config) {
 		path, err := s.JoinPath(f.Path)
 		if err != nil {
 			return nil, err
@@ -439,17 +594,138 @@ func (s stage) getOrderedCreationList(config types.Config) ([]filesystemEntry, e
 		}
 
 	}
-	sort.Slice(entries, func(i, j int) bool { return util.Depth(entries[i].node().Path) < util.Depth(entries[j].node().Path) })
+	sortEntries(entries)
 
 	// Append all the hard links to the list after sorting. This allows
 	// Ignition to create hard links to files that are deeper than the hard
 	// link. For reference: https://github.com/coreos/ignition/issues/800
+	hardlinks, err := s.orderHardlinks(hardlinks)
+	if err != nil {
+		return nil, err
+	}
 	entries = append(entries, hardlinks...)
 
 	return entries, nil
 }
 
+// orderHardlinks sorts hard links by path, then moves each after any hard
+// link which is its target, so chains of hard links are created in order.
+func (s stage) orderHardlinks(hardlinks []filesystemEntry) ([]filesystemEntry, error) {
+	sortEntries(hardlinks)
+
+	byPath := map[string]int{}
+	for i, l := range hardlinks {
+		byPath[l.node().Path] = i
+	}
+	deps := make([]int, len(hardlinks))
+	for i, l := range hardlinks {
+		deps[i] = -1
+		target, err := s.JoinPath(*types.Link(l.(linkEntry)).Target)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving target path of hard link %s: %v", l.node().Path, err)
+		}
+		if j, ok := byPath[target]; ok {
+			deps[i] = j
+		}
+	}
+
+	ordered := make([]filesystemEntry, 0, len(hardlinks))
+	visited := make([]bool, len(hardlinks))
+	var visit func(int)
+	visit = func(i int) {
+		if visited[i] {
+			return
+		}
+		// marked first, so a cycle can't recurse forever; creating it
+		// fails later anyway
+		visited[i] = true
+		if deps[i] >= 0 {
+			visit(deps[i])
+		}
+		ordered = append(ordered, hardlinks[i])
+	}
+	for i := range hardlinks {
+		visit(i)
+	}
+	return ordered, nil
+}
+
+// getInitramfsCreationList returns the files targeting the initramfs, with
+// their paths resolved within it, from shallowest to deepest. Files backing
+// loop devices were already written by the disks stage and are skipped.
+func (s stage) getInitramfsCreationList(config types.Config) ([]filesystemEntry, error) {
+	u := s.initramfsUtil()
+	entries := []filesystemEntry{}
+	for _, f := range config.Storage.Files {
+		if !f.TargetsInitramfs() || backsLoop(config.Storage.Loops, f) {
+			continue
+		}
+		path, err := u.JoinPath(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		f.Path = path
+		entries = append(entries, fileEntry(f))
+	}
+	sortEntries(entries)
+	return entries, nil
+}
+
+// sortEntries orders entries so that each precedes the entries beneath it.
+// Entries at the same depth are ordered by path, so the order of creation
+// doesn't depend on the order of the config.
+func sortEntries(entries []filesystemEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i].node().Path, entries[j].node().Path
+		if da, db := util.Depth(a), util.Depth(b); da != db {
+			return da < db
+		}
+		return a < b
+	})
+}
+
+// initramfsUtil returns a copy of the stage's Util rooted at the initramfs.
+func (s stage) initramfsUtil() util.Util {
+	u := s.Util
+	u.DestDir = s.initramfsDir
+	return u
+}
+
+// backsLoop returns whether f is the backing file of one of loops.
+func backsLoop(loops []types.Loop, f types.File) bool {
+	for _, l := range loops {
+		if l.BackingFile([]types.File{f}) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// hasInitramfsFiles returns whether any files in config target the initramfs.
+func hasInitramfsFiles(config types.Config) bool {
+	for _, f := range config.Storage.Files {
+		if f.TargetsInitramfs() {
+			return true
+		}
+	}
+	return false
+}
+
+// sysrootFiles returns the files in config which are written to the sysroot.
+func sysrootFiles(config types.Config) []types.File {
+	files := []types.File{}
+	for _, f := range config.Storage.Files {
+		if !f.TargetsInitramfs() {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
 func (s *stage) removePathOnOverwrite(e filesystemEntry) error {
+	if f, ok := e.(fileEntry); ok && f.replacesInPlace() {
+		return nil
+	}
 	if cutil.IsTrue(e.node().Overwrite) {
 		return os.RemoveAll(e.node().Path)
 	}
@@ -459,7 +735,7 @@ func (s *stage) removePathOnOverwrite(e filesystemEntry) error {
 // relabelPath schedules relabeling for the path. The first component which was
 // found to be missing is used, or the whole path if it already exists.
 func (s *stage) relabelPath(path string) error {
-	if !s.relabeling() {
+	if !s.trackingWrites() {
 		return nil
 	}
 
@@ -475,23 +751,43 @@ func (s *stage) relabelPath(path string) error {
 
 // createEntries creates any files or directories listed for the filesystem in Storage.{Files,Directories}.
 func (s *stage) createEntries(entries []filesystemEntry) error {
+	return s.createEntriesIn(s.Util, entries)
+}
+
+// createEntriesIn creates entries whose paths are under u.DestDir. Only
+// entries in the stage's own root are relabeled; the initramfs is discarded
+// before the relabeled system boots.
+func (s *stage) createEntriesIn(u util.Util, entries []filesystemEntry) error {
 	s.Logger.PushPrefix("createFiles")
 	defer s.Logger.PopPrefix()
 
 	for _, e := range entries {
 		path := e.node().Path
-		if !strings.HasPrefix(path, s.DestDir) {
-			panic(fmt.Sprintf("Entry path %s isn't under prefix %s", path, s.DestDir))
+		if !strings.HasPrefix(path, u.DestDir) {
+			panic(fmt.Sprintf("Entry path %s isn't under prefix %s", path, u.DestDir))
 		}
 
-		if err := s.relabelPath(path); err != nil {
-			return fmt.Errorf("error relabeling paths for %s: %v", path, err)
+		if u.DestDir == s.DestDir {
+			if err := s.relabelPath(path); err != nil {
+				return stages.NewError(name, path, fmt.Errorf("error relabeling paths for %s: %w", path, err))
+			}
 		}
 		if err := s.removePathOnOverwrite(e); err != nil {
-			return fmt.Errorf("error removing existing file %s: %v", path, err)
+			return stages.NewError(name, path, fmt.Errorf("error removing existing file %s: %w", path, err))
+		}
+		if err := e.create(s.Logger, u); err != nil {
+			return stages.NewError(name, path, fmt.Errorf("error creating %s: %w", path, err))
+		}
+		if _, ok := e.(fileEntry); ok && u.DestDir == s.DestDir {
+			if err := s.recordChecksum(path); err != nil {
+				return stages.NewError(name, path, err)
+			}
+		}
+		if ctx := e.node().SelinuxContext; ctx != nil {
+			s.label(path, *ctx)
 		}
-		if err := e.create(s.Logger, s.Util); err != nil {
-			return fmt.Errorf("error creating %s: %v", path, err)
+		if f, ok := e.(fileEntry); ok && len(f.Flags) > 0 {
+			s.flag(path, f.Flags)
 		}
 	}
 	return nil
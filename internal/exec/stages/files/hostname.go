@@ -0,0 +1,104 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+)
+
+const (
+	hostnamePath = "/etc/hostname"
+	hostsPath    = "/etc/hosts"
+	// hostsAddress is the address Debian and others conventionally map
+	// the static hostname to, so it resolves without the network
+	hostsAddress = "127.0.1.1"
+)
+
+// writeHostname writes the configured hostname to /etc/hostname and, if
+// requested, maps it in /etc/hosts.
+func (s *stage) writeHostname(config types.Config) error {
+	h := config.Hostname
+	if h.Name == nil {
+		return nil
+	}
+
+	if err := s.Logger.LogOp(func() error {
+		return s.writeEtcFile(hostnamePath, []byte(*h.Name+"\n"), 0644)
+	}, "writing hostname %q", *h.Name); err != nil {
+		return err
+	}
+	if !cutil.IsTrue(h.UpdateHosts) {
+		return nil
+	}
+	return s.Logger.LogOp(func() error {
+		path, err := s.JoinPath(hostsPath)
+		if err != nil {
+			return err
+		}
+		hosts, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		updated, replaced := updateHosts(hosts, *h.Name)
+		for _, entry := range replaced {
+			s.Logger.Warning("replacing %s entry %q", hostsPath, entry)
+		}
+		return s.writeEtcFile(hostsPath, updated, 0644)
+	}, "adding hostname %q to %s", *h.Name, hostsPath)
+}
+
+// updateHosts returns the contents of an /etc/hosts file with name mapped
+// to hostsAddress, and the existing entries for the address which it
+// replaced. The first existing entry for the address is replaced, and any
+// others dropped, so running Ignition again doesn't add entries.
+func updateHosts(hosts []byte, name string) ([]byte, []string) {
+	entry := hostsAddress + "\t" + name
+	if short := strings.SplitN(name, ".", 2)[0]; short != name {
+		entry += " " + short
+	}
+	entryFields := strings.Join(strings.Fields(entry), " ")
+
+	var lines, replaced []string
+	found := false
+	for _, line := range strings.SplitAfter(string(hosts), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == hostsAddress {
+			if !found {
+				lines = append(lines, entry+"\n")
+				found = true
+			}
+			if strings.Join(fields, " ") != entryFields {
+				replaced = append(replaced, strings.TrimSpace(line))
+			}
+			continue
+		}
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+		lines = append(lines, line)
+	}
+	if !found {
+		lines = append(lines, entry+"\n")
+	}
+	return []byte(strings.Join(lines, "")), replaced
+}
@@ -0,0 +1,156 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+)
+
+func TestUpdateHosts(t *testing.T) {
+	const localhost = "127.0.0.1\tlocalhost\n::1\tlocalhost\n"
+
+	tests := []struct {
+		hosts    string
+		name     string
+		out      string
+		replaced []string
+	}{
+		{
+			hosts: "",
+			name:  "node1",
+			out:   "127.0.1.1\tnode1\n",
+		},
+		{
+			hosts: localhost,
+			name:  "node1.example.com",
+			out:   localhost + "127.0.1.1\tnode1.example.com node1\n",
+		},
+		// missing trailing newline
+		{
+			hosts: "127.0.0.1\tlocalhost",
+			name:  "node1",
+			out:   "127.0.0.1\tlocalhost\n127.0.1.1\tnode1\n",
+		},
+		// existing entries are replaced in place
+		{
+			hosts:    "127.0.0.1 localhost\n127.0.1.1 old\n# comment\n127.0.1.1 older alias\n",
+			name:     "node1",
+			out:      "127.0.0.1 localhost\n127.0.1.1\tnode1\n# comment\n",
+			replaced: []string{"127.0.1.1 old", "127.0.1.1 older alias"},
+		},
+		// rerunning doesn't change anything
+		{
+			hosts: localhost + "127.0.1.1\tnode1\n",
+			name:  "node1",
+			out:   localhost + "127.0.1.1\tnode1\n",
+		},
+	}
+
+	for i, test := range tests {
+		out, replaced := updateHosts([]byte(test.hosts), test.name)
+		if string(out) != test.out {
+			t.Errorf("#%d: bad hosts: want %q, got %q", i, test.out, string(out))
+		}
+		if !reflect.DeepEqual(test.replaced, replaced) {
+			t.Errorf("#%d: bad replaced entries: want %q, got %q", i, test.replaced, replaced)
+		}
+	}
+}
+
+func TestWriteHostname(t *testing.T) {
+	tests := []struct {
+		in       types.Hostname
+		hosts    *string
+		hostname *string
+		outHosts *string
+	}{
+		{
+			in:    types.Hostname{},
+			hosts: cutil.StrToPtr("127.0.0.1\tlocalhost\n"),
+		},
+		{
+			in:       types.Hostname{Name: cutil.StrToPtr("node1")},
+			hosts:    cutil.StrToPtr("127.0.0.1\tlocalhost\n"),
+			hostname: cutil.StrToPtr("node1\n"),
+		},
+		{
+			in:       types.Hostname{Name: cutil.StrToPtr("node1"), UpdateHosts: cutil.BoolToPtr(true)},
+			hosts:    cutil.StrToPtr("127.0.0.1\tlocalhost\n"),
+			hostname: cutil.StrToPtr("node1\n"),
+			outHosts: cutil.StrToPtr("127.0.0.1\tlocalhost\n127.0.1.1\tnode1\n"),
+		},
+		// /etc/hosts is created if needed
+		{
+			in:       types.Hostname{Name: cutil.StrToPtr("node1.example.com"), UpdateHosts: cutil.BoolToPtr(true)},
+			hostname: cutil.StrToPtr("node1.example.com\n"),
+			outHosts: cutil.StrToPtr("127.0.1.1\tnode1.example.com node1\n"),
+		},
+	}
+
+	for i, test := range tests {
+		s, tmp := newTempStage(t)
+		if err := os.MkdirAll(filepath.Join(tmp, "etc"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if test.hosts != nil {
+			if err := ioutil.WriteFile(filepath.Join(tmp, hostsPath), []byte(*test.hosts), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// an existing /etc/hostname is replaced
+		if err := ioutil.WriteFile(filepath.Join(tmp, hostnamePath), []byte("localhost\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.writeHostname(types.Config{Hostname: test.in}); err != nil {
+			t.Errorf("#%d: writing hostname: %v", i, err)
+			continue
+		}
+
+		check := func(path string, want string, mode os.FileMode) {
+			contents, err := ioutil.ReadFile(filepath.Join(tmp, path))
+			if err != nil {
+				t.Errorf("#%d: reading %s: %v", i, path, err)
+				return
+			}
+			if string(contents) != want {
+				t.Errorf("#%d: bad %s: want %q, got %q", i, path, want, string(contents))
+			}
+			info, err := os.Stat(filepath.Join(tmp, path))
+			if err != nil {
+				t.Errorf("#%d: stat %s: %v", i, path, err)
+			} else if info.Mode().Perm() != mode {
+				t.Errorf("#%d: bad mode of %s: want %v, got %v", i, path, mode, info.Mode().Perm())
+			}
+		}
+		if test.hostname != nil {
+			check(hostnamePath, *test.hostname, 0644)
+		} else {
+			check(hostnamePath, "localhost\n", 0600)
+		}
+		if test.outHosts != nil {
+			check(hostsPath, *test.outHosts, 0644)
+		} else if test.hosts != nil {
+			check(hostsPath, *test.hosts, 0600)
+		}
+	}
+}
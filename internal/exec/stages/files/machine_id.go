@@ -0,0 +1,45 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+)
+
+const machineIDPath = "/etc/machine-id"
+
+// writeMachineID empties /etc/machine-id or writes the configured machine
+// ID to it. An empty file makes systemd generate a new ID at boot, which
+// matters for images that are copied to many machines.
+func (s *stage) writeMachineID(config types.Config) error {
+	m := config.MachineID
+	var contents []byte
+	if cutil.IsTrue(m.Clear) {
+		contents = []byte{}
+	} else if m.Value != nil {
+		contents = []byte(*m.Value + "\n")
+	} else {
+		return nil
+	}
+
+	op := "writing machine ID"
+	if len(contents) == 0 {
+		op = "clearing machine ID"
+	}
+	return s.Logger.LogOp(func() error {
+		return s.writeEtcFile(machineIDPath, contents, 0444)
+	}, op)
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+)
+
+func TestWriteMachineID(t *testing.T) {
+	const id = "0123456789abcdef0123456789abcdef"
+
+	tests := []struct {
+		in       types.MachineID
+		existing *string
+		out      *string
+	}{
+		// nothing configured leaves the file alone
+		{
+			in:       types.MachineID{},
+			existing: cutil.StrToPtr("fedcba9876543210fedcba9876543210\n"),
+			out:      cutil.StrToPtr("fedcba9876543210fedcba9876543210\n"),
+		},
+		{
+			in: types.MachineID{},
+		},
+		// clear mode truncates
+		{
+			in:       types.MachineID{Clear: cutil.BoolToPtr(true)},
+			existing: cutil.StrToPtr("fedcba9876543210fedcba9876543210\n"),
+			out:      cutil.StrToPtr(""),
+		},
+		{
+			in:  types.MachineID{Clear: cutil.BoolToPtr(true)},
+			out: cutil.StrToPtr(""),
+		},
+		// set mode writes the value
+		{
+			in:       types.MachineID{Value: cutil.StrToPtr(id)},
+			existing: cutil.StrToPtr(""),
+			out:      cutil.StrToPtr(id + "\n"),
+		},
+		{
+			in:  types.MachineID{Value: cutil.StrToPtr(id)},
+			out: cutil.StrToPtr(id + "\n"),
+		},
+	}
+
+	for i, test := range tests {
+		s, tmp := newTempStage(t)
+		path := filepath.Join(tmp, machineIDPath)
+		if test.existing != nil {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			// left read-only by an earlier run
+			if err := ioutil.WriteFile(path, []byte(*test.existing), 0444); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := s.writeMachineID(types.Config{MachineID: test.in}); err != nil {
+			t.Errorf("#%d: writing machine ID: %v", i, err)
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(path)
+		if test.out == nil {
+			if !os.IsNotExist(err) {
+				t.Errorf("#%d: machine ID file unexpectedly exists", i)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("#%d: reading machine ID: %v", i, err)
+			continue
+		}
+		if string(contents) != *test.out {
+			t.Errorf("#%d: bad contents: want %q, got %q", i, *test.out, string(contents))
+		}
+		if entries, err := ioutil.ReadDir(filepath.Dir(path)); err != nil {
+			t.Fatal(err)
+		} else if len(entries) != 1 {
+			t.Errorf("#%d: temporary file left behind: %v", i, entries)
+		}
+		if test.in != (types.MachineID{}) {
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0444 {
+				t.Errorf("#%d: bad mode: want %v, got %v", i, os.FileMode(0444), info.Mode().Perm())
+			}
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/coreos/ignition/v2/config/util"
 	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/exec/stages"
 )
 
 func (s *stage) expandGlobList(globs ...string) ([]string, error) {
@@ -41,13 +42,14 @@ func (s *stage) expandGlobList(globs ...string) ([]string, error) {
 }
 
 // createPasswd creates the users and groups as described in config.Passwd.
+// Groups are created first so that users can reference them.
 func (s *stage) createPasswd(config types.Config) error {
 	if err := s.ensureGroups(config); err != nil {
-		return fmt.Errorf("failed to configure groups: %v", err)
+		return fmt.Errorf("failed to configure groups: %w", err)
 	}
 
 	if err := s.ensureUsers(config); err != nil {
-		return fmt.Errorf("failed to configure users: %v", err)
+		return fmt.Errorf("failed to configure users: %w", err)
 	}
 
 	// to be safe, just blanket mark all passwd-related files rather than
@@ -108,9 +110,16 @@ func (s stage) ensureUsers(config types.Config) error {
 	defer s.Logger.PopPrefix()
 
 	for _, u := range config.Passwd.Users {
+		if !util.IsFalse(u.ShouldExist) {
+			if err := s.CheckUserGroups(u); err != nil {
+				return stages.NewError(name, u.Name,
+					fmt.Errorf("failed to create user %q: %w", u.Name, err))
+			}
+		}
+
 		if err := s.EnsureUser(u); err != nil {
-			return fmt.Errorf("failed to create user %q: %v",
-				u.Name, err)
+			return stages.NewError(name, u.Name,
+				fmt.Errorf("failed to create user %q: %w", u.Name, err))
 		}
 
 		if util.IsFalse(u.ShouldExist) {
@@ -118,18 +127,32 @@ func (s stage) ensureUsers(config types.Config) error {
 		}
 
 		if err := s.ModifyHomeDirPermissions(u); err != nil {
-			return fmt.Errorf("failed to modify home directory permissions for %q: %v",
-				u.Name, err)
+			return stages.NewError(name, u.Name,
+				fmt.Errorf("failed to modify home directory permissions for %q: %w", u.Name, err))
 		}
 
 		if err := s.SetPasswordHash(u); err != nil {
-			return fmt.Errorf("failed to set password for %q: %v",
-				u.Name, err)
+			return stages.NewError(name, u.Name,
+				fmt.Errorf("failed to set password for %q: %w", u.Name, err))
+		}
+
+		if err := s.SetAccountLock(u); err != nil {
+			return stages.NewError(name, u.Name,
+				fmt.Errorf("failed to lock or unlock password for %q: %w", u.Name, err))
+		}
+
+		if err := s.SetPasswordAging(u); err != nil {
+			return stages.NewError(name, u.Name,
+				fmt.Errorf("failed to set password aging for %q: %w", u.Name, err))
+		}
+
+		if err := s.RemoveUserFromGroups(u); err != nil {
+			return stages.NewError(name, u.Name, err)
 		}
 
 		if err := s.AuthorizeSSHKeys(u); err != nil {
-			return fmt.Errorf("failed to add keys to user %q: %v",
-				u.Name, err)
+			return stages.NewError(name, u.Name,
+				fmt.Errorf("failed to add keys to user %q: %w", u.Name, err))
 		}
 	}
 
@@ -147,8 +170,8 @@ func (s stage) ensureGroups(config types.Config) error {
 
 	for _, g := range config.Passwd.Groups {
 		if err := s.EnsureGroup(g); err != nil {
-			return fmt.Errorf("failed to create group %q: %v",
-				g.Name, err)
+			return stages.NewError(name, g.Name,
+				fmt.Errorf("failed to create group %q: %w", g.Name, err))
 		}
 	}
 
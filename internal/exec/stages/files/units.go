@@ -22,8 +22,11 @@ import (
 	"github.com/coreos/ignition/v2/config/shared/errors"
 	cutil "github.com/coreos/ignition/v2/config/util"
 	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/exec/stages"
 	"github.com/coreos/ignition/v2/internal/exec/util"
 	"github.com/coreos/ignition/v2/internal/systemd"
+
+	"github.com/coreos/go-systemd/v22/unit"
 )
 
 // Preset holds the information about
@@ -48,12 +51,16 @@ func (s *stage) warnOnOldSystemdVersion() error {
 	return nil
 }
 
-// createUnits creates the units listed under systemd.units.
+// createUnits creates the units listed under systemd.units, along with the
+// units generated for filesystems with withMountUnit set.
 func (s *stage) createUnits(config types.Config) error {
 	presets := make(map[string]*Preset)
-	for _, unit := range config.Systemd.Units {
+	for _, unit := range unitsToCreate(config) {
 		if err := s.writeSystemdUnit(unit); err != nil {
-			return err
+			return stages.NewError(name, unit.Name, err)
+		}
+		if err := s.linkUnitToTargets(unit); err != nil {
+			return stages.NewError(name, unit.Name, err)
 		}
 		if unit.Enabled != nil {
 			// identifier keyword is used to distinguish systemd units
@@ -67,7 +74,7 @@ func (s *stage) createUnits(config types.Config) error {
 			if strings.Contains(unit.Name, "@") {
 				unitName, instance, err := parseInstanceUnit(unit)
 				if err != nil {
-					return err
+					return stages.NewError(name, unit.Name, err)
 				}
 				key := fmt.Sprintf("%s-%s", unitName, identifier)
 				if _, ok := presets[key]; ok {
@@ -77,10 +84,10 @@ func (s *stage) createUnits(config types.Config) error {
 				}
 			} else {
 				key := fmt.Sprintf("%s-%s", unit.Name, identifier)
-				if _, ok := presets[unit.Name]; !ok {
+				if _, ok := presets[key]; !ok {
 					presets[key] = &Preset{unit.Name, *unit.Enabled, false, []string{}}
 				} else {
-					return fmt.Errorf("%q key is already present in the presets map", key)
+					return stages.NewError(name, unit.Name, fmt.Errorf("%q key is already present in the presets map", key))
 				}
 			}
 		}
@@ -95,13 +102,13 @@ func (s *stage) createUnits(config types.Config) error {
 					},
 					"masking unit %q", unit.Name,
 				); err != nil {
-					return err
+					return stages.NewError(name, unit.Name, err)
 				}
 				s.relabel(relabelpath)
 			} else { // mask: false
 				masked, err := s.IsUnitMasked(unit)
 				if err != nil {
-					return err
+					return stages.NewError(name, unit.Name, err)
 				}
 				if masked {
 					if err := s.Logger.LogOp(
@@ -110,7 +117,7 @@ func (s *stage) createUnits(config types.Config) error {
 						},
 						"unmasking unit %q", unit.Name,
 					); err != nil {
-						return err
+						return stages.NewError(name, unit.Name, err)
 					}
 				}
 			}
@@ -126,6 +133,128 @@ func (s *stage) createUnits(config types.Config) error {
 	return nil
 }
 
+// linkUnitToTargets enables a unit without an install section by linking it
+// into the .wants and .requires directories of its wantedBy and requiredBy
+// targets.
+func (s *stage) linkUnitToTargets(u types.Unit) error {
+	if len(u.WantedBy) == 0 && len(u.RequiredBy) == 0 {
+		return nil
+	}
+	if u.Contents != nil {
+		opts, err := unit.Deserialize(strings.NewReader(*u.Contents))
+		if err != nil {
+			return err
+		}
+		if types.HasInstallSection(opts) {
+			s.Logger.Warning("unit %q has an install section; ignoring wantedBy and requiredBy", u.Name)
+			return nil
+		}
+	}
+
+	links := []struct {
+		kind    string
+		targets []string
+	}{
+		{"wants", u.WantedBy},
+		{"requires", u.RequiredBy},
+	}
+	for _, l := range links {
+		for _, target := range l.targets {
+			dir := filepath.Join(s.DestDir, util.SystemdUnitsPath(), fmt.Sprintf("%s.%s", target, l.kind))
+			if err := s.relabelPath(filepath.Join(dir, u.Name)); err != nil {
+				return err
+			}
+			if err := s.Logger.LogOp(
+				func() error {
+					_, err := s.LinkUnitToTarget(u, target, l.kind)
+					return err
+				},
+				"linking unit %q into %q", u.Name, filepath.Base(dir),
+			); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unitsToCreate returns the units generated for filesystems followed by the
+// units listed under systemd.units. A unit listed under systemd.units
+// replaces the generated unit of the same name entirely, so each unit is
+// written and preset once.
+func unitsToCreate(config types.Config) []types.Unit {
+	explicit := make(map[string]bool, len(config.Systemd.Units))
+	for _, u := range config.Systemd.Units {
+		explicit[u.Name] = true
+	}
+	var units []types.Unit
+	for _, u := range filesystemUnits(config.Storage.Filesystems) {
+		if !explicit[u.Name] {
+			units = append(units, u)
+		}
+	}
+	return append(units, config.Systemd.Units...)
+}
+
+// filesystemUnits returns an enabled mount or swap unit for each filesystem
+// that requests one.
+func filesystemUnits(filesystems []types.Filesystem) []types.Unit {
+	var units []types.Unit
+	for _, fs := range filesystems {
+		if !cutil.IsTrue(fs.WithMountUnit) || cutil.NilOrEmpty(fs.Format) {
+			continue
+		}
+		if *fs.Format == "swap" {
+			units = append(units, swapUnit(fs))
+		} else if *fs.Format != "none" && cutil.NotEmpty(fs.Path) {
+			units = append(units, mountUnit(fs))
+		}
+	}
+	return units
+}
+
+// mountUnit returns a mount unit for fs. The unit name is derived from the
+// mount path as systemd-escape --path would.
+func mountUnit(fs types.Filesystem) types.Unit {
+	device := unit.UnitNamePathEscape(fs.Device)
+	var b strings.Builder
+	b.WriteString("# Generated by Ignition\n")
+	b.WriteString("[Unit]\n")
+	fmt.Fprintf(&b, "Requires=systemd-fsck@%s.service\n", device)
+	fmt.Fprintf(&b, "After=systemd-fsck@%s.service\n", device)
+	b.WriteString("\n[Mount]\n")
+	fmt.Fprintf(&b, "Where=%s\n", filepath.Clean(*fs.Path))
+	fmt.Fprintf(&b, "What=%s\n", fs.Device)
+	fmt.Fprintf(&b, "Type=%s\n", *fs.Format)
+	if len(fs.MountOptions) != 0 {
+		opts := make([]string, len(fs.MountOptions))
+		for i, o := range fs.MountOptions {
+			opts[i] = string(o)
+		}
+		fmt.Fprintf(&b, "Options=%s\n", strings.Join(opts, ","))
+	}
+	b.WriteString("\n[Install]\n")
+	b.WriteString("RequiredBy=local-fs.target\n")
+
+	contents := b.String()
+	return types.Unit{
+		Name:     unit.UnitNamePathEscape(filepath.Clean(*fs.Path)) + ".mount",
+		Contents: &contents,
+		Enabled:  cutil.BoolToPtr(true),
+	}
+}
+
+// swapUnit returns a swap unit for fs. The unit name is derived from the
+// device path as systemd-escape --path would.
+func swapUnit(fs types.Filesystem) types.Unit {
+	contents := fmt.Sprintf("# Generated by Ignition\n[Swap]\nWhat=%s\n\n[Install]\nRequiredBy=swap.target\n", fs.Device)
+	return types.Unit{
+		Name:     unit.UnitNamePathEscape(fs.Device) + ".swap",
+		Contents: &contents,
+		Enabled:  cutil.BoolToPtr(true),
+	}
+}
+
 // parseInstanceUnit extracts the name and a corresponding instance
 // for a given instantiated unit.
 // e.g: echo@bar.service ==> unitName=echo@.service & instance=bar
@@ -212,6 +341,9 @@ func (s *stage) writeSystemdUnit(unit types.Unit) error {
 			); err != nil {
 				return err
 			}
+			if err := s.recordChecksum(f.Node.Path); err != nil {
+				return err
+			}
 			if !relabeledDropinDir {
 				s.relabel(filepath.Dir(relabelPath))
 				relabeledDropinDir = true
@@ -238,6 +370,9 @@ func (s *stage) writeSystemdUnit(unit types.Unit) error {
 		); err != nil {
 			return err
 		}
+		if err := s.recordChecksum(f.Node.Path); err != nil {
+			return err
+		}
 		s.relabel(relabelPath)
 
 		return nil
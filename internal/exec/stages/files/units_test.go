@@ -15,11 +15,19 @@
 package files
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
 	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	eutil "github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/log"
 )
 
 func TestParseInstanceUnit(t *testing.T) {
@@ -74,3 +82,146 @@ func TestParseInstanceUnit(t *testing.T) {
 		}
 	}
 }
+
+func TestFilesystemUnits(t *testing.T) {
+	tests := []struct {
+		in  []types.Filesystem
+		out []types.Unit
+	}{
+		// no mount unit requested
+		{
+			in: []types.Filesystem{
+				{
+					Device: "/dev/vda1",
+					Format: util.StrToPtr("xfs"),
+					Path:   util.StrToPtr("/var"),
+				},
+			},
+		},
+		{
+			in: []types.Filesystem{
+				{
+					Device:        "/dev/disk/by-label/data",
+					Format:        util.StrToPtr("xfs"),
+					Path:          util.StrToPtr("/var/lib/containers/"),
+					MountOptions:  []types.MountOption{"noatime", "prjquota"},
+					WithMountUnit: util.BoolToPtr(true),
+				},
+			},
+			out: []types.Unit{
+				{
+					Name: "var-lib-containers.mount",
+					Contents: util.StrToPtr(`# Generated by Ignition
+[Unit]
+Requires=systemd-fsck@dev-disk-by\x2dlabel-data.service
+After=systemd-fsck@dev-disk-by\x2dlabel-data.service
+
+[Mount]
+Where=/var/lib/containers
+What=/dev/disk/by-label/data
+Type=xfs
+Options=noatime,prjquota
+
+[Install]
+RequiredBy=local-fs.target
+`),
+					Enabled: util.BoolToPtr(true),
+				},
+			},
+		},
+		{
+			in: []types.Filesystem{
+				{
+					Device:        "/dev/disk/by-partlabel/swap",
+					Format:        util.StrToPtr("swap"),
+					WithMountUnit: util.BoolToPtr(true),
+				},
+			},
+			out: []types.Unit{
+				{
+					Name: "dev-disk-by\\x2dpartlabel-swap.swap",
+					Contents: util.StrToPtr(`# Generated by Ignition
+[Swap]
+What=/dev/disk/by-partlabel/swap
+
+[Install]
+RequiredBy=swap.target
+`),
+					Enabled: util.BoolToPtr(true),
+				},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		units := filesystemUnits(test.in)
+		if !reflect.DeepEqual(test.out, units) {
+			t.Errorf("#%d: bad units: want %+v, got %+v", i, test.out, units)
+		}
+	}
+}
+
+func TestCreateUnitsOverridesGeneratedUnit(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ignition-files-test")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	config := types.Config{
+		Storage: types.Storage{
+			Filesystems: []types.Filesystem{
+				{
+					Device:        "/dev/vdb1",
+					Format:        util.StrToPtr("xfs"),
+					Path:          util.StrToPtr("/var"),
+					WithMountUnit: util.BoolToPtr(true),
+				},
+				{
+					Device:        "/dev/vdb2",
+					Format:        util.StrToPtr("xfs"),
+					Path:          util.StrToPtr("/srv"),
+					WithMountUnit: util.BoolToPtr(true),
+				},
+			},
+		},
+		Systemd: types.Systemd{
+			Units: []types.Unit{
+				{
+					Name:     "var.mount",
+					Contents: util.StrToPtr("[Mount]\nWhat=/dev/vdc1\nWhere=/var\n"),
+					Enabled:  util.BoolToPtr(false),
+				},
+			},
+		},
+	}
+
+	logger := log.New(true)
+	s := stage{
+		Util: eutil.Util{
+			DestDir: tmp,
+			Logger:  &logger,
+		},
+	}
+	if err := s.createUnits(config); err != nil {
+		t.Fatalf("creating units: %v", err)
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(tmp, "etc/systemd/system/var.mount"))
+	if err != nil {
+		t.Fatalf("reading var.mount: %v", err)
+	}
+	if string(contents) != *config.Systemd.Units[0].Contents {
+		t.Errorf("generated unit written over the explicit one: %q", contents)
+	}
+	presets, err := ioutil.ReadFile(filepath.Join(tmp, eutil.PresetPath))
+	if err != nil {
+		t.Fatalf("reading presets: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(presets)), "\n")
+	sort.Strings(lines)
+	expected := []string{"disable var.mount", "enable srv.mount"}
+	if !reflect.DeepEqual(expected, lines) {
+		t.Errorf("bad presets: want %q, got %q", expected, lines)
+	}
+}
@@ -85,16 +85,23 @@ func (s stage) Run(config types.Config) error {
 	return nil
 }
 
-func (s *stage) addKargs(config types.Config) error {
+// kargsArgs returns the arguments to pass to the kargs helper. The helper
+// only adds arguments that are missing and only removes arguments that are
+// present, so rerunning it with the same arguments is a no-op.
+func kargsArgs(kargs types.KernelArguments) []string {
 	var opts []string
-	for _, arg := range config.KernelArguments.ShouldExist {
+	for _, arg := range kargs.ShouldExist {
 		opts = append(opts, "--should-exist", string(arg))
 	}
-	for _, arg := range config.KernelArguments.ShouldNotExist {
+	for _, arg := range kargs.ShouldNotExist {
 		opts = append(opts, "--should-not-exist", string(arg))
 	}
+	return opts
+}
+
+func (s *stage) addKargs(config types.Config) error {
 	_, err := s.Logger.LogCmd(
-		exec.Command(distro.KargsCmd(), opts...),
+		exec.Command(distro.KargsCmd(), kargsArgs(config.KernelArguments)...),
 		"updating kernel arguments")
 	return err
 }
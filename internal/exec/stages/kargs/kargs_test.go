@@ -0,0 +1,62 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kargs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+)
+
+func TestKargsArgs(t *testing.T) {
+	tests := []struct {
+		in  types.KernelArguments
+		out []string
+	}{
+		{
+			in:  types.KernelArguments{},
+			out: nil,
+		},
+		// add an argument
+		{
+			in: types.KernelArguments{
+				ShouldExist: []types.KernelArgument{"console=ttyS0"},
+			},
+			out: []string{"--should-exist", "console=ttyS0"},
+		},
+		// remove an argument
+		{
+			in: types.KernelArguments{
+				ShouldNotExist: []types.KernelArgument{"quiet"},
+			},
+			out: []string{"--should-not-exist", "quiet"},
+		},
+		{
+			in: types.KernelArguments{
+				ShouldExist:    []types.KernelArgument{"console=ttyS0", "nosmt"},
+				ShouldNotExist: []types.KernelArgument{"quiet"},
+			},
+			out: []string{"--should-exist", "console=ttyS0", "--should-exist", "nosmt", "--should-not-exist", "quiet"},
+		},
+	}
+
+	for i, test := range tests {
+		out := kargsArgs(test.in)
+		if !reflect.DeepEqual(test.out, out) {
+			t.Errorf("#%d: bad args: want %v, got %v", i, test.out, out)
+		}
+	}
+}
@@ -84,14 +84,30 @@ func (s stage) Run(config types.Config) error {
 		}
 	}
 	sort.Slice(fss, func(i, j int) bool { return util.Depth(*fss[i].Path) < util.Depth(*fss[j].Path) })
+	if err := s.createMountNamespace(fss); err != nil {
+		return stages.NewError(name, "", err)
+	}
 	for _, fs := range fss {
 		if err := s.mountFs(fs); err != nil {
-			return err
+			return stages.NewError(name, *fs.Path, err)
 		}
 	}
 	return nil
 }
 
+// createMountNamespace moves the stage into a private mount namespace, if
+// the distro configures one, so the filesystems it mounts are only visible
+// to the later stages.
+func (s stage) createMountNamespace(fss []types.Filesystem) error {
+	path := distro.MountNamespacePath()
+	if path == "" || len(fss) == 0 || s.Logger.DryRun() {
+		return nil
+	}
+	return s.Logger.LogOp(func() error {
+		return util.CreateMountNamespace(path)
+	}, "creating mount namespace at %q", path)
+}
+
 // checkForNonDirectories returns an error if any element of path is not a directory
 func checkForNonDirectories(path string) error {
 	p := "/"
@@ -123,6 +139,11 @@ func (s stage) mountFs(fs types.Filesystem) error {
 		return err
 	}
 
+	if s.Logger.DryRun() {
+		s.Logger.Info("dry run: would mount %q at %q with type %q", fs.Device, path, *fs.Format)
+		return nil
+	}
+
 	var firstMissing string
 	if distro.SelinuxRelabel() {
 		var err error
@@ -148,10 +169,15 @@ func (s stage) mountFs(fs types.Filesystem) error {
 		}
 	}
 
-	args := translateOptionSliceToString(fs.MountOptions, ",")
-	cmd := exec.Command(distro.MountCmd(), "-o", args, "-t", *fs.Format, fs.Device, path)
+	if s.shouldCheckFilesystem(fs) {
+		if err := s.checkFilesystem(fs); err != nil {
+			return err
+		}
+	}
+
+	cmd := exec.Command(distro.MountCmd(), mountArgs(fs, path)...)
 	if _, err := s.Logger.LogCmd(cmd,
-		"mounting %q at %q with type %q and options %q", fs.Device, path, *fs.Format, args,
+		"mounting %q at %q with type %q and options %q", fs.Device, path, *fs.Format, translateOptionSliceToString(fs.MountOptions, ","),
 	); err != nil {
 		return err
 	}
@@ -170,6 +196,60 @@ func (s stage) mountFs(fs types.Filesystem) error {
 	return nil
 }
 
+// shouldCheckFilesystem returns whether fs requests a check and wasn't
+// created by the disks stage. A new filesystem can't need repairs.
+func (s stage) shouldCheckFilesystem(fs types.Filesystem) bool {
+	if !cutil.IsTrue(fs.Fsck) {
+		return false
+	}
+	for _, dev := range s.State.CreatedFilesystems {
+		if dev == fs.Device {
+			return false
+		}
+	}
+	return true
+}
+
+// checkFilesystem checks fs and repairs any errors which can be fixed
+// without user interaction, failing if any remain.
+func (s stage) checkFilesystem(fs types.Filesystem) error {
+	cmd, args, maxCorrected, err := fsckCommand(*fs.Format)
+	if err != nil {
+		return err
+	}
+	code, err := s.Logger.LogCmd(
+		exec.Command(cmd, append(args, fs.Device)...),
+		"checking %q filesystem on %q", *fs.Format, fs.Device,
+	)
+	if err != nil && (code < 1 || code > maxCorrected) {
+		return fmt.Errorf("checking filesystem failed: %v", err)
+	}
+	return nil
+}
+
+// fsckCommand returns the command and arguments which check and repair a
+// filesystem of the given format without prompting, and the highest exit
+// status meaning errors were corrected. The device must be appended to the
+// arguments.
+func fsckCommand(format string) (cmd string, args []string, maxCorrected int, err error) {
+	switch format {
+	case "ext4":
+		// 1 means errors were corrected, 2 that the system should
+		// also be rebooted, which doesn't apply to unmounted filesystems
+		return distro.E2fsckCmd(), []string{"-p"}, 2, nil
+	case "vfat":
+		return distro.VfatFsckCmd(), []string{"-a"}, 1, nil
+	default:
+		return "", nil, 0, fmt.Errorf("checking %q filesystems is not supported", format)
+	}
+}
+
+// mountArgs returns the arguments to the mount command needed to mount fs at
+// path.
+func mountArgs(fs types.Filesystem, path string) []string {
+	return []string{"-o", translateOptionSliceToString(fs.MountOptions, ","), "-t", *fs.Format, fs.Device, path}
+}
+
 func translateOptionSliceToString(opts []types.MountOption, separator string) string {
 	mountOpts := make([]string, len(opts))
 	for i, o := range opts {
@@ -0,0 +1,167 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mount
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+	execUtil "github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/state"
+)
+
+func TestMountArgs(t *testing.T) {
+	tests := []struct {
+		in  types.Filesystem
+		out []string
+	}{
+		{
+			types.Filesystem{
+				Device: "/dev/vda1",
+				Format: util.StrToPtr("ext4"),
+			},
+			[]string{"-o", "", "-t", "ext4", "/dev/vda1", "/sysroot/var"},
+		},
+		{
+			types.Filesystem{
+				Device:       "/dev/vda1",
+				Format:       util.StrToPtr("btrfs"),
+				MountOptions: []types.MountOption{"noatime", "subvol=root"},
+			},
+			[]string{"-o", "noatime,subvol=root", "-t", "btrfs", "/dev/vda1", "/sysroot/var"},
+		},
+		// mounting one of the subvolumes Ignition created
+		{
+			types.Filesystem{
+				Device:       "/dev/vda1",
+				Format:       util.StrToPtr("btrfs"),
+				MountOptions: []types.MountOption{"subvol=var"},
+				Subvolumes:   []types.Subvolume{"root", "var"},
+			},
+			[]string{"-o", "subvol=var", "-t", "btrfs", "/dev/vda1", "/sysroot/var"},
+		},
+	}
+
+	for i, test := range tests {
+		args := mountArgs(test.in, "/sysroot/var")
+		if !reflect.DeepEqual(test.out, args) {
+			t.Errorf("#%d: bad args: want %v, got %v", i, test.out, args)
+		}
+	}
+}
+
+func TestShouldCheckFilesystem(t *testing.T) {
+	// as recorded by the disks stage, which formatted /dev/vdb1
+	s := stage{
+		Util: execUtil.Util{
+			State: &state.State{
+				CreatedFilesystems: []string{"/dev/vdb1"},
+			},
+		},
+	}
+
+	tests := []struct {
+		in  types.Filesystem
+		out bool
+	}{
+		// reused, check requested
+		{
+			types.Filesystem{
+				Device: "/dev/vda1",
+				Format: util.StrToPtr("ext4"),
+				Fsck:   util.BoolToPtr(true),
+			},
+			true,
+		},
+		// created, check requested
+		{
+			types.Filesystem{
+				Device: "/dev/vdb1",
+				Format: util.StrToPtr("ext4"),
+				Fsck:   util.BoolToPtr(true),
+			},
+			false,
+		},
+		// reused, check not requested
+		{
+			types.Filesystem{
+				Device: "/dev/vda1",
+				Format: util.StrToPtr("ext4"),
+			},
+			false,
+		},
+		{
+			types.Filesystem{
+				Device: "/dev/vda1",
+				Format: util.StrToPtr("ext4"),
+				Fsck:   util.BoolToPtr(false),
+			},
+			false,
+		},
+	}
+
+	for i, test := range tests {
+		if out := s.shouldCheckFilesystem(test.in); out != test.out {
+			t.Errorf("#%d: bad result: want %v, got %v", i, test.out, out)
+		}
+	}
+}
+
+func TestFsckCommand(t *testing.T) {
+	tests := []struct {
+		format       string
+		cmd          string
+		args         []string
+		maxCorrected int
+		hasErr       bool
+	}{
+		{
+			format:       "ext4",
+			cmd:          distro.E2fsckCmd(),
+			args:         []string{"-p"},
+			maxCorrected: 2,
+		},
+		{
+			format:       "vfat",
+			cmd:          distro.VfatFsckCmd(),
+			args:         []string{"-a"},
+			maxCorrected: 1,
+		},
+		{
+			format: "xfs",
+			hasErr: true,
+		},
+	}
+
+	for i, test := range tests {
+		cmd, args, maxCorrected, err := fsckCommand(test.format)
+		if test.hasErr {
+			if err == nil {
+				t.Errorf("#%d: expected error for %q", i, test.format)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if cmd != test.cmd || !reflect.DeepEqual(test.args, args) || maxCorrected != test.maxCorrected {
+			t.Errorf("#%d: bad command: want %s %v (max %d), got %s %v (max %d)", i, test.cmd, test.args, test.maxCorrected, cmd, args, maxCorrected)
+		}
+	}
+}
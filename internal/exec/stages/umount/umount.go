@@ -20,10 +20,12 @@ package umount
 
 import (
 	"errors"
+	"fmt"
 	"sort"
 
 	cutil "github.com/coreos/ignition/v2/config/util"
 	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
 	"github.com/coreos/ignition/v2/internal/exec/stages"
 	"github.com/coreos/ignition/v2/internal/exec/util"
 	"github.com/coreos/ignition/v2/internal/log"
@@ -81,14 +83,43 @@ func (s stage) Run(config types.Config) error {
 	}
 	// n.b. sorted backwards
 	sort.Slice(fss, func(i, j int) bool { return util.Depth(*fss[j].Path) < util.Depth(*fss[i].Path) })
+	entered, err := s.enterMountNamespace()
+	if err != nil {
+		return err
+	}
 	for _, fs := range fss {
 		if err := s.umountFs(fs); err != nil {
 			return err
 		}
 	}
+	if entered {
+		path := distro.MountNamespacePath()
+		if err := s.Logger.LogOp(func() error {
+			return util.ReleaseMountNamespace(path)
+		}, "releasing mount namespace at %q", path); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// enterMountNamespace moves the stage into the mount namespace created by
+// the mount stage, if there is one.
+func (s stage) enterMountNamespace() (bool, error) {
+	path := distro.MountNamespacePath()
+	if path == "" || s.Logger.DryRun() {
+		return false, nil
+	}
+	entered, err := util.EnterMountNamespace(path)
+	if err != nil {
+		return false, fmt.Errorf("entering mount namespace at %q: %w", path, err)
+	}
+	if entered {
+		s.Logger.Info("entered mount namespace at %q", path)
+	}
+	return entered, nil
+}
+
 func (s stage) umountFs(fs types.Filesystem) error {
 	if fs.Format == nil || *fs.Format == "swap" || *fs.Format == "" || *fs.Format == "none" {
 		return nil
@@ -98,6 +129,11 @@ func (s stage) umountFs(fs types.Filesystem) error {
 		return err
 	}
 
+	if s.Logger.DryRun() {
+		s.Logger.Info("dry run: would umount %q", path)
+		return nil
+	}
+
 	if err := s.Logger.LogOp(func() error { return unix.Unmount(path, 0) },
 		"umounting %q", path,
 	); err != nil {
@@ -252,6 +252,9 @@ static result_t extract_part_info(blkid_partition part, struct partition_info *i
 		return RESULT_LOOKUP_FAILED;
 	info->size = itmp / sector_divisor;
 
+	// GPT attribute bits
+	info->flags = blkid_partition_get_flags(part);
+
 	return RESULT_OK;
 }
 
@@ -63,6 +63,7 @@ type PartitionInfo struct {
 	StartSector   int64
 	SizeInSectors int64
 	Number        int
+	Attributes    uint64 // GPT attribute bits
 }
 
 type FilesystemInfo struct {
@@ -175,6 +176,7 @@ func DumpDisk(device string) (DiskInfo, error) {
 			Number:        int(cInfo.number),
 			StartSector:   int64(cInfo.start),
 			SizeInSectors: int64(cInfo.size),
+			Attributes:    uint64(cInfo.flags),
 		}
 
 		output.Partitions = append(output.Partitions, current)
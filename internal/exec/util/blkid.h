@@ -47,6 +47,7 @@ struct partition_info {
 	char type_guid[PART_INFO_BUF_SIZE];
 	long long start; // needs to be 64 bit
 	long long size;  // to handle large partitions
+	unsigned long long flags; // GPT attribute bits
 	int number;
 };
 
@@ -15,6 +15,8 @@
 package util
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"hash"
@@ -32,6 +34,7 @@ import (
 	"github.com/coreos/ignition/v2/internal/resource"
 	"github.com/coreos/ignition/v2/internal/util"
 
+	"github.com/vincent-petithory/dataurl"
 	"golang.org/x/sys/unix"
 )
 
@@ -45,32 +48,53 @@ type FetchOp struct {
 	Url          url.URL
 	FetchOptions resource.FetchOptions
 	Append       bool
+	Preallocate  bool
 	Node         types.Node
 }
 
-func newFetchOp(l *log.Logger, node types.Node, contents types.Resource) (FetchOp, error) {
-	var expectedSum []byte
+// fallocate allocates disk space for a file; tests replace it.
+var fallocate = unix.Fallocate
 
+// knownContentLength returns the length of the contents which fetching u
+// with opts will produce, if it can be determined without fetching.
+func knownContentLength(u url.URL, opts resource.FetchOptions) (int64, bool) {
+	if u.Scheme != "data" || opts.Compression != "" {
+		return 0, false
+	}
+	data, err := dataurl.DecodeString(u.String())
+	if err != nil {
+		return 0, false
+	}
+	return int64(len(data.Data)), true
+}
+
+func newFetchOp(l *log.Logger, node types.Node, contents types.Resource) (FetchOp, error) {
 	uri, err := url.Parse(*contents.Source)
 	if err != nil {
 		return FetchOp{}, err
 	}
 
-	hasher, err := util.GetHasher(contents.Verification)
+	opts, err := newFetchOptions(l, contents)
 	if err != nil {
 		l.Crit("Error verifying file %q: %v", node.Path, err)
 		return FetchOp{}, err
 	}
 
-	if hasher != nil {
-		// explicitly ignoring the error here because the config should already
-		// be validated by this point
-		_, expectedSumString, _ := util.HashParts(contents.Verification)
-		expectedSum, err = hex.DecodeString(expectedSumString)
-		if err != nil {
-			l.Crit("Error parsing verification string %q: %v", expectedSumString, err)
-			return FetchOp{}, err
-		}
+	return FetchOp{
+		Hash:         opts.Hash,
+		Node:         node,
+		Url:          *uri,
+		FetchOptions: opts,
+	}, nil
+}
+
+// newFetchOptions returns the options for fetching the given resource,
+// including the hasher and expected sum used to verify it.
+func newFetchOptions(l *log.Logger, contents types.Resource) (resource.FetchOptions, error) {
+	hashes, err := util.ExpectedHashes(contents.Verification)
+	if err != nil {
+		l.Crit("Error parsing verification: %v", err)
+		return resource.FetchOptions{}, err
 	}
 	compression := ""
 	if contents.Compression != nil {
@@ -81,21 +105,16 @@ func newFetchOp(l *log.Logger, node types.Node, contents types.Resource) (FetchO
 	if contents.HTTPHeaders != nil && len(contents.HTTPHeaders) > 0 {
 		headers, err = contents.HTTPHeaders.Parse()
 		if err != nil {
-			return FetchOp{}, err
+			return resource.FetchOptions{}, err
 		}
 	}
 
-	return FetchOp{
-		Hash: hasher,
-		Node: node,
-		Url:  *uri,
-		FetchOptions: resource.FetchOptions{
-			Hash:        hasher,
-			Compression: compression,
-			ExpectedSum: expectedSum,
-			Headers:     headers,
-		},
-	}, nil
+	opts := resource.FetchOptions{
+		Compression: compression,
+		Headers:     headers,
+	}
+	opts.SetHashes(hashes)
+	return opts, nil
 }
 
 // PrepareFetches converts a given logger, http client, and types.File into a
@@ -106,9 +125,17 @@ func (u Util) PrepareFetches(l *log.Logger, f types.File) ([]FetchOp, error) {
 	ops := []FetchOp{}
 
 	if f.Contents.Source != nil {
-		if base, err := newFetchOp(l, f.Node, f.Contents); err != nil {
+		contents := f.Contents
+		if cutil.IsTrue(f.Template) {
+			var err error
+			if contents, err = u.renderTemplate(l, f); err != nil {
+				return nil, err
+			}
+		}
+		if base, err := newFetchOp(l, f.Node, contents); err != nil {
 			return nil, err
 		} else {
+			base.Preallocate = cutil.IsTrue(f.Preallocate)
 			ops = append(ops, base)
 		}
 	}
@@ -125,6 +152,36 @@ func (u Util) PrepareFetches(l *log.Logger, f types.File) ([]FetchOp, error) {
 	return ops, nil
 }
 
+// renderTemplate fetches the file's inline contents, verifying them as
+// usual, and substitutes instance metadata into them. The result is
+// returned as a new inline resource.
+func (u Util) renderTemplate(l *log.Logger, f types.File) (types.Resource, error) {
+	uri, err := url.Parse(*f.Contents.Source)
+	if err != nil {
+		return types.Resource{}, err
+	}
+	opts, err := newFetchOptions(l, f.Contents)
+	if err != nil {
+		return types.Resource{}, err
+	}
+	data, err := u.Fetcher.FetchToBuffer(*uri, opts)
+	if err != nil {
+		l.Crit("Error fetching template for file %q: %v", f.Path, err)
+		return types.Resource{}, err
+	}
+	var metadata map[string]string
+	if u.State != nil {
+		metadata = u.State.Metadata
+	}
+	rendered, err := util.RenderTemplate(data, metadata)
+	if err != nil {
+		l.Crit("Error rendering template for file %q: %v", f.Path, err)
+		return types.Resource{}, err
+	}
+	source := dataurl.EncodeBytes(rendered)
+	return types.Resource{Source: &source}, nil
+}
+
 func (u Util) WriteLink(s types.Link) error {
 	path := s.Path
 
@@ -169,6 +226,71 @@ func (u Util) SetPermissions(mode *int, node types.Node) error {
 	return nil
 }
 
+// Inode flags from linux/fs.h, which x/sys/unix doesn't define.
+const (
+	fsImmutableFl = 0x00000010
+	fsAppendFl    = 0x00000020
+
+	// FS_IOC_SETFLAGS is _IOW('f', 2, long) while FS_IOC_GETFLAGS is
+	// _IOR('f', 1, long). Swapping the read and write direction bits
+	// (whose positions are the same on every architecture, even though
+	// their meaning differs) and bumping the number gives the right value.
+	fsIocSetflags = (unix.FS_IOC_GETFLAGS ^ 0xc0000000) + 1
+)
+
+// SetFileFlags sets the given inode flags on path, like chattr(1). Once a
+// file is immutable nothing else about it can be changed, so this must be
+// the last change made to the file.
+func (u Util) SetFileFlags(path string, flags []types.FileFlag) error {
+	if len(flags) == 0 {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	attrs, err := unix.IoctlGetUint32(int(f.Fd()), unix.FS_IOC_GETFLAGS)
+	if err != nil {
+		return fmt.Errorf("failed to get flags of %s: %v", path, err)
+	}
+	for _, flag := range flags {
+		switch flag {
+		case types.FileFlagImmutable:
+			attrs |= fsImmutableFl
+		case types.FileFlagAppendOnly:
+			attrs |= fsAppendFl
+		default:
+			return fmt.Errorf("unknown file flag %q", flag)
+		}
+	}
+	if err := unix.IoctlSetPointerInt(int(f.Fd()), fsIocSetflags, int(attrs)); err != nil {
+		return fmt.Errorf("failed to set flags of %s: %v", path, err)
+	}
+	return nil
+}
+
+// SetXattrs sets the given extended attributes on path, decoding each value
+// from its data URL. Since changing a file's owner clears
+// security.capability, this must be called after SetPermissions.
+func (u Util) SetXattrs(path string, xattrs []types.FileXattr) error {
+	for _, x := range xattrs {
+		var value []byte
+		if x.Value != nil {
+			data, err := dataurl.DecodeString(*x.Value)
+			if err != nil {
+				return fmt.Errorf("failed to decode value of extended attribute %s: %v", x.Name, err)
+			}
+			value = data.Data
+		}
+		if err := unix.Setxattr(path, x.Name, value, 0); err != nil {
+			return fmt.Errorf("failed to set extended attribute %s on %s: %v", x.Name, path, err)
+		}
+	}
+	return nil
+}
+
 // PerformFetch performs a fetch operation generated by PrepareFetch, retrieving
 // the file and writing it to disk. Any encountered errors are returned.
 func (u Util) PerformFetch(f FetchOp) error {
@@ -194,6 +316,21 @@ func (u Util) PerformFetch(f FetchOp) error {
 	// but that's ok (we wanted to keep the file in that case).
 	defer os.Remove(tmp.Name())
 
+	if f.Preallocate && !f.Append {
+		if size, ok := knownContentLength(f.Url, f.FetchOptions); ok && size > 0 {
+			// keep the size so the fetch still starts with an empty file
+			err := fallocate(int(tmp.Fd()), unix.FALLOC_FL_KEEP_SIZE, 0, size)
+			if err == unix.EOPNOTSUPP || err == unix.ENOSYS {
+				// e.g. tmpfs without KEEP_SIZE support, or NFS; the
+				// file is still written, just not preallocated
+				u.Warning("Filesystem doesn't support preallocating %q; writing it without preallocation: %v", path, err)
+			} else if err != nil {
+				u.Crit("Error preallocating file %q: %v", path, err)
+				return err
+			}
+		}
+	}
+
 	err = u.Fetcher.Fetch(f.Url, tmp, f.FetchOptions)
 	if err != nil {
 		u.Crit("Error fetching file %q: %v", path, err)
@@ -229,6 +366,15 @@ func (u Util) PerformFetch(f FetchOp) error {
 			return err
 		}
 	} else {
+		unchanged, err := sameContents(tmp.Name(), path)
+		if err != nil {
+			return err
+		}
+		if unchanged {
+			// keep the existing file and its timestamps
+			u.Info("file %q is unchanged; skipping write", path)
+			return nil
+		}
 		if err = os.Rename(tmp.Name(), path); err != nil {
 			return err
 		}
@@ -237,6 +383,100 @@ func (u Util) PerformFetch(f FetchOp) error {
 	return nil
 }
 
+// sameContents reports whether the regular file at path exists and has the
+// same contents as the file at newPath.
+func sameContents(newPath, path string) (bool, error) {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	newInfo, err := os.Stat(newPath)
+	if err != nil {
+		return false, err
+	}
+	if !info.Mode().IsRegular() || info.Size() != newInfo.Size() {
+		return false, nil
+	}
+
+	a, err := os.Open(newPath)
+	if err != nil {
+		return false, err
+	}
+	defer a.Close()
+	b, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer b.Close()
+
+	bufA := make([]byte, 32*1024)
+	bufB := make([]byte, 32*1024)
+	for {
+		n, errA := io.ReadFull(a, bufA)
+		if _, errB := io.ReadFull(b, bufB[:n]); errB != nil && errB != io.EOF {
+			return false, errB
+		}
+		if !bytes.Equal(bufA[:n], bufB[:n]) {
+			return false, nil
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return true, nil
+		} else if errA != nil {
+			return false, errA
+		}
+	}
+}
+
+// FileMatchesVerification reports whether the regular file at path matches
+// any of the hashes listed in verify. It returns false if verify has no
+// hashes or the file doesn't exist.
+func FileMatchesVerification(path string, verify types.Verification) (bool, error) {
+	hashes, err := util.ExpectedHashes(verify)
+	if err != nil || len(hashes) == 0 {
+		return false, err
+	}
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if !info.Mode().IsRegular() {
+		return false, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	writers := make([]io.Writer, len(hashes))
+	for i, h := range hashes {
+		writers[i] = h.Hash
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), file); err != nil {
+		return false, err
+	}
+	_, err = util.VerifyHashes(hashes)
+	return err == nil, nil
+}
+
+// FileSHA256 returns the hex-encoded SHA-256 digest of the file at path.
+func FileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 // MkdirForFile helper creates the directory components of path.
 func MkdirForFile(path string) error {
 	return os.MkdirAll(filepath.Dir(path), DefaultDirectoryPermissions)
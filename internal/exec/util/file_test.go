@@ -0,0 +1,343 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/resource"
+	"github.com/coreos/ignition/v2/internal/state"
+	"github.com/coreos/ignition/v2/internal/util"
+
+	"github.com/vincent-petithory/dataurl"
+	"golang.org/x/sys/unix"
+)
+
+func TestPerformFetchDataURLVerification(t *testing.T) {
+	contents := []byte("hello world\n")
+	sum := sha512.Sum512(contents)
+	goodHash := "sha512-" + hex.EncodeToString(sum[:])
+	badSum := sum
+	badSum[0]++
+	badHash := "sha512-" + hex.EncodeToString(badSum[:])
+	sum256 := sha256.Sum256(contents)
+	goodHash256 := "sha256-" + hex.EncodeToString(sum256[:])
+
+	tests := []struct {
+		source string
+		hash   string
+		hashes []string
+		fail   bool
+	}{
+		{
+			source: "data:,hello%20world%0a",
+			hash:   goodHash,
+		},
+		{
+			source: "data:,hello%20world%0a",
+			hash:   badHash,
+			fail:   true,
+		},
+		// the hash covers the decoded contents, not the URL
+		{
+			source: dataurl.EncodeBytes(contents),
+			hash:   goodHash,
+		},
+		{
+			source: dataurl.EncodeBytes(contents),
+			hash:   badHash,
+			fail:   true,
+		},
+		// corrupted in transit
+		{
+			source: "data:,hello%20world%0b",
+			hash:   goodHash,
+			fail:   true,
+		},
+		// any acceptable hash may match
+		{
+			source: "data:,hello%20world%0a",
+			hash:   badHash,
+			hashes: []string{goodHash256},
+		},
+		{
+			source: "data:,hello%20world%0a",
+			hashes: []string{badHash, goodHash},
+		},
+		{
+			source: "data:,hello%20world%0a",
+			hash:   badHash,
+			hashes: []string{badHash},
+			fail:   true,
+		},
+	}
+
+	logger := log.New(true)
+	defer logger.Close()
+	u := Util{
+		Fetcher: resource.Fetcher{Logger: &logger},
+		Logger:  &logger,
+	}
+	for i, test := range tests {
+		var hash *string
+		if test.hash != "" {
+			hash = cutil.StrToPtr(test.hash)
+		}
+		path := filepath.Join(t.TempDir(), "file")
+		ops, err := u.PrepareFetches(&logger, types.File{
+			Node: types.Node{Path: path},
+			FileEmbedded1: types.FileEmbedded1{
+				Contents: types.Resource{
+					Source:       cutil.StrToPtr(test.source),
+					Verification: types.Verification{Hash: hash, Hashes: test.hashes},
+				},
+			},
+		})
+		if err != nil {
+			t.Errorf("#%d: preparing fetch: %v", i, err)
+			continue
+		}
+		if len(ops) != 1 {
+			t.Errorf("#%d: bad number of fetch ops: want 1, got %d", i, len(ops))
+			continue
+		}
+		err = u.PerformFetch(ops[0])
+		if test.fail {
+			if _, ok := err.(util.ErrHashMismatch); !ok {
+				t.Errorf("#%d: bad error: want hash mismatch, got %v", i, err)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("#%d: file written despite hash mismatch", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if got, err := ioutil.ReadFile(path); err != nil {
+			t.Errorf("#%d: reading file: %v", i, err)
+		} else if string(got) != string(contents) {
+			t.Errorf("#%d: bad contents: want %q, got %q", i, contents, got)
+		}
+	}
+}
+
+func TestPerformFetchPreallocate(t *testing.T) {
+	contents := []byte("hello world\n")
+
+	tests := []struct {
+		source      string
+		compression string
+		preallocate bool
+		// error returned by fallocate
+		fallocateErr error
+		// expected fallocate length, or 0 if it shouldn't be called
+		size int64
+		err  bool
+	}{
+		{
+			source:      "data:,hello%20world%0a",
+			preallocate: true,
+			size:        int64(len(contents)),
+		},
+		{
+			source:      dataurl.EncodeBytes(contents),
+			preallocate: true,
+			size:        int64(len(contents)),
+		},
+		// default behavior
+		{
+			source: dataurl.EncodeBytes(contents),
+		},
+		// size unknown
+		{
+			source:      "data:,%1F%8B%08%00%00%00%00%00%00%FF%CBH%CD%C9%C9W(%CF/%CAI%E1%02%00-%3B%08%AF%0C%00%00%00",
+			compression: "gzip",
+			preallocate: true,
+		},
+		// nothing to allocate
+		{
+			source:      "data:,",
+			preallocate: true,
+		},
+		// unsupported by the filesystem, so written anyway
+		{
+			source:       dataurl.EncodeBytes(contents),
+			preallocate:  true,
+			fallocateErr: unix.EOPNOTSUPP,
+			size:         int64(len(contents)),
+		},
+		// out of space
+		{
+			source:       dataurl.EncodeBytes(contents),
+			preallocate:  true,
+			fallocateErr: unix.ENOSPC,
+			size:         int64(len(contents)),
+			err:          true,
+		},
+	}
+
+	defer func(orig func(int, uint32, int64, int64) error) {
+		fallocate = orig
+	}(fallocate)
+
+	logger := log.New(true)
+	defer logger.Close()
+	u := Util{
+		Fetcher: resource.Fetcher{Logger: &logger},
+		Logger:  &logger,
+	}
+	for i, test := range tests {
+		var allocated int64
+		fallocate = func(fd int, mode uint32, off int64, size int64) error {
+			allocated = size
+			return test.fallocateErr
+		}
+
+		path := filepath.Join(t.TempDir(), "file")
+		ops, err := u.PrepareFetches(&logger, types.File{
+			Node: types.Node{Path: path},
+			FileEmbedded1: types.FileEmbedded1{
+				Contents: types.Resource{
+					Source:      cutil.StrToPtr(test.source),
+					Compression: cutil.StrToPtr(test.compression),
+				},
+				Preallocate: cutil.BoolToPtr(test.preallocate),
+			},
+		})
+		if err != nil {
+			t.Errorf("#%d: preparing fetch: %v", i, err)
+			continue
+		}
+		err = u.PerformFetch(ops[0])
+		if allocated != test.size {
+			t.Errorf("#%d: bad fallocate length: want %d, got %d", i, test.size, allocated)
+		}
+		if test.err {
+			if err == nil {
+				t.Errorf("#%d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if test.fallocateErr != nil {
+			// the fallback still writes the file
+			if data, err := ioutil.ReadFile(path); err != nil || string(data) != string(contents) {
+				t.Errorf("#%d: bad contents: want %q, got %q (%v)", i, contents, data, err)
+			}
+		}
+	}
+}
+
+func TestPerformFetchTemplate(t *testing.T) {
+	template := []byte("hostname={{ hostname }}\nid={{ instance_id }}\n{not a variable}\n")
+	sum := sha256.Sum256(template)
+	templateHash := "sha256-" + hex.EncodeToString(sum[:])
+	badHash := "sha256-0519a9826023338828942b081814355d55301b9bc82042390f9afaf75cd3a707"
+
+	tests := []struct {
+		template []byte
+		hash     string
+		out      string
+		err      error
+	}{
+		{
+			template: template,
+			out:      "hostname=node1.example.com\nid=i-0123456789\n{not a variable}\n",
+		},
+		// verification applies to the template, not the rendered file
+		{
+			template: template,
+			hash:     templateHash,
+			out:      "hostname=node1.example.com\nid=i-0123456789\n{not a variable}\n",
+		},
+		{
+			template: template,
+			hash:     badHash,
+			err: util.ErrHashMismatch{
+				Calculated: hex.EncodeToString(sum[:]),
+				Expected:   "0519a9826023338828942b081814355d55301b9bc82042390f9afaf75cd3a707",
+			},
+		},
+		{
+			template: []byte("zone={{ zone }}"),
+			err:      util.ErrTemplateUnknownVariable{Name: "zone"},
+		},
+	}
+
+	logger := log.New(true)
+	defer logger.Close()
+	// metadata as the fetch stage would have recorded it from the provider
+	u := Util{
+		Fetcher: resource.Fetcher{Logger: &logger},
+		Logger:  &logger,
+		State: &state.State{
+			Metadata: map[string]string{
+				"hostname":    "node1.example.com",
+				"instance_id": "i-0123456789",
+			},
+		},
+	}
+	for i, test := range tests {
+		var hash *string
+		if test.hash != "" {
+			hash = cutil.StrToPtr(test.hash)
+		}
+		path := filepath.Join(t.TempDir(), "file")
+		ops, err := u.PrepareFetches(&logger, types.File{
+			Node: types.Node{Path: path},
+			FileEmbedded1: types.FileEmbedded1{
+				Contents: types.Resource{
+					Source:       cutil.StrToPtr(dataurl.EncodeBytes(test.template)),
+					Verification: types.Verification{Hash: hash},
+				},
+				Template: cutil.BoolToPtr(true),
+			},
+		})
+		if err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if len(ops) != 1 {
+			t.Errorf("#%d: bad number of fetch ops: want 1, got %d", i, len(ops))
+			continue
+		}
+		if err := u.PerformFetch(ops[0]); err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if got, err := ioutil.ReadFile(path); err != nil {
+			t.Errorf("#%d: reading file: %v", i, err)
+		} else if string(got) != test.out {
+			t.Errorf("#%d: bad contents: want %q, got %q", i, test.out, got)
+		}
+	}
+}
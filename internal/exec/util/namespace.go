@@ -0,0 +1,144 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// Mount namespaces belong to threads, so the functions below lock the
+// calling goroutine to its thread and never unlock it; the thread exits
+// with the goroutine instead of being reused by the runtime. Commands run
+// from that goroutine inherit its namespace. Threads the runtime starts
+// later are created from a template thread, so they stay in the original
+// namespace.
+
+var ErrMountNamespaceExists = errors.New("mount namespace is already pinned")
+
+// CreateMountNamespace moves the calling goroutine into a new mount
+// namespace and pins it at path, so later processes can enter it with
+// EnterMountNamespace. The original namespace's mounts still propagate
+// into the new one, but not the other way around.
+func CreateMountNamespace(path string) error {
+	if pinned, err := isNamespacePinned(path); err != nil {
+		return err
+	} else if pinned {
+		return ErrMountNamespaceExists
+	}
+
+	// A pinned namespace mustn't propagate into itself, so pin it in a
+	// private mount.
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := unix.Mount(dir, dir, "", unix.MS_BIND, ""); err != nil {
+		return fmt.Errorf("bind mounting %s: %w", dir, err)
+	}
+	if err := unix.Mount("", dir, "", unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("making %s private: %w", dir, err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	f.Close()
+
+	runtime.LockOSThread()
+	if err := unix.Unshare(unix.CLONE_NEWNS); err != nil {
+		return fmt.Errorf("creating mount namespace: %w", err)
+	}
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_SLAVE, ""); err != nil {
+		return fmt.Errorf("making mounts slaves: %w", err)
+	}
+	nsPath := fmt.Sprintf("/proc/%d/task/%d/ns/mnt", os.Getpid(), unix.Gettid())
+	return onOtherThread(func() error {
+		if err := unix.Mount(nsPath, path, "", unix.MS_BIND, ""); err != nil {
+			return fmt.Errorf("pinning mount namespace: %w", err)
+		}
+		return nil
+	})
+}
+
+// EnterMountNamespace moves the calling goroutine into the mount namespace
+// pinned at path, returning false if there isn't one.
+func EnterMountNamespace(path string) (bool, error) {
+	if pinned, err := isNamespacePinned(path); err != nil || !pinned {
+		return false, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	runtime.LockOSThread()
+	// the kernel refuses to move threads which share their filesystem
+	// information, as all of the runtime's threads do
+	if err := unix.Unshare(unix.CLONE_FS); err != nil {
+		return false, fmt.Errorf("unsharing filesystem information: %w", err)
+	}
+	if err := unix.Setns(int(f.Fd()), unix.CLONE_NEWNS); err != nil {
+		return false, fmt.Errorf("entering mount namespace: %w", err)
+	}
+	return true, nil
+}
+
+// ReleaseMountNamespace unpins the mount namespace pinned at path. The
+// namespace, and any mounts only it has, go away once no process is using
+// it.
+func ReleaseMountNamespace(path string) error {
+	return onOtherThread(func() error {
+		if err := unix.Unmount(path, unix.MNT_DETACH); err != nil {
+			return fmt.Errorf("unpinning mount namespace: %w", err)
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		dir := filepath.Dir(path)
+		if err := unix.Unmount(dir, unix.MNT_DETACH); err != nil {
+			return fmt.Errorf("unmounting %s: %w", dir, err)
+		}
+		return nil
+	})
+}
+
+func isNamespacePinned(path string) (bool, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return st.Type == unix.NSFS_MAGIC, nil
+}
+
+// onOtherThread runs f on a thread which is still in the original mount
+// namespace, even if the calling goroutine has left it.
+func onOtherThread(f func() error) error {
+	errChan := make(chan error)
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		errChan <- f()
+	}()
+	return <-errChan
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// inNewGoroutine runs f in a goroutine of its own, so a namespace change
+// doesn't affect the test's goroutine.
+func inNewGoroutine(f func() error) error {
+	errChan := make(chan error)
+	go func() {
+		errChan <- f()
+	}()
+	return <-errChan
+}
+
+func mountNamespace() (string, error) {
+	return os.Readlink("/proc/thread-self/ns/mnt")
+}
+
+func isTmpfs(path string) (bool, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return false, err
+	}
+	return st.Type == unix.TMPFS_MAGIC, nil
+}
+
+func TestMountNamespace(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("creating mount namespaces requires root")
+	}
+	tmp, err := ioutil.TempDir("", "ignition-namespace-test")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+	pin := filepath.Join(tmp, "ns", "mnt")
+	mnt := filepath.Join(tmp, "mnt")
+	if err := os.Mkdir(mnt, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// tmp itself mustn't be tmpfs for the checks below to mean anything
+	if tmpfs, err := isTmpfs(mnt); err != nil {
+		t.Fatal(err)
+	} else if tmpfs {
+		t.Skip("temporary directory is on tmpfs")
+	}
+
+	var original, created string
+	err = inNewGoroutine(func() error {
+		var err error
+		if original, err = mountNamespace(); err != nil {
+			return err
+		}
+		if err := CreateMountNamespace(pin); err != nil {
+			return err
+		}
+		if created, err = mountNamespace(); err != nil {
+			return err
+		}
+		return unix.Mount("tmpfs", mnt, "tmpfs", 0, "")
+	})
+	if err == unix.EPERM {
+		t.Skipf("creating mount namespace: %v", err)
+	} else if err != nil {
+		t.Fatalf("creating mount namespace: %v", err)
+	}
+	if created == original {
+		t.Errorf("mount namespace wasn't unshared: %s", created)
+	}
+	if err := CreateMountNamespace(pin); err != ErrMountNamespaceExists {
+		t.Errorf("bad error recreating namespace: want %v, got %v", ErrMountNamespaceExists, err)
+	}
+
+	// the mount doesn't leak into the original namespace...
+	err = inNewGoroutine(func() error {
+		tmpfs, err := isTmpfs(mnt)
+		if err == nil && tmpfs {
+			t.Errorf("mount leaked into the original namespace")
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ...but is visible to anyone entering the pinned namespace
+	err = inNewGoroutine(func() error {
+		entered, err := EnterMountNamespace(pin)
+		if err != nil {
+			return err
+		}
+		if !entered {
+			t.Errorf("pinned namespace wasn't entered")
+		}
+		if ns, err := mountNamespace(); err != nil {
+			return err
+		} else if ns != created {
+			t.Errorf("bad namespace: want %s, got %s", created, ns)
+		}
+		tmpfs, err := isTmpfs(mnt)
+		if err == nil && !tmpfs {
+			t.Errorf("mount not visible in the pinned namespace")
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ReleaseMountNamespace(pin); err != nil {
+		t.Fatalf("releasing namespace: %v", err)
+	}
+	err = inNewGoroutine(func() error {
+		entered, err := EnterMountNamespace(pin)
+		if entered {
+			t.Errorf("released namespace was entered")
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -16,6 +16,8 @@ package util
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/user"
@@ -54,7 +56,8 @@ func appendIfStringSet(args []string, arg string, str *string) []string {
 // EnsureUser ensures that the user exists as described. If the user does not
 // yet exist, they will be created, otherwise the existing user will be modified.
 // If the `shouldExist` field is set to false and the user already exists, then
-// they will be deleted.
+// they will be deleted. If the `mustExist` field is set and the user doesn't
+// exist, an error is returned.
 func (u Util) EnsureUser(c types.PasswdUser) error {
 	shouldExist := !util.IsFalse(c.ShouldExist)
 	exists, err := u.CheckIfUserExists(c)
@@ -73,7 +76,37 @@ func (u Util) EnsureUser(c types.PasswdUser) error {
 		}
 		return nil
 	}
+	if util.IsTrue(c.MustExist) && !exists {
+		return fmt.Errorf("user %q must already exist but was not found", c.Name)
+	}
+
+	if !exists && !util.IsTrue(c.NoCreateHome) {
+		if util.NotEmpty(c.SkeletonDir) {
+			if err := u.checkSkeletonDir(*c.SkeletonDir); err != nil {
+				return err
+			}
+		} else if util.IsTrue(c.NoCopySkeleton) {
+			// useradd always copies a skeleton directory, so point
+			// it at an empty one
+			skel, cleanup, err := u.emptySkeletonDir()
+			if err != nil {
+				return fmt.Errorf("creating empty skeleton directory: %w", err)
+			}
+			defer cleanup()
+			c.SkeletonDir = &skel
+		}
+	}
+
+	cmd, args := u.userArgs(c, exists)
+	_, err = u.LogCmd(exec.Command(cmd, args...),
+		"creating or modifying user %q", c.Name)
+	return err
+}
 
+// userArgs returns the command and arguments which create the user described
+// by c or, if it already exists, modify it in place. Supplementary groups are
+// appended to those of an existing user.
+func (u Util) userArgs(c types.PasswdUser, exists bool) (string, []string) {
 	args := []string{"--root", u.DestDir}
 
 	var cmd string
@@ -92,6 +125,7 @@ func (u Util) EnsureUser(c types.PasswdUser) error {
 			args = append(args, "--no-create-home")
 		} else {
 			args = append(args, "--create-home")
+			args = appendIfStringSet(args, "--skel", c.SkeletonDir)
 		}
 
 		args = appendIfTrue(args, c.NoUserGroup, "--no-user-group")
@@ -120,16 +154,70 @@ func (u Util) EnsureUser(c types.PasswdUser) error {
 	args = appendIfStringSet(args, "--gid", c.PrimaryGroup)
 
 	if len(c.Groups) > 0 {
+		if exists {
+			args = append(args, "--append")
+		}
 		args = append(args, "--groups", strings.Join(translateV2_1PasswdUserGroupSliceToStringSlice(c.Groups), ","))
 	}
 
 	args = appendIfStringSet(args, "--shell", c.Shell)
 
 	args = append(args, c.Name)
+	return cmd, args
+}
 
-	_, err = u.LogCmd(exec.Command(cmd, args...),
-		"creating or modifying user %q", c.Name)
-	return err
+// emptySkeletonDir creates an empty directory in /tmp within DestDir, since
+// useradd looks up the skeleton directory within the root. It returns the
+// directory's path relative to DestDir and a function which removes it,
+// along with /tmp if that had to be created.
+func (u Util) emptySkeletonDir() (string, func(), error) {
+	tmp, err := u.JoinPath("/tmp")
+	if err != nil {
+		return "", nil, err
+	}
+	createdTmp := false
+	if _, err := os.Stat(tmp); os.IsNotExist(err) {
+		if err := os.Mkdir(tmp, 0700); err != nil {
+			return "", nil, err
+		}
+		createdTmp = true
+	} else if err != nil {
+		return "", nil, err
+	}
+	cleanupTmp := func() {
+		if createdTmp {
+			os.Remove(tmp)
+		}
+	}
+	skel, err := ioutil.TempDir(tmp, "ignition-skel")
+	if err != nil {
+		cleanupTmp()
+		return "", nil, err
+	}
+	cleanup := func() {
+		os.Remove(skel)
+		cleanupTmp()
+	}
+	return filepath.Join("/tmp", filepath.Base(skel)), cleanup, nil
+}
+
+// checkSkeletonDir returns an error unless skel, relative to DestDir, is a
+// directory.
+func (u Util) checkSkeletonDir(skel string) error {
+	path, err := u.JoinPath(skel)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("skeleton directory %q doesn't exist", skel)
+	} else if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("skeleton directory %q isn't a directory", skel)
+	}
+	return nil
 }
 
 // GetUserHomeDir returns the user home directory. Note that DestDir is not
@@ -231,7 +319,7 @@ func (u Util) ModifyHomeDirPermissions(c types.PasswdUser) error {
 
 // AuthorizeSSHKeys adds the provided SSH public keys to the user's authorized keys.
 func (u Util) AuthorizeSSHKeys(c types.PasswdUser) error {
-	if len(c.SSHAuthorizedKeys) == 0 {
+	if len(c.SSHAuthorizedKeys) == 0 && len(c.SSHAuthorizedKeysSources) == 0 {
 		return nil
 	}
 
@@ -241,9 +329,15 @@ func (u Util) AuthorizeSSHKeys(c types.PasswdUser) error {
 			return fmt.Errorf("unable to lookup user %q", c.Name)
 		}
 
+		fetched, err := u.fetchSSHAuthorizedKeys(c)
+		if err != nil {
+			return err
+		}
+
 		// TODO(vc): introduce key names to config?
 		// TODO(vc): validate c.SSHAuthorizedKeys well-formedness.
-		ks := strings.Join(translateV2_1SSHAuthorizedKeySliceToStringSlice(c.SSHAuthorizedKeys), "\n")
+		keys := append(translateV2_1SSHAuthorizedKeySliceToStringSlice(c.SSHAuthorizedKeys), fetched...)
+		ks := strings.Join(keys, "\n")
 		// XXX(vc): for now ensure the addition is always
 		// newline-terminated.  A future version of akd will handle this
 		// for us in addition to validating the ssh keys for
@@ -275,6 +369,32 @@ func (u Util) AuthorizeSSHKeys(c types.PasswdUser) error {
 	}, "adding ssh keys to user %q", c.Name)
 }
 
+// fetchSSHAuthorizedKeys fetches and verifies each of the user's
+// sshAuthorizedKeysSources, returning the non-empty lines of each.
+func (u Util) fetchSSHAuthorizedKeys(c types.PasswdUser) ([]string, error) {
+	keys := []string{}
+	for i, src := range c.SSHAuthorizedKeysSources {
+		uri, err := url.Parse(*src.Source)
+		if err != nil {
+			return nil, err
+		}
+		opts, err := newFetchOptions(u.Logger, src)
+		if err != nil {
+			return nil, err
+		}
+		data, err := u.Fetcher.FetchToBuffer(*uri, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch ssh authorized keys source %d for user %q: %v", i, c.Name, err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if key := strings.TrimSpace(line); key != "" {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
+
 // golang--
 func translateV2_1SSHAuthorizedKeySliceToStringSlice(keys []types.SSHAuthorizedKey) []string {
 	newKeys := make([]string, len(keys))
@@ -307,6 +427,58 @@ func (u Util) SetPasswordHash(c types.PasswdUser) error {
 	return err
 }
 
+// SetAccountLock locks or unlocks the password of the specified user, if
+// c.Locked is set.
+func (u Util) SetAccountLock(c types.PasswdUser) error {
+	args := u.lockArgs(c)
+	if args == nil {
+		return nil
+	}
+	_, err := u.LogCmd(exec.Command(distro.UsermodCmd(), args...),
+		"setting password lock for user %q", c.Name)
+	return err
+}
+
+func (u Util) lockArgs(c types.PasswdUser) []string {
+	if c.Locked == nil {
+		return nil
+	}
+	args := []string{"--root", u.DestDir}
+	if *c.Locked {
+		args = append(args, "--lock")
+	} else {
+		args = append(args, "--unlock")
+	}
+	return append(args, c.Name)
+}
+
+// SetPasswordAging sets the password expiration of the specified user and
+// whether they must change their password at the next login.
+func (u Util) SetPasswordAging(c types.PasswdUser) error {
+	args := u.chageArgs(c)
+	if args == nil {
+		return nil
+	}
+	_, err := u.LogCmd(exec.Command(distro.ChageCmd(), args...),
+		"setting password aging for user %q", c.Name)
+	return err
+}
+
+func (u Util) chageArgs(c types.PasswdUser) []string {
+	if c.PasswordMaxDays == nil && !util.IsTrue(c.ForcePasswordChange) {
+		return nil
+	}
+	args := []string{"--root", u.DestDir}
+	if c.PasswordMaxDays != nil {
+		args = append(args, "--maxdays", strconv.Itoa(*c.PasswordMaxDays))
+	}
+	if util.IsTrue(c.ForcePasswordChange) {
+		// a last change date of 0 expires the password immediately
+		args = append(args, "--lastday", "0")
+	}
+	return append(args, c.Name)
+}
+
 // EnsureGroup ensures that the group exists as described. If the
 // `shouldExist` field is set to false and the group already exists,
 // then it will be deleted.
@@ -351,6 +523,134 @@ func (u Util) EnsureGroup(g types.PasswdGroup) error {
 	return err
 }
 
+// CheckUserGroups verifies that the primary and supplementary groups of the
+// user exist. Groups from the config must already have been created. Groups
+// referenced by GID are left for useradd and usermod to check.
+func (u Util) CheckUserGroups(c types.PasswdUser) error {
+	groups := translateV2_1PasswdUserGroupSliceToStringSlice(c.Groups)
+	if util.NotEmpty(c.PrimaryGroup) {
+		groups = append([]string{*c.PrimaryGroup}, groups...)
+	}
+	for _, g := range groups {
+		if _, err := strconv.ParseUint(g, 10, 32); err == nil {
+			continue
+		}
+		exists, err := u.CheckIfGroupExists(types.PasswdGroup{Name: g})
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("group %q neither exists nor is defined in the config", g)
+		}
+	}
+	return nil
+}
+
+// RemoveUserFromGroups removes the user from each group listed in
+// c.RemoveFromGroups which it's a member of. Memberships are read from
+// the /etc/group and /etc/gshadow files which gpasswd modifies, so groups
+// the user isn't a member of are skipped rather than failing. Groups given
+// by GID are resolved, so that removing the user from a group it's also
+// added to fails even if only one of them is given by GID.
+func (u Util) RemoveUserFromGroups(c types.PasswdUser) error {
+	if len(c.RemoveFromGroups) == 0 {
+		return nil
+	}
+	path, err := u.JoinPath("/etc/group")
+	if err != nil {
+		return err
+	}
+	groupFile, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	path, err = u.JoinPath("/etc/gshadow")
+	if err != nil {
+		return err
+	}
+	gshadowFile, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	added := map[string]struct{}{}
+	groups := translateV2_1PasswdUserGroupSliceToStringSlice(c.Groups)
+	if util.NotEmpty(c.PrimaryGroup) {
+		groups = append(groups, *c.PrimaryGroup)
+	}
+	for _, g := range groups {
+		if fields := findGroup(groupFile, g); fields != nil {
+			added[fields[0]] = struct{}{}
+		}
+	}
+
+	for _, g := range translateV2_1PasswdUserGroupSliceToStringSlice(c.RemoveFromGroups) {
+		name, member := groupMembership(groupFile, g, c.Name)
+		if _, ok := added[name]; ok {
+			return fmt.Errorf("user %q can't be both added to and removed from group %q", c.Name, name)
+		}
+		if name != "" && !member {
+			member = gshadowMembership(gshadowFile, name, c.Name)
+		}
+		if !member {
+			u.Info("user %q is not a member of group %q; skipping removal", c.Name, g)
+			continue
+		}
+		args := []string{"--root", u.DestDir, "--delete", c.Name, name}
+		if _, err := u.LogCmd(exec.Command(distro.GpasswdCmd(), args...),
+			"removing user %q from group %q", c.Name, name); err != nil {
+			return fmt.Errorf("failed to remove user %q from group %q: %v", c.Name, name, err)
+		}
+	}
+	return nil
+}
+
+// groupMembership finds the group with the given name or GID in the
+// contents of an /etc/group file, returning its name and whether user is
+// listed as a member.
+func groupMembership(groupFile []byte, group, user string) (string, bool) {
+	fields := findGroup(groupFile, group)
+	if fields == nil {
+		return "", false
+	}
+	return fields[0], listsMember(fields[3], user)
+}
+
+// findGroup returns the fields of the entry for the group with the given
+// name or GID in the contents of an /etc/group file, or nil if there is
+// none.
+func findGroup(groupFile []byte, group string) []string {
+	for _, line := range strings.Split(string(groupFile), "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) == 4 && (fields[0] == group || fields[2] == group) {
+			return fields
+		}
+	}
+	return nil
+}
+
+// gshadowMembership returns whether user is listed as a member of the group
+// with the given name in the contents of an /etc/gshadow file.
+func gshadowMembership(gshadowFile []byte, name, user string) bool {
+	for _, line := range strings.Split(string(gshadowFile), "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) == 4 && fields[0] == name {
+			return listsMember(fields[3], user)
+		}
+	}
+	return false
+}
+
+// listsMember returns whether user is in the comma-separated members list.
+func listsMember(members, user string) bool {
+	for _, member := range strings.Split(members, ",") {
+		if member == user {
+			return true
+		}
+	}
+	return false
+}
+
 // CheckIfGroupExists will return Info log when group is empty
 func (u Util) CheckIfGroupExists(g types.PasswdGroup) (bool, error) {
 	_, err := u.groupLookup(g.Name)
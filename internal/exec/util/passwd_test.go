@@ -0,0 +1,570 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/resource"
+
+	"github.com/vincent-petithory/dataurl"
+)
+
+func TestFetchSSHAuthorizedKeys(t *testing.T) {
+	keys := "ssh-ed25519 AAAAC3Nza one\n\nssh-rsa AAAAB3Nza two\r\n"
+	sum := sha256.Sum256([]byte(keys))
+	source := dataurl.EncodeBytes([]byte(keys))
+
+	type out struct {
+		keys []string
+		fail bool
+	}
+	tests := []struct {
+		in  []types.Resource
+		out out
+	}{
+		{
+			in:  nil,
+			out: out{keys: []string{}},
+		},
+		{
+			in: []types.Resource{
+				{Source: cutil.StrToPtr(source)},
+				{Source: cutil.StrToPtr(dataurl.EncodeBytes([]byte("ssh-ed25519 AAAAC3Nza three")))},
+			},
+			out: out{keys: []string{"ssh-ed25519 AAAAC3Nza one", "ssh-rsa AAAAB3Nza two", "ssh-ed25519 AAAAC3Nza three"}},
+		},
+		{
+			in: []types.Resource{
+				{
+					Source:       cutil.StrToPtr(source),
+					Verification: types.Verification{Hash: cutil.StrToPtr("sha256-" + hex.EncodeToString(sum[:]))},
+				},
+			},
+			out: out{keys: []string{"ssh-ed25519 AAAAC3Nza one", "ssh-rsa AAAAB3Nza two"}},
+		},
+		{
+			in: []types.Resource{
+				{
+					Source:       cutil.StrToPtr(source),
+					Verification: types.Verification{Hash: cutil.StrToPtr("sha256-0000000000000000000000000000000000000000000000000000000000000000")},
+				},
+			},
+			out: out{fail: true},
+		},
+		{
+			in: []types.Resource{
+				{Source: cutil.StrToPtr("foo://bar")},
+			},
+			out: out{fail: true},
+		},
+	}
+
+	logger := log.New(true)
+	defer logger.Close()
+	u := Util{
+		Fetcher: resource.Fetcher{Logger: &logger},
+		Logger:  &logger,
+	}
+	for i, test := range tests {
+		keys, err := u.fetchSSHAuthorizedKeys(types.PasswdUser{Name: "core", SSHAuthorizedKeysSources: test.in})
+		if test.out.fail {
+			if err == nil {
+				t.Errorf("#%d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(test.out.keys, keys) {
+			t.Errorf("#%d: bad keys: want %v, got %v", i, test.out.keys, keys)
+		}
+	}
+}
+
+func TestUserArgs(t *testing.T) {
+	type in struct {
+		user   types.PasswdUser
+		exists bool
+	}
+	type out struct {
+		cmd  string
+		args []string
+	}
+	tests := []struct {
+		in  in
+		out out
+	}{
+		// new user with supplementary groups by name and GID
+		{
+			in: in{
+				user: types.PasswdUser{
+					Name:         "jenkins",
+					PrimaryGroup: cutil.StrToPtr("1001"),
+					Groups:       []types.Group{"wheel", "233"},
+				},
+			},
+			out: out{
+				cmd: distro.UseraddCmd(),
+				args: []string{"--root", "/sysroot", "--create-home", "--password", "*",
+					"--gid", "1001", "--groups", "wheel,233", "jenkins"},
+			},
+		},
+		// existing user is appended to wheel
+		{
+			in: in{
+				user: types.PasswdUser{
+					Name:   "core",
+					Groups: []types.Group{"wheel"},
+				},
+				exists: true,
+			},
+			out: out{
+				cmd:  distro.UsermodCmd(),
+				args: []string{"--root", "/sysroot", "--append", "--groups", "wheel", "core"},
+			},
+		},
+		// new user with a custom skeleton directory
+		{
+			in: in{
+				user: types.PasswdUser{
+					Name:        "jenkins",
+					SkeletonDir: cutil.StrToPtr("/etc/skel.jenkins"),
+				},
+			},
+			out: out{
+				cmd: distro.UseraddCmd(),
+				args: []string{"--root", "/sysroot", "--create-home", "--skel", "/etc/skel.jenkins",
+					"--password", "*", "jenkins"},
+			},
+		},
+		// skeleton directory is ignored without a home directory
+		{
+			in: in{
+				user: types.PasswdUser{
+					Name:         "jenkins",
+					NoCreateHome: cutil.BoolToPtr(true),
+					SkeletonDir:  cutil.StrToPtr("/etc/skel.jenkins"),
+				},
+			},
+			out: out{
+				cmd:  distro.UseraddCmd(),
+				args: []string{"--root", "/sysroot", "--no-create-home", "--password", "*", "jenkins"},
+			},
+		},
+		// skeleton directory is ignored for existing users
+		{
+			in: in{
+				user: types.PasswdUser{
+					Name:        "core",
+					SkeletonDir: cutil.StrToPtr("/etc/skel.jenkins"),
+				},
+				exists: true,
+			},
+			out: out{
+				cmd:  distro.UsermodCmd(),
+				args: []string{"--root", "/sysroot", "core"},
+			},
+		},
+		// existing user without groups keeps its password
+		{
+			in: in{
+				user: types.PasswdUser{
+					Name:  "core",
+					Shell: cutil.StrToPtr("/bin/zsh"),
+				},
+				exists: true,
+			},
+			out: out{
+				cmd:  distro.UsermodCmd(),
+				args: []string{"--root", "/sysroot", "--shell", "/bin/zsh", "core"},
+			},
+		},
+	}
+
+	u := Util{DestDir: "/sysroot"}
+	for i, test := range tests {
+		cmd, args := u.userArgs(test.in.user, test.in.exists)
+		if cmd != test.out.cmd {
+			t.Errorf("#%d: bad command: want %q, got %q", i, test.out.cmd, cmd)
+		}
+		if !reflect.DeepEqual(test.out.args, args) {
+			t.Errorf("#%d: bad args: want %v, got %v", i, test.out.args, args)
+		}
+	}
+}
+
+func TestLockArgs(t *testing.T) {
+	tests := []struct {
+		in  types.PasswdUser
+		out []string
+	}{
+		{
+			in:  types.PasswdUser{Name: "core"},
+			out: nil,
+		},
+		{
+			in:  types.PasswdUser{Name: "core", Locked: cutil.BoolToPtr(true)},
+			out: []string{"--root", "/sysroot", "--lock", "core"},
+		},
+		{
+			in:  types.PasswdUser{Name: "core", Locked: cutil.BoolToPtr(false)},
+			out: []string{"--root", "/sysroot", "--unlock", "core"},
+		},
+	}
+
+	u := Util{DestDir: "/sysroot"}
+	for i, test := range tests {
+		args := u.lockArgs(test.in)
+		if !reflect.DeepEqual(test.out, args) {
+			t.Errorf("#%d: bad args: want %v, got %v", i, test.out, args)
+		}
+	}
+}
+
+func TestChageArgs(t *testing.T) {
+	tests := []struct {
+		in  types.PasswdUser
+		out []string
+	}{
+		{
+			in:  types.PasswdUser{Name: "core"},
+			out: nil,
+		},
+		{
+			in:  types.PasswdUser{Name: "core", ForcePasswordChange: cutil.BoolToPtr(false)},
+			out: nil,
+		},
+		{
+			in:  types.PasswdUser{Name: "core", PasswordMaxDays: cutil.IntToPtr(90)},
+			out: []string{"--root", "/sysroot", "--maxdays", "90", "core"},
+		},
+		{
+			in:  types.PasswdUser{Name: "core", PasswordMaxDays: cutil.IntToPtr(-1)},
+			out: []string{"--root", "/sysroot", "--maxdays", "-1", "core"},
+		},
+		{
+			in:  types.PasswdUser{Name: "core", ForcePasswordChange: cutil.BoolToPtr(true)},
+			out: []string{"--root", "/sysroot", "--lastday", "0", "core"},
+		},
+		{
+			in: types.PasswdUser{
+				Name:                "core",
+				PasswordMaxDays:     cutil.IntToPtr(30),
+				ForcePasswordChange: cutil.BoolToPtr(true),
+			},
+			out: []string{"--root", "/sysroot", "--maxdays", "30", "--lastday", "0", "core"},
+		},
+	}
+
+	u := Util{DestDir: "/sysroot"}
+	for i, test := range tests {
+		args := u.chageArgs(test.in)
+		if !reflect.DeepEqual(test.out, args) {
+			t.Errorf("#%d: bad args: want %v, got %v", i, test.out, args)
+		}
+	}
+}
+
+func TestEnsureUserSkeletonDir(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("test requires root for chroot(), skipping")
+	}
+
+	td, err := tempBase()
+	if err != nil {
+		t.Fatalf("temp base error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	logger := log.New(true)
+	defer logger.Close()
+	u := Util{DestDir: td, Logger: &logger}
+
+	err = u.EnsureUser(types.PasswdUser{Name: "bar", SkeletonDir: cutil.StrToPtr("/etc/skel.bar")})
+	if err == nil {
+		t.Fatalf("expected error for missing skeleton directory, got none")
+	}
+	if !strings.Contains(err.Error(), `"/etc/skel.bar"`) {
+		t.Errorf("error doesn't name the skeleton directory: %v", err)
+	}
+}
+
+func TestEmptySkeletonDir(t *testing.T) {
+	for _, haveTmp := range []bool{false, true} {
+		td, err := ioutil.TempDir("", "ignition-skel-test")
+		if err != nil {
+			t.Fatalf("creating temp dir: %v", err)
+		}
+		defer os.RemoveAll(td)
+		if haveTmp {
+			if err := os.Mkdir(filepath.Join(td, "tmp"), 01777); err != nil {
+				t.Fatalf("creating tmp: %v", err)
+			}
+		}
+
+		u := Util{DestDir: td}
+		skel, cleanup, err := u.emptySkeletonDir()
+		if err != nil {
+			t.Fatalf("haveTmp=%v: unexpected error: %v", haveTmp, err)
+		}
+		if filepath.Dir(skel) != "/tmp" {
+			t.Errorf("haveTmp=%v: skeleton directory %q isn't in /tmp", haveTmp, skel)
+		}
+		if st, err := os.Stat(filepath.Join(td, skel)); err != nil || !st.IsDir() {
+			t.Errorf("haveTmp=%v: skeleton directory %q missing: %v", haveTmp, skel, err)
+		}
+
+		cleanup()
+		entries, err := ioutil.ReadDir(td)
+		if err != nil {
+			t.Fatalf("reading root: %v", err)
+		}
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		var expected []string
+		if haveTmp {
+			expected = []string{"tmp"}
+		}
+		if !reflect.DeepEqual(expected, names) {
+			t.Errorf("haveTmp=%v: bad root contents after cleanup: want %v, got %v", haveTmp, expected, names)
+		}
+		if haveTmp {
+			if entries, err := ioutil.ReadDir(filepath.Join(td, "tmp")); err != nil || len(entries) != 0 {
+				t.Errorf("haveTmp=%v: skeleton directory not removed: %v %v", haveTmp, entries, err)
+			}
+		}
+	}
+}
+
+func TestEnsureUserMustExist(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("test requires root for chroot(), skipping")
+	}
+
+	td, err := tempBase()
+	if err != nil {
+		t.Fatalf("temp base error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	logger := log.New(true)
+	defer logger.Close()
+	u := Util{DestDir: td, Logger: &logger}
+
+	err = u.EnsureUser(types.PasswdUser{Name: "bar", MustExist: cutil.BoolToPtr(true)})
+	if err == nil {
+		t.Fatalf("expected error for missing user, got none")
+	}
+	if !strings.Contains(err.Error(), `"bar"`) {
+		t.Errorf("error doesn't name the user: %v", err)
+	}
+}
+
+func TestCheckUserGroups(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("test requires root for chroot(), skipping")
+	}
+
+	// foo stands in for a group created from the config by ensureGroups
+	td, err := tempBase()
+	if err != nil {
+		t.Fatalf("temp base error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	logger := log.New(true)
+	defer logger.Close()
+	u := Util{DestDir: td, Logger: &logger}
+
+	tests := []struct {
+		in   types.PasswdUser
+		fail bool
+	}{
+		{
+			in: types.PasswdUser{Name: "test"},
+		},
+		{
+			in: types.PasswdUser{Name: "test", PrimaryGroup: cutil.StrToPtr("foo")},
+		},
+		{
+			in: types.PasswdUser{Name: "test", PrimaryGroup: cutil.StrToPtr("4242"), Groups: []types.Group{"foo", "1001"}},
+		},
+		{
+			in:   types.PasswdUser{Name: "test", PrimaryGroup: cutil.StrToPtr("bar")},
+			fail: true,
+		},
+		{
+			in:   types.PasswdUser{Name: "test", Groups: []types.Group{"foo", "bar"}},
+			fail: true,
+		},
+	}
+
+	for i, test := range tests {
+		err := u.CheckUserGroups(test.in)
+		if test.fail && err == nil {
+			t.Errorf("#%d: expected error, got none", i)
+		} else if !test.fail && err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+		}
+	}
+}
+
+func TestGroupMembership(t *testing.T) {
+	groupFile := []byte("root:x:0:\nwheel:x:10:admin,core\nadm:x:4:admin\nmisc\n")
+	tests := []struct {
+		group  string
+		name   string
+		member bool
+	}{
+		{"wheel", "wheel", true},
+		{"10", "wheel", true},
+		{"adm", "adm", false},
+		{"root", "root", false},
+		{"docker", "", false},
+		{"misc", "", false},
+	}
+
+	for i, test := range tests {
+		name, member := groupMembership(groupFile, test.group, "core")
+		if name != test.name {
+			t.Errorf("#%d: bad name: want %q, got %q", i, test.name, name)
+		}
+		if member != test.member {
+			t.Errorf("#%d: bad membership: want %v, got %v", i, test.member, member)
+		}
+	}
+}
+
+func TestGshadowMembership(t *testing.T) {
+	gshadowFile := []byte("root:::\nwheel:!:core:admin\nadm:!::admin,core\nmisc\n")
+	tests := []struct {
+		name   string
+		member bool
+	}{
+		// administrators aren't members
+		{"wheel", false},
+		{"adm", true},
+		{"root", false},
+		{"docker", false},
+		{"misc", false},
+	}
+
+	for i, test := range tests {
+		member := gshadowMembership(gshadowFile, test.name, "core")
+		if member != test.member {
+			t.Errorf("#%d: bad membership: want %v, got %v", i, test.member, member)
+		}
+	}
+}
+
+func TestRemoveUserFromGroupsConflict(t *testing.T) {
+	td, err := tempBase()
+	if err != nil {
+		t.Fatalf("temp base error: %v", err)
+	}
+	defer os.RemoveAll(td)
+	if err := ioutil.WriteFile(filepath.Join(td, "etc/group"), []byte("wheel:x:10:core\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := log.New(true)
+	defer logger.Close()
+	u := Util{DestDir: td, Logger: &logger}
+
+	tests := []types.PasswdUser{
+		{Name: "core", Groups: []types.Group{"wheel"}, RemoveFromGroups: []types.Group{"10"}},
+		{Name: "core", Groups: []types.Group{"10"}, RemoveFromGroups: []types.Group{"wheel"}},
+		{Name: "core", PrimaryGroup: cutil.StrToPtr("10"), RemoveFromGroups: []types.Group{"wheel"}},
+	}
+	for i, test := range tests {
+		if err := u.RemoveUserFromGroups(test); err == nil {
+			t.Errorf("#%d: expected error, got none", i)
+		}
+	}
+}
+
+func TestRemoveUserFromGroups(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("test requires root for chroot(), skipping")
+	}
+	if _, err := exec.LookPath(distro.GpasswdCmd()); err != nil {
+		t.Skipf("%s not found, skipping", distro.GpasswdCmd())
+	}
+
+	td, err := tempBase()
+	if err != nil {
+		t.Fatalf("temp base error: %v", err)
+	}
+	defer os.RemoveAll(td)
+	groupPath := filepath.Join(td, "etc/group")
+	if err := ioutil.WriteFile(groupPath, []byte("foo:x:4242:\nwheel:x:10:admin,core\nadm:x:4:core\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// foo's membership is only listed in gshadow
+	gshadowPath := filepath.Join(td, "etc/gshadow")
+	if err := ioutil.WriteFile(gshadowPath, []byte("foo:!::core\nwheel:!::admin,core\nadm:!::core\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// gpasswd looks up the user running it
+	if err := ioutil.WriteFile(filepath.Join(td, "etc/passwd"), []byte("root:x:0:0::/root:/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := log.New(true)
+	defer logger.Close()
+	u := Util{DestDir: td, Logger: &logger}
+
+	// missing isn't a membership, and is skipped; removing twice is a
+	// no-op
+	c := types.PasswdUser{Name: "core", RemoveFromGroups: []types.Group{"wheel", "4", "foo", "missing"}}
+	for i := 0; i < 2; i++ {
+		if err := u.RemoveUserFromGroups(c); err != nil {
+			t.Fatalf("#%d: removing groups: %v", i, err)
+		}
+		groupFile, err := ioutil.ReadFile(groupPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := "foo:x:4242:\nwheel:x:10:admin\nadm:x:4:\n"
+		if string(groupFile) != expected {
+			t.Errorf("#%d: bad group file: want %q, got %q", i, expected, groupFile)
+		}
+		gshadowFile, err := ioutil.ReadFile(gshadowPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected = "foo:!::\nwheel:!::admin\nadm:!::\n"
+		if string(gshadowFile) != expected {
+			t.Errorf("#%d: bad gshadow file: want %q, got %q", i, expected, gshadowFile)
+		}
+	}
+}
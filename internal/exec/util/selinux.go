@@ -22,11 +22,14 @@ import (
 	"strings"
 
 	"github.com/coreos/ignition/v2/internal/distro"
+
+	"golang.org/x/sys/unix"
 )
 
 const (
 	selinuxConfig       = "/etc/selinux/config"
 	selinuxFileContexts = "contexts/files/file_contexts"
+	selinuxXattr        = "security.selinux"
 )
 
 var selinuxPolicy = ""
@@ -84,3 +87,13 @@ func (ut Util) RelabelFiles(patterns []string) error {
 	}
 	return nil
 }
+
+// SetSelinuxContext labels path with the given SELinux context, like
+// lsetfilecon(3). Symlinks are labeled rather than followed.
+func (ut Util) SetSelinuxContext(path, context string) error {
+	// the kernel expects the context to be NUL-terminated
+	if err := unix.Lsetxattr(path, selinuxXattr, append([]byte(context), 0), 0); err != nil {
+		return fmt.Errorf("setting SELinux context of %q to %q: %v", path, context, err)
+	}
+	return nil
+}
@@ -146,6 +146,30 @@ func (ut Util) IsUnitMasked(unit types.Unit) (bool, error) {
 	return true, nil
 }
 
+// LinkUnitToTarget creates a symlink to the unit in the target's dependency
+// directory (e.g. multi-user.target.wants for kind "wants") and returns the
+// path of the symlink without the sysroot prefix. systemd only considers
+// the name of the symlink, so units not written by Ignition are linked the
+// same way.
+func (ut Util) LinkUnitToTarget(unit types.Unit, target, kind string) (string, error) {
+	dir := fmt.Sprintf("%s.%s", target, kind)
+	path, err := ut.JoinPath(SystemdUnitsPath(), dir, unit.Name)
+	if err != nil {
+		return "", err
+	}
+
+	if err := MkdirForFile(path); err != nil {
+		return "", err
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return "", err
+	}
+	if err := os.Symlink(filepath.Join("/", SystemdUnitsPath(), unit.Name), path); err != nil {
+		return "", err
+	}
+	return filepath.Join("/", SystemdUnitsPath(), dir, unit.Name), nil
+}
+
 func (ut Util) EnableUnit(enabledUnit string) error {
 	return ut.appendLineToPreset(fmt.Sprintf("enable %s", enabledUnit))
 }
@@ -0,0 +1,138 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+func TestEnableUnitInstance(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ign-unit-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	logger := log.New(true)
+	defer logger.Close()
+	u := Util{DestDir: tmp, Logger: &logger}
+
+	if err := u.EnableUnit("getty@.service tty1 tty2"); err != nil {
+		t.Fatalf("failed to enable unit: %v", err)
+	}
+	if err := u.DisableUnit("echo@.service foo"); err != nil {
+		t.Fatalf("failed to disable unit: %v", err)
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(tmp, PresetPath))
+	if err != nil {
+		t.Fatalf("failed to read preset: %v", err)
+	}
+	expected := "enable getty@.service tty1 tty2\ndisable echo@.service foo\n"
+	if string(contents) != expected {
+		t.Errorf("bad preset: want %q, got %q", expected, string(contents))
+	}
+}
+
+func TestMaskUnitInstance(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ign-unit-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	logger := log.New(true)
+	defer logger.Close()
+	u := Util{DestDir: tmp, Logger: &logger}
+	unit := types.Unit{Name: "getty@tty1.service"}
+
+	path, err := u.MaskUnit(unit)
+	if err != nil {
+		t.Fatalf("failed to mask unit: %v", err)
+	}
+	if path != "/etc/systemd/system/getty@tty1.service" {
+		t.Errorf("bad path: want %q, got %q", "/etc/systemd/system/getty@tty1.service", path)
+	}
+	target, err := os.Readlink(filepath.Join(tmp, path))
+	if err != nil {
+		t.Fatalf("failed to read symlink: %v", err)
+	}
+	if target != "/dev/null" {
+		t.Errorf("bad symlink target: want %q, got %q", "/dev/null", target)
+	}
+	if masked, err := u.IsUnitMasked(unit); err != nil || !masked {
+		t.Errorf("expected unit to be masked, got %v, %v", masked, err)
+	}
+	// other instances of the template are unaffected
+	if masked, err := u.IsUnitMasked(types.Unit{Name: "getty@tty2.service"}); err != nil || masked {
+		t.Errorf("expected other instance to be unmasked, got %v, %v", masked, err)
+	}
+
+	if err := u.UnmaskUnit(unit); err != nil {
+		t.Fatalf("failed to unmask unit: %v", err)
+	}
+	if masked, err := u.IsUnitMasked(unit); err != nil || masked {
+		t.Errorf("expected unit to be unmasked, got %v, %v", masked, err)
+	}
+}
+
+func TestLinkUnitToTarget(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ign-unit-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	logger := log.New(true)
+	defer logger.Close()
+	u := Util{DestDir: tmp, Logger: &logger}
+	unit := types.Unit{Name: "foo.service"}
+
+	tests := []struct {
+		target string
+		kind   string
+		out    string
+	}{
+		{"multi-user.target", "wants", "/etc/systemd/system/multi-user.target.wants/foo.service"},
+		{"local-fs.target", "requires", "/etc/systemd/system/local-fs.target.requires/foo.service"},
+		// relinking is idempotent
+		{"multi-user.target", "wants", "/etc/systemd/system/multi-user.target.wants/foo.service"},
+	}
+
+	for i, test := range tests {
+		path, err := u.LinkUnitToTarget(unit, test.target, test.kind)
+		if err != nil {
+			t.Errorf("#%d: failed to link unit: %v", i, err)
+			continue
+		}
+		if path != test.out {
+			t.Errorf("#%d: bad path: want %q, got %q", i, test.out, path)
+		}
+		target, err := os.Readlink(filepath.Join(tmp, path))
+		if err != nil {
+			t.Errorf("#%d: failed to read symlink: %v", i, err)
+			continue
+		}
+		if target != "/etc/systemd/system/foo.service" {
+			t.Errorf("#%d: bad symlink target: want %q, got %q", i, "/etc/systemd/system/foo.service", target)
+		}
+	}
+}
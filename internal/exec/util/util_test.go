@@ -0,0 +1,88 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJoinPathStaysInRoot(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ign-util-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	root := filepath.Join(tmp, "root")
+	if err := os.MkdirAll(filepath.Join(root, "dir"), 0755); err != nil {
+		t.Fatalf("failed to create root: %v", err)
+	}
+	links := map[string]string{
+		"abs":    "/etc",
+		"rel":    "../../../..",
+		"dir/up": "../../..",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatalf("failed to create symlink: %v", err)
+		}
+	}
+
+	tests := []struct {
+		in  []string
+		out string
+	}{
+		{
+			in:  []string{"/etc/passwd"},
+			out: "/etc/passwd",
+		},
+		{
+			in:  []string{"/abs/passwd"},
+			out: "/etc/passwd",
+		},
+		{
+			in:  []string{"/rel/etc/passwd"},
+			out: "/etc/passwd",
+		},
+		{
+			in:  []string{"/dir/up/etc/passwd"},
+			out: "/etc/passwd",
+		},
+		{
+			in:  []string{"/dir", "up", "up2", "passwd"},
+			out: "/up2/passwd",
+		},
+		// the last component is never followed
+		{
+			in:  []string{"/abs"},
+			out: "/abs",
+		},
+	}
+
+	u := Util{DestDir: root}
+	for i, test := range tests {
+		out, err := u.JoinPath(test.in...)
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if want := filepath.Join(root, test.out); out != want {
+			t.Errorf("#%d: bad path: want %q, got %q", i, want, out)
+		}
+	}
+}
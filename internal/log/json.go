@@ -0,0 +1,60 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// JSON implements LoggerOps by writing one JSON object per line, so that
+// provisioning logs can be parsed by platform tooling.
+type JSON struct {
+	Writer io.Writer
+	Stage  string
+}
+
+type jsonEntry struct {
+	Timestamp string `json:"timestamp"`
+	Level     string `json:"level"`
+	Stage     string `json:"stage,omitempty"`
+	Message   string `json:"message"`
+}
+
+func (j JSON) write(level, msg string) error {
+	b, err := json.Marshal(jsonEntry{
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		Level:     level,
+		Stage:     j.Stage,
+		Message:   msg,
+	})
+	if err != nil {
+		return err
+	}
+	// write the entry in a single call so concurrent entries don't interleave
+	_, err = j.Writer.Write(append(b, '\n'))
+	return err
+}
+
+func (j JSON) Emerg(msg string) error   { return j.write("emerg", msg) }
+func (j JSON) Alert(msg string) error   { return j.write("alert", msg) }
+func (j JSON) Crit(msg string) error    { return j.write("crit", msg) }
+func (j JSON) Err(msg string) error     { return j.write("err", msg) }
+func (j JSON) Warning(msg string) error { return j.write("warning", msg) }
+func (j JSON) Notice(msg string) error  { return j.write("notice", msg) }
+func (j JSON) Info(msg string) error    { return j.write("info", msg) }
+func (j JSON) Debug(msg string) error   { return j.write("debug", msg) }
+func (j JSON) Close() error             { return nil }
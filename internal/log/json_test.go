@@ -0,0 +1,66 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{ops: JSON{Writer: &buf, Stage: "files"}}
+	logger.Info("hello %s", "world")
+	logger.PushPrefix("op(1)")
+	logger.Err("quote \" and newline\n")
+	logger.PopPrefix()
+	logger.Debug("done")
+
+	want := []struct {
+		level   string
+		message string
+	}{
+		{"info", "hello world"},
+		{"err", "op(1): quote \" and newline\n"},
+		{"debug", "done"},
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("bad number of lines: want %d, got %d: %q", len(want), len(lines), buf.String())
+	}
+	for i, line := range lines {
+		var entry map[string]string
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Errorf("#%d: invalid JSON %q: %v", i, line, err)
+			continue
+		}
+		if entry["level"] != want[i].level {
+			t.Errorf("#%d: bad level: want %q, got %q", i, want[i].level, entry["level"])
+		}
+		if entry["message"] != want[i].message {
+			t.Errorf("#%d: bad message: want %q, got %q", i, want[i].message, entry["message"])
+		}
+		if entry["stage"] != "files" {
+			t.Errorf("#%d: bad stage: want %q, got %q", i, "files", entry["stage"])
+		}
+		if _, err := time.Parse(time.RFC3339Nano, entry["timestamp"]); err != nil {
+			t.Errorf("#%d: bad timestamp %q: %v", i, entry["timestamp"], err)
+		}
+	}
+}
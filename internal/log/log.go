@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"fmt"
 	"log/syslog"
+	"os"
 	"os/exec"
 	"strings"
 
@@ -41,6 +42,7 @@ type Logger struct {
 	ops           LoggerOps
 	prefixStack   []string
 	opSequenceNum int
+	dryRun        bool
 }
 
 // New creates a new logger.
@@ -61,11 +63,34 @@ func New(logToStdout bool) Logger {
 	return logger
 }
 
+// NewJSON creates a new logger which writes newline-delimited JSON entries,
+// tagged with the given stage, to stdout.
+func NewJSON(stage string) Logger {
+	return Logger{
+		ops: JSON{
+			Writer: os.Stdout,
+			Stage:  stage,
+		},
+	}
+}
+
 // Close closes the logger.
 func (l Logger) Close() {
 	l.ops.Close()
 }
 
+// SetDryRun toggles dry-run mode. In dry-run mode, LogCmd logs the commands
+// it would run instead of running them, and stages skip any other changes to
+// the system.
+func (l *Logger) SetDryRun(dryRun bool) {
+	l.dryRun = dryRun
+}
+
+// DryRun returns whether the logger is in dry-run mode.
+func (l Logger) DryRun() bool {
+	return l.dryRun
+}
+
 // Emerg logs a message at emergency priority.
 func (l Logger) Emerg(format string, a ...interface{}) {
 	l.log(l.ops.Emerg, format, a...)
@@ -138,8 +163,16 @@ func QuotedCmd(cmd *exec.Cmd) string {
 
 // LogCmd runs and logs the supplied cmd as an operation with distinct start/finish/fail log messages uniformly combined with the supplied format string.
 // The exact command path and arguments being executed are also logged for debugging assistance.
+// In dry-run mode the command is only logged and is assumed to succeed.
 func (l *Logger) LogCmd(cmd *exec.Cmd, format string, a ...interface{}) (int, error) {
 	code := -1
+	if l.dryRun {
+		f := func() error {
+			l.Info("dry run: would execute: %s", QuotedCmd(cmd))
+			return nil
+		}
+		return 0, l.LogOp(f, format, a...)
+	}
 	f := func() error {
 		cmdLine := QuotedCmd(cmd)
 		l.Debug("executing: %s", cmdLine)
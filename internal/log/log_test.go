@@ -0,0 +1,53 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestLogCmdDryRun(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ignition-log-test")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+	path := filepath.Join(tmp, "file")
+
+	logger := New(true)
+	logger.SetDryRun(true)
+	code, err := logger.LogCmd(exec.Command("touch", path), "touching %q", path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if code != 0 {
+		t.Errorf("bad exit code: want 0, got %d", code)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("command ran in dry-run mode: stat returned %v", err)
+	}
+
+	logger.SetDryRun(false)
+	if _, err := logger.LogCmd(exec.Command("touch", path), "touching %q", path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("command didn't run: %v", err)
+	}
+}
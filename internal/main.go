@@ -35,7 +35,9 @@ import (
 	_ "github.com/coreos/ignition/v2/internal/exec/stages/umount"
 	"github.com/coreos/ignition/v2/internal/log"
 	"github.com/coreos/ignition/v2/internal/platform"
+	"github.com/coreos/ignition/v2/internal/resource"
 	"github.com/coreos/ignition/v2/internal/state"
+	"github.com/coreos/ignition/v2/internal/util"
 	"github.com/coreos/ignition/v2/internal/version"
 	"github.com/spf13/pflag"
 )
@@ -52,7 +54,11 @@ func main() {
 func ignitionMain() {
 	flags := struct {
 		configCache  string
+		dryRun       bool
 		fetchTimeout time.Duration
+		logFormat    string
+		maxConfig    int64
+		maxTFTP      int64
 		needNet      string
 		platform     platform.Name
 		root         string
@@ -63,7 +69,10 @@ func ignitionMain() {
 	}{}
 
 	flag.StringVar(&flags.configCache, "config-cache", "/run/ignition.json", "where to cache the config")
+	flag.BoolVar(&flags.dryRun, "dry-run", false, "log the changes the stage would make without making them")
 	flag.DurationVar(&flags.fetchTimeout, "fetch-timeout", exec.DefaultFetchTimeout, "initial duration for which to wait for config")
+	flag.Int64Var(&flags.maxConfig, "max-config-size", exec.DefaultMaxConfigSize, "maximum size in bytes of fetched configs and other resources read into memory, or 0 for no limit")
+	flag.Int64Var(&flags.maxTFTP, "max-tftp-size", exec.DefaultMaxTFTPSize, "maximum size in bytes of resources fetched over TFTP, or 0 for no limit")
 	flag.StringVar(&flags.needNet, "neednet", "/run/ignition/neednet", "flag file to write from fetch-offline if networking is needed")
 	flag.Var(&flags.platform, "platform", fmt.Sprintf("current platform. %v", platform.Names()))
 	flag.StringVar(&flags.root, "root", "/", "root of the filesystem")
@@ -71,6 +80,7 @@ func ignitionMain() {
 	flag.StringVar(&flags.stateFile, "state-file", "/run/ignition/state", "where to store internal state")
 	flag.BoolVar(&flags.version, "version", false, "print the version and exit")
 	flag.BoolVar(&flags.logToStdout, "log-to-stdout", false, "log to stdout instead of the system log when set")
+	flag.StringVar(&flags.logFormat, "log-format", "text", "log format: text, or json to write newline-delimited JSON entries to stdout")
 
 	flag.Parse()
 
@@ -89,8 +99,18 @@ func ignitionMain() {
 		os.Exit(2)
 	}
 
-	logger := log.New(flags.logToStdout)
+	var logger log.Logger
+	switch flags.logFormat {
+	case "text":
+		logger = log.New(flags.logToStdout)
+	case "json":
+		logger = log.NewJSON(flags.stage.String())
+	default:
+		fmt.Fprintf(os.Stderr, "unknown log format %q\n", flags.logFormat)
+		os.Exit(2)
+	}
 	defer logger.Close()
+	logger.SetDryRun(flags.dryRun)
 
 	logger.Info(version.String)
 	logger.Info("Stage: %v", flags.stage)
@@ -101,6 +121,9 @@ func ignitionMain() {
 		logger.Crit("failed to generate fetcher: %s", err)
 		os.Exit(3)
 	}
+	fetcher.Cache = resource.NewCache()
+	fetcher.MaxBufferSize = flags.maxConfig
+	fetcher.MaxTFTPSize = flags.maxTFTP
 	state, err := state.Load(flags.stateFile)
 	if err != nil {
 		logger.Crit("reading state: %s", err)
@@ -125,6 +148,10 @@ func ignitionMain() {
 		logger.Crit("Ignition failed: %v", err.Error())
 		os.Exit(1)
 	}
+	if flags.dryRun {
+		logger.Info("Ignition dry run finished successfully")
+		return
+	}
 	if err := engine.State.Save(flags.stateFile); err != nil {
 		logger.Crit("writing state: %v", err)
 		os.Exit(1)
@@ -134,11 +161,15 @@ func ignitionMain() {
 
 func ignitionApplyMain() {
 	printVersion := false
+	logFormat := ""
 	flags := apply.Flags{}
 	pflag.BoolVar(&printVersion, "version", false, "print the version of ignition-apply")
 	pflag.StringVar(&flags.Root, "root", "/", "root of the filesystem")
 	pflag.BoolVar(&flags.IgnoreUnsupported, "ignore-unsupported", false, "ignore unsupported config sections")
 	pflag.BoolVar(&flags.Offline, "offline", false, "error out if config references remote resources")
+	pflag.BoolVar(&flags.DryRun, "dry-run", false, "log the changes that would be made without making them")
+	pflag.StringVar(&flags.Platform, "platform", "", "apply entries restricted to this platform; entries restricted to other platforms are always skipped")
+	pflag.StringVar(&logFormat, "log-format", "text", "log format: text or json")
 	pflag.Usage = func() {
 		fmt.Fprintf(pflag.CommandLine.Output(), "Usage: %s [options] config.ign\n", os.Args[0])
 		fmt.Fprintf(pflag.CommandLine.Output(), "Options:\n")
@@ -157,7 +188,16 @@ func ignitionApplyMain() {
 	}
 	cfgArg := pflag.Arg(0)
 
-	logger := log.New(true)
+	var logger log.Logger
+	switch logFormat {
+	case "text":
+		logger = log.New(true)
+	case "json":
+		logger = log.NewJSON("")
+	default:
+		fmt.Fprintf(os.Stderr, "unknown log format %q\n", logFormat)
+		os.Exit(1)
+	}
 	defer logger.Close()
 
 	logger.Info(version.String)
@@ -175,6 +215,12 @@ func ignitionApplyMain() {
 		os.Exit(1)
 	}
 
+	blob, err = util.GunzipIfCompressed(blob, exec.DefaultMaxConfigSize)
+	if err != nil {
+		logger.Crit("couldn't decompress config: %v", err)
+		os.Exit(1)
+	}
+
 	cfg, rpt, err := config.Parse(blob)
 	logger.LogReport(rpt)
 	if rpt.IsFatal() || err != nil {
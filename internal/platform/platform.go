@@ -17,6 +17,7 @@ package platform
 import (
 	"fmt"
 
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
 	"github.com/coreos/ignition/v2/internal/log"
 	"github.com/coreos/ignition/v2/internal/providers"
 	"github.com/coreos/ignition/v2/internal/providers/aliyun"
@@ -35,28 +36,41 @@ import (
 	"github.com/coreos/ignition/v2/internal/providers/packet"
 	"github.com/coreos/ignition/v2/internal/providers/powervs"
 	"github.com/coreos/ignition/v2/internal/providers/qemu"
+	"github.com/coreos/ignition/v2/internal/providers/util"
 	"github.com/coreos/ignition/v2/internal/providers/virtualbox"
 	"github.com/coreos/ignition/v2/internal/providers/vmware"
 	"github.com/coreos/ignition/v2/internal/providers/vultr"
 	"github.com/coreos/ignition/v2/internal/providers/zvm"
 	"github.com/coreos/ignition/v2/internal/registry"
 	"github.com/coreos/ignition/v2/internal/resource"
+
+	"github.com/coreos/vcontext/report"
 )
 
 // Config represents a set of options that map to a particular platform.
 type Config struct {
 	name       string
 	fetch      providers.FuncFetchConfig
+	sources    []providers.Source
 	init       providers.FuncInit
 	newFetcher providers.FuncNewFetcher
 	status     providers.FuncPostStatus
+	metadata   providers.FuncFetchMetadata
 }
 
 func (c Config) Name() string {
 	return c.name
 }
 
+// FetchFunc returns the platform's config fetcher. Platforms registered
+// with a list of sources instead of a fetch function get one which tries
+// each source in order of priority.
 func (c Config) FetchFunc() providers.FuncFetchConfig {
+	if c.fetch == nil && len(c.sources) > 0 {
+		return func(f *resource.Fetcher) (types.Config, report.Report, error) {
+			return util.FetchConfigFromSources(f, c.sources)
+		}
+	}
 	return c.fetch
 }
 
@@ -84,6 +98,18 @@ func (c Config) InitFunc() providers.FuncInit {
 	}
 }
 
+// MetadataFunc returns a function that fetches the instance metadata
+// available to templated files. Platforms without a metadata service
+// provide none.
+func (c Config) MetadataFunc() providers.FuncFetchMetadata {
+	if c.metadata != nil {
+		return c.metadata
+	}
+	return func(f *resource.Fetcher) (map[string]string, error) {
+		return map[string]string{}, nil
+	}
+}
+
 // Status takes a Fetcher and the error from Run (from engine)
 func (c Config) Status(stageName string, f resource.Fetcher, statusErr error) error {
 	if c.status != nil {
@@ -104,6 +130,7 @@ func init() {
 		fetch:      aws.FetchConfig,
 		init:       aws.Init,
 		newFetcher: aws.NewFetcher,
+		metadata:   aws.FetchMetadata,
 	})
 	configs.Register(Config{
 		name:  "azure",
@@ -122,8 +149,8 @@ func init() {
 		fetch: cloudstack.FetchConfig,
 	})
 	configs.Register(Config{
-		name:  "digitalocean",
-		fetch: digitalocean.FetchConfig,
+		name:    "digitalocean",
+		sources: digitalocean.Sources,
 	})
 	configs.Register(Config{
 		name:  "exoscale",
@@ -134,8 +161,9 @@ func init() {
 		fetch: file.FetchConfig,
 	})
 	configs.Register(Config{
-		name:  "gcp",
-		fetch: gcp.FetchConfig,
+		name:     "gcp",
+		fetch:    gcp.FetchConfig,
+		metadata: gcp.FetchMetadata,
 	})
 	configs.Register(Config{
 		name:  "ibmcloud",
@@ -0,0 +1,35 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+)
+
+// The config spec validates platform names without access to the registry,
+// so make sure its list doesn't drift from the registered platforms.
+func TestKnownPlatforms(t *testing.T) {
+	names := Names()
+	known := append([]string{}, types.KnownPlatforms...)
+	sort.Strings(names)
+	sort.Strings(known)
+	if !reflect.DeepEqual(names, known) {
+		t.Errorf("bad known platforms: want %v, got %v", names, known)
+	}
+}
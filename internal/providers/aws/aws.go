@@ -40,6 +40,11 @@ var (
 		Host:   "169.254.169.254",
 		Path:   "2019-10-01/user-data",
 	}
+	metadataURL = url.URL{
+		Scheme: "http",
+		Host:   "169.254.169.254",
+		Path:   "2019-10-01/meta-data/",
+	}
 	imdsTokenURL = url.URL{
 		Scheme: "http",
 		Host:   "169.254.169.254",
@@ -54,7 +59,7 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 		return types.Config{}, report.Report{}, err
 	}
 
-	return util.ParseConfig(f.Logger, data)
+	return util.ParseConfig(f.Logger, data, f.MaxBufferSize)
 }
 
 func NewFetcher(l *log.Logger) (resource.Fetcher, error) {
@@ -70,6 +75,26 @@ func NewFetcher(l *log.Logger) (resource.Fetcher, error) {
 	}, nil
 }
 
+// FetchMetadata returns the instance's hostname and ID for use in
+// templated files.
+func FetchMetadata(f *resource.Fetcher) (map[string]string, error) {
+	paths := map[string]string{
+		"hostname":    "local-hostname",
+		"instance_id": "instance-id",
+	}
+	metadata := make(map[string]string)
+	for name, path := range paths {
+		u := metadataURL
+		u.Path += path
+		value, err := fetchFromAWSMetadata(u, resource.FetchOptions{}, f)
+		if err != nil {
+			return nil, err
+		}
+		metadata[name] = string(value)
+	}
+	return metadata, nil
+}
+
 // Init prepares the fetcher for this platform
 func Init(f *resource.Fetcher) error {
 	// During the fetch stage we might be running before the networking
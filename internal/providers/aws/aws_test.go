@@ -0,0 +1,87 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/resource"
+)
+
+func TestFetchFromAWSMetadata(t *testing.T) {
+	const token = "AQAEAFq7VSAMPLE"
+	const userdata = `{"ignition": {"version": "3.3.0"}}`
+
+	tests := []struct {
+		// whether the server supports IMDSv2
+		v2 bool
+		// whether the server rejects requests without a token
+		requireToken bool
+	}{
+		{v2: true, requireToken: true},
+		{v2: true, requireToken: false},
+		{v2: false, requireToken: false},
+	}
+
+	for i, test := range tests {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/latest/api/token", func(w http.ResponseWriter, r *http.Request) {
+			if !test.v2 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.Method != http.MethodPut || r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(token))
+		})
+		mux.HandleFunc("/2019-10-01/user-data", func(w http.ResponseWriter, r *http.Request) {
+			got := r.Header.Get("X-aws-ec2-metadata-token")
+			if (test.requireToken && got != token) || (!test.v2 && got != "") {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(userdata))
+		})
+		server := httptest.NewServer(mux)
+
+		base, err := url.Parse(server.URL)
+		if err != nil {
+			t.Fatalf("parsing URL: %v", err)
+		}
+		imdsTokenURL = *base
+		imdsTokenURL.Path = "/latest/api/token"
+		u := *base
+		u.Path = "/2019-10-01/user-data"
+
+		logger := log.New(true)
+		f := resource.Fetcher{Logger: &logger}
+		data, err := fetchFromAWSMetadata(u, resource.FetchOptions{}, &f)
+		server.Close()
+
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if string(data) != userdata {
+			t.Errorf("#%d: bad user-data: want %q, got %q", i, userdata, string(data))
+		}
+	}
+}
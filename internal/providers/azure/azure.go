@@ -17,6 +17,8 @@
 package azure
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -28,6 +30,7 @@ import (
 	"github.com/coreos/ignition/v2/internal/log"
 	"github.com/coreos/ignition/v2/internal/providers/util"
 	"github.com/coreos/ignition/v2/internal/resource"
+	ut "github.com/coreos/ignition/v2/internal/util"
 
 	"github.com/coreos/vcontext/report"
 	"golang.org/x/sys/unix"
@@ -82,7 +85,11 @@ func FetchFromOvfDevice(f *resource.Fetcher, ovfFsTypes []string) (types.Config,
 					if err != nil {
 						logger.Debug("failed to retrieve config from device %q: %v", dev, err)
 					} else {
-						return util.ParseConfig(logger, rawConfig)
+						rawConfig, err = decodeCustomData(rawConfig)
+						if err != nil {
+							return types.Config{}, report.Report{}, err
+						}
+						return util.ParseConfig(logger, rawConfig, f.MaxBufferSize)
 					}
 				}
 				checkedDevices[dev] = struct{}{}
@@ -131,6 +138,37 @@ func getRawConfig(f *resource.Fetcher, devicePath string, fstype string) ([]byte
 	return rawConfig, nil
 }
 
+// decodeCustomData returns the config contained in Azure custom data. The
+// custom data is usually the config itself, but some provisioning paths
+// deliver it base64-encoded, with or without padding. A config always starts
+// with "{" and gzipped data with its magic number, neither of which is in
+// the base64 alphabet, so they can't be confused with encoded data and are
+// returned as is.
+func decodeCustomData(data []byte) ([]byte, error) {
+	if ut.IsGzipped(data) {
+		return data, nil
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] == '{' {
+		return data, nil
+	}
+
+	// drop line breaks and padding so both padded and unpadded data decode
+	encoded := bytes.Map(func(r rune) rune {
+		switch r {
+		case '\r', '\n', ' ', '\t', '=':
+			return -1
+		}
+		return r
+	}, trimmed)
+	decoded := make([]byte, base64.RawStdEncoding.DecodedLen(len(encoded)))
+	n, err := base64.RawStdEncoding.Decode(decoded, encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode base64 custom data: %v", err)
+	}
+	return decoded[:n], nil
+}
+
 // isCdromPresent verifies if the given config drive is CD-ROM
 func isCdromPresent(logger *log.Logger, devicePath string) bool {
 	logger.Debug("opening config device: %q", devicePath)
@@ -0,0 +1,95 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+)
+
+func TestDecodeCustomData(t *testing.T) {
+	const config = `{"ignition": {"version": "3.3.0"}}`
+	var gzipped bytes.Buffer
+	w := gzip.NewWriter(&gzipped)
+	if _, err := w.Write([]byte(config)); err != nil {
+		t.Fatalf("compressing config: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("compressing config: %v", err)
+	}
+	type out struct {
+		data string
+		fail bool
+	}
+	tests := []struct {
+		in  string
+		out out
+	}{
+		{
+			in:  config,
+			out: out{data: config},
+		},
+		{
+			in:  "\n" + config + "\n",
+			out: out{data: "\n" + config + "\n"},
+		},
+		{
+			in:  "",
+			out: out{data: ""},
+		},
+		// padded
+		{
+			in:  "eyJpZ25pdGlvbiI6IHsidmVyc2lvbiI6ICIzLjMuMCJ9fQ==",
+			out: out{data: config},
+		},
+		// unpadded, with line breaks
+		{
+			in:  "eyJpZ25pdGlvbiI6IHsidmVyc2lv\r\nbiI6ICIzLjMuMCJ9fQ\n",
+			out: out{data: config},
+		},
+		// raw gzip is decompressed later, not decoded
+		{
+			in:  gzipped.String(),
+			out: out{data: gzipped.String()},
+		},
+		// malformed
+		{
+			in:  "eyJpZ25pdGlvbiI6!!!",
+			out: out{fail: true},
+		},
+		{
+			in:  "e",
+			out: out{fail: true},
+		},
+	}
+
+	for i, test := range tests {
+		data, err := decodeCustomData([]byte(test.in))
+		if test.out.fail {
+			if err == nil {
+				t.Errorf("#%d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if string(data) != test.out.data {
+			t.Errorf("#%d: bad data: want %q, got %q", i, test.out.data, string(data))
+		}
+	}
+}
@@ -104,7 +104,7 @@ Loop:
 		}
 	}
 
-	return util.ParseConfig(f.Logger, data)
+	return util.ParseConfig(f.Logger, data, f.MaxBufferSize)
 }
 
 func fileExists(path string) bool {
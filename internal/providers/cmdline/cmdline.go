@@ -13,13 +13,18 @@
 // limitations under the License.
 
 // The cmdline provider fetches a remote configuration from the URL specified
-// in the kernel boot option "ignition.config.url".
+// in the kernel boot option "ignition.config.url". A file:// URL reads the
+// configuration from the local filesystem instead.
 
 package cmdline
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
@@ -28,6 +33,7 @@ import (
 	"github.com/coreos/ignition/v2/internal/providers"
 	"github.com/coreos/ignition/v2/internal/providers/util"
 	"github.com/coreos/ignition/v2/internal/resource"
+	ut "github.com/coreos/ignition/v2/internal/util"
 
 	"github.com/coreos/vcontext/report"
 )
@@ -36,6 +42,11 @@ const (
 	cmdlineUrlFlag = "ignition.config.url"
 )
 
+var (
+	ErrRelativeFileURL = errors.New("file URLs must contain an absolute path")
+	ErrFileURLHost     = errors.New("file URLs must not specify a host")
+)
+
 func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 	url, err := readCmdline(f.Logger)
 	if err != nil {
@@ -46,12 +57,48 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 		return types.Config{}, report.Report{}, providers.ErrNoProvider
 	}
 
-	data, err := f.FetchToBuffer(*url, resource.FetchOptions{})
+	data, err := fetch(f, *url)
+	if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+
+	keyring, err := ut.ConfigSigningKeyring()
 	if err != nil {
 		return types.Config{}, report.Report{}, err
 	}
+	if keyring != "" {
+		if err := verifySignature(f, *url, keyring, data); err != nil {
+			return types.Config{}, report.Report{}, err
+		}
+	}
+
+	return util.ParseConfig(f.Logger, data, f.MaxBufferSize)
+}
+
+func fetch(f *resource.Fetcher, u url.URL) ([]byte, error) {
+	if u.Scheme == "file" {
+		return readLocalConfig(u)
+	}
+	return f.FetchToBuffer(u, resource.FetchOptions{})
+}
 
-	return util.ParseConfig(f.Logger, data)
+// verifySignature fetches the detached signature stored alongside the config
+// at u and checks it against keyring.
+func verifySignature(f *resource.Fetcher, u url.URL, keyring string, data []byte) error {
+	sigURL := ut.SignatureURL(u)
+	sig, err := fetch(f, sigURL)
+	if err == resource.ErrNotFound || errors.Is(err, os.ErrNotExist) {
+		f.Logger.Crit("config signature %s not found", sigURL.String())
+		return ut.ErrSignatureMissing
+	} else if err != nil {
+		return err
+	}
+	if err := ut.VerifySignature(keyring, data, sig); err != nil {
+		f.Logger.Crit("failed to verify config signature: %v", err)
+		return err
+	}
+	f.Logger.Info("verified config signature using keyring %q", keyring)
+	return nil
 }
 
 func readCmdline(logger *log.Logger) (*url.URL, error) {
@@ -77,6 +124,24 @@ func readCmdline(logger *log.Logger) (*url.URL, error) {
 	return url, err
 }
 
+// readLocalConfig reads the config referenced by a file:// URL.
+func readLocalConfig(u url.URL) ([]byte, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, ErrFileURLHost
+	}
+	// file:relative/path parses into Opaque rather than Path
+	if u.Opaque != "" || !filepath.IsAbs(u.Path) {
+		return nil, ErrRelativeFileURL
+	}
+	data, err := ioutil.ReadFile(u.Path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("config file %q does not exist: %w", u.Path, os.ErrNotExist)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read config file %q: %v", u.Path, err)
+	}
+	return data, nil
+}
+
 func parseCmdline(cmdline []byte) (url string) {
 	for _, arg := range strings.Split(string(cmdline), " ") {
 		parts := strings.SplitN(strings.TrimSpace(arg), "=", 2)
@@ -0,0 +1,104 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdline
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadLocalConfig(t *testing.T) {
+	const config = `{"ignition": {"version": "3.3.0"}}`
+	tmp, err := ioutil.TempDir("", "ign-cmdline-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+	path := filepath.Join(tmp, "ignition.json")
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	type out struct {
+		data string
+		err  error
+		fail bool
+	}
+	tests := []struct {
+		in  string
+		out out
+	}{
+		{
+			in:  "file://" + path,
+			out: out{data: config},
+		},
+		{
+			in:  "file://localhost" + path,
+			out: out{data: config},
+		},
+		{
+			in:  "file:ignition.json",
+			out: out{err: ErrRelativeFileURL},
+		},
+		{
+			in:  "file://boot/ignition.json",
+			out: out{err: ErrFileURLHost},
+		},
+		{
+			in:  "file://" + filepath.Join(tmp, "missing.json"),
+			out: out{fail: true},
+		},
+	}
+
+	for i, test := range tests {
+		u, err := url.Parse(test.in)
+		if err != nil {
+			t.Fatalf("#%d: parsing URL: %v", i, err)
+		}
+		data, err := readLocalConfig(*u)
+		if test.out.fail {
+			if err == nil {
+				t.Errorf("#%d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if string(data) != test.out.data {
+			t.Errorf("#%d: bad data: want %q, got %q", i, test.out.data, string(data))
+		}
+	}
+}
+
+func TestParseCmdline(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{in: "root=/dev/sda1 quiet", out: ""},
+		{in: "ignition.config.url=file:///boot/ignition.json", out: "file:///boot/ignition.json"},
+		{in: "quiet ignition.config.url=http://example.com/config.ign ro\n", out: "http://example.com/config.ign"},
+	}
+
+	for i, test := range tests {
+		if url := parseCmdline([]byte(test.in)); url != test.out {
+			t.Errorf("#%d: bad url: want %q, got %q", i, test.out, url)
+		}
+	}
+}
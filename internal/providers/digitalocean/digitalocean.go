@@ -13,18 +13,17 @@
 // limitations under the License.
 
 // The digitalocean provider fetches a remote configuration from the
-// digitalocean user-data metadata service URL.
+// digitalocean user-data metadata service URL, falling back to vendor-data.
+// Either may hold a cloud-init payload instead, which is ignored.
 
 package digitalocean
 
 import (
+	"bytes"
 	"net/url"
 
-	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
-	"github.com/coreos/ignition/v2/internal/providers/util"
+	"github.com/coreos/ignition/v2/internal/providers"
 	"github.com/coreos/ignition/v2/internal/resource"
-
-	"github.com/coreos/vcontext/report"
 )
 
 var (
@@ -33,13 +32,49 @@ var (
 		Host:   "169.254.169.254",
 		Path:   "metadata/v1/user-data",
 	}
+	vendordataUrl = url.URL{
+		Scheme: "http",
+		Host:   "169.254.169.254",
+		Path:   "metadata/v1/vendor-data",
+	}
+
+	Sources = []providers.Source{
+		{
+			Name:     "user-data",
+			Priority: 0,
+			Fetch:    func(f *resource.Fetcher) ([]byte, error) { return fetchIgnition(f, userdataUrl) },
+		},
+		{
+			Name:     "vendor-data",
+			Priority: 1,
+			Fetch:    func(f *resource.Fetcher) ([]byte, error) { return fetchIgnition(f, vendordataUrl) },
+		},
+	}
 )
 
-func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
-	data, err := f.FetchToBuffer(userdataUrl, resource.FetchOptions{})
-	if err != nil {
-		return types.Config{}, report.Report{}, err
+// fetchIgnition fetches u, returning nothing if it's missing or holds a
+// cloud-init payload. Other failures are returned, so they aren't mistaken
+// for missing user-data.
+func fetchIgnition(f *resource.Fetcher, u url.URL) ([]byte, error) {
+	data, err := f.FetchToBuffer(u, resource.FetchOptions{})
+	if err == resource.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
 	}
+	if isCloudInit(data) {
+		f.Logger.Info("ignoring cloud-init data at %s", u.String())
+		return nil, nil
+	}
+	return data, nil
+}
 
-	return util.ParseConfig(f.Logger, data)
+// isCloudInit reports whether data is one of the cloud-init user-data
+// formats (cloud-config, scripts, includes, or MIME multipart), all of which
+// start with a "#" directive or a MIME header.
+func isCloudInit(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return bytes.HasPrefix(data, []byte("#")) ||
+		bytes.HasPrefix(data, []byte("Content-Type:")) ||
+		bytes.HasPrefix(data, []byte("MIME-Version:"))
 }
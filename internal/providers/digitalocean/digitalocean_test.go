@@ -0,0 +1,144 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package digitalocean
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/providers/util"
+	"github.com/coreos/ignition/v2/internal/resource"
+)
+
+func TestFetchConfig(t *testing.T) {
+	const (
+		ignition    = `{"ignition": {"version": "3.3.0"}, "storage": {"files": [{"path": "/%s"}]}}`
+		cloudConfig = "#cloud-config\nusers:\n  - name: core\n"
+		multipart   = "Content-Type: multipart/mixed; boundary=\"abc\"\nMIME-Version: 1.0\n\n--abc--\n"
+	)
+	tests := []struct {
+		// payloads served by the metadata service; absent paths 404
+		userdata   *string
+		vendordata *string
+		// status returned for user-data instead of the payload, if set
+		userdataStatus int
+		// path of the file in the resulting config, if any
+		path string
+		err  error
+	}{
+		{
+			userdata:   strPtr(ignition, "userdata"),
+			vendordata: strPtr(ignition, "vendordata"),
+			path:       "/userdata",
+		},
+		// cloud-config user-data isn't an error
+		{
+			userdata: strPtr(cloudConfig),
+			err:      errors.ErrEmpty,
+		},
+		{
+			userdata:   strPtr(cloudConfig),
+			vendordata: strPtr(ignition, "vendordata"),
+			path:       "/vendordata",
+		},
+		{
+			vendordata: strPtr(ignition, "vendordata"),
+			path:       "/vendordata",
+		},
+		{
+			userdata:   strPtr(multipart),
+			vendordata: strPtr(cloudConfig),
+			err:        errors.ErrEmpty,
+		},
+		{
+			err: errors.ErrEmpty,
+		},
+		// a failure to fetch user-data isn't mistaken for missing
+		// user-data
+		{
+			userdataStatus: http.StatusForbidden,
+			vendordata:     strPtr(ignition, "vendordata"),
+			err:            resource.ErrFailed,
+		},
+		{
+			userdataStatus: http.StatusNotFound,
+			vendordata:     strPtr(ignition, "vendordata"),
+			path:           "/vendordata",
+		},
+	}
+
+	origUserdata, origVendordata := userdataUrl, vendordataUrl
+	defer func() {
+		userdataUrl, vendordataUrl = origUserdata, origVendordata
+	}()
+	for i, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body *string
+			switch r.URL.Path {
+			case "/" + origUserdata.Path:
+				if test.userdataStatus != 0 {
+					w.WriteHeader(test.userdataStatus)
+					return
+				}
+				body = test.userdata
+			case "/" + origVendordata.Path:
+				body = test.vendordata
+			}
+			if body == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(*body))
+		}))
+
+		u, err := url.Parse(server.URL)
+		if err != nil {
+			t.Fatalf("parsing URL: %v", err)
+		}
+		userdataUrl, vendordataUrl = *u, *u
+		userdataUrl.Path = origUserdata.Path
+		vendordataUrl.Path = origVendordata.Path
+
+		logger := log.New(true)
+		f := resource.Fetcher{Logger: &logger}
+		cfg, _, err := util.FetchConfigFromSources(&f, Sources)
+		server.Close()
+
+		if err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if cfg.Ignition.Version != types.MaxVersion.String() {
+			t.Errorf("#%d: bad version: want %q, got %q", i, types.MaxVersion.String(), cfg.Ignition.Version)
+		}
+		if len(cfg.Storage.Files) != 1 || cfg.Storage.Files[0].Path != test.path {
+			t.Errorf("#%d: bad files: want %q, got %+v", i, test.path, cfg.Storage.Files)
+		}
+	}
+}
+
+func strPtr(format string, args ...interface{}) *string {
+	s := fmt.Sprintf(format, args...)
+	return &s
+}
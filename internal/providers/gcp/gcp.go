@@ -34,6 +34,11 @@ var (
 		Host:   "169.254.169.254",
 		Path:   "computeMetadata/v1/instance/attributes/user-data",
 	}
+	instanceMetadataUrl = url.URL{
+		Scheme: "http",
+		Host:   "169.254.169.254",
+		Path:   "computeMetadata/v1/instance/",
+	}
 	metadataHeaderKey = "Metadata-Flavor"
 	metadataHeaderVal = "Google"
 )
@@ -48,5 +53,29 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 		return types.Config{}, report.Report{}, err
 	}
 
-	return util.ParseConfig(f.Logger, data)
+	return util.ParseConfig(f.Logger, data, f.MaxBufferSize)
+}
+
+// FetchMetadata returns the instance's hostname and ID for use in
+// templated files.
+func FetchMetadata(f *resource.Fetcher) (map[string]string, error) {
+	headers := make(http.Header)
+	headers.Set(metadataHeaderKey, metadataHeaderVal)
+	paths := map[string]string{
+		"hostname":    "hostname",
+		"instance_id": "id",
+	}
+	metadata := make(map[string]string)
+	for name, path := range paths {
+		u := instanceMetadataUrl
+		u.Path += path
+		value, err := f.FetchToBuffer(u, resource.FetchOptions{
+			Headers: headers,
+		})
+		if err != nil {
+			return nil, err
+		}
+		metadata[name] = string(value)
+	}
+	return metadata, nil
 }
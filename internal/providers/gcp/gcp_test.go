@@ -0,0 +1,89 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/resource"
+)
+
+func TestFetchConfig(t *testing.T) {
+	tests := []struct {
+		// statuses returned by the server, in order; the last one repeats
+		statuses []int
+		out      error
+	}{
+		{
+			statuses: []int{http.StatusOK},
+			out:      nil,
+		},
+		// the metadata server is eventually consistent
+		{
+			statuses: []int{http.StatusServiceUnavailable, http.StatusOK},
+			out:      nil,
+		},
+		// absent attribute
+		{
+			statuses: []int{http.StatusNotFound},
+			out:      errors.ErrEmpty,
+		},
+	}
+
+	for i, test := range tests {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(metadataHeaderKey) != metadataHeaderVal {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			status := test.statuses[len(test.statuses)-1]
+			if attempts < len(test.statuses) {
+				status = test.statuses[attempts]
+			}
+			attempts++
+			w.WriteHeader(status)
+			if status == http.StatusOK {
+				_, _ = w.Write([]byte(`{"ignition": {"version": "3.3.0"}}`))
+			}
+		}))
+
+		u, err := url.Parse(server.URL)
+		if err != nil {
+			t.Fatalf("parsing URL: %v", err)
+		}
+		u.Path = userdataUrl.Path
+		userdataUrl = *u
+
+		logger := log.New(true)
+		f := resource.Fetcher{Logger: &logger}
+		cfg, _, err := FetchConfig(&f)
+		server.Close()
+
+		if err != test.out {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out, err)
+			continue
+		}
+		if err == nil && cfg.Ignition.Version != types.MaxVersion.String() {
+			t.Errorf("#%d: bad version: want %q, got %q", i, types.MaxVersion.String(), cfg.Ignition.Version)
+		}
+	}
+}
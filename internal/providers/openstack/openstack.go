@@ -13,14 +13,13 @@
 // limitations under the License.
 
 // The OpenStack provider fetches configurations from the userdata available in
-// both the config-drive as well as the network metadata service. Whichever
-// responds first is the config that is used.
+// the config-drive, falling back to the network metadata service if there's
+// no config drive or it has no userdata.
 // NOTE: This provider is still EXPERIMENTAL.
 
 package openstack
 
 import (
-	"context"
 	"fmt"
 	"io/ioutil"
 	"net/url"
@@ -32,6 +31,7 @@ import (
 	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
 	"github.com/coreos/ignition/v2/internal/distro"
 	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/providers"
 	"github.com/coreos/ignition/v2/internal/providers/util"
 	"github.com/coreos/ignition/v2/internal/resource"
 	ut "github.com/coreos/ignition/v2/internal/util"
@@ -49,67 +49,38 @@ var (
 		Host:   "169.254.169.254",
 		Path:   "openstack/latest/user_data",
 	}
-)
 
-func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
-	// The fetch-offline approach doesn't work well here because of the "split
-	// personality" of this provider. See:
-	// https://github.com/coreos/ignition/issues/1081
-	if f.Offline {
-		return types.Config{}, report.Report{}, resource.ErrNeedNet
+	// configDriveLabels are the filesystem labels a config drive may have.
+	configDriveLabels = []string{"config-2", "CONFIG-2"}
+	// configDriveTimeout bounds the wait for a config drive to show up
+	// before falling back to the metadata service.
+	configDriveTimeout = 10 * time.Second
+
+	// replaced in tests
+	diskByLabelDir   = distro.DiskByLabelDir
+	mountConfigDrive = func(logger *log.Logger, dev, mnt string) error {
+		cmd := exec.Command(distro.MountCmd(), "-o", "ro", "-t", "auto", dev, mnt)
+		_, err := logger.LogCmd(cmd, "mounting config drive")
+		return err
 	}
-
-	var data []byte
-	errChan := make(chan error)
-	ctx, cancel := context.WithCancel(context.Background())
-	dispatchCount := 0
-
-	dispatch := func(name string, fn func() ([]byte, error)) {
-		dispatchCount++
-		go func() {
-			raw, err := fn()
-			if err != nil {
-				switch err {
-				case context.Canceled:
-				default:
-					f.Logger.Err("failed to fetch config from %s: %v", name, err)
-				}
-				errChan <- err
-				return
-			}
-
-			data = raw
-			cancel()
-		}()
-	}
-
-	dispatch("config drive (config-2)", func() ([]byte, error) {
-		return fetchConfigFromDevice(f.Logger, ctx, filepath.Join(distro.DiskByLabelDir(), "config-2"))
-	})
-
-	dispatch("config drive (CONFIG-2)", func() ([]byte, error) {
-		return fetchConfigFromDevice(f.Logger, ctx, filepath.Join(distro.DiskByLabelDir(), "CONFIG-2"))
-	})
-
-	dispatch("metadata service", func() ([]byte, error) {
-		return fetchConfigFromMetadataService(f)
-	})
-
-Loop:
-	for {
-		select {
-		case <-ctx.Done():
-			break Loop
-		case <-errChan:
-			dispatchCount--
-			if dispatchCount == 0 {
-				f.Logger.Info("couldn't fetch config")
-				break Loop
-			}
-		}
+	unmountConfigDrive = ut.UmountPath
+
+	Sources = []providers.Source{
+		{
+			Name:     "config drive",
+			Priority: 0,
+			Fetch:    fetchConfigFromConfigDrive,
+		},
+		{
+			Name:     "metadata service",
+			Priority: 1,
+			Fetch:    fetchConfigFromMetadataService,
+		},
 	}
+)
 
-	return util.ParseConfig(f.Logger, data)
+func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
+	return util.FetchConfigFromSources(f, Sources)
 }
 
 func fileExists(path string) bool {
@@ -117,16 +88,43 @@ func fileExists(path string) bool {
 	return (err == nil)
 }
 
-func fetchConfigFromDevice(logger *log.Logger, ctx context.Context, path string) ([]byte, error) {
-	for !fileExists(path) {
-		logger.Debug("config drive (%q) not found. Waiting...", path)
-		select {
-		case <-time.After(time.Second):
-		case <-ctx.Done():
-			return nil, ctx.Err()
+// findConfigDrive waits for a device with one of the config drive labels
+// and returns its path, or "" if none appears in time.
+func findConfigDrive(logger *log.Logger) string {
+	deadline := time.Now().Add(configDriveTimeout)
+	for {
+		for _, label := range configDriveLabels {
+			path := filepath.Join(diskByLabelDir(), label)
+			if fileExists(path) {
+				return path
+			}
 		}
+		if time.Now().After(deadline) {
+			return ""
+		}
+		logger.Debug("config drive not found. Waiting...")
+		time.Sleep(time.Second)
 	}
+}
+
+// fetchConfigFromConfigDrive returns the userdata from the config drive, or
+// nothing if there's no config drive, it can't be read, or it has no
+// userdata. The metadata service is tried next in each case.
+func fetchConfigFromConfigDrive(f *resource.Fetcher) ([]byte, error) {
+	path := findConfigDrive(f.Logger)
+	if path == "" {
+		f.Logger.Info("no config drive found")
+		return nil, nil
+	}
+	data, err := fetchConfigFromDevice(f.Logger, path)
+	if err != nil {
+		f.Logger.Err("failed to read config drive %q: %v", path, err)
+		return nil, nil
+	}
+	return data, nil
+}
 
+func fetchConfigFromDevice(logger *log.Logger, path string) ([]byte, error) {
 	logger.Debug("creating temporary mount point")
 	mnt, err := ioutil.TempDir("", "ignition-configdrive")
 	if err != nil {
@@ -134,14 +132,13 @@ func fetchConfigFromDevice(logger *log.Logger, ctx context.Context, path string)
 	}
 	defer os.Remove(mnt)
 
-	cmd := exec.Command(distro.MountCmd(), "-o", "ro", "-t", "auto", path, mnt)
-	if _, err := logger.LogCmd(cmd, "mounting config drive"); err != nil {
+	if err := mountConfigDrive(logger, path, mnt); err != nil {
 		return nil, err
 	}
 	defer func() {
 		_ = logger.LogOp(
 			func() error {
-				return ut.UmountPath(mnt)
+				return unmountConfigDrive(mnt)
 			},
 			"unmounting %q at %q", path, mnt,
 		)
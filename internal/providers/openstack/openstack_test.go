@@ -0,0 +1,201 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openstack
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	configErrors "github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/resource"
+)
+
+func TestFetchConfig(t *testing.T) {
+	const ignition = `{"ignition": {"version": "3.3.0"}, "storage": {"files": [{"path": "/%s"}]}}`
+	errMount := errors.New("mount failed")
+
+	tests := []struct {
+		// label of the config drive, if any
+		label string
+		// userdata on the config drive, if any
+		driveUserdata *string
+		mountErr      error
+		// userdata served by the metadata service; 404 if nil
+		serviceUserdata *string
+		offline         bool
+		// path of the file in the resulting config, if any
+		path string
+		// whether the metadata service should have been queried
+		queried bool
+		err     error
+	}{
+		// config drive takes precedence
+		{
+			label:           "config-2",
+			driveUserdata:   strPtr(ignition, "drive"),
+			serviceUserdata: strPtr(ignition, "service"),
+			path:            "/drive",
+		},
+		{
+			label:         "CONFIG-2",
+			driveUserdata: strPtr(ignition, "drive"),
+			path:          "/drive",
+		},
+		// config drive without userdata
+		{
+			label:           "config-2",
+			serviceUserdata: strPtr(ignition, "service"),
+			path:            "/service",
+			queried:         true,
+		},
+		// no config drive
+		{
+			serviceUserdata: strPtr(ignition, "service"),
+			path:            "/service",
+			queried:         true,
+		},
+		// unreadable config drive
+		{
+			label:           "config-2",
+			driveUserdata:   strPtr(ignition, "drive"),
+			mountErr:        errMount,
+			serviceUserdata: strPtr(ignition, "service"),
+			path:            "/service",
+			queried:         true,
+		},
+		// no userdata anywhere
+		{
+			label:   "config-2",
+			queried: true,
+			err:     configErrors.ErrEmpty,
+		},
+		{
+			queried: true,
+			err:     configErrors.ErrEmpty,
+		},
+		// the config drive works without networking
+		{
+			label:         "config-2",
+			driveUserdata: strPtr(ignition, "drive"),
+			offline:       true,
+			path:          "/drive",
+		},
+		{
+			label:   "config-2",
+			offline: true,
+			err:     resource.ErrNeedNet,
+		},
+	}
+
+	origMetadataServiceUrl := metadataServiceUrl
+	origDiskByLabelDir := diskByLabelDir
+	origMount, origUnmount := mountConfigDrive, unmountConfigDrive
+	origTimeout := configDriveTimeout
+	defer func() {
+		metadataServiceUrl = origMetadataServiceUrl
+		diskByLabelDir = origDiskByLabelDir
+		mountConfigDrive, unmountConfigDrive = origMount, origUnmount
+		configDriveTimeout = origTimeout
+	}()
+	configDriveTimeout = 0
+
+	for i, test := range tests {
+		queried := false
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			queried = true
+			if r.URL.Path != "/"+origMetadataServiceUrl.Path || test.serviceUserdata == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(*test.serviceUserdata))
+		}))
+		u, err := url.Parse(server.URL)
+		if err != nil {
+			t.Fatalf("parsing URL: %v", err)
+		}
+		metadataServiceUrl = *u
+		metadataServiceUrl.Path = origMetadataServiceUrl.Path
+
+		// fake the by-label symlink with a regular file
+		labelDir := t.TempDir()
+		diskByLabelDir = func() string { return labelDir }
+		if test.label != "" {
+			if err := ioutil.WriteFile(filepath.Join(labelDir, test.label), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// "mounting" populates the mount point with the drive's contents
+		mountConfigDrive = func(_ *log.Logger, dev, mnt string) error {
+			if dev != filepath.Join(labelDir, test.label) {
+				return fmt.Errorf("mounted unexpected device %q", dev)
+			}
+			if test.mountErr != nil || test.driveUserdata == nil {
+				return test.mountErr
+			}
+			path := filepath.Join(mnt, configDriveUserdataPath)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			return ioutil.WriteFile(path, []byte(*test.driveUserdata), 0644)
+		}
+		unmountConfigDrive = func(mnt string) error {
+			entries, err := ioutil.ReadDir(mnt)
+			if err != nil {
+				return err
+			}
+			for _, e := range entries {
+				if err := os.RemoveAll(filepath.Join(mnt, e.Name())); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		logger := log.New(true)
+		f := resource.Fetcher{
+			Logger:  &logger,
+			Offline: test.offline,
+		}
+		cfg, _, err := FetchConfig(&f)
+		server.Close()
+
+		if queried != test.queried {
+			t.Errorf("#%d: bad metadata service query: want %v, got %v", i, test.queried, queried)
+		}
+		if err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if len(cfg.Storage.Files) != 1 || cfg.Storage.Files[0].Path != test.path {
+			t.Errorf("#%d: bad files: want %q, got %+v", i, test.path, cfg.Storage.Files)
+		}
+	}
+}
+
+func strPtr(format string, args ...interface{}) *string {
+	s := fmt.Sprintf(format, args...)
+	return &s
+}
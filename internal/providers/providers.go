@@ -29,6 +29,20 @@ var (
 )
 
 type FuncFetchConfig func(f *resource.Fetcher) (types.Config, report.Report, error)
+type FuncFetchRawConfig func(f *resource.Fetcher) ([]byte, error)
 type FuncInit func(f *resource.Fetcher) error
 type FuncNewFetcher func(logger *log.Logger) (resource.Fetcher, error)
 type FuncPostStatus func(stageName string, f resource.Fetcher, e error) error
+
+// FuncFetchMetadata returns the instance metadata available to templated
+// files, keyed by variable name.
+type FuncFetchMetadata func(f *resource.Fetcher) (map[string]string, error)
+
+// Source is one of several places a platform's config might live. A
+// platform with multiple sources tries them in ascending order of Priority
+// and uses the first that yields a non-empty config.
+type Source struct {
+	Name     string
+	Priority int
+	Fetch    FuncFetchRawConfig
+}
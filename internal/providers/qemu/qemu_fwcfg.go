@@ -53,7 +53,7 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 	sizeBytes, err := ioutil.ReadFile(firmwareConfigSizePath)
 	if os.IsNotExist(err) {
 		f.Logger.Info("QEMU firmware config was not found. Ignoring...")
-		return util.ParseConfig(f.Logger, []byte{})
+		return util.ParseConfig(f.Logger, []byte{}, f.MaxBufferSize)
 	} else if err != nil {
 		f.Logger.Err("couldn't read QEMU firmware config size: %v", err)
 		return types.Config{}, report.Report{}, err
@@ -107,5 +107,5 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 	// trust that firmwareConfigSizePath was telling the truth to avoid
 	// incurring an extra read call to check for EOF.  We're at the end
 	// of the file so the extra read would be maximally expensive.
-	return util.ParseConfig(f.Logger, data)
+	return util.ParseConfig(f.Logger, data, f.MaxBufferSize)
 }
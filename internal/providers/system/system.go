@@ -53,10 +53,12 @@ func FetchBaseConfig(logger *log.Logger, platformName string) (types.Config, rep
 }
 
 func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
-	return fetchConfig(f.Logger, userFilename)
+	return fetchConfig(f.Logger, userFilename, f.MaxBufferSize)
 }
 
-func fetchConfig(logger *log.Logger, filename string) (types.Config, report.Report, error) {
+// fetchConfig reads a config from the system config directory, gunzipping
+// it up to maxSize bytes if maxSize is non-zero.
+func fetchConfig(logger *log.Logger, filename string, maxSize int64) (types.Config, report.Report, error) {
 	path := filepath.Join(distro.SystemConfigDir(), filename)
 	logger.Info("reading system config file %q", path)
 
@@ -68,7 +70,7 @@ func fetchConfig(logger *log.Logger, filename string) (types.Config, report.Repo
 		logger.Err("couldn't read config %q: %v", path, err)
 		return types.Config{}, report.Report{}, err
 	}
-	return util.ParseConfig(logger, rawConfig)
+	return util.ParseConfig(logger, rawConfig, maxSize)
 }
 
 // fetchBaseDirectoryConfig is a helper function to merge all the base config fragments inside of a particular directory.
@@ -89,7 +91,8 @@ func fetchBaseDirectoryConfig(logger *log.Logger, dir string) (types.Config, rep
 		return types.Config{}, report, nil
 	}
 	for _, config := range configs {
-		intermediateConfig, intermediateReport, err := fetchConfig(logger, filepath.Join(dir, config.Name()))
+		// base configs are shipped by the distro, so they aren't limited
+		intermediateConfig, intermediateReport, err := fetchConfig(logger, filepath.Join(dir, config.Name()), 0)
 		if err != nil {
 			return types.Config{}, intermediateReport, err
 		}
@@ -15,19 +15,39 @@
 package util
 
 import (
+	"bytes"
 	"crypto/sha512"
 	"encoding/hex"
 
 	"github.com/coreos/ignition/v2/config"
+	"github.com/coreos/ignition/v2/config/shared/errors"
 	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
 	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/util"
 
 	"github.com/coreos/vcontext/report"
 )
 
-func ParseConfig(logger *log.Logger, rawConfig []byte) (types.Config, report.Report, error) {
+// ParseConfig parses a config fetched by a provider. Metadata services often
+// return an empty or whitespace-only body when no config was supplied, so
+// those are reported as errors.ErrEmpty (no config) rather than as a syntax
+// error. If maxSize is non-zero, configs larger than maxSize bytes, before or
+// after decompression, result in util.ErrTooLarge.
+func ParseConfig(logger *log.Logger, rawConfig []byte, maxSize int64) (types.Config, report.Report, error) {
 	hash := sha512.Sum512(rawConfig)
 	logger.Debug("parsing config with SHA512: %s", hex.EncodeToString(hash[:]))
 
+	if maxSize > 0 && int64(len(rawConfig)) > maxSize {
+		logger.Crit("config is larger than %d bytes", maxSize)
+		return types.Config{}, report.Report{}, util.ErrTooLarge
+	}
+	rawConfig, err := util.GunzipIfCompressed(rawConfig, maxSize)
+	if err != nil {
+		logger.Crit("failed to decompress gzipped config: %v", err)
+		return types.Config{}, report.Report{}, err
+	}
+	if len(bytes.TrimSpace(rawConfig)) == 0 {
+		return types.Config{}, report.Report{}, errors.ErrEmpty
+	}
 	return config.Parse(rawConfig)
 }
@@ -0,0 +1,99 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/util"
+)
+
+func TestParseConfig(t *testing.T) {
+	gzipped := func(data string) string {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	tests := []struct {
+		in      string
+		maxSize int64
+		version string
+		err     error
+	}{
+		{
+			in:  "",
+			err: errors.ErrEmpty,
+		},
+		{
+			in:  " \t \r\n ",
+			err: errors.ErrEmpty,
+		},
+		{
+			in:  "\n",
+			err: errors.ErrEmpty,
+		},
+		{
+			in:  gzipped("\n"),
+			err: errors.ErrEmpty,
+		},
+		{
+			in:      "\n" + `{"ignition": {"version": "3.3.0"}}` + "\n",
+			version: "3.4.0-experimental",
+		},
+		{
+			in:  "\n{\n",
+			err: errors.ErrInvalid,
+		},
+		{
+			in:      `{"ignition": {"version": "3.3.0"}}`,
+			maxSize: 34,
+			version: "3.4.0-experimental",
+		},
+		{
+			in:      `{"ignition": {"version": "3.3.0"}}`,
+			maxSize: 33,
+			err:     util.ErrTooLarge,
+		},
+		{
+			in:      gzipped(`{"ignition": {"version": "3.3.0"}}`),
+			maxSize: 33,
+			err:     util.ErrTooLarge,
+		},
+	}
+
+	logger := log.New(true)
+	defer logger.Close()
+	for i, test := range tests {
+		cfg, _, err := ParseConfig(&logger, []byte(test.in), test.maxSize)
+		if err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+			continue
+		}
+		if err == nil && cfg.Ignition.Version != test.version {
+			t.Errorf("#%d: bad version: want %q, got %q", i, test.version, cfg.Ignition.Version)
+		}
+	}
+}
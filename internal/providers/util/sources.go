@@ -0,0 +1,60 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/providers"
+	"github.com/coreos/ignition/v2/internal/resource"
+
+	"github.com/coreos/vcontext/report"
+)
+
+// FetchConfigFromSources tries the given sources in order of priority and
+// parses the config from the first one which returns a non-empty config.
+// Only sources which have no config, or report resource.ErrNotFound, are
+// skipped. Any other error, including resource.ErrNeedNet, is returned
+// immediately, so a transient failure doesn't pass over a higher priority
+// source or boot the machine without its config.
+func FetchConfigFromSources(f *resource.Fetcher, sources []providers.Source) (types.Config, report.Report, error) {
+	sorted := make([]providers.Source, len(sources))
+	copy(sorted, sources)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority < sorted[j].Priority
+	})
+
+	for _, source := range sorted {
+		data, err := source.Fetch(f)
+		if err == resource.ErrNeedNet {
+			return types.Config{}, report.Report{}, err
+		}
+		if err != nil && err != resource.ErrNotFound && err != errors.ErrEmpty {
+			f.Logger.Err("failed to fetch config from %s: %v", source.Name, err)
+			return types.Config{}, report.Report{}, err
+		}
+		if err != nil || len(bytes.TrimSpace(data)) == 0 {
+			f.Logger.Debug("no config found in %s", source.Name)
+			continue
+		}
+		f.Logger.Info("using config from %s", source.Name)
+		return ParseConfig(f.Logger, data, f.MaxBufferSize)
+	}
+	f.Logger.Info("couldn't fetch config")
+	return types.Config{}, report.Report{}, errors.ErrEmpty
+}
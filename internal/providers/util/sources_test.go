@@ -0,0 +1,137 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/providers"
+	"github.com/coreos/ignition/v2/internal/resource"
+)
+
+func TestFetchConfigFromSources(t *testing.T) {
+	static := func(data string, err error) providers.FuncFetchRawConfig {
+		return func(f *resource.Fetcher) ([]byte, error) {
+			return []byte(data), err
+		}
+	}
+	config := func(version string) string {
+		return fmt.Sprintf(`{"ignition": {"version": "%s"}}`, version)
+	}
+	errMount := fmt.Errorf("mount failed")
+
+	tests := []struct {
+		in      []providers.Source
+		version string
+		err     error
+	}{
+		// first source is empty, fall back to the second
+		{
+			in: []providers.Source{
+				{Name: "config drive", Priority: 0, Fetch: static("", nil)},
+				{Name: "metadata service", Priority: 1, Fetch: static(config("3.3.0"), nil)},
+			},
+			version: "3.4.0-experimental",
+		},
+		// first source is whitespace, fall back to the second
+		{
+			in: []providers.Source{
+				{Name: "config drive", Priority: 0, Fetch: static("\n", nil)},
+				{Name: "metadata service", Priority: 1, Fetch: static(config("3.4.0-experimental"), nil)},
+			},
+			version: "3.4.0-experimental",
+		},
+		// priority wins over registration order
+		{
+			in: []providers.Source{
+				{Name: "metadata service", Priority: 1, Fetch: static("not a config", nil)},
+				{Name: "config drive", Priority: 0, Fetch: static(config("3.3.0"), nil)},
+			},
+			version: "3.4.0-experimental",
+		},
+		// sources without a config are skipped
+		{
+			in: []providers.Source{
+				{Name: "config drive", Priority: 0, Fetch: static("", resource.ErrNotFound)},
+				{Name: "metadata service", Priority: 1, Fetch: static(config("3.3.0"), nil)},
+			},
+			version: "3.4.0-experimental",
+		},
+		{
+			in: []providers.Source{
+				{Name: "config drive", Priority: 0, Fetch: static("", errors.ErrEmpty)},
+				{Name: "metadata service", Priority: 1, Fetch: static(config("3.3.0"), nil)},
+			},
+			version: "3.4.0-experimental",
+		},
+		// failing sources aren't, so a transient failure isn't mistaken
+		// for a missing config
+		{
+			in: []providers.Source{
+				{Name: "config drive", Priority: 0, Fetch: static("", errMount)},
+				{Name: "metadata service", Priority: 1, Fetch: static(config("3.3.0"), nil)},
+			},
+			err: errMount,
+		},
+		{
+			in: []providers.Source{
+				{Name: "config drive", Priority: 0, Fetch: static("", nil)},
+				{Name: "metadata service", Priority: 1, Fetch: static("", resource.ErrFailed)},
+			},
+			err: resource.ErrFailed,
+		},
+		// a higher priority source needing networking isn't skipped
+		{
+			in: []providers.Source{
+				{Name: "metadata service", Priority: 0, Fetch: static("", resource.ErrNeedNet)},
+				{Name: "config drive", Priority: 1, Fetch: static(config("3.3.0"), nil)},
+			},
+			err: resource.ErrNeedNet,
+		},
+		// all sources empty
+		{
+			in: []providers.Source{
+				{Name: "config drive", Priority: 0, Fetch: static("", nil)},
+				{Name: "metadata service", Priority: 1, Fetch: static("", nil)},
+			},
+			err: errors.ErrEmpty,
+		},
+		// the winning source's config is parsed
+		{
+			in: []providers.Source{
+				{Name: "config drive", Priority: 0, Fetch: static("not a config", nil)},
+				{Name: "metadata service", Priority: 1, Fetch: static(config("3.3.0"), nil)},
+			},
+			err: errors.ErrInvalid,
+		},
+	}
+
+	logger := log.New(true)
+	defer logger.Close()
+	f := resource.Fetcher{Logger: &logger}
+	for i, test := range tests {
+		cfg, _, err := FetchConfigFromSources(&f, test.in)
+		if err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+			continue
+		}
+		if err == nil && cfg.Ignition.Version != test.version {
+			t.Errorf("#%d: bad version: want %q, got %q", i, test.version, cfg.Ignition.Version)
+		}
+	}
+}
@@ -66,7 +66,7 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 		f.Logger.Info("VirtualBox guest property %q does not exist; assuming no config", configProperty)
 		return types.Config{}, report.Report{}, errors.ErrEmpty
 	}
-	return util.ParseConfig(f.Logger, config)
+	return util.ParseConfig(f.Logger, config, f.MaxBufferSize)
 }
 
 func fetchProperty(name string) ([]byte, error) {
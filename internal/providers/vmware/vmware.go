@@ -18,11 +18,20 @@
 package vmware
 
 import (
-	"compress/gzip"
 	"encoding/base64"
 	"fmt"
-	"io/ioutil"
+	"os/exec"
 	"strings"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/providers/util"
+	ut "github.com/coreos/ignition/v2/internal/util"
+
+	"github.com/coreos/vcontext/report"
+	"github.com/vmware/vmw-ovflib"
 )
 
 type config struct {
@@ -30,7 +39,87 @@ type config struct {
 	encoding string
 }
 
-func decodeConfig(config config) ([]byte, error) {
+// guestinfoFunc returns the value of the given guestinfo key (without the
+// "guestinfo." prefix), or defaultValue if the key is unset.
+type guestinfoFunc func(key, defaultValue string) (string, error)
+
+// rpctoolCommand is overridden in tests
+var rpctoolCommand = exec.Command
+
+// rpctoolGuestinfo reads guestinfo keys using vmware-rpctool. It's used
+// where the backdoor interface isn't available.
+func rpctoolGuestinfo(key, defaultValue string) (string, error) {
+	cmd := rpctoolCommand(distro.VmwareRpctoolCmd(), "info-get guestinfo."+key)
+	out, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		// vmware-rpctool exits non-zero if the key is unset
+		if strings.Contains(string(exitErr.Stderr), "No value found") {
+			return defaultValue, nil
+		}
+		return "", fmt.Errorf("%s failed: %v: %s", distro.VmwareRpctoolCmd(), err, strings.TrimSpace(string(exitErr.Stderr)))
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func fetchConfig(logger *log.Logger, get guestinfoFunc, maxSize int64) (types.Config, report.Report, error) {
+	config, err := fetchRawConfig(logger, get)
+	if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+	if config.data == "" {
+		logger.Info("no config found in guestinfo")
+		return types.Config{}, report.Report{}, errors.ErrEmpty
+	}
+
+	decodedData, err := decodeConfig(config, maxSize)
+	if err != nil {
+		logger.Debug("failed to decode config: %v", err)
+		return types.Config{}, report.Report{}, err
+	}
+
+	logger.Debug("config successfully fetched")
+	return util.ParseConfig(logger, decodedData, maxSize)
+}
+
+func fetchRawConfig(logger *log.Logger, get guestinfoFunc) (config, error) {
+	var ovfData string
+	var ovfEncoding string
+
+	ovfEnv, err := get("ovfenv", "")
+	if err != nil {
+		logger.Warning("failed to fetch ovfenv: %v. Continuing...", err)
+	} else if ovfEnv != "" {
+		logger.Debug("using OVF environment from guestinfo")
+		env, err := ovf.ReadEnvironment([]byte(ovfEnv))
+		if err != nil {
+			logger.Warning("failed to parse OVF environment: %v. Continuing...", err)
+		}
+
+		ovfData = env.Properties["guestinfo.ignition.config.data"]
+		ovfEncoding = env.Properties["guestinfo.ignition.config.data.encoding"]
+	}
+
+	data, err := get("ignition.config.data", ovfData)
+	if err != nil {
+		logger.Debug("failed to fetch config: %v", err)
+		return config{}, err
+	}
+
+	encoding, err := get("ignition.config.data.encoding", ovfEncoding)
+	if err != nil {
+		logger.Debug("failed to fetch config encoding: %v", err)
+		return config{}, err
+	}
+
+	return config{
+		data:     data,
+		encoding: encoding,
+	}, nil
+}
+
+func decodeConfig(config config, maxSize int64) ([]byte, error) {
 	switch config.encoding {
 	case "":
 		return []byte(config.data), nil
@@ -39,7 +128,7 @@ func decodeConfig(config config) ([]byte, error) {
 		return decodeBase64Data(config.data)
 
 	case "gz", "gzip":
-		return decodeGzipData(config.data)
+		return decodeGzipData(config.data, maxSize)
 
 	case "gz+base64", "gzip+base64", "gz+b64", "gzip+b64":
 		gz, err := decodeBase64Data(config.data)
@@ -48,7 +137,7 @@ func decodeConfig(config config) ([]byte, error) {
 			return nil, err
 		}
 
-		return decodeGzipData(string(gz))
+		return decodeGzipData(string(gz), maxSize)
 	}
 
 	return nil, fmt.Errorf("unsupported encoding %q", config.encoding)
@@ -63,12 +152,6 @@ func decodeBase64Data(data string) ([]byte, error) {
 	return decodedData, nil
 }
 
-func decodeGzipData(data string) ([]byte, error) {
-	reader, err := gzip.NewReader(strings.NewReader(data))
-	if err != nil {
-		return nil, err
-	}
-	defer reader.Close()
-
-	return ioutil.ReadAll(reader)
+func decodeGzipData(data string, maxSize int64) ([]byte, error) {
+	return ut.Gunzip([]byte(data), maxSize)
 }
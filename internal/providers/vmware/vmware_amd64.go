@@ -19,14 +19,13 @@ package vmware
 
 import (
 	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
 	"github.com/coreos/ignition/v2/internal/providers"
-	"github.com/coreos/ignition/v2/internal/providers/util"
 	"github.com/coreos/ignition/v2/internal/resource"
 
 	"github.com/coreos/vcontext/report"
 	"github.com/vmware/vmw-guestinfo/rpcvmx"
 	"github.com/vmware/vmw-guestinfo/vmcheck"
-	"github.com/vmware/vmw-ovflib"
 )
 
 func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
@@ -36,55 +35,13 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 		return types.Config{}, report.Report{}, providers.ErrNoProvider
 	}
 
-	config, err := fetchRawConfig(f)
-	if err != nil {
-		return types.Config{}, report.Report{}, err
-	}
-
-	decodedData, err := decodeConfig(config)
-	if err != nil {
-		f.Logger.Debug("failed to decode config: %v", err)
-		return types.Config{}, report.Report{}, err
-	}
-
-	f.Logger.Debug("config successfully fetched")
-	return util.ParseConfig(f.Logger, decodedData)
-}
-
-func fetchRawConfig(f *resource.Fetcher) (config, error) {
 	info := rpcvmx.NewConfig()
-
-	var ovfData string
-	var ovfEncoding string
-
-	ovfEnv, err := info.String("ovfenv", "")
-	if err != nil {
-		f.Logger.Warning("failed to fetch ovfenv: %v. Continuing...", err)
-	} else if ovfEnv != "" {
-		f.Logger.Debug("using OVF environment from guestinfo")
-		env, err := ovf.ReadEnvironment([]byte(ovfEnv))
+	return fetchConfig(f.Logger, func(key, defaultValue string) (string, error) {
+		value, err := info.String(key, defaultValue)
 		if err != nil {
-			f.Logger.Warning("failed to parse OVF environment: %v. Continuing...", err)
+			f.Logger.Debug("failed to read guestinfo.%s from the backdoor: %v; trying %s", key, err, distro.VmwareRpctoolCmd())
+			return rpctoolGuestinfo(key, defaultValue)
 		}
-
-		ovfData = env.Properties["guestinfo.ignition.config.data"]
-		ovfEncoding = env.Properties["guestinfo.ignition.config.data.encoding"]
-	}
-
-	data, err := info.String("ignition.config.data", ovfData)
-	if err != nil {
-		f.Logger.Debug("failed to fetch config: %v", err)
-		return config{}, err
-	}
-
-	encoding, err := info.String("ignition.config.data.encoding", ovfEncoding)
-	if err != nil {
-		f.Logger.Debug("failed to fetch config encoding: %v", err)
-		return config{}, err
-	}
-
-	return config{
-		data:     data,
-		encoding: encoding,
-	}, nil
+		return value, nil
+	}, f.MaxBufferSize)
 }
@@ -0,0 +1,141 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vmware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
+	ut "github.com/coreos/ignition/v2/internal/util"
+)
+
+func TestFetchConfigRpctool(t *testing.T) {
+	const rawConfig = `{"ignition":{"version":"3.4.0-experimental"},"storage":{"files":[{"path":"/a"}]}}`
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	if _, err := w.Write([]byte(rawConfig)); err != nil {
+		t.Fatalf("compressing config: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("compressing config: %v", err)
+	}
+	want := types.Config{
+		Ignition: types.Ignition{Version: "3.4.0-experimental"},
+		Storage: types.Storage{
+			Files: []types.File{{Node: types.Node{Path: "/a"}}},
+		},
+	}
+
+	tests := []struct {
+		// guestinfo keys mapped to their values; unset keys aren't present
+		guestinfo map[string]string
+		// make vmware-rpctool fail outright
+		broken  bool
+		maxSize int64
+		out     types.Config
+		err     error
+		wantErr bool
+	}{
+		// unset
+		{
+			guestinfo: map[string]string{},
+			err:       errors.ErrEmpty,
+		},
+		{
+			guestinfo: map[string]string{
+				"ignition.config.data": rawConfig,
+			},
+			out: want,
+		},
+		{
+			guestinfo: map[string]string{
+				"ignition.config.data":          base64.StdEncoding.EncodeToString([]byte(rawConfig)),
+				"ignition.config.data.encoding": "base64",
+			},
+			out: want,
+		},
+		{
+			guestinfo: map[string]string{
+				"ignition.config.data":          base64.StdEncoding.EncodeToString(gz.Bytes()),
+				"ignition.config.data.encoding": "gzip+base64",
+			},
+			out: want,
+		},
+		// gzip is detected even if the encoding only says base64
+		{
+			guestinfo: map[string]string{
+				"ignition.config.data":          base64.StdEncoding.EncodeToString(gz.Bytes()),
+				"ignition.config.data.encoding": "b64",
+			},
+			out: want,
+		},
+		// the size limit applies to the decompressed config
+		{
+			guestinfo: map[string]string{
+				"ignition.config.data":          base64.StdEncoding.EncodeToString(gz.Bytes()),
+				"ignition.config.data.encoding": "gzip+base64",
+			},
+			maxSize: int64(len(rawConfig) - 1),
+			err:     ut.ErrTooLarge,
+		},
+		{
+			broken:  true,
+			wantErr: true,
+		},
+	}
+
+	defer func(orig func(string, ...string) *exec.Cmd) {
+		rpctoolCommand = orig
+	}(rpctoolCommand)
+	logger := log.New(true)
+	for i, test := range tests {
+		test := test
+		rpctoolCommand = func(name string, args ...string) *exec.Cmd {
+			if test.broken {
+				return exec.Command("sh", "-c", "echo 'rpci send failed' >&2; exit 1")
+			}
+			key := strings.TrimPrefix(args[0], "info-get guestinfo.")
+			value, ok := test.guestinfo[key]
+			if !ok {
+				return exec.Command("sh", "-c", "echo 'No value found' >&2; exit 1")
+			}
+			// rpctool appends a newline
+			return exec.Command("printf", "%s\n", value)
+		}
+
+		out, _, err := fetchConfig(&logger, rpctoolGuestinfo, test.maxSize)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("#%d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+			continue
+		}
+		if !reflect.DeepEqual(test.out, out) {
+			t.Errorf("#%d: bad config: want %+v, got %+v", i, test.out, out)
+		}
+	}
+}
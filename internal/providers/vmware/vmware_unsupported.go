@@ -21,14 +21,21 @@
 package vmware
 
 import (
-	"errors"
+	"fmt"
+	"os/exec"
 
 	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
 	"github.com/coreos/ignition/v2/internal/resource"
 
 	"github.com/coreos/vcontext/report"
 )
 
-func FetchConfig(_ *resource.Fetcher) (types.Config, report.Report, error) {
-	return types.Config{}, report.Report{}, errors.New("vmware provider is not supported on this architecture")
+// The backdoor interface is only available on amd64; elsewhere, fall back
+// to vmware-rpctool.
+func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
+	if _, err := exec.LookPath(distro.VmwareRpctoolCmd()); err != nil {
+		return types.Config{}, report.Report{}, fmt.Errorf("vmware provider requires %s on this architecture: %v", distro.VmwareRpctoolCmd(), err)
+	}
+	return fetchConfig(f.Logger, rpctoolGuestinfo, f.MaxBufferSize)
 }
@@ -81,7 +81,7 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 				break
 			}
 			jsonConfig := bytes.Trim(rawConfig, string(byte(0)))
-			return util.ParseConfig(f.Logger, jsonConfig)
+			return util.ParseConfig(f.Logger, jsonConfig, f.MaxBufferSize)
 		}
 	}
 	return types.Config{}, report.Report{}, errors.ErrEmpty
@@ -0,0 +1,75 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// maxCachedSize is the largest resource which will be kept in a Cache.
+// Larger resources (e.g. disk images) are refetched rather than held in
+// memory.
+const maxCachedSize = 16 * 1024 * 1024
+
+// Cache holds the contents of resources fetched during a single run, so
+// that a source referenced more than once is only downloaded once. Entries
+// are keyed by the URL and every fetch option that affects the result, so
+// the same URL with a different verification hash is a separate entry.
+// Only successfully fetched (and verified) resources are cached.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+}
+
+func NewCache() *Cache {
+	return &Cache{
+		entries: map[string][]byte{},
+	}
+}
+
+// cacheKey returns the cache key for fetching u with opts, or false if the
+// resource shouldn't be cached. data URLs are never cached since there is
+// nothing to download.
+func cacheKey(u url.URL, opts FetchOptions) (string, bool) {
+	switch u.Scheme {
+	case "http", "https", "tftp", "s3", "gs":
+	default:
+		return "", false
+	}
+	sums := hex.EncodeToString(opts.ExpectedSum)
+	for _, h := range opts.AlternateHashes {
+		sums += "," + hex.EncodeToString(h.Sum)
+	}
+	return fmt.Sprintf("%s %s %s %s %v", u.String(), opts.HTTPVerb, opts.Compression, sums, opts.Headers), true
+}
+
+func (c *Cache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.entries[key]
+	return data, ok
+}
+
+func (c *Cache) put(key string, data []byte) {
+	if len(data) > maxCachedSize {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = append([]byte(nil), data...)
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+func TestFetchCache(t *testing.T) {
+	contents := []byte("hello world\n")
+	sum256 := sha256.Sum256(contents)
+	sum512 := sha512.Sum512(contents)
+	badSum := sum512
+	badSum[0]++
+
+	tests := []struct {
+		opts  []FetchOptions
+		cache bool
+		hits  int
+	}{
+		// repeated source
+		{
+			opts:  []FetchOptions{{}, {}, {}},
+			cache: true,
+			hits:  1,
+		},
+		// same verification
+		{
+			opts: []FetchOptions{
+				{Hash: sha512.New(), ExpectedSum: sum512[:]},
+				{Hash: sha512.New(), ExpectedSum: sum512[:]},
+			},
+			cache: true,
+			hits:  1,
+		},
+		// different verification hashes are distinct entries
+		{
+			opts: []FetchOptions{
+				{Hash: sha512.New(), ExpectedSum: sum512[:]},
+				{Hash: sha256.New(), ExpectedSum: sum256[:]},
+				{},
+			},
+			cache: true,
+			hits:  3,
+		},
+		// failed verification isn't cached
+		{
+			opts: []FetchOptions{
+				{Hash: sha512.New(), ExpectedSum: badSum[:]},
+				{Hash: sha512.New(), ExpectedSum: badSum[:]},
+			},
+			cache: true,
+			hits:  2,
+		},
+		// different headers are distinct entries
+		{
+			opts: []FetchOptions{
+				{Headers: http.Header{"X-Key": []string{"a"}}},
+				{Headers: http.Header{"X-Key": []string{"b"}}},
+			},
+			cache: true,
+			hits:  2,
+		},
+		// no cache
+		{
+			opts: []FetchOptions{{}, {}},
+			hits: 2,
+		},
+	}
+
+	for i, test := range tests {
+		for _, toFile := range []bool{false, true} {
+			hits := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits++
+				_, _ = w.Write(contents)
+			}))
+			u, err := url.Parse(server.URL)
+			if err != nil {
+				t.Fatalf("parsing URL: %v", err)
+			}
+
+			logger := log.New(true)
+			f := Fetcher{
+				Logger: &logger,
+			}
+			if test.cache {
+				f.Cache = NewCache()
+			}
+			for j, opts := range test.opts {
+				var data []byte
+				if toFile {
+					data, err = fetchToFile(t, f, *u, opts)
+				} else {
+					data, err = f.FetchToBuffer(*u, opts)
+				}
+				if bytes.Equal(opts.ExpectedSum, badSum[:]) {
+					if err == nil {
+						t.Errorf("#%d/%d: expected hash mismatch", i, j)
+					}
+					continue
+				}
+				if err != nil {
+					t.Errorf("#%d/%d: unexpected error: %v", i, j, err)
+				} else if string(data) != string(contents) {
+					t.Errorf("#%d/%d: bad contents: want %q, got %q", i, j, contents, data)
+				}
+			}
+			server.Close()
+
+			if hits != test.hits {
+				t.Errorf("#%d (to file %v): bad number of requests: want %d, got %d", i, toFile, test.hits, hits)
+			}
+		}
+	}
+}
+
+func fetchToFile(t *testing.T, f Fetcher, u url.URL, opts FetchOptions) ([]byte, error) {
+	dest, err := os.Create(filepath.Join(t.TempDir(), "dest"))
+	if err != nil {
+		t.Fatalf("creating file: %v", err)
+	}
+	defer dest.Close()
+	if err := f.Fetch(u, dest, opts); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(dest.Name())
+}
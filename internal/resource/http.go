@@ -18,14 +18,15 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
-	"encoding/hex"
 	"encoding/pem"
 	"errors"
 	"io"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	ignerrors "github.com/coreos/ignition/v2/config/shared/errors"
@@ -51,6 +52,11 @@ const (
 var (
 	ErrTimeout         = errors.New("unable to fetch resource in time")
 	ErrPEMDecodeFailed = errors.New("unable to decode PEM block")
+
+	// jitter is not safe for concurrent use and must be accessed via
+	// jitterLock
+	jitter     = rand.New(rand.NewSource(time.Now().UnixNano()))
+	jitterLock sync.Mutex
 )
 
 // HttpClient is a simple wrapper around the Go HTTP client that standardizes
@@ -60,6 +66,10 @@ type HttpClient struct {
 	logger  *log.Logger
 	timeout time.Duration
 
+	// retry settings used unless overridden by FetchOptions
+	maxAttempts    int
+	initialBackoff time.Duration
+
 	transport *http.Transport
 	cas       map[string][]byte
 }
@@ -84,6 +94,16 @@ func (f *Fetcher) UpdateHttpTimeoutsAndCAs(timeouts types.Timeouts, cas []types.
 	f.client.client.Timeout = time.Duration(total) * time.Second
 	f.client.timeout = f.client.client.Timeout
 
+	// Update retries
+	f.client.maxAttempts = 0
+	if timeouts.HTTPMaxAttempts != nil {
+		f.client.maxAttempts = *timeouts.HTTPMaxAttempts
+	}
+	f.client.initialBackoff = 0
+	if timeouts.HTTPRetryDelay != nil {
+		f.client.initialBackoff = time.Duration(*timeouts.HTTPRetryDelay) * time.Millisecond
+	}
+
 	f.client.transport.ResponseHeaderTimeout = time.Duration(responseHeader) * time.Second
 	f.client.client.Transport = f.client.transport
 
@@ -152,24 +172,12 @@ func (f *Fetcher) getCABlob(ca types.Resource) ([]byte, error) {
 		f.Logger.Crit("Unable to parse CA URL: %s", err)
 		return nil, err
 	}
-	hasher, err := util.GetHasher(ca.Verification)
+	hashes, err := util.ExpectedHashes(ca.Verification)
 	if err != nil {
 		f.Logger.Crit("Unable to get hasher: %s", err)
 		return nil, err
 	}
 
-	var expectedSum []byte
-	if hasher != nil {
-		// explicitly ignoring the error here because the config should already
-		// be validated by this point
-		_, expectedSumString, _ := util.HashParts(ca.Verification)
-		expectedSum, err = hex.DecodeString(expectedSumString)
-		if err != nil {
-			f.Logger.Crit("Error parsing verification string %q: %v", expectedSumString, err)
-			return nil, err
-		}
-	}
-
 	var headers http.Header
 	if ca.HTTPHeaders != nil && len(ca.HTTPHeaders) > 0 {
 		headers, err = ca.HTTPHeaders.Parse()
@@ -183,12 +191,12 @@ func (f *Fetcher) getCABlob(ca types.Resource) ([]byte, error) {
 		compression = *ca.Compression
 	}
 
-	cablob, err := f.FetchToBuffer(*u, FetchOptions{
-		Hash:        hasher,
+	opts := FetchOptions{
 		Headers:     headers,
-		ExpectedSum: expectedSum,
 		Compression: compression,
-	})
+	}
+	opts.SetHashes(hashes)
+	cablob, err := f.FetchToBuffer(*u, opts)
 	if err != nil {
 		f.Logger.Err("Unable to fetch CA (%s): %s", u, err)
 		return nil, err
@@ -292,23 +300,44 @@ func (c HttpClient) httpReaderWithHeader(opts FetchOptions, url string) (io.Read
 	}
 
 	duration := initialBackoff
+	if opts.InitialBackoff != 0 {
+		duration = opts.InitialBackoff
+	} else if c.initialBackoff != 0 {
+		duration = c.initialBackoff
+	}
+	maxAttempts := c.maxAttempts
+	if opts.MaxAttempts != 0 {
+		maxAttempts = opts.MaxAttempts
+	}
 	for attempt := 1; ; attempt++ {
 		c.logger.Info("%s %s: attempt #%d", opts.HTTPVerb, url, attempt)
 		resp, err := c.client.Do(req.WithContext(ctx))
 
+		// 4xx responses are not retried, and neither is the final attempt
+		lastAttempt := attempt == maxAttempts
 		if err == nil {
 			c.logger.Info("%s result: %s", opts.HTTPVerb, http.StatusText(resp.StatusCode))
-			if resp.StatusCode < 500 {
+			if resp.StatusCode < 500 || lastAttempt {
 				return resp.Body, resp.StatusCode, cancelFn, nil
 			}
 			resp.Body.Close()
 		} else {
 			c.logger.Info("%s error: %v", opts.HTTPVerb, err)
+			if lastAttempt {
+				return nil, 0, cancelFn, err
+			}
 		}
 
+		// Add up to 50% jitter so that machines booted together don't
+		// retry in lockstep
+		jitterLock.Lock()
+		wait := duration + time.Duration(jitter.Int63n(int64(duration)/2+1))
+		jitterLock.Unlock()
+		c.logger.Debug("retrying %s in %v", url, wait)
+
 		// Wait before next attempt or exit if we timeout while waiting
 		select {
-		case <-time.After(duration):
+		case <-time.After(wait):
 		case <-ctx.Done():
 			return nil, 0, cancelFn, ErrTimeout
 		}
@@ -0,0 +1,249 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
+
+	"github.com/vincent-petithory/dataurl"
+)
+
+func TestFetchFromHTTPRetries(t *testing.T) {
+	type in struct {
+		// statuses returned by the server, in order; the last one repeats
+		statuses    []int
+		maxAttempts int
+	}
+	type out struct {
+		attempts int
+		err      error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{statuses: []int{http.StatusOK}, maxAttempts: 3},
+			out: out{attempts: 1},
+		},
+		{
+			in:  in{statuses: []int{http.StatusServiceUnavailable, http.StatusOK}, maxAttempts: 3},
+			out: out{attempts: 2},
+		},
+		{
+			in:  in{statuses: []int{http.StatusInternalServerError}, maxAttempts: 3},
+			out: out{attempts: 3, err: ErrFailed},
+		},
+		// 4xx responses are not retried
+		{
+			in:  in{statuses: []int{http.StatusNotFound}, maxAttempts: 3},
+			out: out{attempts: 1, err: ErrNotFound},
+		},
+		{
+			in:  in{statuses: []int{http.StatusForbidden}, maxAttempts: 3},
+			out: out{attempts: 1, err: ErrFailed},
+		},
+	}
+
+	for i, test := range tests {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			status := test.in.statuses[len(test.in.statuses)-1]
+			if attempts < len(test.in.statuses) {
+				status = test.in.statuses[attempts]
+			}
+			attempts++
+			w.WriteHeader(status)
+		}))
+
+		logger := log.New(true)
+		f := Fetcher{
+			Logger: &logger,
+		}
+		u, err := url.Parse(server.URL)
+		if err != nil {
+			t.Fatalf("parsing URL: %v", err)
+		}
+		_, err = f.FetchToBuffer(*u, FetchOptions{
+			MaxAttempts:    test.in.maxAttempts,
+			InitialBackoff: time.Millisecond,
+		})
+		server.Close()
+
+		if err != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if attempts != test.out.attempts {
+			t.Errorf("#%d: bad number of attempts: want %d, got %d", i, test.out.attempts, attempts)
+		}
+	}
+}
+
+func TestProxyFuncFromIgnitionConfig(t *testing.T) {
+	proxy := types.Proxy{
+		HTTPProxy:  util.StrToPtr("http://proxy.example.com:3128"),
+		HTTPSProxy: util.StrToPtr("http://secure-proxy.example.com:3128"),
+		NoProxy:    []types.NoProxyItem{"internal.example.com", "10.0.0.0/8"},
+	}
+
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{
+			"http://example.com/config.ign",
+			"http://proxy.example.com:3128",
+		},
+		{
+			"https://example.com/config.ign",
+			"http://secure-proxy.example.com:3128",
+		},
+		{
+			"https://internal.example.com/config.ign",
+			"",
+		},
+		{
+			"http://10.1.2.3/config.ign",
+			"",
+		},
+		{
+			"http://192.168.1.1/config.ign",
+			"http://proxy.example.com:3128",
+		},
+	}
+
+	proxyFunc := proxyFuncFromIgnitionConfig(proxy)
+	for i, test := range tests {
+		u, err := url.Parse(test.in)
+		if err != nil {
+			t.Fatalf("#%d: parsing URL: %v", i, err)
+		}
+		result, err := proxyFunc(u)
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		got := ""
+		if result != nil {
+			got = result.String()
+		}
+		if got != test.out {
+			t.Errorf("#%d: bad proxy: want %q, got %q", i, test.out, got)
+		}
+	}
+}
+
+func TestUpdateHttpTimeoutsAndCAs(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello world\n"))
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("parsing URL: %v", err)
+	}
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	tests := []struct {
+		in      []types.Resource
+		wantErr bool
+	}{
+		// the test server's certificate isn't trusted by default
+		{
+			in:      nil,
+			wantErr: true,
+		},
+		{
+			in: []types.Resource{
+				{Source: util.StrToPtr(dataurl.EncodeBytes(ca))},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		logger := log.New(true)
+		f := Fetcher{
+			Logger: &logger,
+		}
+		if err := f.UpdateHttpTimeoutsAndCAs(types.Timeouts{}, test.in, types.Proxy{}); err != nil {
+			t.Errorf("#%d: updating CAs: %v", i, err)
+			continue
+		}
+		_, err := f.FetchToBuffer(*u, FetchOptions{MaxAttempts: 1})
+		if test.wantErr && err == nil {
+			t.Errorf("#%d: expected error, got none", i)
+		} else if !test.wantErr && err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+		}
+	}
+}
+
+func TestFetchFromHTTPConfiguredRetries(t *testing.T) {
+	tests := []struct {
+		in  types.Timeouts
+		out int
+	}{
+		{
+			in:  types.Timeouts{HTTPMaxAttempts: util.IntToPtr(1)},
+			out: 1,
+		},
+		{
+			in: types.Timeouts{
+				HTTPMaxAttempts: util.IntToPtr(4),
+				HTTPRetryDelay:  util.IntToPtr(1),
+			},
+			out: 4,
+		},
+	}
+
+	for i, test := range tests {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+
+		logger := log.New(true)
+		f := Fetcher{
+			Logger: &logger,
+		}
+		if err := f.UpdateHttpTimeoutsAndCAs(test.in, nil, types.Proxy{}); err != nil {
+			t.Fatalf("#%d: updating fetcher: %v", i, err)
+		}
+		u, err := url.Parse(server.URL)
+		if err != nil {
+			t.Fatalf("parsing URL: %v", err)
+		}
+		_, err = f.FetchToBuffer(*u, FetchOptions{})
+		server.Close()
+
+		if err != ErrFailed {
+			t.Errorf("#%d: bad error: want %v, got %v", i, ErrFailed, err)
+		}
+		if attempts != test.out {
+			t.Errorf("#%d: bad number of attempts: want %d, got %d", i, test.out, attempts)
+		}
+	}
+}
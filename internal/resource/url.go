@@ -49,12 +49,12 @@ import (
 )
 
 var (
-	ErrSchemeUnsupported      = errors.New("unsupported source scheme")
-	ErrPathNotAbsolute        = errors.New("path is not absolute")
-	ErrNotFound               = errors.New("resource not found")
-	ErrFailed                 = errors.New("failed to fetch resource")
-	ErrCompressionUnsupported = errors.New("compression is not supported with that scheme")
-	ErrNeedNet                = errors.New("resource requires networking")
+	ErrSchemeUnsupported = errors.New("unsupported source scheme")
+	ErrPathNotAbsolute   = errors.New("path is not absolute")
+	ErrNotFound          = errors.New("resource not found")
+	ErrFailed            = errors.New("failed to fetch resource")
+	ErrNeedNet           = errors.New("resource requires networking")
+	ErrTooLarge          = util.ErrTooLarge
 
 	// ConfigHeaders are the HTTP headers that should be used when the Ignition
 	// config is being fetched
@@ -94,6 +94,24 @@ type Fetcher struct {
 	// network"-related errors to ErrNeedNet. That way, distro integrators
 	// could distinguish between "partial" and full network bring-up.
 	Offline bool
+
+	// Cache, if set, is used to avoid downloading the same resource more
+	// than once. It is shared between copies of the Fetcher.
+	Cache *Cache
+
+	// MaxBufferSize, if non-zero, is the largest resource FetchToBuffer
+	// will read into memory, after decompression. Larger resources result
+	// in ErrTooLarge. Callers which gunzip configs themselves apply the
+	// same limit with util.GunzipIfCompressed. It guards against exhausting memory with configs
+	// and other resources held in memory; Fetch isn't limited.
+	MaxBufferSize int64
+
+	// MaxTFTPSize, if non-zero, is the largest resource that will be
+	// transferred over TFTP, before decompression. TFTP doesn't report a
+	// resource's size up front, so a transfer is aborted with ErrTooLarge
+	// once it exceeds the limit. Unlike MaxBufferSize, it also applies to
+	// Fetch.
+	MaxTFTPSize int64
 }
 
 type FetchOptions struct {
@@ -109,6 +127,10 @@ type FetchOptions struct {
 	// nil, this field is ignored.
 	ExpectedSum []byte
 
+	// AlternateHashes are other hashes the fetched resource may match
+	// instead of Hash and ExpectedSum. They're ignored if Hash is nil.
+	AlternateHashes []util.ExpectedHash
+
 	// Compression specifies the type of compression to use when decompressing
 	// the fetched object. If left empty, no decompression will be used.
 	Compression string
@@ -116,6 +138,17 @@ type FetchOptions struct {
 	// HTTPVerb is an HTTP request method to indicate the desired action to
 	// be performed for a given resource.
 	HTTPVerb string
+
+	// MaxAttempts is the maximum number of attempts made when fetching
+	// http(s) resources. If zero, the config's httpMaxAttempts is used,
+	// and if that is also unset, failed requests are retried until the
+	// fetch times out.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry of a failed http(s)
+	// request. The delay doubles after each attempt, up to a fixed maximum.
+	// If zero, the config's httpRetryDelay or a default is used.
+	InitialBackoff time.Duration
 }
 
 // FetchToBuffer will fetch the given url into a temporary file, and then read
@@ -126,23 +159,53 @@ func (f *Fetcher) FetchToBuffer(u url.URL, opts FetchOptions) ([]byte, error) {
 		return nil, ErrNeedNet
 	}
 
+	key, cacheable := cacheKey(u, opts)
+	if cacheable && f.Cache != nil {
+		if data, ok := f.Cache.get(key); ok {
+			// the cache may be shared with fetchers with a larger limit
+			if f.MaxBufferSize > 0 && int64(len(data)) > f.MaxBufferSize {
+				return nil, ErrTooLarge
+			}
+			f.Logger.Debug("using cached copy of %s", u.String())
+			return append([]byte(nil), data...), nil
+		}
+	}
+	data, err := f.fetchToBuffer(u, opts)
+	if err == nil && cacheable && f.Cache != nil {
+		f.Cache.put(key, data)
+	}
+	return data, err
+}
+
+func (f *Fetcher) fetchToBuffer(u url.URL, opts FetchOptions) ([]byte, error) {
 	var err error
 	dest := new(bytes.Buffer)
+	var w io.Writer = dest
+	if f.MaxBufferSize > 0 {
+		w = &limitedWriter{w: dest, remaining: f.MaxBufferSize}
+	}
 	switch u.Scheme {
 	case "http", "https":
-		err = f.fetchFromHTTP(u, dest, opts)
+		err = f.fetchFromHTTP(u, w, opts)
 	case "tftp":
-		err = f.fetchFromTFTP(u, dest, opts)
+		err = f.fetchFromTFTP(u, w, opts)
 	case "data":
-		err = f.fetchFromDataURL(u, dest, opts)
+		err = f.fetchFromDataURL(u, w, opts)
 	case "s3":
 		buf := &s3buf{
 			WriteAtBuffer: aws.NewWriteAtBuffer([]byte{}),
+			max:           f.MaxBufferSize,
 		}
 		err = f.fetchFromS3(u, buf, opts)
+		if buf.tooLarge {
+			// the downloader's error doesn't wrap ours
+			return nil, ErrTooLarge
+		}
 		return buf.Bytes(), err
 	case "gs":
-		err = f.fetchFromGCS(u, dest, opts)
+		err = f.fetchFromGCS(u, w, opts)
+	case "file":
+		err = f.fetchFromFile(u, w, opts)
 	case "":
 		return nil, nil
 	default:
@@ -158,6 +221,18 @@ type s3buf struct {
 	*aws.WriteAtBuffer
 	// only safe to call read/seek after finishing writing. Not safe for parallel use
 	reader io.ReadSeeker
+	// max, if non-zero, is the largest object that may be written, so
+	// oversized objects are rejected while they're downloaded
+	max      int64
+	tooLarge bool
+}
+
+func (s *s3buf) WriteAt(p []byte, off int64) (int, error) {
+	if s.max > 0 && off+int64(len(p)) > s.max {
+		s.tooLarge = true
+		return 0, ErrTooLarge
+	}
+	return s.WriteAtBuffer.WriteAt(p, off)
 }
 
 func (s *s3buf) Read(p []byte) (int, error) {
@@ -174,6 +249,34 @@ func (s *s3buf) Seek(offset int64, whence int) (int64, error) {
 	return s.reader.Seek(offset, whence)
 }
 
+// limitedWriter passes writes through to w until more than remaining
+// bytes have been written, after which it fails with ErrTooLarge.
+type limitedWriter struct {
+	w         io.Writer
+	remaining int64
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining {
+		return 0, ErrTooLarge
+	}
+	l.remaining -= int64(len(p))
+	return l.w.Write(p)
+}
+
+// offsetWriter adapts an io.WriterAt into an io.Writer which writes
+// sequentially from the start of the underlying WriterAt.
+type offsetWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+func (o *offsetWriter) Write(p []byte) (int, error) {
+	n, err := o.w.WriteAt(p, o.off)
+	o.off += int64(n)
+	return n, err
+}
+
 // Fetch calls the appropriate FetchFrom* function based on the scheme of the
 // given URL. The results will be decompressed if compression is set in opts,
 // and written into dest. If opts.Hash is set the data stream will also be
@@ -189,6 +292,33 @@ func (f *Fetcher) Fetch(u url.URL, dest *os.File, opts FetchOptions) error {
 		return ErrNeedNet
 	}
 
+	key, cacheable := cacheKey(u, opts)
+	if !cacheable || f.Cache == nil {
+		return f.fetch(u, dest, opts)
+	}
+	if data, ok := f.Cache.get(key); ok {
+		f.Logger.Debug("using cached copy of %s", u.String())
+		_, err := dest.Write(data)
+		return err
+	}
+	if err := f.fetch(u, dest, opts); err != nil {
+		return err
+	}
+	info, err := dest.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() <= maxCachedSize {
+		data, err := ioutil.ReadAll(io.NewSectionReader(dest, 0, info.Size()))
+		if err != nil {
+			return err
+		}
+		f.Cache.put(key, data)
+	}
+	return nil
+}
+
+func (f *Fetcher) fetch(u url.URL, dest *os.File, opts FetchOptions) error {
 	switch u.Scheme {
 	case "http", "https":
 		return f.fetchFromHTTP(u, dest, opts)
@@ -200,6 +330,8 @@ func (f *Fetcher) Fetch(u url.URL, dest *os.File, opts FetchOptions) error {
 		return f.fetchFromS3(u, dest, opts)
 	case "gs":
 		return f.fetchFromGCS(u, dest, opts)
+	case "file":
+		return f.fetchFromFile(u, dest, opts)
 	case "":
 		return nil
 	default:
@@ -244,10 +376,15 @@ func (f *Fetcher) fetchFromTFTP(u url.URL, dest io.Writer, opts FetchOptions) er
 		}
 	}
 
+	var w io.Writer = pWriter
+	if f.MaxTFTPSize > 0 {
+		w = &limitedWriter{w: pWriter, remaining: f.MaxTFTPSize}
+	}
+
 	// A goroutine is used to handle writing the fetched data into the pipe
 	// while also copying it out of the pipe concurrently
 	go func() {
-		_, err := wt.WriteTo(pWriter)
+		_, err := wt.WriteTo(w)
 		doneChan <- err
 		err = pWriter.Close()
 		doneChan <- err
@@ -338,6 +475,19 @@ func (f *Fetcher) fetchFromDataURL(u url.URL, dest io.Writer, opts FetchOptions)
 	return f.decompressCopyHashAndVerify(dest, bytes.NewBuffer(url.Data), opts)
 }
 
+// FetchFromFile writes the contents of the local file at u's path into dest,
+// returning an error if one is encountered. The path is resolved on the
+// machine running Ignition, not relative to the root being provisioned.
+func (f *Fetcher) fetchFromFile(u url.URL, dest io.Writer, opts FetchOptions) error {
+	src, err := os.Open(u.Path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	return f.decompressCopyHashAndVerify(dest, src, opts)
+}
+
 // FetchFromGCS writes the data stored in a GCS bucket as described by u into dest, returning
 // an error if one is encountered. It looks for the default credentials by querying metadata
 // server on GCE. If it fails to get the credentials, then it will fall back to anonymous
@@ -386,9 +536,6 @@ type s3target interface {
 // IAM credentials from the EC2 metadata service, and if this fails will attempt
 // to fetch the object with anonymous credentials.
 func (f *Fetcher) fetchFromS3(u url.URL, dest s3target, opts FetchOptions) error {
-	if opts.Compression != "" {
-		return ErrCompressionUnsupported
-	}
 	ctx := context.Background()
 	if f.client != nil && f.client.timeout != 0 {
 		var cancelFn context.CancelFunc
@@ -432,28 +579,40 @@ func (f *Fetcher) fetchFromS3(u url.URL, dest s3target, opts FetchOptions) error
 		Key:       &u.Path,
 		VersionId: versionId,
 	}
+	if opts.Compression != "" {
+		// The downloader fetches chunks out of order, so the compressed
+		// object has to be buffered before it can be decompressed.
+		buf := &s3buf{
+			WriteAtBuffer: aws.NewWriteAtBuffer([]byte{}),
+		}
+		// the limit on dest also bounds the compressed object, so an
+		// oversized one isn't buffered in full before being rejected
+		destBuf, limited := dest.(*s3buf)
+		if limited {
+			buf.max = destBuf.max
+		}
+		if err := f.fetchFromS3WithCreds(ctx, buf, input, sess); err != nil {
+			if limited && buf.tooLarge {
+				destBuf.tooLarge = true
+			}
+			return fmt.Errorf("error while reading content from (%q): %v", u.String(), err)
+		}
+		return f.decompressCopyHashAndVerify(&offsetWriter{w: dest}, buf, opts)
+	}
 	err = f.fetchFromS3WithCreds(ctx, dest, input, sess)
 	if err != nil {
-		return err
+		return fmt.Errorf("error while reading content from (%q): %v", u.String(), err)
 	}
-	if opts.Hash != nil {
-		opts.Hash.Reset()
+	if hashes := opts.expectedHashes(); len(hashes) > 0 {
 		_, err = dest.Seek(0, io.SeekStart)
 		if err != nil {
 			return err
 		}
-		_, err = io.Copy(opts.Hash, dest)
+		_, err = io.Copy(hashWriter(hashes), dest)
 		if err != nil {
 			return err
 		}
-		calculatedSum := opts.Hash.Sum(nil)
-		if !bytes.Equal(calculatedSum, opts.ExpectedSum) {
-			return util.ErrHashMismatch{
-				Calculated: hex.EncodeToString(calculatedSum),
-				Expected:   hex.EncodeToString(opts.ExpectedSum),
-			}
-		}
-		f.Logger.Debug("file matches expected sum of: %s", hex.EncodeToString(opts.ExpectedSum))
+		return f.verifyHashes(hashes)
 	}
 	return nil
 }
@@ -502,23 +661,55 @@ func (f *Fetcher) decompressCopyHashAndVerify(dest io.Writer, src io.Reader, opt
 		return err
 	}
 	defer decompressor.Close()
-	if opts.Hash != nil {
-		opts.Hash.Reset()
-		dest = io.MultiWriter(dest, opts.Hash)
+	hashes := opts.expectedHashes()
+	if len(hashes) > 0 {
+		dest = io.MultiWriter(dest, hashWriter(hashes))
 	}
 	_, err = io.Copy(dest, decompressor)
 	if err != nil {
 		return err
 	}
-	if opts.Hash != nil {
-		calculatedSum := opts.Hash.Sum(nil)
-		if !bytes.Equal(calculatedSum, opts.ExpectedSum) {
-			return util.ErrHashMismatch{
-				Calculated: hex.EncodeToString(calculatedSum),
-				Expected:   hex.EncodeToString(opts.ExpectedSum),
-			}
-		}
-		f.Logger.Debug("file matches expected sum of: %s", hex.EncodeToString(opts.ExpectedSum))
+	return f.verifyHashes(hashes)
+}
+
+// SetHashes sets the hashes the fetched resource must match at least one
+// of, replacing Hash, ExpectedSum, and AlternateHashes.
+func (opts *FetchOptions) SetHashes(hashes []util.ExpectedHash) {
+	opts.Hash, opts.ExpectedSum, opts.AlternateHashes = nil, nil, nil
+	if len(hashes) > 0 {
+		opts.Hash = hashes[0].Hash
+		opts.ExpectedSum = hashes[0].Sum
+		opts.AlternateHashes = hashes[1:]
+	}
+}
+
+// expectedHashes returns every hash the fetched resource may match.
+func (opts FetchOptions) expectedHashes() []util.ExpectedHash {
+	if opts.Hash == nil {
+		return nil
+	}
+	return append([]util.ExpectedHash{{Hash: opts.Hash, Sum: opts.ExpectedSum}}, opts.AlternateHashes...)
+}
+
+// hashWriter resets hashes and returns a writer which feeds all of them.
+func hashWriter(hashes []util.ExpectedHash) io.Writer {
+	writers := make([]io.Writer, len(hashes))
+	for i, h := range hashes {
+		h.Hash.Reset()
+		writers[i] = h.Hash
+	}
+	return io.MultiWriter(writers...)
+}
+
+// verifyHashes checks that the data written to hashes matches at least one
+// of their expected sums.
+func (f *Fetcher) verifyHashes(hashes []util.ExpectedHash) error {
+	sum, err := util.VerifyHashes(hashes)
+	if err != nil {
+		return err
+	}
+	if sum != nil {
+		f.Logger.Debug("file matches expected sum of: %s", hex.EncodeToString(sum))
 	}
 	return nil
 }
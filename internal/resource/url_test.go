@@ -15,12 +15,25 @@
 package resource
 
 import (
+	"bytes"
 	"compress/gzip"
+	"crypto/sha256"
 	"crypto/sha512"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/pin/tftp"
+
 	"github.com/coreos/ignition/v2/config/shared/errors"
 	"github.com/coreos/ignition/v2/internal/log"
 	"github.com/coreos/ignition/v2/internal/util"
@@ -63,6 +76,31 @@ func TestDataUrl(t *testing.T) {
 				Expected:   "db3974a97f2407b7cae1ae637c0030687a11913274d578492558e39c16c017de84eacdc8c62fe34ee4e12b4b1428817f09b6a2760c3f8a664ceae94d2434a500",
 			}},
 		},
+		// data url, sha256
+		{
+			in: in{
+				url: "data:,hello%20world%0a",
+				opts: FetchOptions{
+					Hash:        sha256.New(),
+					ExpectedSum: []byte("\xa9\x48\x90\x4f\x2f\x0f\x47\x9b\x8f\x81\x97\x69\x4b\x30\x18\x4b\x0d\x2e\xd1\xc1\xcd\x2a\x1e\xc0\xfb\x85\xd2\x99\xa1\x92\xa4\x47"),
+				},
+			},
+			out: out{data: []byte("hello world\n")},
+		},
+		// data url, sha256, corrupted data
+		{
+			in: in{
+				url: "data:,hello%20world%0b",
+				opts: FetchOptions{
+					Hash:        sha256.New(),
+					ExpectedSum: []byte("\xa9\x48\x90\x4f\x2f\x0f\x47\x9b\x8f\x81\x97\x69\x4b\x30\x18\x4b\x0d\x2e\xd1\xc1\xcd\x2a\x1e\xc0\xfb\x85\xd2\x99\xa1\x92\xa4\x47"),
+				},
+			},
+			out: out{err: util.ErrHashMismatch{
+				Calculated: "8150c43a165dce02b4f1226d1e3123ff8abca259bc8cf6b335bccfa670070979",
+				Expected:   "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447",
+			}},
+		},
 		// data url, gzipped
 		{
 			in: in{
@@ -122,7 +160,9 @@ func TestDataUrl(t *testing.T) {
 			t.Errorf("#%d: parsing URL: %v", i, err)
 			continue
 		}
-		test.in.opts.Hash = sha512.New()
+		if test.in.opts.Hash == nil {
+			test.in.opts.Hash = sha512.New()
+		}
 		result, err := f.FetchToBuffer(*u, test.in.opts)
 		if !reflect.DeepEqual(test.out.err, err) {
 			t.Errorf("#%d: fetching URL: expected error %+v, got %+v", i, test.out.err, err)
@@ -135,6 +175,226 @@ func TestDataUrl(t *testing.T) {
 	}
 }
 
+func TestFetchFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignition-fetch-file")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string][]byte{
+		"hello":    []byte("hello world\n"),
+		"hello.gz": []byte("\x1f\x8b\x08\x08\x90e\xab^\x02\x03z\x00K\xadH\xcc-\xc8IUH\xcb\xccI\xe5\x02\x00tp\xa6\xcb\x0d\x00\x00\x00"),
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path string
+		opts FetchOptions
+		data []byte
+		err  error
+	}{
+		{
+			path: "hello",
+			opts: FetchOptions{
+				Hash:        sha256.New(),
+				ExpectedSum: []byte("\xa9\x48\x90\x4f\x2f\x0f\x47\x9b\x8f\x81\x97\x69\x4b\x30\x18\x4b\x0d\x2e\xd1\xc1\xcd\x2a\x1e\xc0\xfb\x85\xd2\x99\xa1\x92\xa4\x47"),
+			},
+			data: []byte("hello world\n"),
+		},
+		{
+			path: "hello",
+			opts: FetchOptions{
+				Hash:        sha256.New(),
+				ExpectedSum: []byte("\xa9\x48\x90\x4f\x2f\x0f\x47\x9b\x8f\x81\x97\x69\x4b\x30\x18\x4b\x0d\x2e\xd1\xc1\xcd\x2a\x1e\xc0\xfb\x85\xd2\x99\xa1\x92\xa4\x00"),
+			},
+			err: util.ErrHashMismatch{
+				Calculated: "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447",
+				Expected:   "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a400",
+			},
+		},
+		{
+			path: "hello.gz",
+			opts: FetchOptions{
+				Compression: "gzip",
+			},
+			data: []byte("example file\n"),
+		},
+		{
+			path: "missing",
+			err:  os.ErrNotExist,
+		},
+	}
+
+	logger := log.New(true)
+	// local files don't need networking
+	f := Fetcher{
+		Logger:  &logger,
+		Offline: true,
+	}
+
+	for i, test := range tests {
+		u := url.URL{Scheme: "file", Path: filepath.Join(dir, test.path)}
+		for _, toFile := range []bool{false, true} {
+			if test.opts.Hash != nil {
+				test.opts.Hash.Reset()
+			}
+			var result []byte
+			if toFile {
+				var dest *os.File
+				dest, err = ioutil.TempFile(dir, "dest")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err = f.Fetch(u, dest, test.opts); err == nil {
+					result, err = ioutil.ReadFile(dest.Name())
+				}
+				dest.Close()
+			} else {
+				result, err = f.FetchToBuffer(u, test.opts)
+			}
+			if test.err == os.ErrNotExist {
+				if !os.IsNotExist(err) {
+					t.Errorf("#%d: expected error %v, got %v", i, test.err, err)
+				}
+				continue
+			}
+			if !reflect.DeepEqual(test.err, err) {
+				t.Errorf("#%d: expected error %+v, got %+v", i, test.err, err)
+				continue
+			}
+			if test.err == nil && !reflect.DeepEqual(test.data, result) {
+				t.Errorf("#%d: expected output %q, got %q", i, test.data, result)
+			}
+		}
+	}
+}
+
+func TestFetchFromTFTP(t *testing.T) {
+	files := map[string][]byte{
+		"/hello":    []byte("hello world\n"),
+		"/hello.gz": []byte("\x1f\x8b\x08\x08\x90e\xab^\x02\x03z\x00K\xadH\xcc-\xc8IUH\xcb\xccI\xe5\x02\x00tp\xa6\xcb\x0d\x00\x00\x00"),
+	}
+	server := tftp.NewServer(func(filename string, rf io.ReaderFrom) error {
+		data, ok := files[filename]
+		if !ok {
+			return fmt.Errorf("file not found")
+		}
+		_, err := rf.ReadFrom(bytes.NewReader(data))
+		return err
+	}, nil)
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	go server.Serve(conn)
+	defer server.Shutdown()
+	addr := conn.LocalAddr().String()
+
+	type in struct {
+		path    string
+		opts    FetchOptions
+		maxSize int64
+	}
+	type out struct {
+		data  []byte
+		error bool
+		err   error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in: in{
+				path: "/hello",
+				opts: FetchOptions{
+					Hash:        sha512.New(),
+					ExpectedSum: []byte("\xdb\x39\x74\xa9\x7f\x24\x07\xb7\xca\xe1\xae\x63\x7c\x00\x30\x68\x7a\x11\x91\x32\x74\xd5\x78\x49\x25\x58\xe3\x9c\x16\xc0\x17\xde\x84\xea\xcd\xc8\xc6\x2f\xe3\x4e\xe4\xe1\x2b\x4b\x14\x28\x81\x7f\x09\xb6\xa2\x76\x0c\x3f\x8a\x66\x4c\xea\xe9\x4d\x24\x34\xa5\x93"),
+				},
+			},
+			out: out{data: []byte("hello world\n")},
+		},
+		{
+			in: in{
+				path: "/hello",
+				opts: FetchOptions{
+					Hash:        sha512.New(),
+					ExpectedSum: []byte("\x00"),
+				},
+			},
+			out: out{error: true},
+		},
+		{
+			in: in{
+				path: "/hello.gz",
+				opts: FetchOptions{
+					Compression: "gzip",
+				},
+			},
+			out: out{data: []byte("example file\n")},
+		},
+		{
+			in: in{
+				path: "/missing",
+			},
+			out: out{error: true},
+		},
+		{
+			in: in{
+				path:    "/hello",
+				maxSize: 12,
+			},
+			out: out{data: []byte("hello world\n")},
+		},
+		{
+			in: in{
+				path:    "/hello",
+				maxSize: 11,
+			},
+			out: out{error: true, err: ErrTooLarge},
+		},
+		// the limit applies before decompression
+		{
+			in: in{
+				path:    "/hello.gz",
+				opts:    FetchOptions{Compression: "gzip"},
+				maxSize: 13,
+			},
+			out: out{error: true, err: ErrTooLarge},
+		},
+	}
+
+	logger := log.New(true)
+	for i, test := range tests {
+		f := Fetcher{
+			Logger:      &logger,
+			MaxTFTPSize: test.in.maxSize,
+		}
+		u := url.URL{Scheme: "tftp", Host: addr, Path: test.in.path}
+		result, err := f.FetchToBuffer(u, test.in.opts)
+		if test.out.error {
+			if err == nil {
+				t.Errorf("#%d: expected error, got none", i)
+			} else if test.out.err != nil && err != test.out.err {
+				t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: fetching URL: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(test.out.data, result) {
+			t.Errorf("#%d: expected output %q, got %q", i, test.out.data, result)
+		}
+	}
+}
+
 func TestFetchOffline(t *testing.T) {
 	type in struct {
 		url  string
@@ -215,7 +475,9 @@ func TestFetchOffline(t *testing.T) {
 			t.Errorf("#%d: parsing URL: %v", i, err)
 			continue
 		}
-		test.in.opts.Hash = sha512.New()
+		if test.in.opts.Hash == nil {
+			test.in.opts.Hash = sha512.New()
+		}
 		result, err := f.FetchToBuffer(*u, test.in.opts)
 		if !reflect.DeepEqual(test.out.err, err) {
 			t.Errorf("#%d: fetching URL: expected error %+v, got %+v", i, test.out.err, err)
@@ -227,3 +489,225 @@ func TestFetchOffline(t *testing.T) {
 		}
 	}
 }
+
+// Fetching to a file must honor offline mode the same way as fetching to
+// a buffer.
+func TestFetchOfflineToFile(t *testing.T) {
+	tests := []struct {
+		url  string
+		data []byte
+		err  error
+	}{
+		{
+			url:  "data:,hello%20world%0a",
+			data: []byte("hello world\n"),
+		},
+		{
+			url: "http://127.0.0.1/config",
+			err: ErrNeedNet,
+		},
+		{
+			url: "https://127.0.0.1/config",
+			err: ErrNeedNet,
+		},
+		{
+			url: "tftp://127.0.0.1/config",
+			err: ErrNeedNet,
+		},
+		{
+			url: "s3://kola-fixtures/resources/anonymous",
+			err: ErrNeedNet,
+		},
+		{
+			url: "gs://foo/bar",
+			err: ErrNeedNet,
+		},
+	}
+
+	logger := log.New(true)
+	f := Fetcher{
+		Logger:  &logger,
+		Offline: true,
+		Cache:   NewCache(),
+	}
+
+	for i, test := range tests {
+		u, err := url.Parse(test.url)
+		if err != nil {
+			t.Errorf("#%d: parsing URL: %v", i, err)
+			continue
+		}
+		dest, err := ioutil.TempFile("", "ignition-resource-test")
+		if err != nil {
+			t.Fatalf("creating temp file: %v", err)
+		}
+		defer os.Remove(dest.Name())
+		defer dest.Close()
+
+		err = f.Fetch(*u, dest, FetchOptions{})
+		if err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+			continue
+		}
+		if test.err != nil {
+			continue
+		}
+		data, err := ioutil.ReadFile(dest.Name())
+		if err != nil {
+			t.Fatalf("#%d: reading result: %v", i, err)
+		}
+		if !bytes.Equal(test.data, data) {
+			t.Errorf("#%d: bad data: want %q, got %q", i, test.data, data)
+		}
+	}
+}
+
+func TestFetchToBufferMaxSize(t *testing.T) {
+	big := bytes.Repeat([]byte("a"), 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(big)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		url  string
+		opts FetchOptions
+		max  int64
+		err  error
+	}{
+		{
+			url: "data:,hello%20world%0a",
+			max: 12,
+		},
+		{
+			url: "data:,hello%20world%0a",
+			max: 11,
+			err: ErrTooLarge,
+		},
+		// no limit
+		{
+			url: "data:,hello%20world%0a",
+		},
+		// the limit applies after decompression
+		{
+			url:  "data:,%1F%8B%08%08%90e%AB%5E%02%03z%00K%ADH%CC-%C8IUH%CB%CCI%E5%02%00tp%A6%CB%0D%00%00%00",
+			opts: FetchOptions{Compression: "gzip"},
+			max:  13,
+		},
+		{
+			url:  "data:,%1F%8B%08%08%90e%AB%5E%02%03z%00K%ADH%CC-%C8IUH%CB%CCI%E5%02%00tp%A6%CB%0D%00%00%00",
+			opts: FetchOptions{Compression: "gzip"},
+			max:  12,
+			err:  ErrTooLarge,
+		},
+		{
+			url: server.URL,
+			max: 100,
+		},
+		{
+			url: server.URL,
+			max: 64,
+			err: ErrTooLarge,
+		},
+	}
+
+	logger := log.New(true)
+	// shared, so that the limit must also be checked for cached resources
+	cache := NewCache()
+	for i, test := range tests {
+		u, err := url.Parse(test.url)
+		if err != nil {
+			t.Errorf("#%d: parsing URL: %v", i, err)
+			continue
+		}
+		f := Fetcher{
+			Logger:        &logger,
+			MaxBufferSize: test.max,
+			Cache:         cache,
+		}
+		_, err = f.FetchToBuffer(*u, test.opts)
+		if err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+func TestOffsetWriter(t *testing.T) {
+	tests := []struct {
+		in  []string
+		out []byte
+	}{
+		{
+			in:  []string{},
+			out: []byte{},
+		},
+		{
+			in:  []string{"hello world\n"},
+			out: []byte("hello world\n"),
+		},
+		{
+			in:  []string{"hello", " ", "world\n"},
+			out: []byte("hello world\n"),
+		},
+	}
+
+	for i, test := range tests {
+		buf := aws.NewWriteAtBuffer([]byte{})
+		w := &offsetWriter{w: buf}
+		for _, chunk := range test.in {
+			if _, err := w.Write([]byte(chunk)); err != nil {
+				t.Errorf("#%d: writing chunk: %v", i, err)
+			}
+		}
+		if !reflect.DeepEqual(test.out, buf.Bytes()) {
+			t.Errorf("#%d: expected output %q, got %q", i, test.out, buf.Bytes())
+		}
+	}
+}
+
+func TestS3BufMaxSize(t *testing.T) {
+	type write struct {
+		data string
+		off  int64
+	}
+	tests := []struct {
+		max    int64
+		writes []write
+		err    error
+	}{
+		{
+			max:    0,
+			writes: []write{{"hello ", 0}, {"world\n", 6}},
+		},
+		{
+			max:    12,
+			writes: []write{{"world\n", 6}, {"hello ", 0}},
+		},
+		// the downloader writes parts out of order, so the end of a
+		// later part can exceed the limit first
+		{
+			max:    11,
+			writes: []write{{"world\n", 6}, {"hello ", 0}},
+			err:    ErrTooLarge,
+		},
+	}
+
+	for i, test := range tests {
+		buf := &s3buf{
+			WriteAtBuffer: aws.NewWriteAtBuffer([]byte{}),
+			max:           test.max,
+		}
+		var err error
+		for _, w := range test.writes {
+			if _, err = buf.WriteAt([]byte(w.data), w.off); err != nil {
+				break
+			}
+		}
+		if err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+		}
+		if buf.tooLarge != (test.err == ErrTooLarge) {
+			t.Errorf("#%d: bad tooLarge: got %t", i, buf.tooLarge)
+		}
+	}
+}
@@ -148,6 +148,9 @@ func (op Operation) buildOptions() []string {
 		if util.NotEmpty(p.GUID) {
 			opts = append(opts, fmt.Sprintf("--partition-guid=%d:%s", p.Number, *p.GUID))
 		}
+		for _, attr := range p.Attributes {
+			opts = append(opts, fmt.Sprintf("--attributes=%d:set:%d", p.Number, attr))
+		}
 	}
 
 	for _, partition := range op.infos {
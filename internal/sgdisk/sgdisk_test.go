@@ -0,0 +1,96 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sgdisk
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_4_experimental/types"
+)
+
+func int64ToPtr(x int64) *int64 {
+	return &x
+}
+
+func TestBuildOptions(t *testing.T) {
+	tests := []struct {
+		wipe      bool
+		parts     []Partition
+		deletions []int
+		out       []string
+	}{
+		{
+			out: nil,
+		},
+		{
+			wipe: true,
+			out:  []string{"--zap-all", "/dev/vda"},
+		},
+		{
+			parts: []Partition{
+				{
+					Partition: types.Partition{
+						Number:   1,
+						Label:    util.StrToPtr("data"),
+						TypeGUID: util.StrToPtr("0FC63DAF-8483-4772-8E79-3D69D8477DE4"),
+						GUID:     util.StrToPtr("9F3E0C52-1B2A-4C8D-9E4F-5A6B7C8D9E0F"),
+					},
+					StartSector:   int64ToPtr(2048),
+					SizeInSectors: int64ToPtr(4096),
+				},
+			},
+			deletions: []int{2},
+			out: []string{
+				"--delete=2",
+				"--new=1:2048:+4096",
+				"--change-name=1:data",
+				"--typecode=1:0FC63DAF-8483-4772-8E79-3D69D8477DE4",
+				"--partition-guid=1:9F3E0C52-1B2A-4C8D-9E4F-5A6B7C8D9E0F",
+				"/dev/vda",
+			},
+		},
+		// legacy BIOS bootable
+		{
+			parts: []Partition{
+				{
+					Partition: types.Partition{
+						Number:     1,
+						Attributes: []types.PartitionAttribute{2},
+					},
+				},
+			},
+			out: []string{
+				"--new=1:0:+0",
+				"--attributes=1:set:2",
+				"/dev/vda",
+			},
+		},
+	}
+
+	for i, test := range tests {
+		op := Operation{
+			dev:       "/dev/vda",
+			wipe:      test.wipe,
+			parts:     test.parts,
+			deletions: test.deletions,
+		}
+		out := op.buildOptions()
+		if !reflect.DeepEqual(test.out, out) {
+			t.Errorf("#%d: bad options: want %v, got %v", i, test.out, out)
+		}
+	}
+}
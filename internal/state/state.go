@@ -36,6 +36,13 @@ type State struct {
 	// created by the mount stage so the files stage can chown them
 	// when creating users.
 	NotatedDirectories []string `json:"notatedDirectories"`
+	// Devices of the filesystems created by the disks stage, so the
+	// mount stage can tell them apart from reused filesystems.
+	CreatedFilesystems []string `json:"createdFilesystems"`
+	// Instance metadata fetched from the platform by the fetch stages
+	// when the config has templated files, for substitution by the
+	// files stage.
+	Metadata map[string]string `json:"metadata"`
 }
 
 type FetchedConfig struct {
@@ -0,0 +1,67 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+
+	ErrTooLarge = errors.New("resource exceeds the maximum size")
+)
+
+// IsGzipped returns whether data starts with the gzip magic number.
+func IsGzipped(data []byte) bool {
+	return bytes.HasPrefix(data, gzipMagic)
+}
+
+// GunzipIfCompressed returns data decompressed if it starts with the gzip
+// magic number, and unchanged otherwise. This lets users gzip configs that
+// are too large for their platform's user-data limits. If max is non-zero,
+// decompressed data larger than max results in ErrTooLarge, so a small
+// compressed config can't exhaust memory.
+func GunzipIfCompressed(data []byte, max int64) ([]byte, error) {
+	if !IsGzipped(data) {
+		return data, nil
+	}
+	return Gunzip(data, max)
+}
+
+// Gunzip decompresses gzipped data. If max is non-zero, decompressed data
+// larger than max results in ErrTooLarge.
+func Gunzip(data []byte, max int64) ([]byte, error) {
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+	if max <= 0 {
+		return ioutil.ReadAll(gr)
+	}
+	out, err := ioutil.ReadAll(io.LimitReader(gr, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(out)) > max {
+		return nil, ErrTooLarge
+	}
+	return out, nil
+}
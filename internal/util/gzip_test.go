@@ -0,0 +1,114 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("compressing: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("compressing: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestGunzipIfCompressed(t *testing.T) {
+	config := []byte(`{"ignition": {"version": "3.4.0-experimental"}}`)
+
+	bomb := gzipBytes(t, make([]byte, 1024*1024))
+
+	tests := []struct {
+		in     []byte
+		max    int64
+		out    []byte
+		err    error
+		hasErr bool
+	}{
+		{
+			in:  nil,
+			out: nil,
+		},
+		{
+			in:  config,
+			out: config,
+		},
+		{
+			in:  gzipBytes(t, config),
+			out: config,
+		},
+		// gzip magic number with a truncated stream
+		{
+			in:     gzipBytes(t, config)[:12],
+			hasErr: true,
+		},
+		// the limit applies to the decompressed size
+		{
+			in:  gzipBytes(t, config),
+			max: int64(len(config)),
+			out: config,
+		},
+		{
+			in:  gzipBytes(t, config),
+			max: int64(len(config) - 1),
+			err: ErrTooLarge,
+		},
+		{
+			in:  bomb,
+			max: 64 * 1024,
+			err: ErrTooLarge,
+		},
+		{
+			in:  bomb,
+			out: make([]byte, 1024*1024),
+		},
+		// uncompressed data is returned as-is
+		{
+			in:  config,
+			max: 1,
+			out: config,
+		},
+	}
+
+	for i, test := range tests {
+		out, err := GunzipIfCompressed(test.in, test.max)
+		if test.err != nil {
+			if err != test.err {
+				t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+			}
+			continue
+		}
+		if test.hasErr {
+			if err == nil {
+				t.Errorf("#%d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(test.out, out) {
+			t.Errorf("#%d: bad output: want %q, got %q", i, test.out, out)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/ignition/v2/internal/distro"
+)
+
+const (
+	cmdlineSigningKeyringFlag = "ignition.config.signing_keyring"
+	signatureSuffix           = ".sig"
+)
+
+var (
+	ErrSignatureMissing   = errors.New("config signature not found")
+	ErrSignatureInvalid   = errors.New("config signature verification failed")
+	ErrKeyringNotAbsolute = errors.New("config signing keyring must be an absolute path")
+)
+
+// ConfigSigningKeyring returns the path to the OpenPGP keyring that fetched
+// configs must be signed with, or "" if signatures aren't required. A keyring
+// baked in at build time takes precedence over the kernel command line.
+func ConfigSigningKeyring() (string, error) {
+	keyring := distro.ConfigSigningKeyring()
+	if keyring == "" {
+		cmdline, err := ioutil.ReadFile(distro.KernelCmdlinePath())
+		if err != nil {
+			return "", err
+		}
+		keyring = parseSigningKeyring(cmdline)
+	}
+	// gpgv looks up relative keyring paths in its home directory
+	if keyring != "" && !filepath.IsAbs(keyring) {
+		return "", ErrKeyringNotAbsolute
+	}
+	return keyring, nil
+}
+
+func parseSigningKeyring(cmdline []byte) (keyring string) {
+	for _, arg := range strings.Fields(string(cmdline)) {
+		parts := strings.SplitN(arg, "=", 2)
+		if parts[0] == cmdlineSigningKeyringFlag && len(parts) == 2 {
+			keyring = parts[1]
+		}
+	}
+	return
+}
+
+// SignatureURL returns the URL of the detached signature for the config at u.
+func SignatureURL(u url.URL) url.URL {
+	u.Path += signatureSuffix
+	u.RawPath = ""
+	return u
+}
+
+// VerifySignature checks that sig is a detached OpenPGP signature of data
+// made by a key in keyring. The check is done by gpgv, which only accepts
+// binary keyrings (as written by "gpg --export").
+func VerifySignature(keyring string, data, sig []byte) error {
+	if len(sig) == 0 {
+		return ErrSignatureMissing
+	}
+	dir, err := ioutil.TempDir("", "ignition-signature")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	dataPath := filepath.Join(dir, "config")
+	sigPath := filepath.Join(dir, "config"+signatureSuffix)
+	if err := ioutil.WriteFile(dataPath, data, 0600); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(sigPath, sig, 0600); err != nil {
+		return err
+	}
+
+	// point the home directory at our scratch directory so gpgv doesn't
+	// go looking for (or try to create) one of its own
+	cmd := exec.Command(distro.GpgvCmd(), "--homedir", dir, "--keyring", keyring, sigPath, dataPath)
+	out, err := cmd.CombinedOutput()
+	if _, ok := err.(*exec.ExitError); ok {
+		return fmt.Errorf("%w: %s", ErrSignatureInvalid, strings.TrimSpace(string(out)))
+	} else if err != nil {
+		return fmt.Errorf("running %s: %w", distro.GpgvCmd(), err)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/ignition/v2/internal/distro"
+)
+
+const (
+	// binary export of an ed25519 key for "Ignition Test <test@example.com>"
+	testKeyring = "mDMEatCHARYJKwYBBAHaRw8BAQdAaRHoeK5f/bSVnEMn9xVDLYoor3eZxVH4m67P3NHM9aS0IElnbml0aW9uIFRlc3QgPHRlc3RAZXhhbXBsZS5jb20+iJAEExYIADgWIQQwckCQMvJWetYMUVKGhOQYn/KDJwUCatCHAQIbAwULCQgHAgYVCgkICwIEFgIDAQIeAQIXgAAKCRCGhOQYn/KDJwmXAQDst0b78jdioLT8H71qldB3QDPyud4g18PiATdTBMtBcQEApdRDQCYFOJ2DQcYaJteqXHGlfdEVKxU5c2C4aLWBkAI="
+
+	testSignedConfig = `{"ignition": {"version": "3.4.0-experimental"}}`
+
+	// signature of testSignedConfig by the key in testKeyring
+	testSignature = `-----BEGIN PGP SIGNATURE-----
+
+iHUEABYIAB0WIQQwckCQMvJWetYMUVKGhOQYn/KDJwUCatCHAQAKCRCGhOQYn/KD
+J03fAQD/2BdRWzZaEJxTQfK3PwkLI6pjNrRxV5LFcTHMem6GQAEA2vYyKI6bYBRa
+wfPXVz7xzxQ2m7aKFdTsSR9wV50qyg4=
+=4h4k
+-----END PGP SIGNATURE-----
+`
+
+	// signature of testSignedConfig by a key not in testKeyring
+	testUntrustedSignature = `-----BEGIN PGP SIGNATURE-----
+
+iHUEABYIAB0WIQSidfCdq7N3b05oFljfA0vGLIZvCwUCatCHBQAKCRDfA0vGLIZv
+C/ZJAP0WvzPZnPKopouVCEw2fGKx8iyMHbwt8H+JeQ1HJt20AwD9Ho4WTepy1C0+
+0MAuU5N9TLm61mv2DXZ+367yO/VBvQU=
+=t2f2
+-----END PGP SIGNATURE-----
+`
+)
+
+func TestVerifySignature(t *testing.T) {
+	if _, err := exec.LookPath(distro.GpgvCmd()); err != nil {
+		t.Skipf("%s not available", distro.GpgvCmd())
+	}
+
+	dir, err := ioutil.TempDir("", "ignition-signature-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keyring, err := base64.StdEncoding.DecodeString(testKeyring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyringPath := filepath.Join(dir, "keyring.gpg")
+	if err := ioutil.WriteFile(keyringPath, keyring, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		data string
+		sig  string
+		err  error
+	}{
+		{
+			data: testSignedConfig,
+			sig:  testSignature,
+		},
+		// tampered config
+		{
+			data: `{"ignition": {"version": "3.4.0-experimental"}, "passwd": {}}`,
+			sig:  testSignature,
+			err:  ErrSignatureInvalid,
+		},
+		// signed by someone else
+		{
+			data: testSignedConfig,
+			sig:  testUntrustedSignature,
+			err:  ErrSignatureInvalid,
+		},
+		// garbage signature
+		{
+			data: testSignedConfig,
+			sig:  "not a signature",
+			err:  ErrSignatureInvalid,
+		},
+		// unsigned
+		{
+			data: testSignedConfig,
+			err:  ErrSignatureMissing,
+		},
+	}
+
+	for i, test := range tests {
+		err := VerifySignature(keyringPath, []byte(test.data), []byte(test.sig))
+		if !errors.Is(err, test.err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+func TestParseSigningKeyring(t *testing.T) {
+	tests := []struct {
+		cmdline string
+		keyring string
+	}{
+		{
+			cmdline: "",
+			keyring: "",
+		},
+		{
+			cmdline: "ro ignition.config.url=http://example.com/config.ign",
+			keyring: "",
+		},
+		{
+			cmdline: "ignition.config.signing_keyring=/etc/keyring.gpg quiet\n",
+			keyring: "/etc/keyring.gpg",
+		},
+		{
+			cmdline: "ignition.config.signing_keyring",
+			keyring: "",
+		},
+	}
+
+	for i, test := range tests {
+		keyring := parseSigningKeyring([]byte(test.cmdline))
+		if keyring != test.keyring {
+			t.Errorf("#%d: bad keyring: want %q, got %q", i, test.keyring, keyring)
+		}
+	}
+}
+
+func TestSignatureURL(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{
+			in:  "http://example.com/config.ign",
+			out: "http://example.com/config.ign.sig",
+		},
+		{
+			in:  "https://example.com/config.ign?token=abc",
+			out: "https://example.com/config.ign.sig?token=abc",
+		},
+		{
+			in:  "file:///etc/config.ign",
+			out: "file:///etc/config.ign.sig",
+		},
+	}
+
+	for i, test := range tests {
+		u, err := url.Parse(test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sigURL := SignatureURL(*u)
+		if sigURL.String() != test.out {
+			t.Errorf("#%d: bad URL: want %q, got %q", i, test.out, sigURL.String())
+		}
+	}
+}
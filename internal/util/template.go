@@ -0,0 +1,55 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// templateVarRegex matches a variable reference such as "{{ hostname }}".
+// Anything else, including other uses of braces, is left alone.
+var templateVarRegex = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// ErrTemplateUnknownVariable is returned when a template refers to a
+// variable which has no value.
+type ErrTemplateUnknownVariable struct {
+	Name string
+}
+
+func (e ErrTemplateUnknownVariable) Error() string {
+	return fmt.Sprintf("unknown template variable %q", e.Name)
+}
+
+// RenderTemplate replaces each variable reference in data with its value
+// from vars. The substituted values aren't themselves expanded.
+func RenderTemplate(data []byte, vars map[string]string) ([]byte, error) {
+	var err error
+	out := templateVarRegex.ReplaceAllFunc(data, func(match []byte) []byte {
+		name := string(templateVarRegex.FindSubmatch(match)[1])
+		value, ok := vars[name]
+		if !ok {
+			if err == nil {
+				err = ErrTemplateUnknownVariable{Name: name}
+			}
+			return match
+		}
+		return []byte(value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
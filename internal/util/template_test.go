@@ -0,0 +1,75 @@
+// Copyright 2022 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	vars := map[string]string{
+		"hostname":    "node1.example.com",
+		"instance_id": "i-0123456789",
+		"recursive":   "{{ hostname }}",
+	}
+
+	tests := []struct {
+		in  string
+		out string
+		err error
+	}{
+		{
+			in:  "",
+			out: "",
+		},
+		{
+			in:  "no variables here",
+			out: "no variables here",
+		},
+		{
+			in:  "{{hostname}}",
+			out: "node1.example.com",
+		},
+		{
+			in:  "host={{ hostname }} id={{  instance_id  }}\n",
+			out: "host=node1.example.com id=i-0123456789\n",
+		},
+		// values aren't expanded again
+		{
+			in:  "{{ recursive }}",
+			out: "{{ hostname }}",
+		},
+		// braces which aren't variable references are untouched
+		{
+			in:  "func() { return {} } {{ }} {{ not a variable }} {hostname}",
+			out: "func() { return {} } {{ }} {{ not a variable }} {hostname}",
+		},
+		{
+			in:  "{{ hostname }} {{ region }}",
+			err: ErrTemplateUnknownVariable{Name: "region"},
+		},
+	}
+
+	for i, test := range tests {
+		out, err := RenderTemplate([]byte(test.in), vars)
+		if err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+			continue
+		}
+		if err == nil && string(out) != test.out {
+			t.Errorf("#%d: bad output: want %q, got %q", i, test.out, string(out))
+		}
+	}
+}
@@ -15,6 +15,7 @@
 package util
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/hex"
@@ -58,36 +59,72 @@ func HashParts(v types.Verification) (string, string, error) {
 	return parts[0], parts[1], nil
 }
 
+// AssertValid checks that data matches the hash in verify, or any of its
+// alternative hashes.
 func AssertValid(verify types.Verification, data []byte) error {
-	if hash := verify.Hash; hash != nil {
-		hashFunc, hashSum, err := HashParts(verify)
+	hashes, err := ExpectedHashes(verify)
+	if err != nil {
+		return err
+	}
+	for _, h := range hashes {
+		h.Hash.Write(data)
+	}
+	_, err = VerifyHashes(hashes)
+	return err
+}
+
+// ExpectedHash is one acceptable hash of some data: the hasher to calculate
+// it with and the sum it must produce.
+type ExpectedHash struct {
+	Hash hash.Hash
+	Sum  []byte
+}
+
+// ExpectedHashes returns the acceptable hashes listed in verify, starting
+// with Hash and followed by Hashes.
+func ExpectedHashes(verify types.Verification) ([]ExpectedHash, error) {
+	var specs []string
+	if verify.Hash != nil {
+		specs = append(specs, *verify.Hash)
+	}
+	specs = append(specs, verify.Hashes...)
+
+	var ret []ExpectedHash
+	for _, spec := range specs {
+		spec := spec
+		v := types.Verification{Hash: &spec}
+		hasher, err := GetHasher(v)
 		if err != nil {
-			return err
+			return nil, err
 		}
-
-		var sum []byte
-		switch hashFunc {
-		case "sha512":
-			rawSum := sha512.Sum512(data)
-			sum = rawSum[:]
-		case "sha256":
-			rawSum := sha256.Sum256(data)
-			sum = rawSum[:]
-		default:
-			return ErrHashUnrecognized
+		_, sumString, _ := HashParts(v)
+		sum, err := hex.DecodeString(sumString)
+		if err != nil {
+			return nil, fmt.Errorf("parsing verification string %q: %v", sumString, err)
 		}
+		ret = append(ret, ExpectedHash{Hash: hasher, Sum: sum})
+	}
+	return ret, nil
+}
 
-		encodedSum := make([]byte, hex.EncodedLen(len(sum)))
-		hex.Encode(encodedSum, sum)
-		if string(encodedSum) != hashSum {
-			return ErrHashMismatch{
-				Calculated: string(encodedSum),
-				Expected:   hashSum,
-			}
+// VerifyHashes returns the expected sum of the first of hashes which produced
+// it, or nil if there are no hashes. If none match it returns
+// ErrHashMismatch.
+func VerifyHashes(hashes []ExpectedHash) ([]byte, error) {
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+	var expected []string
+	for _, h := range hashes {
+		if bytes.Equal(h.Hash.Sum(nil), h.Sum) {
+			return h.Sum, nil
 		}
+		expected = append(expected, hex.EncodeToString(h.Sum))
+	}
+	return nil, ErrHashMismatch{
+		Calculated: hex.EncodeToString(hashes[0].Hash.Sum(nil)),
+		Expected:   strings.Join(expected, " or "),
 	}
-
-	return nil
 }
 
 func GetHasher(verify types.Verification) (hash.Hash, error) {
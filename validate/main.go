@@ -19,18 +19,27 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/coreos/ignition/v2/config"
+	"github.com/coreos/ignition/v2/config/util"
+	latest "github.com/coreos/ignition/v2/config/v3_4_experimental"
 	"github.com/coreos/ignition/v2/internal/version"
 )
 
 var (
 	flagVersion bool
+	flagLint    bool
+	flagYAML    bool
+	flagRelaxed bool
 )
 
 func init() {
 	flag.BoolVar(&flagVersion, "version", false, "print the version of ignition-validate")
+	flag.BoolVar(&flagLint, "lint", false, "also warn about valid configs that are likely mistakes")
+	flag.BoolVar(&flagYAML, "yaml", false, "read the config as YAML (implied for .yaml and .yml files)")
+	flag.BoolVar(&flagRelaxed, "relaxed", false, "allow comments and trailing commas in JSON configs")
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n  %s [flags] config.ign\n\n", os.Args[0])
 		flag.PrintDefaults()
@@ -76,7 +85,18 @@ func runIgnValidate(args []string) {
 	if err != nil {
 		die("couldn't read config: %v", err)
 	}
-	_, rpt, err := config.Parse(blob)
+	if ext := filepath.Ext(args[0]); flagYAML || ext == ".yaml" || ext == ".yml" {
+		blob, err = util.YAMLToJSON(blob)
+		if err != nil {
+			die("couldn't convert YAML config: %v", err)
+		}
+	} else if flagRelaxed {
+		blob, err = util.RelaxedJSONToJSON(blob)
+		if err != nil {
+			die("couldn't convert relaxed config: %v", err)
+		}
+	}
+	cfg, rpt, err := config.Parse(blob)
 	if len(rpt.Entries) > 0 {
 		stdout(rpt.String())
 	}
@@ -86,4 +106,16 @@ func runIgnValidate(args []string) {
 	if err != nil {
 		die("couldn't parse config: %v", err)
 	}
+	if flagLint {
+		// skip findings which validation already reported
+		reported := map[string]struct{}{}
+		for _, e := range rpt.Entries {
+			reported[e.Context.String()+e.Message] = struct{}{}
+		}
+		for _, w := range latest.Lint(cfg) {
+			if _, ok := reported[w.Context.String()+w.Message]; !ok {
+				stdout(w.String())
+			}
+		}
+	}
 }